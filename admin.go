@@ -0,0 +1,304 @@
+package objst
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/exp/slog"
+)
+
+// StoreStats describes the size and the state
+// of the compactions of a store of the bucket.
+type StoreStats struct {
+	Name string `json:"name"`
+	// LSMSize and VLogSize are the sizes in bytes of the
+	// LSM tree and the value log as last computed by badger.
+	LSMSize  int64 `json:"lsmSize"`
+	VLogSize int64 `json:"vlogSize"`
+	// Level0Tables is the number of tables in level 0.
+	// Writes are stalled if it reaches the stall threshold.
+	Level0Tables int `json:"level0Tables"`
+}
+
+// Stats is a snapshot of the state of the bucket.
+type Stats struct {
+	Frozen bool `json:"frozen"`
+	// Role and Epoch are the role of the bucket
+	// and its fencing token. See Promote.
+	Role  Role   `json:"role"`
+	Epoch uint64 `json:"epoch"`
+	// Stalled is the store stalling the writes
+	// of the bucket or empty if none is stalling.
+	Stalled string `json:"stalled,omitempty"`
+	// InFlight is the number of running operations.
+	InFlight  int            `json:"inFlight"`
+	Stores    []StoreStats   `json:"stores"`
+	Latencies []LatencyStats `json:"latencies"`
+	Cache     CacheStats     `json:"cache"`
+	// Replication is the status of the running
+	// replicators. See ReplicateTo.
+	Replication []ReplicationStatus `json:"replication"`
+}
+
+// Stats returns a snapshot of the state of the bucket.
+func (b *Bucket) Stats() Stats {
+	stats := Stats{
+		Frozen:      b.IsFrozen(),
+		Stores:      make([]StoreStats, 0, 3),
+		Latencies:   b.Latencies(),
+		Cache:       b.cache.stats(),
+		Replication: b.Replicators(),
+	}
+	if stall := b.stall.Load(); stall != nil {
+		stats.Stalled = stall.Store
+	}
+	stats.Role, stats.Epoch = b.Role()
+	b.opsMu.Lock()
+	stats.InFlight = b.ops
+	b.opsMu.Unlock()
+	for _, s := range b.databases() {
+		lsm, vlog := s.size()
+		stats.Stores = append(stats.Stores, StoreStats{
+			Name:         s.handle.name,
+			LSMSize:      lsm,
+			VLogSize:     vlog,
+			Level0Tables: s.level0Tables(),
+		})
+	}
+	return stats
+}
+
+// JobStatus is the progress of a pipeline and
+// whether it is running in the background.
+type JobStatus struct {
+	PipelineProgress
+	Running bool `json:"running"`
+}
+
+// Jobs returns the status of all pipelines which
+// ran at least once in the order of their names.
+func (b *Bucket) Jobs() ([]JobStatus, error) {
+	jobs := make([]JobStatus, 0)
+	b.pipelineMu.Lock()
+	defer b.pipelineMu.Unlock()
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(pipelinePrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var job JobStatus
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &job.PipelineProgress)
+			})
+			if err != nil {
+				return err
+			}
+			_, job.Running = b.pipelines[job.Name]
+			jobs = append(jobs, job)
+		}
+		return nil
+	})
+	return jobs, err
+}
+
+// adminRoutes registers the endpoints for
+// operators under /objst/admin.
+func (h *HTTPHandler) adminRoutes(r chi.Router) {
+	r.Use(withAPIKey(h.opts.IsAdmin))
+	r.Use(h.requireScope(ScopeAdmin))
+	r.Get("/stats", h.AdminStats)
+	r.Get("/usage", h.Usages)
+	r.Get("/changes", h.AdminChanges)
+	r.Get("/auditlog", h.AuditLog)
+	r.Get("/slowops", h.SlowOps)
+	r.Get("/jobs", h.Jobs)
+	r.Post("/promote", h.Promote)
+	r.Post("/demote", h.Demote)
+	r.Post("/replicate", h.Replicate)
+	r.Get("/replication", h.Replicators)
+	r.Post("/replication/{name}/resync", h.Resync)
+	r.Get("/lifecycle", h.LifecycleRules)
+	r.With(h.rejectWhenFrozen).Post("/lifecycle", h.PutLifecycleRule)
+	r.With(h.rejectWhenFrozen).Put("/lifecycle/{ruleID}", h.PutLifecycleRule)
+	r.With(h.rejectWhenFrozen).Delete("/lifecycle/{ruleID}", h.DeleteLifecycleRule)
+	r.Get("/retention", h.RetentionClasses)
+	r.With(h.rejectWhenFrozen).Put("/retention/{class}", h.PutRetentionClass)
+	r.With(h.rejectWhenFrozen).Delete("/retention/{class}", h.DeleteRetentionClass)
+}
+
+// LifecycleRules returns the persisted lifecycle rules.
+func (h *HTTPHandler) LifecycleRules(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	rules, err := h.bucket.LifecycleRules()
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "something went wrong while reading the lifecycle rules", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, r, rules)
+}
+
+// PutLifecycleRule creates a lifecycle rule or replaces
+// the rule with the id of the route if it is set.
+func (h *HTTPHandler) PutLifecycleRule(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	var rule Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "request body is not a valid lifecycle rule", http.StatusBadRequest)
+		return
+	}
+	rule.ID = chi.URLParam(r, "ruleID")
+	rule, err := h.bucket.PutLifecycleRule(rule)
+	if errors.Is(err, ErrUnknownLifecycleAction) || errors.Is(err, ErrNegativeDuration) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	h.writeJSON(w, r, rule)
+}
+
+// DeleteLifecycleRule deletes the lifecycle rule with the id of the route.
+func (h *HTTPHandler) DeleteLifecycleRule(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	if err := h.bucket.DeleteLifecycleRule(chi.URLParam(r, "ruleID")); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RetentionClasses returns the retention classes of the bucket.
+func (h *HTTPHandler) RetentionClasses(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	classes, err := h.bucket.RetentionClasses()
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "something went wrong while reading the retention classes", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, r, classes)
+}
+
+// PutRetentionClass creates or replaces the retention
+// class with the name of the route.
+func (h *HTTPHandler) PutRetentionClass(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	var c RetentionClass
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "request body is not a valid retention class", http.StatusBadRequest)
+		return
+	}
+	c.Name = chi.URLParam(r, "class")
+	err := h.bucket.PutRetentionClass(c)
+	if errors.Is(err, ErrInvalidRetentionClass) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	h.writeJSON(w, r, c)
+}
+
+// DeleteRetentionClass deletes the retention class with the name of the route.
+func (h *HTTPHandler) DeleteRetentionClass(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	if err := h.bucket.DeleteRetentionClass(chi.URLParam(r, "class")); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminStats returns the stats of the bucket.
+func (h *HTTPHandler) AdminStats(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, r, h.bucket.Stats())
+}
+
+// Jobs returns the status of the pipelines of the bucket.
+func (h *HTTPHandler) Jobs(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	jobs, err := h.bucket.Jobs()
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "something went wrong while reading the jobs", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, r, jobs)
+}
+
+// AdminChanges returns the change feed of the bucket since the
+// sequence number of the `since` query parameter. The changes can
+// be filtered by the `owner`, `op` and `id` query parameters. The
+// `limit` query parameter limits the matching changes. See
+// Bucket.ChangesWithOptions.
+func (h *HTTPHandler) AdminChanges(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	since, limit, ok := h.parseFeedQuery(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	changes, seq, err := h.bucket.ChangesWithOptions(ChangesOptions{
+		Owner: query.Get("owner"),
+		Op:    ChangeOp(query.Get("op")),
+		ID:    query.Get("id"),
+		Since: since,
+		Limit: limit,
+	})
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "something went wrong while reading the changes", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, r, changesModel{Changes: changes, Seq: seq})
+}
+
+// parseFeedQuery parses the `since` and `limit` query parameters
+// of the change feed. ok is false if a response was written.
+func (h *HTTPHandler) parseFeedQuery(w http.ResponseWriter, r *http.Request) (since uint64, limit int, ok bool) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	if v := r.URL.Query().Get("since"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, "since is not a valid sequence number", http.StatusBadRequest)
+			return 0, 0, false
+		}
+		since = n
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			msg := "limit is not a valid number"
+			h.opts.Logger.ErrorCtx(r.Context(), msg, slog.String("req_id", reqID))
+			http.Error(w, msg, http.StatusBadRequest)
+			return 0, 0, false
+		}
+		limit = n
+	}
+	return since, limit, true
+}
+
+// writeJSON writes v as the JSON body of the response.
+func (h *HTTPHandler) writeJSON(w http.ResponseWriter, r *http.Request, v any) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	w.Header().Set(headerContentType, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+	}
+}
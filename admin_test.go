@@ -0,0 +1,170 @@
+package objst
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsAndJobs(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	if err := b.Create(tEnv.obj()); err != nil {
+		t.Fatal(err)
+	}
+	stats := b.Stats()
+	if len(stats.Stores) != 3 || stats.Frozen || stats.Stalled != "" || stats.InFlight != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if err := b.RunPipeline(context.Background(), Pipeline{Name: "recompress", Compression: CompressionNone}); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := b.Jobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 1 || jobs[0].Name != "recompress" || !jobs[0].Done || jobs[0].Running {
+		t.Fatalf("unexpected jobs: %+v", jobs)
+	}
+}
+
+func TestHTTPAdmin(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	obj := tEnv.obj()
+	if err := b.Create(obj); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.DeleteByID(obj.ID()); err != nil {
+		t.Fatal(err)
+	}
+	hopts := DefaultHTTPHandlerOptions()
+	serve := func(h *HTTPHandler, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+	if w := serve(NewHTTPHandler(b, hopts), "/objst/admin/stats"); w.Code != http.StatusForbidden {
+		t.Fatalf("admin endpoints should be rejected by default. Got: %d", w.Code)
+	}
+	hopts.IsAdmin = isAuthorized
+	h := NewHTTPHandler(b, hopts)
+	for _, target := range []string{"/objst/admin/stats", "/objst/admin/usage", "/objst/admin/slowops", "/objst/admin/jobs"} {
+		if w := serve(h, target); w.Code != http.StatusOK {
+			t.Fatalf("statuscode of %s is not %d. Got: %d. Body: %s", target, http.StatusOK, w.Code, w.Body.String())
+		}
+	}
+	w := serve(h, "/objst/admin/changes?op="+string(ChangeDelete))
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	model := changesModel{}
+	if err := json.NewDecoder(w.Body).Decode(&model); err != nil {
+		t.Fatal(err)
+	}
+	if len(model.Changes) != 1 || model.Changes[0].ID != obj.ID() {
+		t.Fatalf("audit should return the deletion of %s. Got: %+v", obj.ID(), model.Changes)
+	}
+	// the limit counts the matching changes only
+	w = serve(h, "/objst/admin/changes?limit=1&op="+string(ChangeDelete))
+	model = changesModel{}
+	if err := json.NewDecoder(w.Body).Decode(&model); err != nil {
+		t.Fatal(err)
+	}
+	if len(model.Changes) != 1 || model.Changes[0].ID != obj.ID() {
+		t.Fatalf("first page should contain the deletion of %s. Got: %+v", obj.ID(), model.Changes)
+	}
+	if w := serve(h, "/objst/admin/changes?since=x"); w.Code != http.StatusBadRequest {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestHTTPAdminConfig(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	hopts := DefaultHTTPHandlerOptions()
+	hopts.IsAdmin = isAuthorized
+	h := NewHTTPHandler(b, hopts)
+	serve := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	w := serve(http.MethodPost, "/objst/admin/lifecycle", `{"prefix": "tmp/", "olderThan": "72h", "action": "archive"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var rule Rule
+	if err := json.NewDecoder(w.Body).Decode(&rule); err != nil {
+		t.Fatal(err)
+	}
+	if rule.ID == "" || rule.OlderThan != 72*time.Hour || rule.Action != LifecycleArchive {
+		t.Fatalf("unexpected rule: %+v", rule)
+	}
+	if w := serve(http.MethodPost, "/objst/admin/lifecycle", `{"action": "shred"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusBadRequest, w.Code)
+	}
+	w = serve(http.MethodPut, "/objst/admin/lifecycle/"+rule.ID, `{"prefix": "logs/", "action": "delete"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	w = serve(http.MethodGet, "/objst/admin/lifecycle", "")
+	rules := make([]Rule, 0)
+	if err := json.NewDecoder(w.Body).Decode(&rules); err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Prefix != "logs/" || rules[0].Action != LifecycleDelete {
+		t.Fatalf("rule should be replaced. Got: %+v", rules)
+	}
+	if w := serve(http.MethodDelete, "/objst/admin/lifecycle/"+rule.ID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusNoContent, w.Code)
+	}
+	if w := serve(http.MethodDelete, "/objst/admin/lifecycle/"+rule.ID, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusNotFound, w.Code)
+	}
+
+	if w := serve(http.MethodPut, "/objst/admin/retention/legal", `{"duration": "0s"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusBadRequest, w.Code)
+	}
+	if w := serve(http.MethodPut, "/objst/admin/retention/legal", `{"duration": "8760h"}`); w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	w = serve(http.MethodGet, "/objst/admin/retention", "")
+	classes := make([]RetentionClass, 0)
+	if err := json.NewDecoder(w.Body).Decode(&classes); err != nil {
+		t.Fatal(err)
+	}
+	if len(classes) != 1 || classes[0].Name != "legal" || classes[0].Duration != 8760*time.Hour {
+		t.Fatalf("unexpected retention classes: %+v", classes)
+	}
+	if w := serve(http.MethodDelete, "/objst/admin/retention/legal", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusNoContent, w.Code)
+	}
+	if w := serve(http.MethodDelete, "/objst/admin/retention/legal", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusNotFound, w.Code)
+	}
+}
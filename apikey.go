@@ -0,0 +1,322 @@
+package objst
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// apiKeyPrefix is the prefix of the API keys in the name store.
+	apiKeyPrefix = "#apikey/"
+	// apiKeyTokenPrefix is the prefix of the tokens of the API
+	// keys which allows secret scanners to detect leaked tokens.
+	apiKeyTokenPrefix = "objst_"
+	// apiKeySecretSize is the number of random bytes of a secret.
+	apiKeySecretSize = 32
+)
+
+// Scope is a permission granted to an API key.
+type Scope string
+
+const (
+	// ScopeRead allows to read objects and their meta data,
+	// to look up checksums and to read the change feed.
+	ScopeRead Scope = "read"
+	// ScopeUpload allows to upload objects.
+	ScopeUpload Scope = "upload"
+	// ScopeWrite allows to update the meta data of
+	// objects and to delete objects.
+	ScopeWrite Scope = "write"
+	// ScopeAdmin allows to use the admin and debug
+	// endpoints and implies all other scopes.
+	ScopeAdmin Scope = "admin"
+)
+
+func (s Scope) isValid() bool {
+	return s == ScopeRead || s == ScopeUpload || s == ScopeWrite || s == ScopeAdmin
+}
+
+// APIKey is a credential of a service which acts on behalf of
+// an owner using the HTTP handler. Only the hash of the secret
+// of the key is stored in the bucket. See Bucket.CreateAPIKey.
+type APIKey struct {
+	ID string `json:"id"`
+	// Name describes the key e.g. the service using it.
+	Name string `json:"name,omitempty"`
+	// Owner is the owner the requests are sent on behalf of.
+	// Objects of other owners can't be accessed using the key.
+	Owner  string  `json:"owner"`
+	Scopes []Scope `json:"scopes"`
+	// Prefixes limits the key to the objects whose name is
+	// starting with any of the prefixes. Empty allows all.
+	Prefixes  []string   `json:"prefixes,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// apiKeyEntry is the persisted API key
+// including the hash of its secret.
+type apiKeyEntry struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+func apiKeyKey(id string) []byte {
+	return []byte(apiKeyPrefix + id)
+}
+
+// HasScope reports whether the scope is granted to the key.
+func (k APIKey) HasScope(s Scope) bool {
+	return slices.Contains(k.Scopes, s) || slices.Contains(k.Scopes, ScopeAdmin)
+}
+
+// allows returns an error wrapping ErrAPIKeyForbidden if the key
+// is not allowed to access the object with the name and owner
+// using the scope.
+func (k APIKey) allows(s Scope, name, owner string) error {
+	if !k.HasScope(s) {
+		return fmt.Errorf("%w: missing scope %s", ErrAPIKeyForbidden, s)
+	}
+	if owner != k.Owner {
+		return fmt.Errorf("%w: object is owned by another owner", ErrAPIKeyForbidden)
+	}
+	if len(k.Prefixes) == 0 {
+		return nil
+	}
+	for _, p := range k.Prefixes {
+		if strings.HasPrefix(name, p) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: name %s doesn't match any prefix", ErrAPIKeyForbidden, name)
+}
+
+// CreateAPIKey stores the API key and returns the token which has
+// to be sent as `Authorization: Bearer <token>`. The token can't
+// be retrieved later. The id and the creation time of the key are
+// set by the bucket.
+func (b *Bucket) CreateAPIKey(k APIKey) (string, APIKey, error) {
+	done, err := b.beginWrite()
+	if err != nil {
+		return "", k, err
+	}
+	defer done()
+	if err := b.opts.OwnerValidator(k.Owner); err != nil {
+		return "", k, err
+	}
+	if len(k.Scopes) == 0 {
+		return "", k, fmt.Errorf("%w: missing scopes", ErrInvalidAPIKey)
+	}
+	for _, s := range k.Scopes {
+		if !s.isValid() {
+			return "", k, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKey, s)
+		}
+	}
+	secret := make([]byte, apiKeySecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return "", k, err
+	}
+	k.ID = uuid.NewString()
+	k.CreatedAt = time.Now().UTC()
+	k.RevokedAt = nil
+	token := apiKeyTokenPrefix + k.ID + "." + hex.EncodeToString(secret)
+	if err := b.writeAPIKey(apiKeyEntry{APIKey: k, Hash: apiKeyHash(token)}); err != nil {
+		return "", k, err
+	}
+	return token, k, nil
+}
+
+// RevokeAPIKey revokes the API key. Revoked keys
+// are kept and listed by APIKeys for auditing.
+func (b *Bucket) RevokeAPIKey(id string) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	e, err := b.apiKey(id)
+	if err != nil {
+		return err
+	}
+	if e.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	e.RevokedAt = &now
+	return b.writeAPIKey(e)
+}
+
+// APIKeys returns the API keys of the owner ordered by their
+// id. An empty owner returns the API keys of all owners.
+func (b *Bucket) APIKeys(owner string) ([]APIKey, error) {
+	keys := make([]APIKey, 0)
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(apiKeyPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var e apiKeyEntry
+			err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &e)
+			})
+			if err != nil {
+				return err
+			}
+			if owner == "" || e.Owner == owner {
+				keys = append(keys, e.APIKey)
+			}
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// VerifyAPIKey returns the API key of the token. ErrInvalidAPIKey
+// is returned if the token doesn't belong to any key and
+// ErrAPIKeyRevoked if the key is revoked.
+func (b *Bucket) VerifyAPIKey(token string) (APIKey, error) {
+	id, _, ok := strings.Cut(strings.TrimPrefix(token, apiKeyTokenPrefix), ".")
+	if !ok || !strings.HasPrefix(token, apiKeyTokenPrefix) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	e, err := b.apiKey(id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(e.Hash), []byte(apiKeyHash(token))) != 1 {
+		return APIKey{}, ErrInvalidAPIKey
+	}
+	if e.RevokedAt != nil {
+		return e.APIKey, ErrAPIKeyRevoked
+	}
+	return e.APIKey, nil
+}
+
+func (b *Bucket) apiKey(id string) (apiKeyEntry, error) {
+	var e apiKeyEntry
+	err := b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get(apiKeyKey(id))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &e)
+		})
+	})
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return e, fmt.Errorf("%w: %s", ErrAPIKeyNotFound, id)
+	}
+	return e, err
+}
+
+func (b *Bucket) writeAPIKey(e apiKeyEntry) error {
+	data, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Set(apiKeyKey(e.ID), data)
+	})
+}
+
+func apiKeyHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// bearerToken returns the token of the
+// Authorization header of the request.
+func bearerToken(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get(headerAuthorization), "Bearer ")
+	return token, ok && token != ""
+}
+
+// authenticate verifies the API key of the request if API keys are
+// enabled and the request has a bearer token. The key and its owner
+// are injected into the request context. All other requests are
+// authenticated by IsAuthenticated.
+func (h *HTTPHandler) authenticate(next http.Handler) http.Handler {
+	fallback := h.opts.IsAuthenticated(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !h.opts.APIKeys || !ok {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		reqID := r.Context().Value(CtxKeyReqID).(string)
+		k, err := h.bucket.VerifyAPIKey(token)
+		if err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		ctx := context.WithValue(r.Context(), CtxKeyOwner, k.Owner)
+		ctx = context.WithValue(ctx, CtxKeyAPIKey, k)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// withAPIKey returns a middleware which serves the requests
+// authenticated by an API key using next and all other
+// requests using the middleware mw e.g. IsAuthorized.
+func withAPIKey(mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fallback := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := r.Context().Value(CtxKeyAPIKey).(APIKey); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			fallback.ServeHTTP(w, r)
+		})
+	}
+}
+
+// requireScope rejects requests authenticated by an API key
+// without the scope. If the route has an id the key must also
+// be allowed to access the object. Objects which don't exist
+// are left to the handler.
+func (h *HTTPHandler) requireScope(s Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k, ok := r.Context().Value(CtxKeyAPIKey).(APIKey)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			reqID := r.Context().Value(CtxKeyReqID).(string)
+			err := fmt.Errorf("%w: missing scope %s", ErrAPIKeyForbidden, s)
+			if k.HasScope(s) {
+				err = nil
+			}
+			if id := chi.URLParam(r, "id"); err == nil && id != "" {
+				if meta, metaErr := h.bucket.GetMeta(id); metaErr == nil {
+					err = k.allows(s, meta.Get(MetaKeyName), meta.Get(MetaKeyOwner))
+				}
+			}
+			if err != nil {
+				h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
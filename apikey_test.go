@@ -0,0 +1,128 @@
+package objst
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestAPIKey(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	owner := tEnv.owner()
+	if _, _, err := b.CreateAPIKey(APIKey{Owner: owner}); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("key without scopes should be invalid. Got: %v", err)
+	}
+	if _, _, err := b.CreateAPIKey(APIKey{Owner: owner, Scopes: []Scope{"delete"}}); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("key with an unknown scope should be invalid. Got: %v", err)
+	}
+	token, k, err := b.CreateAPIKey(APIKey{Name: "thumbnailer", Owner: owner, Scopes: []Scope{ScopeRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.VerifyAPIKey(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != k.ID || got.Owner != owner || !got.HasScope(ScopeRead) || got.HasScope(ScopeWrite) {
+		t.Fatalf("unexpected api key: %+v", got)
+	}
+	if _, err := b.VerifyAPIKey(token + "0"); !errors.Is(err, ErrInvalidAPIKey) {
+		t.Fatalf("token with a wrong secret should be invalid. Got: %v", err)
+	}
+	if err := b.RevokeAPIKey(k.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.VerifyAPIKey(token); !errors.Is(err, ErrAPIKeyRevoked) {
+		t.Fatalf("revoked key should be rejected. Got: %v", err)
+	}
+	if err := b.RevokeAPIKey(tEnv.owner()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("revoking a missing key should fail. Got: %v", err)
+	}
+	keys, err := b.APIKeys(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Fatalf("revoked key should be listed. Got: %+v", keys)
+	}
+}
+
+func TestHTTPAPIKey(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	obj, other := tEnv.obj(), tEnv.obj()
+	if err := b.BatchCreate([]*Object{obj, other}); err != nil {
+		t.Fatal(err)
+	}
+	hopts := DefaultHTTPHandlerOptions()
+	hopts.APIKeys = true
+	h := NewHTTPHandler(b, hopts)
+	newKey := func(k APIKey) string {
+		token, _, err := b.CreateAPIKey(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	serve := func(r *http.Request, token string) int {
+		r.Header.Set(headerAuthorization, "Bearer "+token)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	reader := newKey(APIKey{Owner: obj.Owner(), Scopes: []Scope{ScopeRead}})
+	tests := []struct {
+		name  string
+		r     *http.Request
+		token string
+		code  int
+	}{
+		{name: "read", r: httptest.NewRequest(http.MethodGet, "/objst/read/"+obj.ID(), nil), token: reader, code: http.StatusOK},
+		{name: "read other owner", r: httptest.NewRequest(http.MethodGet, "/objst/read/"+other.ID(), nil), token: reader, code: http.StatusForbidden},
+		{name: "delete without scope", r: httptest.NewRequest(http.MethodDelete, "/objst/"+obj.ID(), nil), token: reader, code: http.StatusForbidden},
+		{name: "admin without scope", r: httptest.NewRequest(http.MethodGet, "/objst/admin/stats", nil), token: reader, code: http.StatusForbidden},
+		{name: "invalid token", r: httptest.NewRequest(http.MethodGet, "/objst/read/"+obj.ID(), nil), token: "objst_invalid", code: http.StatusUnauthorized},
+		{
+			name:  "prefix",
+			r:     httptest.NewRequest(http.MethodGet, "/objst/read/"+obj.ID(), nil),
+			token: newKey(APIKey{Owner: obj.Owner(), Scopes: []Scope{ScopeRead}, Prefixes: []string{"avatars/"}}),
+			code:  http.StatusForbidden,
+		},
+		{
+			name:  "admin",
+			r:     httptest.NewRequest(http.MethodGet, "/objst/admin/stats", nil),
+			token: newKey(APIKey{Owner: obj.Owner(), Scopes: []Scope{ScopeAdmin}}),
+			code:  http.StatusOK,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if code := serve(tc.r, tc.token); code != tc.code {
+				t.Fatalf("statuscode is not %d. Got: %d", tc.code, code)
+			}
+		})
+	}
+	uploader := newKey(APIKey{Owner: tEnv.owner(), Scopes: []Scope{ScopeUpload}, Prefixes: []string{"avatars/"}})
+	r, err := tEnv.newUploadRequest("/objst/upload", nil, hopts.FormKey, "testdata/images/2500KB.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := serve(r, uploader); code != http.StatusForbidden {
+		t.Fatalf("upload outside of the prefixes should be forbidden. Got: %d", code)
+	}
+}
@@ -0,0 +1,190 @@
+package objst
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// ArchiveVersion is the version of the archive format written
+// by Export. It is increased on every incompatible change of the
+// format so archives can be read and verified decades later.
+const ArchiveVersion = 1
+
+// ManifestName is the name of the manifest which is the last file
+// of every archive created by Export. It is not a valid object name
+// so it can't collide with the files of the objects.
+const ManifestName = "objst-manifest"
+
+// Manifest lists the files of an archive in the order
+// of the archive. See ManifestName and VerifyArchive.
+type Manifest struct {
+	// Version is the ArchiveVersion
+	// the archive was written with.
+	Version int             `json:"version"`
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry describes one file of an archive.
+type ManifestEntry struct {
+	// Path is the name of the file in the archive.
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// SHA256 is the hex encoded SHA-256 of the file.
+	SHA256 string `json:"sha256"`
+	// Object is the object of the file.
+	Object *ObjectInfo `json:"object"`
+}
+
+func newManifest() *Manifest {
+	return &Manifest{Version: ArchiveVersion, Entries: make([]ManifestEntry, 0)}
+}
+
+// modTime returns the time of the last modification of
+// all objects which is used as the time of the manifest
+// so the archive only depends on the objects.
+func (m *Manifest) modTime() time.Time {
+	var t time.Time
+	for _, e := range m.Entries {
+		if mt := modTime(e.Object); mt.After(t) {
+			t = mt
+		}
+	}
+	return t
+}
+
+// VerifyArchive verifies that the tar or zip archive read from r
+// matches its manifest and returns the manifest. It doesn't need
+// a bucket so archives can be verified anywhere. An error wrapping
+// ErrArchiveCorrupted is returned if a file is missing, unexpected
+// or its size or checksum differ from the manifest. Zip archives
+// are buffered in a temporary file.
+func VerifyArchive(r io.Reader) (*Manifest, error) {
+	br := bufio.NewReader(r)
+	var files []ManifestEntry
+	var manifest []byte
+	var err error
+	if magic, peekErr := br.Peek(len(zipMagic)); peekErr == nil && string(magic) == zipMagic {
+		files, manifest, err = zipEntries(br)
+	} else {
+		files, manifest, err = tarEntries(br)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: missing manifest", ErrArchiveCorrupted)
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(manifest, m); err != nil {
+		return nil, fmt.Errorf("%w: invalid manifest: %w", ErrArchiveCorrupted, err)
+	}
+	if m.Version < 1 || m.Version > ArchiveVersion {
+		return nil, fmt.Errorf("%w: %d", ErrArchiveVersion, m.Version)
+	}
+	if len(files) != len(m.Entries) {
+		return nil, fmt.Errorf("%w: %d files but %d entries in the manifest", ErrArchiveCorrupted, len(files), len(m.Entries))
+	}
+	for i, f := range files {
+		e := m.Entries[i]
+		if f.Path != e.Path || f.Size != e.Size || f.SHA256 != e.SHA256 {
+			return nil, fmt.Errorf("%w: %s doesn't match the manifest", ErrArchiveCorrupted, f.Path)
+		}
+	}
+	return m, nil
+}
+
+// tarEntries returns the path, size and checksum of
+// the files of the tar archive and its manifest.
+func tarEntries(r io.Reader) ([]ManifestEntry, []byte, error) {
+	files := make([]ManifestEntry, 0)
+	var manifest []byte
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, manifest, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrArchiveCorrupted, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if manifest != nil {
+			return nil, nil, fmt.Errorf("%w: %s follows the manifest", ErrArchiveCorrupted, hdr.Name)
+		}
+		if hdr.Name == ManifestName {
+			if manifest, err = io.ReadAll(tr); err != nil {
+				return nil, nil, fmt.Errorf("%w: %w", ErrArchiveCorrupted, err)
+			}
+			continue
+		}
+		e, err := fileEntry(hdr.Name, tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, e)
+	}
+}
+
+// zipEntries is like tarEntries for zip archives.
+func zipEntries(r io.Reader) ([]ManifestEntry, []byte, error) {
+	f, err := os.CreateTemp("", "objst-verify-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrArchiveCorrupted, err)
+	}
+	files := make([]ManifestEntry, 0, len(zr.File))
+	var manifest []byte
+	for i, zf := range zr.File {
+		if zf.Name == ManifestName && i == len(zr.File)-1 {
+			if manifest, err = readZipFile(zf); err != nil {
+				return nil, nil, fmt.Errorf("%w: %w", ErrArchiveCorrupted, err)
+			}
+			continue
+		}
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %w", ErrArchiveCorrupted, err)
+		}
+		e, err := fileEntry(zf.Name, rc)
+		rc.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		files = append(files, e)
+	}
+	return files, manifest, nil
+}
+
+// fileEntry returns the entry of the file with
+// the path whose content is read from r.
+func fileEntry(path string, r io.Reader) (ManifestEntry, error) {
+	h := sha256.New()
+	n, err := io.Copy(h, r)
+	if err != nil {
+		return ManifestEntry{}, fmt.Errorf("%w: %s: %w", ErrArchiveCorrupted, path, err)
+	}
+	return ManifestEntry{Path: path, Size: n, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
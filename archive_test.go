@@ -0,0 +1,68 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestVerifyArchive(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	owner := tEnv.owner()
+	for i := 0; i < 3; i++ {
+		o, err := NewObject(tEnv.name(), owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Write(tEnv.payload(64))
+		if err := b.Create(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+	for _, format := range []ArchiveFormat{ArchiveTar, ArchiveZip} {
+		var first, second bytes.Buffer
+		if err := b.Export(ctx, &first, NewQuery().Owner(owner), format); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Export(ctx, &second, NewQuery().Owner(owner), format); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(first.Bytes(), second.Bytes()) {
+			t.Fatalf("exporting the same objects should result in the same archive. Format: %d", format)
+		}
+		m, err := VerifyArchive(bytes.NewReader(first.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Version != ArchiveVersion || len(m.Entries) != 3 {
+			t.Fatalf("manifest should list all objects. Got: %+v", m)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := b.Export(ctx, &buf, NewQuery().Owner(owner), ArchiveTar); err != nil {
+		t.Fatal(err)
+	}
+	m, _ := VerifyArchive(bytes.NewReader(buf.Bytes()))
+	archive := buf.Bytes()
+	// the payloads of the test objects are unique
+	payload, err := b.GetPayload(m.Entries[0].Object.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i := bytes.Index(archive, payload)
+	archive[i] ^= 0xff
+	if _, err := VerifyArchive(bytes.NewReader(archive)); !errors.Is(err, ErrArchiveCorrupted) {
+		t.Fatalf("modified file should be detected. Got: %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package objst
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// auditPrefix is the prefix of the entries of the audit
+	// log in the name store. Unlike the change feed the
+	// entries never expire.
+	auditPrefix = "#audit/"
+	// auditSeqKey is the key of the highest
+	// sequence number of the audit log.
+	auditSeqKey = "#auditseq"
+	// contentTypeJSONLines is the content type
+	// of the export of the audit log.
+	contentTypeJSONLines = "application/x-ndjson"
+)
+
+// AuditOp is the kind of a mutation recorded in the audit log.
+type AuditOp string
+
+// The operations on objects correspond to the
+// events of Watch and the ones on KVs to its methods.
+const (
+	AuditCreate   AuditOp = "create"
+	AuditUpdate   AuditOp = "update"
+	AuditDelete   AuditOp = "delete"
+	AuditKVSet    AuditOp = "kv.set"
+	AuditKVDelete AuditOp = "kv.delete"
+)
+
+// AuditEntry is an entry of the audit log.
+type AuditEntry struct {
+	Seq uint64    `json:"seq"`
+	At  time.Time `json:"at"`
+	Op  AuditOp   `json:"op"`
+	// Owner is the owner of the object or of the KV.
+	Owner string `json:"owner"`
+	// ID is the id of the object or the key of the KV.
+	ID string `json:"id"`
+	// Actor made the mutation. It is the id of the API key
+	// prefixed by "apikey:" or the authenticated owner of
+	// requests of the HTTP handler and empty for the Go
+	// API and the internal jobs e.g. lifecycle rules.
+	Actor string `json:"actor,omitempty"`
+}
+
+// AuditQuery filters the entries of the audit log.
+// Empty fields are not filtering.
+type AuditQuery struct {
+	Owner string
+	ID    string
+	Op    AuditOp
+	Actor string
+	// Since and Until limit the entries to
+	// the ones recorded in [Since, Until).
+	Since time.Time
+	Until time.Time
+	// After skips the entries up to the sequence number
+	// e.g. the last entry of the previous page.
+	After uint64
+	// Limit is the maximum number of entries if positive.
+	Limit int
+}
+
+func (q AuditQuery) matches(e AuditEntry) bool {
+	return (q.Owner == "" || e.Owner == q.Owner) &&
+		(q.ID == "" || e.ID == q.ID) &&
+		(q.Op == "" || e.Op == q.Op) &&
+		(q.Actor == "" || e.Actor == q.Actor) &&
+		(q.Since.IsZero() || !e.At.Before(q.Since)) &&
+		(q.Until.IsZero() || e.At.Before(q.Until))
+}
+
+// auditKey returns the key of the audit log entry in the format
+// #audit/<seq>. The sequence number is zero padded so the entries
+// are ordered by it.
+func auditKey(seq uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", auditPrefix, seq))
+}
+
+// AuditLog returns the entries of the audit log matching the query
+// in the order they were recorded. The audit log is append-only and
+// records every create, update and delete of an object including the
+// deletions by lifecycle rules and expiry, and every write to a KV,
+// if BucketOptions.AuditLog is enabled. The entries of a KV are
+// committed in the same transaction as the write. The entries of an
+// object are committed with its change feed entries after the
+// mutation itself was committed, so a crash in between loses them,
+// but no entry is ever recorded for a failed mutation.
+func (b *Bucket) AuditLog(q AuditQuery) ([]AuditEntry, error) {
+	entries := make([]AuditEntry, 0)
+	err := b.eachAudit(q, func(e AuditEntry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
+}
+
+// ExportAuditLog writes the entries of the audit log
+// matching the query to w as JSON lines. See AuditLog.
+func (b *Bucket) ExportAuditLog(w io.Writer, q AuditQuery) error {
+	enc := json.NewEncoder(w)
+	return b.eachAudit(q, func(e AuditEntry) error {
+		return enc.Encode(&e)
+	})
+}
+
+// eachAudit calls fn for every entry matching the query.
+func (b *Bucket) eachAudit(q AuditQuery, fn func(e AuditEntry) error) error {
+	if !b.opts.AuditLog {
+		return ErrAuditLogDisabled
+	}
+	if q.Limit < 0 {
+		return ErrNegativeLimit
+	}
+	n := 0
+	return b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(auditPrefix)
+		for it.Seek(auditKey(q.After + 1)); it.ValidForPrefix(prefix); it.Next() {
+			var e AuditEntry
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &e)
+			}); err != nil {
+				return err
+			}
+			if !q.matches(e) {
+				continue
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+			if n++; q.Limit > 0 && n == q.Limit {
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// updateAudited runs fn in a transaction of the name store and
+// appends the entries to the audit log in the same transaction if
+// the audit log is enabled. The sequence numbers and the time of
+// the entries are set.
+func (b *Bucket) updateAudited(fn func(txn *storeTxn) error, entries ...AuditEntry) error {
+	if !b.opts.AuditLog || len(entries) == 0 {
+		return b.name.Update(fn)
+	}
+	b.auditMu.Lock()
+	defer b.auditMu.Unlock()
+	seq := b.auditSeq
+	now := time.Now().UTC()
+	err := b.name.Update(func(txn *storeTxn) error {
+		if err := fn(txn); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			seq++
+			e.Seq, e.At = seq, now
+			data, err := json.Marshal(&e)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(auditKey(seq), data); err != nil {
+				return err
+			}
+		}
+		return txn.Set([]byte(auditSeqKey), binary.BigEndian.AppendUint64(nil, seq))
+	})
+	if err != nil {
+		return err
+	}
+	b.auditSeq = seq
+	return nil
+}
+
+// auditEntries returns the audit log entries
+// of the change of the objects.
+func auditEntries(t EventType, metas []*Metadata) []AuditEntry {
+	op := AuditUpdate
+	switch t {
+	case EventCreated:
+		op = AuditCreate
+	case EventDeleted:
+		op = AuditDelete
+	}
+	entries := make([]AuditEntry, 0, len(metas))
+	for _, meta := range metas {
+		entries = append(entries, AuditEntry{Op: op, Owner: meta.Get(MetaKeyOwner), ID: meta.Get(MetaKeyID), Actor: meta.actor})
+	}
+	return entries
+}
+
+// loadAuditSeq loads the highest sequence number of the audit log.
+func (b *Bucket) loadAuditSeq() error {
+	b.auditMu.Lock()
+	defer b.auditMu.Unlock()
+	b.auditSeq = 0
+	return b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(auditSeqKey))
+		if errors.Is(err, ErrStoreKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			b.auditSeq = binary.BigEndian.Uint64(val)
+			return nil
+		})
+	})
+}
+
+// AuditLog writes the entries of the audit log matching the query
+// parameters `owner`, `id`, `op`, `actor`, `since`, `until` (RFC
+// 3339), `after` and `limit` as JSON lines.
+func (h *HTTPHandler) AuditLog(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	q, err := parseAuditQuery(r)
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !h.bucket.opts.AuditLog {
+		http.Error(w, ErrAuditLogDisabled.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set(headerContentType, contentTypeJSONLines)
+	if err := h.bucket.ExportAuditLog(w, q); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+	}
+}
+
+// actorOf returns the actor of the request recorded in the
+// audit log which is the API key or the authenticated owner.
+func actorOf(r *http.Request) string {
+	if k, ok := r.Context().Value(CtxKeyAPIKey).(APIKey); ok {
+		return "apikey:" + k.ID
+	}
+	owner, _ := r.Context().Value(CtxKeyOwner).(string)
+	return owner
+}
+
+func parseAuditQuery(r *http.Request) (AuditQuery, error) {
+	query := r.URL.Query()
+	q := AuditQuery{
+		Owner: query.Get("owner"),
+		ID:    query.Get("id"),
+		Op:    AuditOp(query.Get("op")),
+		Actor: query.Get("actor"),
+	}
+	var err error
+	for name, t := range map[string]*time.Time{"since": &q.Since, "until": &q.Until} {
+		if v := query.Get(name); v != "" {
+			if *t, err = time.Parse(time.RFC3339, v); err != nil {
+				return q, fmt.Errorf("%s is not a valid RFC 3339 time", name)
+			}
+		}
+	}
+	if v := query.Get("after"); v != "" {
+		if q.After, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return q, errors.New("after is not a valid sequence number")
+		}
+	}
+	if v := query.Get("limit"); v != "" {
+		if q.Limit, err = strconv.Atoi(v); err != nil || q.Limit < 0 {
+			return q, errors.New("limit is not a valid number")
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,163 @@
+package objst
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestAuditLog(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.AuditLog = true
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	start := time.Now()
+	obj := tEnv.obj()
+	if err := b.Create(obj); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.UpdateMeta(obj.ID(), map[MetaKey]string{"foo": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.DeleteByID(obj.ID()); err != nil {
+		t.Fatal(err)
+	}
+	kv := b.KV(obj.Owner())
+	if err := kv.Set("theme", []byte("dark")); err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Delete("theme"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := b.AuditLog(AuditQuery{Since: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type entry struct {
+		Op    AuditOp
+		Owner string
+		ID    string
+	}
+	got := make([]entry, 0, len(entries))
+	for i, e := range entries {
+		if e.Seq != uint64(i+1) || e.At.Before(start) {
+			t.Fatalf("entry %d has an unexpected sequence number or time: %+v", i, e)
+		}
+		got = append(got, entry{Op: e.Op, Owner: e.Owner, ID: e.ID})
+	}
+	want := []entry{
+		{Op: AuditCreate, Owner: obj.Owner(), ID: obj.ID()},
+		{Op: AuditUpdate, Owner: obj.Owner(), ID: obj.ID()},
+		{Op: AuditDelete, Owner: obj.Owner(), ID: obj.ID()},
+		{Op: AuditKVSet, Owner: obj.Owner(), ID: "theme"},
+		{Op: AuditKVDelete, Owner: obj.Owner(), ID: "theme"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("audit log is not the same: %s", diff)
+	}
+	entries, err = b.AuditLog(AuditQuery{Op: AuditDelete, After: 1, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Seq != 3 {
+		t.Fatalf("query should return the delete. Got: %+v", entries)
+	}
+	if entries, _ := b.AuditLog(AuditQuery{Until: start}); len(entries) != 0 {
+		t.Fatalf("no entry should be recorded before the start. Got: %+v", entries)
+	}
+
+	hopts := DefaultHTTPHandlerOptions()
+	hopts.IsAdmin = isAuthorized
+	w := httptest.NewRecorder()
+	NewHTTPHandler(b, hopts).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/objst/admin/auditlog?owner="+obj.Owner()+"&op=kv.set", nil))
+	if w.Code != http.StatusOK || w.Header().Get(headerContentType) != contentTypeJSONLines {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	lines := 0
+	for sc := bufio.NewScanner(w.Body); sc.Scan(); lines++ {
+		var e AuditEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatal(err)
+		}
+		if e.Op != AuditKVSet || e.Seq != 4 {
+			t.Fatalf("unexpected entry: %+v", e)
+		}
+	}
+	if lines != 1 {
+		t.Fatalf("export should contain one line. Got: %d", lines)
+	}
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	if _, err := tEnv.b.AuditLog(AuditQuery{}); !errors.Is(err, ErrAuditLogDisabled) {
+		t.Fatalf("audit log should be disabled by default. Got: %v", err)
+	}
+}
+
+func TestAuditLogActor(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.AuditLog = true
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	obj, internal := tEnv.obj(), tEnv.obj()
+	if err := b.BatchCreate([]*Object{obj, internal}); err != nil {
+		t.Fatal(err)
+	}
+	token, k, err := b.CreateAPIKey(APIKey{Owner: obj.Owner(), Scopes: []Scope{ScopeWrite}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hopts := DefaultHTTPHandlerOptions()
+	hopts.APIKeys = true
+	hopts.IsAdmin = isAuthorized
+	h := NewHTTPHandler(b, hopts)
+	r := httptest.NewRequest(http.MethodDelete, "/objst/"+obj.ID(), nil)
+	r.Header.Set(headerAuthorization, "Bearer "+token)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if err := b.DeleteByID(internal.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	actor := "apikey:" + k.ID
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/objst/admin/auditlog?actor="+actor, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var e AuditEntry
+	if err := json.NewDecoder(w.Body).Decode(&e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Op != AuditDelete || e.ID != obj.ID() || e.Actor != actor {
+		t.Fatalf("deletion of the request should be recorded with its actor. Got: %+v", e)
+	}
+	entries, err := b.AuditLog(AuditQuery{ID: internal.ID(), Op: AuditDelete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Actor != "" {
+		t.Fatalf("deletion of the Go API should be recorded without an actor. Got: %+v", entries)
+	}
+}
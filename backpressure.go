@@ -0,0 +1,99 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// backpressureRetryAfter is the duration clients are asked
+// to wait before retrying a write rejected by backpressure.
+// Badger only reports stalls which are longer.
+const backpressureRetryAfter = time.Second
+
+// BackpressureError is returned by writes while badger is stalling
+// the writes of a store because the compactions can't keep up. It
+// wraps ErrBackpressure. See BucketOptions.StallCheckInterval.
+type BackpressureError struct {
+	// Store is the name of the stalling store.
+	Store string
+	// Level0Tables is the number of tables in level 0
+	// of the store which exceeded the stall threshold.
+	Level0Tables int
+	// RetryAfter is the duration to wait
+	// before the write should be retried.
+	RetryAfter time.Duration
+}
+
+func (e *BackpressureError) Error() string {
+	return fmt.Sprintf("%v: %s store has %d tables in level 0. Retry after %s", ErrBackpressure, e.Store, e.Level0Tables, e.RetryAfter)
+}
+
+func (e *BackpressureError) Unwrap() error {
+	return ErrBackpressure
+}
+
+// backpressure returns the BackpressureError
+// if any store was stalling at the last check.
+func (b *Bucket) backpressure() error {
+	if err := b.stall.Load(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// checkStalls records whether any store is stalling its writes.
+func (b *Bucket) checkStalls() {
+	var stall *BackpressureError
+	for _, s := range b.databases() {
+		if n := s.level0Tables(); n >= s.opts.NumLevelZeroTablesStall {
+			stall = &BackpressureError{Store: s.handle.name, Level0Tables: n, RetryAfter: backpressureRetryAfter}
+			break
+		}
+	}
+	b.stall.Store(stall)
+}
+
+// runStallCheck checks the stores for stalls
+// every interval until the context is canceled.
+func (b *Bucket) runStallCheck(ctx context.Context, interval time.Duration) {
+	defer b.stallWg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.checkStalls()
+		}
+	}
+}
+
+// level0Tables returns the number of tables in level 0.
+func (s *store) level0Tables() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	db, ok := s.badger()
+	if !ok {
+		return 0
+	}
+	for _, l := range db.Levels() {
+		if l.Level == 0 {
+			return l.NumTables
+		}
+	}
+	return 0
+}
+
+// setRetryAfter sets the Retry-After header
+// if the write was rejected by backpressure.
+func setRetryAfter(w http.ResponseWriter, err error) {
+	var bp *BackpressureError
+	if errors.As(err, &bp) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(bp.RetryAfter.Round(time.Second).Seconds())))
+	}
+}
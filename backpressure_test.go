@@ -0,0 +1,50 @@
+package objst
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestBackpressure(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.StallCheckInterval = 0
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	// the meta store is stalling with a threshold of zero
+	threshold := b.meta.opts.NumLevelZeroTablesStall
+	b.meta.opts.NumLevelZeroTablesStall = 0
+	b.checkStalls()
+	err = b.Create(tEnv.obj())
+	var bp *BackpressureError
+	if !errors.As(err, &bp) || !errors.Is(err, ErrBackpressure) {
+		t.Fatalf("write should be rejected by backpressure. Got: %v", err)
+	}
+	if bp.Store != metaStore || bp.RetryAfter <= 0 {
+		t.Fatalf("error should name the stalling store. Got: %+v", bp)
+	}
+	if _, err := b.GetByID(o.ID()); err != nil {
+		t.Fatalf("reads should not be affected by backpressure. Got: %v", err)
+	}
+	w := httptest.NewRecorder()
+	setRetryAfter(w, err)
+	if w.Header().Get("Retry-After") != "1" || errorStatus(err, 0) != http.StatusServiceUnavailable {
+		t.Fatalf("backpressure should be a 503 with Retry-After. Got: %d, %q", errorStatus(err, 0), w.Header().Get("Retry-After"))
+	}
+	b.meta.opts.NumLevelZeroTablesStall = threshold
+	b.checkStalls()
+	if err := b.Create(tEnv.obj()); err != nil {
+		t.Fatalf("writes should be accepted after the stall. Got: %v", err)
+	}
+}
@@ -0,0 +1,289 @@
+package objst
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// backupMagic starts every backup followed by backupVersion.
+const backupMagic = "OBJSTBAK"
+
+// backupVersion is the version of the container format. A
+// backup consists of one section per store which is the name
+// and the key prefix of the store followed by the backup stream
+// of badger split into frames. A frame is the length of its data
+// as uint32 followed by the data and an empty frame ends the
+// section. Version 2 added the section of the blob files after
+// the stores. A section with an empty name ends the backup.
+const backupVersion = 2
+
+// backupFrameSize is the maximum size in bytes of a frame.
+const backupFrameSize = 64 << 10
+
+// Backup writes all entries of the payload, name and meta store with
+// a version newer than since and the blob files referenced by them
+// to w. Writes are blocked until the backup
+// is written so the backup is a consistent point-in-time copy of the
+// bucket while reads are still possible. It returns the since of the
+// next incremental backup which contains only the entries changed in
+// the meantime. Pass 0 for a full backup.
+//
+// The stores of the separate layout have their own versions so an
+// incremental backup may repeat some entries which is harmless.
+// Backups can be restored using Restore.
+func (b *Bucket) Backup(w io.Writer, since uint64) (uint64, error) {
+	defer b.finishOp(b.startOp("Backup"))
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if b.closing {
+		return 0, ErrBucketClosed
+	}
+	if _, err := io.WriteString(w, backupMagic); err != nil {
+		return 0, err
+	}
+	if _, err := w.Write([]byte{backupVersion}); err != nil {
+		return 0, err
+	}
+	var next uint64
+	for i, s := range []*store{b.payload, b.name, b.meta} {
+		v, err := s.backup(w, since)
+		if err != nil {
+			return 0, fmt.Errorf("backup of the %s store: %w", s.name, err)
+		}
+		if i == 0 || v < next {
+			next = v
+		}
+	}
+	if err := b.backupBlobs(w, since); err != nil {
+		return 0, fmt.Errorf("backup of the blob files: %w", err)
+	}
+	// the empty name ends the backup
+	if err := writeSectionHeader(w, "", nil); err != nil {
+		return 0, err
+	}
+	return next, nil
+}
+
+// Restore loads a backup created by Backup into the stores.
+// Entries of the backup replace the entries with the same key
+// and an older version. Incremental backups have to be restored
+// in the order they were created after the full backup. Writes
+// are blocked until the backup is restored and the role of the
+// bucket is kept. The next scheduled backup is a full backup. See
+// RunBackup. The bucket has to use the same store layout as the
+// backed up bucket. Otherwise ErrBackupLayout is returned.
+func (b *Bucket) Restore(r io.Reader) error {
+	defer b.finishOp(b.startOp("Restore"))
+	b.backupMu.Lock()
+	defer b.backupMu.Unlock()
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if b.closing {
+		return ErrBucketClosed
+	}
+	if b.frozen {
+		return ErrBucketFrozen
+	}
+	br := bufio.NewReader(r)
+	header := make([]byte, len(backupMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	// backups of version 1 have no blob files
+	if v := header[len(backupMagic)]; string(header[:len(backupMagic)]) != backupMagic || v < 1 || v > backupVersion {
+		return ErrInvalidBackup
+	}
+	stores := map[string]*store{
+		payloadStore: b.payload,
+		nameStore:    b.name,
+		metaStore:    b.meta,
+	}
+	role, epoch := b.role, b.epoch
+	backups, err := b.backupState()
+	if err != nil {
+		return err
+	}
+	// the cache and the loaded state are refreshed
+	// even if only a part of the backup was restored
+	defer b.reloadState()
+	for {
+		name, prefix, err := readSectionHeader(br)
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			break
+		}
+		if name == blobSection {
+			if err := b.restoreBlobs(&frameReader{r: br}); err != nil {
+				return fmt.Errorf("restoring the blob files: %w", err)
+			}
+			continue
+		}
+		s, ok := stores[name]
+		if !ok {
+			return fmt.Errorf("%w: unknown store %q", ErrInvalidBackup, name)
+		}
+		if !bytes.Equal(prefix, s.prefix) {
+			return ErrBackupLayout
+		}
+		if err := s.load(&frameReader{r: br}); err != nil {
+			return fmt.Errorf("restoring the %s store: %w", name, err)
+		}
+	}
+	// the role and the backups of the backed up bucket are not
+	// adopted. The restored entries may be older than the last
+	// backup so an incremental backup would miss them.
+	if err := b.setRole(role, epoch); err != nil {
+		return err
+	}
+	backups.Since = 0
+	return b.writeBackupState(backups)
+}
+
+// reloadState clears the cache and reloads the state
+// of the bucket which is derived from the stores.
+func (b *Bucket) reloadState() {
+	b.cache.clear()
+	for _, load := range []func() error{b.loadDictionary, b.loadIndexes, b.loadChangeSeq, b.loadAuditSeq, b.loadUsage} {
+		if err := load(); err != nil && b.opts.Logger != nil {
+			b.opts.Logger.Errorf("reloading the state after a restore failed: %v", err)
+		}
+	}
+	if err := b.ReconcileUsage(context.Background()); err != nil && b.opts.Logger != nil {
+		b.opts.Logger.Errorf("reconciling the usage after a restore failed: %v", err)
+	}
+	// blob files of objects deleted since the last
+	// restored backup are not referenced anymore
+	if err := b.pruneBlobs(); err != nil && b.opts.Logger != nil {
+		b.opts.Logger.Errorf("pruning the blob files after a restore failed: %v", err)
+	}
+}
+
+// backup writes the section of the store to w
+// and returns the highest version of the store.
+func (s *store) backup(w io.Writer, since uint64) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	db, ok := s.badger()
+	if !ok {
+		return 0, ErrStoreUnsupported
+	}
+	if err := writeSectionHeader(w, s.name, s.prefix); err != nil {
+		return 0, err
+	}
+	version := db.MaxVersion()
+	stream := db.NewStream()
+	stream.LogPrefix = "objst.Backup"
+	stream.Prefix = s.prefix
+	stream.SinceTs = since
+	fw := bufio.NewWriterSize(&frameWriter{w: w}, backupFrameSize)
+	if _, err := stream.Backup(fw, since); err != nil {
+		return 0, err
+	}
+	if err := fw.Flush(); err != nil {
+		return 0, err
+	}
+	// the empty frame ends the section
+	return version, binary.Write(w, binary.BigEndian, uint32(0))
+}
+
+// load loads the backup stream of badger read from r.
+func (s *store) load(r io.Reader) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	db, ok := s.badger()
+	if !ok {
+		return ErrStoreUnsupported
+	}
+	return db.Load(r, 256)
+}
+
+func writeSectionHeader(w io.Writer, name string, prefix []byte) error {
+	header := make([]byte, 0, 2+len(name)+len(prefix))
+	header = append(header, byte(len(name)))
+	header = append(header, name...)
+	header = append(header, byte(len(prefix)))
+	header = append(header, prefix...)
+	_, err := w.Write(header)
+	return err
+}
+
+func readSectionHeader(r io.ByteReader) (string, []byte, error) {
+	readField := func() ([]byte, error) {
+		n, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		field := make([]byte, n)
+		for i := range field {
+			if field[i], err = r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		return field, nil
+	}
+	name, err := readField()
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if len(name) == 0 {
+		return "", nil, nil
+	}
+	prefix, err := readField()
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	return string(name), prefix, nil
+}
+
+// frameWriter writes every non-empty write as one frame.
+type frameWriter struct {
+	w io.Writer
+}
+
+func (f *frameWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := binary.Write(f.w, binary.BigEndian, uint32(len(p))); err != nil {
+		return 0, err
+	}
+	return f.w.Write(p)
+}
+
+// frameReader reads the data of the frames of one section
+// and returns io.EOF after the empty frame ending it.
+type frameReader struct {
+	r    io.Reader
+	left uint32
+	done bool
+}
+
+func (f *frameReader) Read(p []byte) (int, error) {
+	if f.done {
+		return 0, io.EOF
+	}
+	if f.left == 0 {
+		if err := binary.Read(f.r, binary.BigEndian, &f.left); err != nil {
+			return 0, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+		}
+		if f.left == 0 {
+			f.done = true
+			return 0, io.EOF
+		}
+	}
+	if uint32(len(p)) > f.left {
+		p = p[:f.left]
+	}
+	n, err := f.r.Read(p)
+	f.left -= uint32(n)
+	if err == io.EOF {
+		err = fmt.Errorf("%w: %v", ErrInvalidBackup, io.ErrUnexpectedEOF)
+	}
+	return n, err
+}
@@ -0,0 +1,104 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestBackupRestore(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	src, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src.BasePath)
+	defer src.Shutdown()
+	dst, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst.BasePath)
+	defer dst.Shutdown()
+
+	first := tEnv.obj()
+	if err := src.Create(first); err != nil {
+		t.Fatal(err)
+	}
+	var full bytes.Buffer
+	since, err := src.Backup(&full, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := tEnv.obj()
+	if err := src.Create(second); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.DeleteByID(first.ID()); err != nil {
+		t.Fatal(err)
+	}
+	var incr bytes.Buffer
+	if _, err := src.Backup(&incr, since); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dst.Restore(bytes.NewReader(full.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	got, err := dst.GetByID(first.ID())
+	if err != nil {
+		t.Fatalf("object of the full backup should be restored: %v", err)
+	}
+	if !bytes.Equal(got.Payload(), first.Payload()) {
+		t.Fatal("restored payload is not the backed up payload")
+	}
+	if _, err := dst.GetByName(first.Name(), first.Owner()); err != nil {
+		t.Fatalf("name of the object should be restored: %v", err)
+	}
+	if err := dst.Restore(bytes.NewReader(incr.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.GetByID(first.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("deletion of the incremental backup should be restored. Got: %v", err)
+	}
+	if _, err := dst.GetByID(second.ID()); err != nil {
+		t.Fatalf("object of the incremental backup should be restored: %v", err)
+	}
+}
+
+func TestRestoreInvalid(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	src, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src.BasePath)
+	defer src.Shutdown()
+	if err := src.Create(tEnv.obj()); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := src.Backup(&buf, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	opts.Layout = LayoutSingle
+	dst, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst.BasePath)
+	defer dst.Shutdown()
+	if err := dst.Restore(bytes.NewReader(buf.Bytes())); !errors.Is(err, ErrBackupLayout) {
+		t.Fatalf("backup of another layout should be rejected. Got: %v", err)
+	}
+	if err := src.Restore(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("truncated backup should be rejected. Got: %v", err)
+	}
+	if err := src.Restore(bytes.NewReader([]byte("objst"))); !errors.Is(err, ErrInvalidBackup) {
+		t.Fatalf("data without the header should be rejected. Got: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package objst
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupStateKey is the key of the state of the
+// scheduled backups in the name store.
+const backupStateKey = "#backup"
+
+// backupState is the state of the scheduled backups.
+type backupState struct {
+	// Since is the version returned by the last backup.
+	// The next backup is a full backup if it is 0.
+	Since uint64 `json:"since"`
+	// Seq is the sequence number of the last backup.
+	Seq uint64 `json:"seq"`
+	// Backups are the names of the kept backups
+	// starting with the last full backup.
+	Backups []string `json:"backups"`
+}
+
+// RunBackup writes the next scheduled backup to BackupDir or
+// BackupWriter and returns its name. It is a full backup if no
+// backup was written before, BackupRetention is reached or the
+// bucket was restored. Otherwise it is an incremental backup of
+// the changes since the last backup. The backups are named by
+// their sequence number so restoring them in the order of their
+// names starting with the last full backup restores the bucket.
+// After a full backup the older backups are pruned.
+func (b *Bucket) RunBackup(ctx context.Context) (string, error) {
+	if b.opts.BackupDir == "" && b.opts.BackupWriter == nil {
+		return "", ErrNoBackupTarget
+	}
+	b.backupMu.Lock()
+	defer b.backupMu.Unlock()
+	state, err := b.backupState()
+	if err != nil {
+		return "", err
+	}
+	retention := b.opts.BackupRetention
+	full := state.Since == 0 || (retention > 0 && len(state.Backups) >= retention)
+	since, kind := state.Since, "incr"
+	if full {
+		since, kind = 0, "full"
+	}
+	state.Seq++
+	name := fmt.Sprintf("backup-%06d-%s.objst", state.Seq, kind)
+	w, err := b.createBackup(name)
+	if err != nil {
+		return "", err
+	}
+	next, err := b.Backup(&ctxWriter{ctx: ctx, w: w}, since)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// incomplete backups are never kept
+		b.removeBackup(name)
+		return "", err
+	}
+	if full {
+		for _, old := range state.Backups {
+			if err := b.removeBackup(old); err != nil && b.opts.Logger != nil {
+				b.opts.Logger.Warningf("pruning the backup %s failed: %v", old, err)
+			}
+		}
+		state.Backups = nil
+	}
+	state.Since = next
+	state.Backups = append(state.Backups, name)
+	return name, b.writeBackupState(state)
+}
+
+// runBackups writes a backup every interval
+// until the context is canceled.
+func (b *Bucket) runBackups(ctx context.Context, interval time.Duration) {
+	defer b.backupWg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := b.RunBackup(ctx); err != nil && ctx.Err() == nil && b.opts.Logger != nil {
+				b.opts.Logger.Warningf("scheduled backup failed: %v", err)
+			}
+		}
+	}
+}
+
+func (b *Bucket) createBackup(name string) (io.WriteCloser, error) {
+	if b.opts.BackupWriter != nil {
+		return b.opts.BackupWriter(name)
+	}
+	if err := os.MkdirAll(b.opts.BackupDir, 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(filepath.Join(b.opts.BackupDir, name))
+}
+
+func (b *Bucket) removeBackup(name string) error {
+	if b.opts.BackupWriter != nil {
+		if b.opts.BackupRemove == nil {
+			return nil
+		}
+		return b.opts.BackupRemove(name)
+	}
+	err := os.Remove(filepath.Join(b.opts.BackupDir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (b *Bucket) backupState() (backupState, error) {
+	var state backupState
+	err := b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(backupStateKey))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &state)
+		})
+	})
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return state, nil
+	}
+	return state, err
+}
+
+func (b *Bucket) writeBackupState(state backupState) error {
+	data, err := json.Marshal(&state)
+	if err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Set([]byte(backupStateKey), data)
+	})
+}
+
+// ctxWriter fails the writes after the context is done.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (c *ctxWriter) Write(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.w.Write(p)
+}
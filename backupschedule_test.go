@@ -0,0 +1,127 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunBackup(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.BackupDir = t.TempDir()
+	opts.BackupRetention = 3
+	src, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(src.BasePath)
+	defer src.Shutdown()
+	dst, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst.BasePath)
+	defer dst.Shutdown()
+
+	objs := tEnv.nObj(3)
+	names := make([]string, 0, len(objs))
+	for _, o := range objs {
+		if err := src.Create(o); err != nil {
+			t.Fatal(err)
+		}
+		name, err := src.RunBackup(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	want := []string{"backup-000001-full.objst", "backup-000002-incr.objst", "backup-000003-incr.objst"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("backups should be a full backup followed by incremental backups. Got: %v", names)
+	}
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(opts.BackupDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = dst.Restore(f)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, o := range objs {
+		if _, err := dst.GetByID(o.ID()); err != nil {
+			t.Fatalf("object should be restored from the backups: %v", err)
+		}
+	}
+
+	name, err := src.RunBackup(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "backup-000004-full.objst" {
+		t.Fatalf("reaching the retention should result in a full backup. Got: %s", name)
+	}
+	entries, err := os.ReadDir(opts.BackupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != name {
+		t.Fatalf("older backups should be pruned. Got: %v", entries)
+	}
+	// the restored bucket starts with a full backup
+	if name, err := dst.RunBackup(context.Background()); err != nil || name != "backup-000001-full.objst" {
+		t.Fatalf("first backup of the restored bucket should be a full backup. Got: %s %v", name, err)
+	}
+}
+
+func TestBackupScheduler(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.BackupInterval = 10 * time.Millisecond
+	if _, err := NewBucket(opts); !errors.Is(err, ErrNoBackupTarget) {
+		t.Fatalf("scheduled backups without a target should be rejected. Got: %v", err)
+	}
+	written := make(chan string, 16)
+	opts.BackupWriter = func(name string) (io.WriteCloser, error) {
+		return nopWriteCloser{name: name, written: written}, nil
+	}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	select {
+	case name := <-written:
+		if name != "backup-000001-full.objst" {
+			t.Fatalf("first scheduled backup should be a full backup. Got: %s", name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no backup was scheduled")
+	}
+}
+
+type nopWriteCloser struct {
+	name    string
+	written chan<- string
+}
+
+func (n nopWriteCloser) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (n nopWriteCloser) Close() error {
+	select {
+	case n.written <- n.name:
+	default:
+	}
+	return nil
+}
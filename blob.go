@@ -0,0 +1,435 @@
+package objst
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// blobDir is the directory of the blob files
+// in the base path of the bucket.
+const blobDir = "blobs"
+
+// blobReadSize is the size in bytes of
+// the parts passed to ViewPayload.
+const blobReadSize = 1 << 20
+
+// blobSection is the name of the section of
+// the blob files in a backup. See Backup.
+const blobSection = "blobs"
+
+const (
+	// blobPrefix is the prefix of the keys in the payload store
+	// referencing the blob file of an object by its hash.
+	blobPrefix = "blob/"
+	// blobRefPrefix is the prefix of the reverse references
+	// blobref/<hash>/<id> which are counting the objects
+	// using a blob file.
+	blobRefPrefix = "blobref/"
+)
+
+func blobKey(id string) []byte {
+	return []byte(blobPrefix + id)
+}
+
+func blobRefKey(hash, id string) []byte {
+	return []byte(blobRefPrefix + hash + "/" + id)
+}
+
+// blobPath returns the content-addressed path of the blob
+// file which is <base path>/blobs/<hash[:2]>/<hash>.
+func (b *Bucket) blobPath(hash string) string {
+	return filepath.Join(b.BasePath, blobDir, hash[:2], hash)
+}
+
+// splitPayload returns the entries of the encoded payload of the
+// object. Payloads larger than BlobThreshold are written to a blob
+// file and the only entry is the reference to the file. Otherwise
+// the payload is split into chunks. See payloadEntries.
+func (b *Bucket) splitPayload(id string, chunkSize int64, data []byte) ([]*Entry, error) {
+	if b.opts.BlobThreshold <= 0 || int64(len(data)) <= b.opts.BlobThreshold {
+		return payloadEntries(id, chunkSize, data), nil
+	}
+	hash, err := b.writeBlob(id, data)
+	if err != nil {
+		return nil, err
+	}
+	return []*Entry{newEntry(blobKey(id), []byte(hash))}, nil
+}
+
+// writeBlob writes the data to its blob file and references
+// it by the object with the id. Existing files are kept
+// because their content is equal. It returns the hash.
+func (b *Bucket) writeBlob(id string, data []byte) (string, error) {
+	hash := checksum(data)
+	path := b.blobPath(hash)
+	tmp, err := b.createBlobTemp(path)
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := syncClose(tmp); err != nil {
+		return "", err
+	}
+	// the reference is written while holding blobMu so the
+	// file can't be removed by a concurrent removeBlob.
+	b.blobMu.Lock()
+	defer b.blobMu.Unlock()
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	err = b.payload.Update(func(txn *storeTxn) error {
+		return txn.Set(blobRefKey(hash, id), nil)
+	})
+	return hash, err
+}
+
+func (b *Bucket) createBlobTemp(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	return os.CreateTemp(filepath.Dir(path), ".blob-*")
+}
+
+func syncClose(f *os.File) error {
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// blobOf returns the hash of the blob file storing the
+// payload of the object and false if it's stored in chunks.
+func blobOf(txn *storeTxn, id string) (string, bool, error) {
+	item, err := txn.Get(blobKey(id))
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	v, err := item.ValueCopy(nil)
+	return string(v), err == nil, err
+}
+
+// hasBlob reports whether the payload of
+// the object is stored in a blob file.
+func (b *Bucket) hasBlob(id string) (bool, error) {
+	var ok bool
+	err := b.payload.View(func(txn *storeTxn) (err error) {
+		_, ok, err = blobOf(txn, id)
+		return err
+	})
+	return ok, err
+}
+
+// readBlob reads the whole blob file.
+func (b *Bucket) readBlob(hash string) ([]byte, error) {
+	data, err := os.ReadFile(b.blobPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: missing blob %s", ErrStoreCorrupted, hash)
+	}
+	return data, err
+}
+
+// viewBlob calls fn with the content of the
+// blob file in parts of blobReadSize bytes.
+func (b *Bucket) viewBlob(hash string, fn func(chunk []byte) error) error {
+	f, err := os.Open(b.blobPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: missing blob %s", ErrStoreCorrupted, hash)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return viewParts(f, fn)
+}
+
+// viewParts calls fn with the data read
+// from r in parts of blobReadSize bytes.
+func viewParts(r io.Reader, fn func(chunk []byte) error) error {
+	buf := make([]byte, blobReadSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := fn(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// releaseBlob returns the entries removing the reference of the
+// object to its blob file which is replaced by the blob with the
+// hash next. It returns the hash of the released blob which has
+// to be removed using removeBlob after the entries are written.
+func releaseBlob(txn *storeTxn, id, next string) ([][]byte, string, error) {
+	hash, ok, err := blobOf(txn, id)
+	if err != nil || !ok || hash == next {
+		return nil, "", err
+	}
+	keys := [][]byte{blobRefKey(hash, id)}
+	if next == "" {
+		keys = append(keys, blobKey(id))
+	}
+	return keys, hash, nil
+}
+
+// removeBlob removes the blob file if
+// no object is referencing it anymore.
+func (b *Bucket) removeBlob(hash string) error {
+	if hash == "" {
+		return nil
+	}
+	b.blobMu.Lock()
+	defer b.blobMu.Unlock()
+	referenced := false
+	err := b.payload.View(func(txn *storeTxn) error {
+		opts := defaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := []byte(blobRefPrefix + hash + "/")
+		it.Seek(prefix)
+		referenced = it.ValidForPrefix(prefix)
+		return nil
+	})
+	if err != nil || referenced {
+		return err
+	}
+	err = os.Remove(b.blobPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// OpenPayloadFile opens the blob file storing the payload of the
+// object e.g. to serve it using http.ServeContent which can use
+// sendfile. The file must not be modified. ErrPayloadNotFile is
+// returned if the payload is stored in chunks or is compressed
+// or encrypted. See BucketOptions.BlobThreshold.
+func (b *Bucket) OpenPayloadFile(id string) (*os.File, error) {
+	meta, err := b.GetMeta(id)
+	if err != nil {
+		return nil, err
+	}
+	if isEncoded(meta) {
+		return nil, fmt.Errorf("%w: %s", ErrPayloadNotFile, id)
+	}
+	var hash string
+	err = b.payload.View(func(txn *storeTxn) error {
+		h, ok, err := blobOf(txn, id)
+		if err == nil && !ok {
+			err = fmt.Errorf("%w: %s", ErrPayloadNotFile, id)
+		}
+		hash = h
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(b.blobPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: missing blob %s", ErrStoreCorrupted, hash)
+	}
+	return f, err
+}
+
+// pruneBlobs removes the references of objects to blob files they
+// are not using anymore and the blob files which are not referenced
+// e.g. after a failed write or a restore. No write may be in progress.
+func (b *Bucket) pruneBlobs() error {
+	used := make(map[string]bool)
+	var stale [][]byte
+	err := b.payload.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(blobRefPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			key := it.Item().KeyCopy(nil)
+			hash, id, _ := strings.Cut(string(key[len(prefix):]), "/")
+			current, _, err := blobOf(txn, id)
+			if err != nil {
+				return err
+			}
+			if current == hash {
+				used[hash] = true
+			} else {
+				stale = append(stale, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(stale) > 0 {
+		wb := b.payload.NewWriteBatch()
+		defer wb.Cancel()
+		for _, k := range stale {
+			if err := wb.Delete(k); err != nil {
+				return err
+			}
+		}
+		if err := wb.Flush(); err != nil {
+			return err
+		}
+	}
+	dir := filepath.Join(b.BasePath, blobDir)
+	err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || d.IsDir() || used[d.Name()] {
+			return err
+		}
+		// temporary files of interrupted writes
+		// are removed like unreferenced blobs.
+		return os.Remove(path)
+	})
+	return err
+}
+
+// backupBlobs writes the section of the blob files referenced
+// by entries with a version newer than since to w. Every blob
+// is its hash prefixed by its length, the size of the file as
+// uint64 and the content of the file.
+func (b *Bucket) backupBlobs(w io.Writer, since uint64) error {
+	db, ok := b.payload.badger()
+	if !ok {
+		return ErrStoreUnsupported
+	}
+	hashes := make(map[string]bool)
+	err := db.View(func(txn *badger.Txn) error {
+		prefix := append(append([]byte(nil), b.payload.prefix...), blobPrefix...)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if it.Item().Version() <= since {
+				continue
+			}
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			hashes[string(v)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := writeSectionHeader(w, blobSection, nil); err != nil {
+		return err
+	}
+	fw := bufio.NewWriterSize(&frameWriter{w: w}, backupFrameSize)
+	for hash := range hashes {
+		if err := b.writeBlobRecord(fw, hash); err != nil {
+			return fmt.Errorf("blob %s: %w", hash, err)
+		}
+	}
+	if err := fw.Flush(); err != nil {
+		return err
+	}
+	// the empty frame ends the section
+	return binary.Write(w, binary.BigEndian, uint32(0))
+}
+
+func (b *Bucket) writeBlobRecord(w io.Writer, hash string) error {
+	f, err := os.Open(b.blobPath(hash))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(append([]byte{byte(len(hash))}, hash...)); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint64(info.Size())); err != nil {
+		return err
+	}
+	_, err = io.CopyN(w, f, info.Size())
+	return err
+}
+
+// restoreBlobs writes the blob files of the section read from r.
+// The content of every file is verified against its hash.
+func (b *Bucket) restoreBlobs(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		n, err := br.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := make([]byte, n)
+		if _, err := io.ReadFull(br, name); err != nil {
+			return err
+		}
+		var size uint64
+		if err := binary.Read(br, binary.BigEndian, &size); err != nil {
+			return err
+		}
+		if err := b.restoreBlob(string(name), io.LimitReader(br, int64(size)), int64(size)); err != nil {
+			return err
+		}
+	}
+}
+
+func (b *Bucket) restoreBlob(hash string, r io.Reader, size int64) error {
+	if len(hash) != sha256.Size*2 {
+		return fmt.Errorf("%w: invalid blob %q", ErrInvalidBackup, hash)
+	}
+	path := b.blobPath(hash)
+	tmp, err := b.createBlobTemp(path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err == nil && n != size {
+		err = fmt.Errorf("%w: %v", ErrInvalidBackup, io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != hash {
+		tmp.Close()
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, hash)
+	}
+	if err := syncClose(tmp); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,152 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func newBlobBucket(t *testing.T) *Bucket {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.BlobThreshold = 1 << 10
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		b.Shutdown()
+		os.RemoveAll(b.BasePath)
+	})
+	return b
+}
+
+func blobFiles(t *testing.T, b *Bucket) []string {
+	files, err := filepath.Glob(filepath.Join(b.BasePath, blobDir, "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestBlobThreshold(t *testing.T) {
+	b := newBlobBucket(t)
+	pl := tEnv.payload(4 << 10)
+	objs := make([]*Object, 0, 2)
+	for i := 0; i < 2; i++ {
+		o := tEnv.emptyObj()
+		o.Write(pl)
+		if err := b.Create(o); err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, o)
+	}
+	small := tEnv.obj()
+	if err := b.Create(small); err != nil {
+		t.Fatal(err)
+	}
+	if files := blobFiles(t, b); len(files) != 1 {
+		t.Fatalf("equal payloads should share one blob file. Got: %v", files)
+	}
+	got, err := b.GetPayload(objs[0].ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, pl) {
+		t.Fatal("payload of the blob differs")
+	}
+	var viewed []byte
+	err = b.ViewPayload(objs[1].ID(), func(chunk []byte) error {
+		viewed = append(viewed, chunk...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(viewed, pl) {
+		t.Fatal("viewed payload of the blob differs")
+	}
+	f, err := b.OpenPayloadFile(objs[0].ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, pl) {
+		t.Fatal("content of the payload file differs")
+	}
+	if _, err := b.OpenPayloadFile(small.ID()); !errors.Is(err, ErrPayloadNotFile) {
+		t.Fatalf("small payloads should be stored in the payload store. Got: %v", err)
+	}
+
+	if err := b.PatchPayload(objs[0].ID(), 0, []byte("patched")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Verify(objs[0].ID()); err != nil {
+		t.Fatal(err)
+	}
+	if files := blobFiles(t, b); len(files) != 2 {
+		t.Fatalf("the patched payload should be written to a new blob file. Got: %v", files)
+	}
+	if err := b.DeleteByID(objs[0].ID()); err != nil {
+		t.Fatal(err)
+	}
+	if files := blobFiles(t, b); len(files) != 1 {
+		t.Fatalf("the blob file of the deleted object should be removed. Got: %v", files)
+	}
+	if err := b.DeleteByID(objs[1].ID()); err != nil {
+		t.Fatal(err)
+	}
+	if files := blobFiles(t, b); len(files) != 0 {
+		t.Fatalf("unreferenced blob files should be removed. Got: %v", files)
+	}
+}
+
+func TestBlobBackupRestore(t *testing.T) {
+	src := newBlobBucket(t)
+	dst := newBlobBucket(t)
+	o := tEnv.emptyObj()
+	o.Write(tEnv.payload(4 << 10))
+	if err := src.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	var backup bytes.Buffer
+	if _, err := src.Backup(&backup, 0); err != nil {
+		t.Fatal(err)
+	}
+	// a blob file without a reference e.g. of a
+	// failed write is removed by the restore.
+	stray := filepath.Join(dst.BasePath, blobDir, "ab", "ab")
+	if err := os.MkdirAll(filepath.Dir(stray), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stray, []byte("stray"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Restore(&backup); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Verify(o.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stray); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("unreferenced blob file should be pruned. Got: %v", err)
+	}
+
+	if err := os.WriteFile(stray, []byte("stray"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.RunGC(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if files := blobFiles(t, dst); len(files) != 1 {
+		t.Fatalf("the garbage collection should only keep the referenced blob file. Got: %v", files)
+	}
+}
@@ -1,12 +1,20 @@
 package objst
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/dgraph-io/badger/v4"
 	"github.com/google/uuid"
 )
 
@@ -15,15 +23,143 @@ const (
 	dataDir  = "data"
 	nameDir  = "name"
 	metaDir  = "meta"
+	// storeDir is the directory of the single layout.
+	storeDir = "store"
+	// payloadLockStripes is the number of mutexes the
+	// payload locks are striped by. See lockPayload.
+	payloadLockStripes = 64
 )
 
 type Bucket struct {
 	// store persists the objects and the
 	// actual data the client will interact with.
-	payload *badger.DB
-	name    *badger.DB
+	payload *store
+	name    *store
 
-	meta *badger.DB
+	meta *store
+
+	opts BucketOptions
+
+	// writeMu is held by every write operation
+	// and exclusively by Freeze and Unfreeze.
+	writeMu sync.RWMutex
+	frozen  bool
+	// readOnly buckets are frozen forever.
+	// See OpenBucketWithRecovery.
+	readOnly bool
+
+	// quotaMu guards quotas and serializes writes
+	// of owners with a quota. See reserveQuota.
+	quotaMu sync.Mutex
+	quotas  map[string]Quota
+	// typeQuotas maps the owners to the quotas
+	// of their objects per content type.
+	typeQuotas map[string]map[string]Quota
+
+	// dictMu guards the cached zstd dictionaries
+	// and the manifest in the name store.
+	dictMu sync.RWMutex
+	dicts  dictionaries
+
+	// indexMu guards indexes which maps the indexed keys
+	// to whether the index is complete and usable by queries.
+	indexMu sync.RWMutex
+	indexes map[MetaKey]bool
+
+	// changeMu serializes the writes to the change
+	// feed and guards changeSeq which is the highest
+	// sequence number of the change feed.
+	changeMu  sync.Mutex
+	changeSeq uint64
+	// auditMu serializes the writes to the audit log and
+	// guards auditSeq which is its highest sequence number.
+	auditMu  sync.Mutex
+	auditSeq uint64
+
+	// usageMu guards usages which are the
+	// persisted usage counters of the owners.
+	usageMu sync.Mutex
+	usages  map[usageID]Usage
+	// stopUsage stops the usage reconciler
+	// and is nil if no reconciler is running.
+	stopUsage context.CancelFunc
+	usageWg   sync.WaitGroup
+
+	// pipelineMu guards pipelines which maps the names
+	// of the running pipelines to their cancel function.
+	pipelineMu sync.Mutex
+	pipelines  map[string]context.CancelFunc
+	pipelineWg sync.WaitGroup
+
+	slowOps *slowLog
+	// cache is nil if the object cache is disabled.
+	cache *objectCache
+
+	lifecycleMu sync.Mutex
+	rules       []Rule
+	// nameRepairs queues the meta data of objects whose
+	// name entry is missing. See resolveName.
+	nameRepairs    chan *Metadata
+	stopNameRepair context.CancelFunc
+	nameRepairWg   sync.WaitGroup
+	// stopLifecycle stops the lifecycle worker
+	// and is nil if no worker is running.
+	stopLifecycle context.CancelFunc
+	lifecycleWg   sync.WaitGroup
+	// stopGC stops the garbage collection of the
+	// value logs and is nil if it is not running.
+	stopGC context.CancelFunc
+	gcWg   sync.WaitGroup
+	// backupMu serializes the scheduled backups and the
+	// restores. stopBackup stops the backup scheduler and
+	// is nil if no scheduler is running.
+	backupMu   sync.Mutex
+	stopBackup context.CancelFunc
+	backupWg   sync.WaitGroup
+	// blobMu serializes the references to new blob files
+	// and the removal of unreferenced ones. See writeBlob.
+	blobMu sync.Mutex
+	// deliveryMu serializes the deliveries of the events to
+	// the subscriptions. stopDelivery stops the delivery
+	// worker and is nil if no worker is running.
+	deliveryMu   sync.Mutex
+	stopDelivery context.CancelFunc
+	deliveryWg   sync.WaitGroup
+	// replicatorMu guards replicators which maps the
+	// names of the running replicators to them.
+	replicatorMu sync.Mutex
+	replicators  map[string]*Replicator
+	// watchMu guards watchers which are the running watches
+	// and watchStopped which rejects new ones. See Watch.
+	watchMu      sync.Mutex
+	watchers     map[*watcher]struct{}
+	watchStopped bool
+	// hookMu guards hooks. See Use.
+	hookMu sync.RWMutex
+	hooks  []Hook
+	// payloadMu serializes the rewrites of the payload of an
+	// object so the checksum and the size in its meta data
+	// describe the stored payload. See lockPayload.
+	payloadMu [payloadLockStripes]sync.Mutex
+	// stall is the stall of a store found by the last
+	// check which rejects all writes. See backpressure.
+	stall     atomic.Pointer[BackpressureError]
+	stopStall context.CancelFunc
+	stallWg   sync.WaitGroup
+	// ops is the number of in-flight operations and
+	// drained is closed when it drops to zero while
+	// the bucket is closing. See Close.
+	opsMu   sync.Mutex
+	ops     int
+	drained chan struct{}
+	// role and epoch are the role of the bucket and its
+	// fencing token and are guarded by writeMu. See Promote.
+	role  Role
+	epoch uint64
+	// closing rejects all writes with ErrBucketClosed
+	// and is guarded by writeMu.
+	closing bool
+	closed  atomic.Bool
 
 	BasePath string
 }
@@ -32,283 +168,1506 @@ type Bucket struct {
 // The `Dir` option will be overwritten by the application to have
 // a gurantee about the data path.
 func NewBucket(opts BucketOptions) (*Bucket, error) {
-	uniqueBasePath := filepath.Join(basePath, uuid.NewString())
-	payloadDataDir := filepath.Join(uniqueBasePath, dataDir)
-	opts.overwriteDataDir(payloadDataDir)
-	payload, err := badger.Open(opts.toBadgerOpts())
-	if err != nil {
+	return openBucket(filepath.Join(basePath, uuid.NewString()), opts, false)
+}
+
+// openBucket opens the bucket stored in uniqueBasePath which is
+// created if it doesn't exist. A read-only bucket is frozen and
+// is opened even if another process is using the bucket.
+func openBucket(uniqueBasePath string, opts BucketOptions, readOnly bool) (*Bucket, error) {
+	if err := opts.validateFIPS(); err != nil {
 		return nil, err
 	}
-	nameDataDir := filepath.Join(uniqueBasePath, nameDir)
-	name, err := badger.Open(badger.DefaultOptions(nameDataDir))
+	if opts.BackupInterval > 0 && opts.BackupDir == "" && opts.BackupWriter == nil {
+		return nil, ErrNoBackupTarget
+	}
+	opts, configs, err := bucketStores(uniqueBasePath, opts)
 	if err != nil {
 		return nil, err
 	}
-	metaDataDir := filepath.Join(uniqueBasePath, metaDir)
-	meta, err := badger.Open(badger.DefaultOptions(metaDataDir))
+	stores, err := openStores(readOnly, configs...)
 	if err != nil {
 		return nil, err
 	}
+	var payload, name, meta *store
+	if len(stores) == 1 {
+		payload, name, meta = stores[0].split()
+	} else {
+		payload, name, meta = stores[0], stores[1], stores[2]
+	}
+	if opts.ArchiveDir == "" {
+		opts.ArchiveDir = filepath.Join(uniqueBasePath, archiveDir)
+	}
+	if opts.OwnerValidator == nil {
+		opts.OwnerValidator = ValidateUUIDOwner
+	}
+	if opts.Codec == nil {
+		opts.Codec = CodecProtobuf
+	}
+	if opts.EventDelivery == nil {
+		opts.EventDelivery = DeliverWebhook
+	}
 	b := &Bucket{
-		payload:  payload,
-		name:     name,
-		meta:     meta,
-		BasePath: uniqueBasePath,
+		payload:     payload,
+		name:        name,
+		meta:        meta,
+		opts:        opts,
+		slowOps:     newSlowLog(opts.SlowOpThreshold, opts.SlowOpLogSize),
+		cache:       newObjectCache(opts.CacheSize, opts.CacheMaxPayloadSize),
+		pipelines:   make(map[string]context.CancelFunc),
+		nameRepairs: make(chan *Metadata, nameRepairQueueSize),
+		frozen:      readOnly,
+		readOnly:    readOnly,
+		BasePath:    uniqueBasePath,
+	}
+	if err := b.loadDictionary(); err != nil {
+		return nil, err
+	}
+	if err := b.loadIndexes(); err != nil {
+		return nil, err
+	}
+	if err := b.loadChangeSeq(); err != nil {
+		return nil, err
+	}
+	if err := b.loadAuditSeq(); err != nil {
+		return nil, err
+	}
+	if err := b.loadRole(); err != nil {
+		return nil, err
+	}
+	if err := b.loadUsage(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.stopNameRepair = cancel
+	b.nameRepairWg.Add(1)
+	go b.runNameRepair(ctx)
+	// the background workers of a read-only bucket could only fail
+	if opts.LifecycleInterval > 0 && !readOnly {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.stopLifecycle = cancel
+		b.lifecycleWg.Add(1)
+		go b.runLifecycle(ctx, opts.LifecycleInterval)
+	}
+	if opts.UsageReconcileInterval > 0 && !readOnly {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.stopUsage = cancel
+		b.usageWg.Add(1)
+		go b.runUsageReconciler(ctx, opts.UsageReconcileInterval)
+	}
+	if opts.StallCheckInterval > 0 && !readOnly {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.stopStall = cancel
+		b.stallWg.Add(1)
+		go b.runStallCheck(ctx, opts.StallCheckInterval)
+	}
+	if opts.GCInterval > 0 && !readOnly {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.stopGC = cancel
+		b.gcWg.Add(1)
+		go b.runGC(ctx, opts.GCInterval)
+	}
+	if opts.BackupInterval > 0 && !readOnly {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.stopBackup = cancel
+		b.backupWg.Add(1)
+		go b.runBackups(ctx, opts.BackupInterval)
+	}
+	if opts.EventDeliveryInterval > 0 && !readOnly {
+		ctx, cancel := context.WithCancel(context.Background())
+		b.stopDelivery = cancel
+		b.deliveryWg.Add(1)
+		go b.runEventDelivery(ctx, opts.EventDeliveryInterval)
+	}
+	return b, nil
+}
+
+// bucketStores returns the options of the bucket stored in
+// uniqueBasePath and the configs of its stores in the order
+// payload, name and meta store. The single layout has only
+// one store. Existing buckets keep the layout they were
+// created with.
+func bucketStores(uniqueBasePath string, opts BucketOptions) (BucketOptions, []storeConfig, error) {
+	if opts.KeyProvider != nil {
+		key, err := opts.KeyProvider.EncryptionKey()
+		if err != nil {
+			return opts, nil, err
+		}
+		opts.EncryptionKey = key
+	}
+	// badger requires an index cache for encrypted stores
+	if len(opts.EncryptionKey) > 0 && opts.IndexCacheSize == 0 {
+		opts.IndexCacheSize = defaultIndexCacheSize
+	}
+	if isDir(filepath.Join(uniqueBasePath, storeDir)) {
+		opts.Layout = LayoutSingle
+	} else if isDir(filepath.Join(uniqueBasePath, dataDir)) {
+		opts.Layout = LayoutSeparate
+	}
+	var configs []storeConfig
+	if opts.Layout == LayoutSingle {
+		opts.overwriteDataDir(filepath.Join(uniqueBasePath, storeDir))
+		configs = []storeConfig{{name: singleStore, opts: opts.toBadgerOpts()}}
+	} else {
+		payloadDataDir := filepath.Join(uniqueBasePath, dataDir)
+		opts.overwriteDataDir(payloadDataDir)
+		configs = []storeConfig{
+			{name: payloadStore, opts: opts.toBadgerOpts()},
+			{name: nameStore, opts: opts.storeOpts(filepath.Join(uniqueBasePath, nameDir))},
+			{name: metaStore, opts: opts.storeOpts(filepath.Join(uniqueBasePath, metaDir))},
+		}
+	}
+	for i := range configs {
+		configs[i].open = opts.OpenStore
+	}
+	withEventLogger(opts, configs)
+	return opts, configs, nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Execute executes the query. A get query returns the matching
+// objects and a delete query the deleted objects without their
+// payload. See Query.DryRun.
+func (b *Bucket) Execute(q *Query) ([]*Object, error) {
+	op := b.startOp("Execute")
+	op.Query = q.String()
+	defer b.finishOp(op)
+	if err := q.isValid(b.opts.OwnerValidator); err != nil {
+		return nil, err
+	}
+	if q.op == OperationGet {
+		return b.Get(q)
+	}
+	return b.deleteMatching(q)
+}
+
+func (b *Bucket) GetByID(id string) (*Object, error) {
+	op := b.startOp("GetByID", id)
+	defer b.finishOp(op)
+	obj, err := b.composeObjectByID(id)
+	if err != nil {
+		return nil, err
+	}
+	op.Size = int64(obj.pl.Len())
+	return obj, b.afterGet(obj)
+}
+
+// Head returns the object with the given id without loading
+// its payload. The size, etag and all other meta data of the
+// object are available but the payload is empty.
+func (b *Bucket) Head(id string) (*Object, error) {
+	defer b.finishOp(b.startOp("Head", id))
+	meta, err := b.GetMeta(id)
+	if err != nil {
+		return nil, err
+	}
+	obj := &Object{meta: meta, pl: new(bytes.Buffer)}
+	obj.markAsImmutable()
+	return obj, b.afterGet(b.composeLazyObject(meta))
+}
+
+func (b *Bucket) GetByName(name, owner string) (*Object, error) {
+	op := b.startOp("GetByName")
+	defer b.finishOp(op)
+	id, gen, err := b.resolveName(name, owner)
+	if err != nil {
+		return nil, err
+	}
+	op.IDs = []string{id}
+	obj, err := b.composeObjectByID(id)
+	if err != nil {
+		return nil, err
+	}
+	op.Size = int64(obj.pl.Len())
+	// the name entry belongs to another incarnation
+	if gen != "" && obj.meta.Get(MetaKeyGeneration) != gen {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	return obj, b.afterGet(obj)
+}
+
+func (b *Bucket) Get(q *Query) ([]*Object, error) {
+	objs, _, err := b.queryObjs(q)
+	return objs, err
+}
+
+// queryObjs returns the objects of the requested page of the
+// objects matching the query and the cursor of the next page.
+func (b *Bucket) queryObjs(q *Query) ([]*Object, string, error) {
+	var errs map[string]error
+	if q.partial {
+		errs = make(map[string]error)
+	}
+	ids, next, err := b.queryIDs(q, errs, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	objs, err := b.idsToObjs(ids, errs)
+	if err := b.afterGet(objs...); err != nil {
+		return nil, "", err
+	}
+	return objs, next, err
+}
+
+// Create inserts the given object into the storage.
+// If you have to create multiple objects use
+// `BatchCreate` which is more performant than
+// multiple calls to Create. If objects with the
+// same name and owner are created concurrently
+// only one succeeds and the others are failing
+// with ErrDuplicateName.
+func (b *Bucket) Create(obj *Object) error {
+	return b.CreateWithOptions(obj, CreateOptions{})
+}
+
+// CreateWithOptions is like Create but allows
+// to set options for the object e.g. the size
+// of the payload chunks.
+func (b *Bucket) CreateWithOptions(obj *Object, opts CreateOptions) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	op := b.startOp("Create", obj.ID())
+	op.Size = int64(obj.pl.Len())
+	defer b.finishOp(op)
+	if opts.Pending {
+		obj.meta.set(MetaKeyModeration, string(ModerationPending))
+	}
+	if opts.TTL > 0 {
+		obj.meta.set(MetaKeyExpiresAt, formatTime(time.Now().Add(opts.TTL)))
+	}
+	if opts.Plaintext {
+		obj.meta.set(MetaKeyPlaintext, "true")
+	}
+	if err := b.beforeCreate(obj); err != nil {
+		return err
+	}
+	entries, err := b.createObjectEntries(obj, opts)
+	if err != nil {
+		return err
+	}
+	release, err := b.reserveQuota(b.usageOf(obj.meta))
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := b.insertPayload(entries); err != nil {
+		return err
+	}
+	if err := b.reserveNames(obj.meta); err != nil {
+		return errors.Join(err, b.deletePayload(obj.ID()))
+	}
+	if err := b.writeMeta(obj.ID(), obj.meta); err != nil {
+		return errors.Join(err, b.releaseNames(obj.meta))
+	}
+	if err := b.metaInserted(obj.ID(), obj.meta); err != nil {
+		return err
+	}
+	obj.markAsImmutable()
+	b.afterCreate(obj)
+	return nil
+}
+
+// CreateIfAbsent inserts the given object iff no object
+// with the same name exists for the owner of the object.
+// Otherwise an error wrapping ErrPreconditionFailed and
+// ErrDuplicateName will be returned. The name is reserved
+// atomically so only one of concurrent calls succeeds.
+func (b *Bucket) CreateIfAbsent(obj *Object) error {
+	err := b.Create(obj)
+	if errors.Is(err, ErrDuplicateName) {
+		return fmt.Errorf("%w: %w", ErrPreconditionFailed, err)
+	}
+	return err
+}
+
+// BatchCreate inserts multiple objects in an efficient way.
+// The objects are validated and marshaled in parallel by
+// BatchConcurrency workers but are written in the order of
+// objs. If any object is invalid no object will be inserted
+// and the error of the first invalid object (in the order
+// of objs) will be returned.
+func (b *Bucket) BatchCreate(objs []*Object) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	op := b.startOp("BatchCreate")
+	for _, obj := range objs {
+		op.IDs = append(op.IDs, obj.ID())
+		op.Size += int64(obj.pl.Len())
+	}
+	defer b.finishOp(op)
+	if err := b.beforeCreate(objs...); err != nil {
+		return err
+	}
+	if err := b.hasDuplicateNames(objs); err != nil {
+		return err
+	}
+	entries, err := b.createBatchEntries(objs, CreateOptions{})
+	if err != nil {
+		return err
+	}
+	deltas := make([]Usage, 0, len(objs))
+	for _, obj := range objs {
+		deltas = append(deltas, b.usageOf(obj.meta))
+	}
+	release, err := b.reserveQuota(deltas...)
+	if err != nil {
+		return err
+	}
+	defer release()
+	payloadWb := b.payload.NewWriteBatch()
+	defer payloadWb.Cancel()
+	metaWb := b.meta.NewWriteBatch()
+	defer metaWb.Cancel()
+	metas := make([]*Metadata, 0, len(objs))
+	for i, obj := range objs {
+		for _, e := range entries[i].payload {
+			if err := payloadWb.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		if err := metaWb.Set([]byte(obj.ID()), entries[i].meta); err != nil {
+			return err
+		}
+		metas = append(metas, obj.meta)
+	}
+	// the meta data is flushed last because
+	// objects are only visible to queries
+	// after their meta data is persisted.
+	if err := payloadWb.Flush(); err != nil {
+		return err
+	}
+	if err := b.reserveNames(metas...); err != nil {
+		errs := []error{err}
+		for _, obj := range objs {
+			errs = append(errs, b.deletePayload(obj.ID()))
+		}
+		return errors.Join(errs...)
+	}
+	err = metaWb.Flush()
+	b.cache.invalidate(op.IDs...)
+	if err != nil {
+		// the batch may be flushed partially
+		errs := []error{err, b.releaseNames(metas...)}
+		for _, obj := range objs {
+			if err := b.deleteMeta(obj.ID()); !errors.Is(err, ErrObjectNotFound) {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+	if err := b.addUsage(deltas...); err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		obj.markAsImmutable()
+	}
+	if err := b.recordChanges(EventCreated, metas...); err != nil {
+		return err
+	}
+	b.afterCreate(objs...)
+	return nil
+}
+
+// Delete deletes all objects matching the query and returns the
+// number of deleted objects. If the query is a dry run no object
+// is deleted and the number of objects which would be deleted is
+// returned instead. Unlike Execute the memory usage is bounded
+// regardless of the number of deleted objects. See SpillThreshold.
+func (b *Bucket) Delete(q *Query) (int, error) {
+	n := 0
+	err := b.deleteEach(q, func(*Metadata) {
+		n++
+	})
+	return n, err
+}
+
+// deleteMatching deletes all objects matching the query and returns
+// them without their payload. If the query is a dry run the objects
+// are only returned. If an error occurs the objects deleted so far
+// are returned.
+func (b *Bucket) deleteMatching(q *Query) ([]*Object, error) {
+	objs := make([]*Object, 0)
+	err := b.deleteEach(q, func(meta *Metadata) {
+		obj := &Object{meta: meta, pl: new(bytes.Buffer)}
+		obj.markAsImmutable()
+		objs = append(objs, obj)
+	})
+	return objs, err
+}
+
+// deleteEach deletes all objects matching the query and calls
+// fn with the meta data of every deleted object. If the query
+// is a dry run fn is called without deleting the objects.
+func (b *Bucket) deleteEach(q *Query, fn func(meta *Metadata)) error {
+	if !q.dryRun {
+		done, err := b.beginWrite()
+		if err != nil {
+			return err
+		}
+		defer done()
+	}
+	spool, err := b.spoolMatchingIDs(q)
+	if err != nil {
+		return err
+	}
+	defer spool.Close()
+	return spool.each(func(id string) error {
+		meta, err := b.GetMeta(id)
+		// the object was deleted concurrently
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !q.dryRun {
+			err := b.deleteObject(meta)
+			if errors.Is(err, ErrObjectNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+		fn(meta)
+		return nil
+	})
+}
+
+func (b *Bucket) GetPayload(id string) ([]byte, error) {
+	meta, err := b.GetMeta(id)
+	if err != nil {
+		return nil, err
+	}
+	return b.readPayload(meta)
+}
+
+// ViewPayload calls fn with the payload of the object in order
+// without copying it. Payloads stored in chunks are passed chunk
+// by chunk and payloads stored in files in parts of 1 MiB.
+// Compressed or encrypted payloads are decoded first and passed
+// at once. The slice is only valid during the call of fn and must
+// not be modified or retained e.g. like io.Writer. If the object
+// was deleted or replaced while it was passed to fn the error
+// wraps ErrObjectNotFound and the passed data is invalid.
+func (b *Bucket) ViewPayload(id string, fn func(chunk []byte) error) error {
+	op := b.startOp("ViewPayload", id)
+	defer b.finishOp(op)
+	meta, err := b.GetMeta(id)
+	if err != nil {
+		return err
+	}
+	op.Size, _ = strconv.ParseInt(meta.Get(MetaKeySize), 10, 64)
+	if err := b.afterGet(b.composeLazyObject(meta)); err != nil {
+		return err
+	}
+	return b.viewPayload(meta, fn)
+}
+
+// viewObject is like ViewPayload for an object returned
+// by Head without passing it to the hooks again.
+func (b *Bucket) viewObject(obj *Object, fn func(chunk []byte) error) error {
+	op := b.startOp("ViewPayload", obj.ID())
+	op.Size = obj.Size()
+	defer b.finishOp(op)
+	return b.viewPayload(obj.meta, fn)
+}
+
+// viewPayload is like ViewPayload
+// for the object described by meta.
+func (b *Bucket) viewPayload(meta *Metadata, fn func(chunk []byte) error) error {
+	if pl, ok := b.cache.payload(meta); ok {
+		return fn(pl)
+	}
+	id := meta.Get(MetaKeyID)
+	if isCold(meta) && !isEncoded(meta) {
+		if err := b.viewRemote(id, fn); err != nil {
+			return err
+		}
+		return b.checkGeneration(meta)
+	}
+	if isCold(meta) {
+		pl, err := b.readPayload(meta)
+		if err != nil {
+			return err
+		}
+		if err := fn(pl); err != nil {
+			return err
+		}
+		return b.checkGeneration(meta)
+	}
+	err := b.payload.View(func(txn *storeTxn) error {
+		hash, blob, err := blobOf(txn, id)
+		if err != nil {
+			return err
+		}
+		if !isEncoded(meta) {
+			if blob {
+				return b.viewBlob(hash, fn)
+			}
+			return viewChunks(txn, id, fn)
+		}
+		var data []byte
+		if blob {
+			data, err = b.readBlob(hash)
+		} else {
+			data, err = readChunks(txn, id)
+		}
+		if err != nil {
+			return err
+		}
+		pl, err := b.decodePayload(meta, data)
+		if err != nil {
+			return err
+		}
+		return fn(pl)
+	})
+	if err != nil {
+		return notFound(err)
+	}
+	return b.checkGeneration(meta)
+}
+
+func (b *Bucket) GetMeta(id string) (*Metadata, error) {
+	if meta, ok := b.cache.meta(id); ok {
+		return meta, nil
+	}
+	ticket := b.cache.ticket()
+	meta, err := b.readMeta(id)
+	if err != nil {
+		return meta, err
+	}
+	b.cache.putMeta(ticket, meta)
+	return meta, nil
+}
+
+// readMeta reads the meta data of the object
+// from the meta store bypassing the cache.
+func (b *Bucket) readMeta(id string) (*Metadata, error) {
+	meta := NewMetadata()
+	err := b.meta.View(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(id))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return b.unmarshalMeta(meta, val)
+		})
+	})
+	return meta, notFound(err)
+}
+
+// UpdateMeta merges the given user defined meta data
+// into the meta data of the object with the given id.
+// System meta data like MetaKeyID will be ignored.
+func (b *Bucket) UpdateMeta(id string, patch map[MetaKey]string) error {
+	return b.updateMetaAs("", id, patch)
+}
+
+// updateMetaAs is UpdateMeta recording the actor in the audit log.
+func (b *Bucket) updateMetaAs(actor, id string, patch map[MetaKey]string) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	defer b.finishOp(b.startOp("UpdateMeta", id))
+	return b.updateMeta(id, func(meta *Metadata) error {
+		if err := checkRetention(meta); err != nil {
+			return err
+		}
+		meta.Merge(patch)
+		meta.actor = actor
+		return b.validateMeta(meta)
+	})
+}
+
+// UpdateMetaIf is like UpdateMeta but will only update
+// the meta data iff the current etag of the object is
+// equal to expectedETag. Otherwise ErrPreconditionFailed
+// will be returned, allowing optimistic concurrency
+// control for multiple writers.
+func (b *Bucket) UpdateMetaIf(id, expectedETag string, patch map[MetaKey]string) error {
+	return b.updateMetaIfAs("", id, expectedETag, patch)
+}
+
+// updateMetaIfAs is UpdateMetaIf recording the actor in the audit log.
+func (b *Bucket) updateMetaIfAs(actor, id, expectedETag string, patch map[MetaKey]string) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	defer b.finishOp(b.startOp("UpdateMetaIf", id))
+	return b.updateMeta(id, func(meta *Metadata) error {
+		if err := checkRetention(meta); err != nil {
+			return err
+		}
+		if meta.Get(MetaKeyETag) != expectedETag {
+			return ErrPreconditionFailed
+		}
+		meta.Merge(patch)
+		meta.actor = actor
+		return b.validateMeta(meta)
+	})
+}
+
+// PatchPayload writes data into the payload of the object
+// with the given id starting at offset, similar to
+// io.WriterAt. The payload grows if needed and any gap
+// between the end of the current payload and offset will
+// be filled with zeros. The checksum and etag of the
+// object are updated accordingly.
+func (b *Bucket) PatchPayload(id string, offset int64, data []byte) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	op := b.startOp("PatchPayload", id)
+	op.Size = int64(len(data))
+	defer b.finishOp(op)
+	if offset < 0 {
+		return ErrNegativeOffset
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	// the payload and the meta data are updated in
+	// separate transactions of different stores.
+	defer b.lockPayload(id)()
+	meta, err := b.GetMeta(id)
+	if err != nil {
+		return err
+	}
+	if err := checkRetention(meta); err != nil {
+		return err
+	}
+	if isCold(meta) {
+		return fmt.Errorf("%w: %s", ErrColdObject, id)
+	}
+	if grow := offset + int64(len(data)) - b.sizeOf(meta); grow > 0 {
+		release, err := b.reserveQuota(Usage{
+			Owner:       meta.Get(MetaKeyOwner),
+			ContentType: meta.Get(MetaKeyContentType),
+			Bytes:       grow,
+		})
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	// blob files are immutable because
+	// they are shared by equal payloads.
+	blob, err := b.hasBlob(id)
+	if err != nil {
+		return err
+	}
+	if isEncoded(meta) || blob {
+		return b.patchEncodedPayload(meta, offset, data)
+	}
+	chunkSize := chunkSizeOf(meta)
+	err = b.payload.Update(func(txn *storeTxn) error {
+		if chunkSize > 0 {
+			return patchChunks(txn, id, chunkSize, offset, data)
+		}
+		pl, err := readChunks(txn, id)
+		if err != nil {
+			return err
+		}
+		if end := offset + int64(len(data)); end > int64(len(pl)) {
+			pl = append(pl, make([]byte, end-int64(len(pl)))...)
+		}
+		copy(pl[offset:], data)
+		return txn.Set([]byte(id), pl)
+	})
+	b.cache.invalidate(id)
+	if err != nil {
+		return err
+	}
+	pl, err := b.GetPayload(id)
+	if err != nil {
+		return err
+	}
+	sum := checksum(pl)
+	err = b.updateMeta(id, func(meta *Metadata) error {
+		meta.set(MetaKeyChecksum, sum)
+		meta.set(MetaKeySize, strconv.Itoa(len(pl)))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return b.reindexChecksum(id, meta.Get(MetaKeyChecksum), sum)
+}
+
+// patchEncodedPayload patches the decoded payload and encodes
+// it again. Encrypted payloads are getting a new data key
+// because reusing the nonce of the data key for the patched
+// payload is unsafe.
+func (b *Bucket) patchEncodedPayload(meta *Metadata, offset int64, data []byte) error {
+	pl, err := b.readPayload(meta)
+	if err != nil {
+		return err
+	}
+	if end := offset + int64(len(data)); end > int64(len(pl)) {
+		pl = append(pl, make([]byte, end-int64(len(pl)))...)
+	}
+	copy(pl[offset:], data)
+	sum := checksum(pl)
+	encoded := plaintextMeta(meta)
+	enc, err := b.encodePayload(encoded, pl)
+	if err != nil {
+		return err
+	}
+	id := meta.Get(MetaKeyID)
+	entries, err := b.splitPayload(id, chunkSizeOf(meta), enc)
+	if err != nil {
+		return err
+	}
+	if err := b.replacePayload(id, entries); err != nil {
+		return err
+	}
+	err = b.updateMeta(id, func(meta *Metadata) error {
+		meta.set(MetaKeyChecksum, sum)
+		for _, k := range []MetaKey{MetaKeySize, MetaKeyCompression, MetaKeyDictionary, MetaKeyDataKey, MetaKeyNonce} {
+			if encoded.Has(k) {
+				meta.set(k, encoded.Get(k))
+			} else {
+				meta.del(k)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return b.reindexChecksum(id, meta.Get(MetaKeyChecksum), sum)
+}
+
+func (b *Bucket) DeleteByID(id string) error {
+	return b.deleteByIDAs("", id)
+}
+
+// deleteByIDAs is DeleteByID recording the actor in the audit log.
+func (b *Bucket) deleteByIDAs(actor, id string) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	defer b.finishOp(b.startOp("DeleteByID", id))
+	meta, err := b.GetMeta(id)
+	if err != nil {
+		return err
+	}
+	meta.actor = actor
+	return b.deleteObject(meta)
+}
+
+func (b *Bucket) DeleteByName(name, owner string) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	op := b.startOp("DeleteByName")
+	defer b.finishOp(op)
+	id, gen, err := b.resolveName(name, owner)
+	if err != nil {
+		return err
+	}
+	op.IDs = []string{id}
+	meta, err := b.GetMeta(id)
+	if err != nil {
+		return err
+	}
+	if gen != "" && meta.Get(MetaKeyGeneration) != gen {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	return b.deleteObject(meta)
+}
+
+// Read writes the payload of the object to w without
+// loading it into memory. See ViewPayload.
+func (b *Bucket) Read(id string, w io.Writer) error {
+	return b.ViewPayload(id, func(chunk []byte) error {
+		_, err := w.Write(chunk)
+		return err
+	})
+}
+
+// Shutdown closes the bucket after all in-flight
+// operations are done. See Close.
+func (b *Bucket) Shutdown() error {
+	return b.Close(context.Background())
+}
+
+// stopWorkers stops all background workers
+// and waits until they are stopped.
+func (b *Bucket) stopWorkers() {
+	b.stopPipelines()
+	b.stopReplicators()
+	b.stopWatchers()
+	b.stopNameRepair()
+	b.nameRepairWg.Wait()
+	if b.stopLifecycle != nil {
+		b.stopLifecycle()
+		b.lifecycleWg.Wait()
+	}
+	if b.stopUsage != nil {
+		b.stopUsage()
+		b.usageWg.Wait()
+	}
+	if b.stopDelivery != nil {
+		b.stopDelivery()
+		b.deliveryWg.Wait()
+	}
+	if b.stopStall != nil {
+		b.stopStall()
+		b.stallWg.Wait()
+	}
+	if b.stopBackup != nil {
+		b.stopBackup()
+		b.backupWg.Wait()
+	}
+	// the garbage collection has to be stopped
+	// before the stores are closed
+	if b.stopGC != nil {
+		b.stopGC()
+		b.gcWg.Wait()
+	}
+}
+
+// closeStores closes the dictionaries and the stores.
+func (b *Bucket) closeStores() error {
+	if err := b.closeDictionaries(); err != nil {
+		return err
+	}
+	if err := b.payload.Close(); err != nil {
+		return err
+	}
+	if err := b.meta.Close(); err != nil {
+		return err
+	}
+	return b.name.Close()
+}
+
+func (b *Bucket) getMatchingIDs(q *Query) ([]string, error) {
+	ids, _, err := b.queryIDs(q, nil, nil)
+	return ids, err
+}
+
+// queryIDs returns the ids of the requested page of the objects
+// matching the query and the cursor of the next page which is
+// empty if there is no next page. If errs is not nil objects
+// whose meta data can't be decoded are skipped and recorded
+// in errs instead of failing the query. If emit is not nil and the
+// query is streamable, the ids are passed to emit in the order of
+// the scan instead of being collected and no ids are returned.
+func (b *Bucket) queryIDs(q *Query, errs map[string]error, emit func(id string) error) ([]string, string, error) {
+	cur, err := q.cursor()
+	if err != nil {
+		return nil, "", err
+	}
+	iterOpts := b.opts.QueryIterator
+	if q.iterOpts != nil {
+		iterOpts = *q.iterOpts
+	}
+	// undecodable meta data has to be detected for partial results
+	keysOnly := q.keysOnly() && errs == nil
+	if keysOnly {
+		iterOpts.PrefetchValues = false
+	}
+	matches := make([]match, 0)
+	expr := q.expr()
+	// without ordering the iteration can be stopped as soon as
+	// the requested page and one more match are found which is
+	// used to determine if a next page exists.
+	pageEnd := -1
+	if q.orderBy == "" && q.limit > 0 {
+		pageEnd = q.offset + q.limit + 1
+	}
+	ctx := q.context()
+	var errsMu sync.Mutex
+	// decode is called concurrently by the workers of a parallel scan
+	decode := func(item *storeItem) (match, bool, error) {
+		id := string(item.KeyCopy(nil))
+		// unordered results are ordered by id
+		if cur != nil && q.orderBy == "" && id <= cur.ID {
+			return match{}, false, nil
+		}
+		if keysOnly {
+			meta := NewMetadata()
+			meta.set(MetaKeyID, id)
+			return match{id: id, value: meta.Get(q.orderBy)}, meta.Compare(expr), nil
+		}
+		var m match
+		var ok bool
+		err := item.Value(func(val []byte) error {
+			meta := NewMetadata()
+			if err := b.decodeMeta(meta, val); errs != nil && err != nil {
+				errsMu.Lock()
+				defer errsMu.Unlock()
+				errs[id] = err
+				return nil
+			} else if err != nil {
+				return err
+			}
+			m, ok = match{id: id, value: meta.Get(q.orderBy)}, meta.Compare(expr)
+			return nil
+		})
+		return m, ok, err
+	}
+	visit := func(item *storeItem) error {
+		m, ok, err := decode(item)
+		if ok {
+			matches = append(matches, m)
+		}
+		return err
+	}
+	streaming := emit != nil && q.streamable()
+	if streaming {
+		visit = func(item *storeItem) error {
+			m, ok, err := decode(item)
+			if ok && err == nil {
+				err = emit(m.id)
+			}
+			return err
+		}
+	}
+	var start []byte
+	if cur != nil && q.orderBy == "" {
+		start = []byte(cur.ID)
+	}
+	scan := func(txn *storeTxn) error {
+		it := txn.NewIterator(iterOpts)
+		defer it.Close()
+		for it.Seek(start); it.Valid() && len(matches) != pageEnd; it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := visit(it.Item()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	run := func() error {
+		return b.meta.View(scan)
+	}
+	if workers := b.opts.ScanConcurrency; workers > 1 && pageEnd < 0 && !streaming {
+		run = func() error {
+			var err error
+			matches, err = b.scanParallel(ctx, workers, start, iterOpts, decode)
+			return err
+		}
+	}
+	// the index only narrows down the candidates which are
+	// compared like all objects because the index entries
+	// of concurrent writes may not be up to date yet.
+	if prefix, ok := b.indexLookup(expr); ok {
+		candidates, err := b.indexedIDs(prefix)
+		if err != nil {
+			return nil, "", err
+		}
+		run = func() error {
+			return b.meta.View(scan)
+		}
+		scan = func(txn *storeTxn) error {
+			for _, id := range candidates {
+				if len(matches) == pageEnd {
+					return nil
+				}
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				item, err := txn.Get([]byte(id))
+				if errors.Is(err, ErrStoreKeyNotFound) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				if err := visit(item); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if err := run(); err != nil {
+		return nil, "", err
+	}
+	if streaming {
+		return nil, "", nil
+	}
+	if q.orderBy != "" {
+		// equal values are ordered by id because the
+		// matches are found in the order of their ids.
+		sort.SliceStable(matches, func(i, j int) bool {
+			c := compareMetaValues(q.orderBy, matches[i].value, matches[j].value)
+			if q.order == Desc {
+				return c > 0
+			}
+			return c < 0
+		})
+		if cur != nil {
+			matches = q.after(cur, matches)
+		}
+	}
+	if q.offset >= len(matches) {
+		return []string{}, "", nil
+	}
+	matches = matches[q.offset:]
+	var next string
+	if q.limit > 0 && q.limit < len(matches) {
+		matches = matches[:q.limit]
+		next = q.encodeCursor(matches[len(matches)-1])
+	}
+	ids := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.id)
+	}
+	return ids, next, nil
+}
+
+// forEachID calls fn for the id of every object
+// in the store until fn returns an error or the
+// context is done.
+func (b *Bucket) forEachID(ctx context.Context, fn func(id string) error) error {
+	return b.meta.View(func(txn *storeTxn) error {
+		opts := defaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(string(it.Item().KeyCopy(nil))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// idsToObjs returns the lazily loaded objects with the ids. If
+// errs is not nil the objects which can't be read are skipped
+// and a PartialError is returned along with the other objects.
+func (b *Bucket) idsToObjs(ids []string, errs map[string]error) ([]*Object, error) {
+	objs := make([]*Object, 0, len(ids))
+	for _, id := range ids {
+		meta, err := b.GetMeta(id)
+		if errs != nil && err != nil {
+			errs[id] = err
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, b.composeLazyObject(meta))
+	}
+	return partialResult(objs, errs)
+}
+
+func (b *Bucket) isIDExisting(id string) bool {
+	err := b.meta.View(func(txn *storeTxn) error {
+		_, err := txn.Get([]byte(id))
+		return err
+	})
+	return !errors.Is(err, ErrStoreKeyNotFound)
+}
+
+func (b *Bucket) isNameExisting(name, owner string) bool {
+	err := b.name.View(func(txn *storeTxn) error {
+		_, err := txn.Get([]byte(b.nameFormat(name, owner)))
+		return err
+	})
+	return !errors.Is(err, ErrStoreKeyNotFound)
+}
+
+// insertName inserts the name, the checksum index entry and
+// the index entries of the object. The entries are expiring
+// with the object.
+func (b *Bucket) insertName(meta *Metadata) error {
+	return b.name.Update(func(txn *storeTxn) error {
+		return b.setNameEntries(txn, meta)
+	})
+}
+
+// reserveNames inserts the name entries of the objects like
+// insertName iff none of the names exists for the owner. The
+// check and the insert are done in one transaction so only one
+// of concurrent creates of the same name succeeds. Badger aborts
+// the commit of the others with ErrConflict and the reservation
+// is retried to report ErrDuplicateName.
+func (b *Bucket) reserveNames(metas ...*Metadata) error {
+	var err error
+	for i := 0; i < nameReservationRetries; i++ {
+		err = b.name.Update(func(txn *storeTxn) error {
+			for _, meta := range metas {
+				name, owner := meta.Get(MetaKeyName), meta.Get(MetaKeyOwner)
+				_, err := txn.Get([]byte(b.nameFormat(name, owner)))
+				if err == nil {
+					return fmt.Errorf("%w: %s of %s", ErrDuplicateName, name, owner)
+				}
+				if !errors.Is(err, ErrStoreKeyNotFound) {
+					return err
+				}
+				if err := b.setNameEntries(txn, meta); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if !errors.Is(err, ErrStoreConflict) {
+			return err
+		}
 	}
-	return b, nil
+	return err
 }
 
-func (b Bucket) Execute(q *Query) ([]*Object, error) {
-	if err := q.isValid(); err != nil {
-		return nil, err
+// setNameEntries sets the name, the checksum index entry
+// and the index entries of the object in the transaction.
+func (b *Bucket) setNameEntries(txn *storeTxn, meta *Metadata) error {
+	name := newEntry([]byte(b.nameFormat(meta.Get(MetaKeyName), meta.Get(MetaKeyOwner))), nameValue(meta))
+	if err := txn.SetEntry(withExpiry(name, meta)); err != nil {
+		return err
 	}
-	// empty object array for operation which
-	// do not return any objects
-	var defaultRes []*Object
-	if q.op == OperationGet {
-		return b.Get(q)
+	for _, k := range b.indexEntries(meta) {
+		if err := txn.SetEntry(withExpiry(newEntry(k, nil), meta)); err != nil {
+			return err
+		}
 	}
-	return defaultRes, b.Delete(q)
+	sum := newEntry(checksumKey(meta.Get(MetaKeyChecksum), meta.Get(MetaKeyID)), nil)
+	return txn.SetEntry(withExpiry(sum, meta))
 }
 
-func (b Bucket) GetByID(id string) (*Object, error) {
-	return b.composeObjectByID(id)
+// releaseNames deletes the names and the payloads of objects
+// whose meta data couldn't be written after reserveNames.
+func (b *Bucket) releaseNames(metas ...*Metadata) error {
+	errs := make([]error, 0, 2*len(metas))
+	for _, meta := range metas {
+		errs = append(errs, b.deleteName(meta), b.deletePayload(meta.Get(MetaKeyID)))
+	}
+	return errors.Join(errs...)
 }
 
-func (b Bucket) GetByName(name, owner string) (*Object, error) {
-	id, err := b.getIDByName(name, owner)
-	if err != nil {
-		return nil, err
+// insertMeta inserts the meta data of the object, adds
+// it to the usage of the owner, records the change in
+// the change feed and notifies OnModeration if the
+// object is pending.
+func (b *Bucket) insertMeta(id string, meta *Metadata) error {
+	if err := b.writeMeta(id, meta); err != nil {
+		return err
 	}
-	return b.GetByID(id)
+	return b.metaInserted(id, meta)
 }
 
-func (b Bucket) Get(q *Query) ([]*Object, error) {
-	ids, err := b.getMatchingIDs(q)
+// writeMeta writes the meta data of the object making it
+// visible. See insertMeta.
+func (b *Bucket) writeMeta(id string, meta *Metadata) error {
+	err := b.meta.Update(func(txn *storeTxn) error {
+		data, err := b.marshalMeta(meta)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(withExpiry(newEntry([]byte(id), data), meta))
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
-	return b.idsToObjs(ids)
+	b.cache.invalidate(id)
+	return nil
 }
 
-// Create inserts the given object into the storage.
-// If you have to create multiple objects use
-// `BatchCreate` which is more performant than
-// multiple calls to Create.
-func (b Bucket) Create(obj *Object) error {
-	e, err := b.createObjectEntry(obj)
-	if err != nil {
+// metaInserted updates the expiry marker and the usage, records
+// the change and notifies OnModeration after the meta data of the
+// object was inserted.
+func (b *Bucket) metaInserted(id string, meta *Metadata) error {
+	if err := b.updateExpiryMarker(NewMetadata(), meta); err != nil {
 		return err
 	}
-	if err := b.insertPayload(string(e.Key), e.Value); err != nil {
+	if err := b.addUsage(b.usageOf(meta)); err != nil {
 		return err
 	}
-	if err := b.insertName(obj.Name(), obj.Owner(), obj.ID()); err != nil {
+	if err := b.recordChanges(EventCreated, meta); err != nil {
 		return err
 	}
-	if err := b.insertMeta(obj.ID(), obj.meta); err != nil {
-		return err
+	if ModerationState(meta.Get(MetaKeyModeration)) == ModerationPending {
+		b.notifyModeration(ModerationEvent{
+			ID:    id,
+			Owner: meta.Get(MetaKeyOwner),
+			To:    ModerationPending,
+			At:    time.Now(),
+		})
 	}
-	obj.markAsImmutable()
 	return nil
 }
 
-// BatchCreate inserts multiple objects in an efficient way.
-func (b Bucket) BatchCreate(objs []*Object) error {
+func (b *Bucket) insertPayload(entries []*Entry) error {
 	wb := b.payload.NewWriteBatch()
 	defer wb.Cancel()
-	for _, obj := range objs {
-		e, err := b.createObjectEntry(obj)
-		if err != nil {
-			return err
-		}
+	for _, e := range entries {
 		if err := wb.SetEntry(e); err != nil {
 			return err
 		}
-		if err := b.insertName(obj.Name(), obj.Owner(), obj.ID()); err != nil {
-			return err
-		}
-		if err := b.insertMeta(obj.ID(), obj.meta); err != nil {
-			return err
-		}
-		obj.markAsImmutable()
 	}
 	return wb.Flush()
 }
 
-func (b Bucket) Delete(q *Query) error {
-	ids, err := b.getMatchingIDs(q)
-	if err != nil {
-		return nil
-	}
-	for _, id := range ids {
-		if err := b.DeleteByID(id); err != nil {
-			return err
-		}
-	}
-	return nil
+// lockPayload locks the payload of the object for a rewrite
+// and returns the function to unlock it. The locks are striped
+// by the id so rewrites of different objects rarely wait.
+func (b *Bucket) lockPayload(id string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	mu := &b.payloadMu[h.Sum32()%payloadLockStripes]
+	mu.Lock()
+	return mu.Unlock
 }
 
-func (b Bucket) GetPayload(id string) ([]byte, error) {
-	var payload []byte
-	err := b.payload.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(id))
+// replacePayload writes the entries as the new payload of
+// the object with the given id and removes all chunks of the
+// previous payload which aren't overwritten by the entries.
+func (b *Bucket) replacePayload(id string, entries []*Entry) error {
+	keep := make(map[string]bool, len(entries))
+	next := ""
+	for _, e := range entries {
+		keep[string(e.Key)] = true
+		if string(e.Key) == string(blobKey(id)) {
+			next = string(e.Value)
+		}
+	}
+	var stale [][]byte
+	var released string
+	err := b.payload.View(func(txn *storeTxn) error {
+		keys, hash, err := releaseBlob(txn, id, next)
 		if err != nil {
 			return err
 		}
-		dst := make([]byte, item.ValueSize())
-		if _, err := item.ValueCopy(dst); err != nil {
-			return err
+		stale, released = keys, hash
+		opts := defaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := chunkPrefix(id)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if k := it.Item().KeyCopy(nil); !keep[string(k)] {
+				stale = append(stale, k)
+			}
 		}
-		payload = dst
 		return nil
 	})
-	return payload, err
+	if err != nil {
+		return err
+	}
+	wb := b.payload.NewWriteBatch()
+	defer wb.Cancel()
+	for _, k := range stale {
+		if err := wb.Delete(k); err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		if err := wb.SetEntry(e); err != nil {
+			return err
+		}
+	}
+	err = wb.Flush()
+	b.cache.invalidate(id)
+	if err != nil {
+		return err
+	}
+	return b.removeBlob(released)
 }
 
-func (b Bucket) GetMeta(id string) (*Metadata, error) {
+// updateMeta applies mutate to the meta data of the object and
+// recalculates the etag. If mutate returns an error the update
+// is aborted. The read and write of the meta data are happening
+// in the same transaction so concurrent updates will result in
+// a conflict instead of a lost update. The index entries, the
+// usage and the change feed are updated after the meta data
+// is persisted.
+func (b *Bucket) updateMeta(id string, mutate func(*Metadata) error) error {
+	old := NewMetadata()
 	meta := NewMetadata()
-	err := b.meta.View(func(txn *badger.Txn) error {
+	err := b.meta.Update(func(txn *storeTxn) error {
 		item, err := txn.Get([]byte(id))
 		if err != nil {
 			return err
 		}
-		dst := make([]byte, item.ValueSize())
-		if _, err := item.ValueCopy(dst); err != nil {
+		if err := item.Value(func(val []byte) error {
+			return b.unmarshalMeta(old, val)
+		}); err != nil {
+			return err
+		}
+		if err := item.Value(func(val []byte) error {
+			return b.decodeMeta(meta, val)
+		}); err != nil {
 			return err
 		}
-		return meta.Unmarshal(dst)
+		if err := mutate(meta); err != nil {
+			return err
+		}
+		meta.set(MetaKeyUpdatedAt, formatTime(time.Now()))
+		obj := &Object{meta: meta}
+		meta.set(MetaKeyETag, obj.etag())
+		data, err := b.marshalMeta(meta)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(withExpiry(newEntry([]byte(id), data), meta))
 	})
-	return meta, err
-}
-
-func (b Bucket) DeleteByID(id string) error {
-	meta, err := b.GetMeta(id)
 	if err != nil {
-		return err
-	}
-	return b.deleteObject(meta)
-}
-
-func (b Bucket) DeleteByName(name, owner string) error {
-	id, err := b.getIDByName(name, owner)
-	if err != nil {
-		return err
+		return notFound(err)
 	}
-	return b.DeleteByID(id)
-}
-
-func (b Bucket) Read(id string, w io.Writer) error {
-	obj, err := b.GetByID(id)
-	if err != nil {
+	b.cache.invalidate(id)
+	if err := b.reindexMeta(old, meta); err != nil {
 		return err
 	}
-	_, err = io.Copy(w, obj)
-	return err
-}
-
-func (b Bucket) Shutdown() error {
-	if err := b.payload.Close(); err != nil {
+	if err := b.updateExpiryMarker(old, meta); err != nil {
 		return err
 	}
-	if err := b.meta.Close(); err != nil {
+	if err := b.addMetaDelta(old, meta); err != nil {
 		return err
 	}
-	return b.name.Close()
+	return b.recordChanges(EventUpdated, meta)
 }
 
-func (b Bucket) getMatchingIDs(q *Query) ([]string, error) {
-	const prefetchSize = 10
-	ids := make([]string, 0, prefetchSize)
-	err := b.meta.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchSize = prefetchSize
-		it := txn.NewIterator(opts)
-		defer it.Close()
-
-		for it.Rewind(); it.Valid(); it.Next() {
-			err := it.Item().Value(func(val []byte) error {
-				meta := NewMetadata()
-				if err := meta.Unmarshal(val); err != nil {
-					return err
-				}
-				if meta.Compare(q.params, q.act) {
-					dst := make([]byte, it.Item().KeySize())
-					it.Item().KeyCopy(dst)
-					ids = append(ids, string(dst))
-				}
-				return nil
-			})
-			if err != nil {
+// deleteName deletes the checksum index entry, the index entries
+// and the name entry of the object iff it belongs to the same
+// incarnation of the object. Otherwise the name was already taken
+// by a new object.
+func (b *Bucket) deleteName(meta *Metadata) error {
+	key := []byte(b.nameFormat(meta.Get(MetaKeyName), meta.Get(MetaKeyOwner)))
+	return b.name.Update(func(txn *storeTxn) error {
+		if err := txn.Delete(checksumKey(meta.Get(MetaKeyChecksum), meta.Get(MetaKeyID))); err != nil {
+			return err
+		}
+		for _, k := range b.indexEntries(meta) {
+			if err := txn.Delete(k); err != nil {
 				return err
 			}
 		}
-		return nil
-	})
-	return ids, err
-}
-
-func (b Bucket) idsToObjs(ids []string) ([]*Object, error) {
-	objs := make([]*Object, 0, len(ids))
-	for _, id := range ids {
-		obj, err := b.composeObjectByID(id)
-		if err != nil {
-			return nil, err
+		item, err := txn.Get(key)
+		if errors.Is(err, ErrStoreKeyNotFound) {
+			return nil
 		}
-		objs = append(objs, obj)
-	}
-	return objs, nil
-}
-
-func (b Bucket) isNameExisting(name, owner string) bool {
-	err := b.name.View(func(txn *badger.Txn) error {
-		_, err := txn.Get([]byte(b.nameFormat(name, owner)))
-		return err
-	})
-	return !errors.Is(err, badger.ErrKeyNotFound)
-}
-
-func (b Bucket) insertName(name, owner, id string) error {
-	return b.name.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(b.nameFormat(name, owner)), []byte(id))
-	})
-}
-
-func (b Bucket) insertMeta(id string, meta *Metadata) error {
-	return b.meta.Update(func(txn *badger.Txn) error {
-		data, err := meta.Marshal()
 		if err != nil {
 			return err
 		}
-		return txn.Set([]byte(id), data)
+		var id, gen string
+		if err := item.Value(func(val []byte) error {
+			id, gen = parseNameValue(val)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if id != meta.Get(MetaKeyID) || (gen != "" && gen != meta.Get(MetaKeyGeneration)) {
+			return nil
+		}
+		return txn.Delete(key)
 	})
 }
 
-func (b Bucket) insertPayload(id string, pl []byte) error {
-	return b.payload.Update(func(txn *badger.Txn) error {
-		return txn.Set([]byte(id), pl)
-	})
+// deleteMeta deletes the meta data of the object. The meta data
+// is read in the same transaction so only one of concurrent
+// deletions succeeds and the others fail with ErrObjectNotFound.
+func (b *Bucket) deleteMeta(id string) error {
+	err := deleteExisting(b.meta, []byte(id))
+	b.cache.invalidate(id)
+	return notFound(err)
 }
 
-func (b Bucket) deleteName(name, owner string) error {
-	return b.name.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(b.nameFormat(name, owner)))
-	})
+// deleteExisting deletes the key of the store and returns an error
+// wrapping ErrStoreKeyNotFound if it doesn't exist. A deletion which
+// conflicts with a concurrent write of the key is retried.
+func deleteExisting(s *store, key []byte) error {
+	var err error
+	for i := 0; i < deleteRetries; i++ {
+		err = s.Update(func(txn *storeTxn) error {
+			if _, err := txn.Get(key); err != nil {
+				return err
+			}
+			return txn.Delete(key)
+		})
+		if !errors.Is(err, ErrStoreConflict) {
+			return err
+		}
+	}
+	return err
 }
 
-func (b Bucket) deleteMeta(id string) error {
-	return b.meta.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(id))
+// deletePayload deletes all chunks of the payload, the
+// single value of payloads stored without chunks and the
+// blob file if no other object is referencing it.
+func (b *Bucket) deletePayload(id string) error {
+	keys := [][]byte{[]byte(id)}
+	var released string
+	err := b.payload.View(func(txn *storeTxn) error {
+		blobKeys, hash, err := releaseBlob(txn, id, "")
+		if err != nil {
+			return err
+		}
+		keys, released = append(keys, blobKeys...), hash
+		opts := defaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := chunkPrefix(id)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	wb := b.payload.NewWriteBatch()
+	defer wb.Cancel()
+	for _, k := range keys {
+		if err := wb.Delete(k); err != nil {
+			return err
+		}
+	}
+	err = wb.Flush()
+	b.cache.invalidate(id)
+	if err != nil {
+		return err
+	}
+	return b.removeBlob(released)
 }
 
-func (b Bucket) deletePayload(id string) error {
-	return b.payload.Update(func(txn *badger.Txn) error {
-		return txn.Delete([]byte(id))
-	})
+// validateMeta validates the meta data using the MetaLimits and
+// the MetaValidator of the bucket. The returned error wraps
+// ErrInvalidMeta.
+func (b *Bucket) validateMeta(meta *Metadata) error {
+	if err := b.opts.MetaLimits.check(meta); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMeta, err)
+	}
+	if b.opts.MetaValidator == nil {
+		return nil
+	}
+	if err := b.opts.MetaValidator(meta); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMeta, err)
+	}
+	return nil
 }
 
-func (b Bucket) nameFormat(name, owner string) string {
+func (b *Bucket) nameFormat(name, owner string) string {
 	// choosing the name format as <name>_<owner> allows
 	// to have unique names in the context of a owner e.g.
 	// owner 1 can have foo_1 and owner 2 can have foo_2
@@ -316,35 +1675,186 @@ func (b Bucket) nameFormat(name, owner string) string {
 	return fmt.Sprintf("%s_%s", name, owner)
 }
 
-// createObjectEntry validates the object and creates a entry.
-func (b Bucket) createObjectEntry(obj *Object) (*badger.Entry, error) {
-	if err := obj.isValid(); err != nil {
+// createObjectEntries validates the object and creates
+// the entries of the payload chunks.
+func (b *Bucket) createObjectEntries(obj *Object, opts CreateOptions) ([]*Entry, error) {
+	b.detectContentType(obj, obj.Payload())
+	if err := obj.isValid(b.opts.OwnerValidator); err != nil {
 		return nil, err
 	}
-	if b.isNameExisting(obj.Name(), obj.Owner()) {
-		return nil, fmt.Errorf("object with the name %s for the owner %s exists", obj.Name(), obj.Owner())
+	if !opts.replace && b.isNameExisting(obj.Name(), obj.Owner()) {
+		return nil, fmt.Errorf("%w: %s of %s", ErrDuplicateName, obj.Name(), obj.Owner())
 	}
 	data, err := obj.Marshal()
 	if err != nil {
 		return nil, err
 	}
-	e := badger.NewEntry([]byte(obj.ID()), data)
-	return e, nil
+	chunkSize := opts.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = b.opts.ChunkSize
+	}
+	if chunkSize <= 0 {
+		chunkSize = int64(len(data))
+	}
+	now := formatTime(time.Now())
+	obj.meta.set(MetaKeyCreatedAt, now)
+	obj.meta.set(MetaKeyUpdatedAt, now)
+	obj.meta.set(MetaKeyGeneration, newGeneration())
+	obj.meta.set(MetaKeyChunkSize, strconv.FormatInt(chunkSize, 10))
+	obj.meta.set(MetaKeyChecksum, checksum(data))
+	obj.meta.set(MetaKeyETag, obj.etag())
+	if err := b.applyRetentionClass(obj.meta); err != nil {
+		return nil, err
+	}
+	if err := b.validateMeta(obj.meta); err != nil {
+		return nil, err
+	}
+	data, err = b.encodePayload(obj.meta, data)
+	if err != nil {
+		return nil, err
+	}
+	return b.splitPayload(obj.ID(), chunkSize, data)
+}
+
+// batchEntry is the validated and marshaled
+// representation of an object in a batch.
+type batchEntry struct {
+	payload []*Entry
+	meta    []byte
+}
+
+// createBatchEntries creates the entries of all objects
+// using a pool of BatchConcurrency workers. The returned
+// entries have the same order as objs.
+func (b *Bucket) createBatchEntries(objs []*Object, opts CreateOptions) ([]batchEntry, error) {
+	workers := b.opts.BatchConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	entries := make([]batchEntry, len(objs))
+	errs := make([]error, len(objs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				entries[i], errs[i] = b.createBatchEntry(objs[i], opts)
+			}
+		}()
+	}
+	for i := range objs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func (b *Bucket) createBatchEntry(obj *Object, opts CreateOptions) (batchEntry, error) {
+	e, err := b.createObjectEntries(obj, opts)
+	if err != nil {
+		return batchEntry{}, err
+	}
+	meta, err := b.marshalMeta(obj.meta)
+	if err != nil {
+		return batchEntry{}, err
+	}
+	return batchEntry{payload: e, meta: meta}, nil
+}
+
+// hasDuplicateNames checks if two objects of
+// the batch are sharing the same name and owner.
+func (b *Bucket) hasDuplicateNames(objs []*Object) error {
+	names := make(map[string]struct{}, len(objs))
+	for _, obj := range objs {
+		name := b.nameFormat(obj.Name(), obj.Owner())
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("object with the name %s for the owner %s exists", obj.Name(), obj.Owner())
+		}
+		names[name] = struct{}{}
+	}
+	return nil
 }
 
-func (b Bucket) composeObject(meta *Metadata) (*Object, error) {
+func (b *Bucket) composeObject(meta *Metadata) (*Object, error) {
 	obj := &Object{
 		meta: meta,
 	}
-	pl, err := b.GetPayload(meta.Get(MetaKeyID))
+	if pl, ok := b.cache.payload(meta); ok {
+		return obj, obj.Unmarshal(pl)
+	}
+	ticket := b.cache.ticket()
+	pl, err := b.readPayload(meta)
 	if err != nil {
 		return nil, err
 	}
+	if err := b.checkGeneration(meta); err != nil {
+		return nil, err
+	}
+	// the payload is only cached after the generation was
+	// checked so it is never cached for a stale meta data.
+	b.cache.putPayload(ticket, meta, pl)
 	err = obj.Unmarshal(pl)
 	return obj, err
 }
 
-func (b Bucket) composeObjectByID(id string) (*Object, error) {
+// composeLazyObject returns the object described by meta
+// without its payload which is loaded on the first access.
+// See Object.Load.
+func (b *Bucket) composeLazyObject(meta *Metadata) *Object {
+	return &Object{
+		meta:   meta,
+		pl:     new(bytes.Buffer),
+		bucket: b,
+	}
+}
+
+// readPayload reads the payload of the object
+// described by meta and decodes it if needed.
+func (b *Bucket) readPayload(meta *Metadata) ([]byte, error) {
+	payload, err := b.readStoredPayload(meta)
+	if err != nil {
+		return nil, err
+	}
+	return b.decodePayload(meta, payload)
+}
+
+// readStoredPayload reads the payload of the object described
+// by meta as it is stored i.e. compressed or encrypted.
+func (b *Bucket) readStoredPayload(meta *Metadata) ([]byte, error) {
+	if isCold(meta) {
+		return b.readRemote(meta.Get(MetaKeyID))
+	}
+	var payload []byte
+	err := b.payload.View(func(txn *storeTxn) error {
+		id := meta.Get(MetaKeyID)
+		if hash, ok, err := blobOf(txn, id); err != nil || ok {
+			if err == nil {
+				payload, err = b.readBlob(hash)
+			}
+			return err
+		}
+		pl, err := readChunks(txn, id)
+		if err != nil {
+			return err
+		}
+		payload = pl
+		return nil
+	})
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return payload, nil
+}
+
+func (b *Bucket) composeObjectByID(id string) (*Object, error) {
 	meta, err := b.GetMeta(id)
 	if err != nil {
 		return nil, err
@@ -352,34 +1862,117 @@ func (b Bucket) composeObjectByID(id string) (*Object, error) {
 	return b.composeObject(meta)
 }
 
-func (b Bucket) getIDByName(name, owner string) (string, error) {
-	var id string
-	err := b.name.View(func(txn *badger.Txn) error {
+// resolveName returns the id and generation of the object
+// with the name and owner. If the name entry is missing the
+// meta data of all objects is scanned for the object and the
+// name entry is repaired in the background.
+func (b *Bucket) resolveName(name, owner string) (string, string, error) {
+	id, gen, err := b.resolveEntry(name, owner)
+	if !errors.Is(err, ErrObjectNotFound) {
+		return id, gen, err
+	}
+	meta, scanErr := b.scanName(name, owner)
+	if scanErr != nil {
+		return "", "", err
+	}
+	b.queueNameRepair(meta)
+	return meta.Get(MetaKeyID), meta.Get(MetaKeyGeneration), nil
+}
+
+// resolveEntry returns the id and generation of
+// the name entry of the object with the name and owner.
+func (b *Bucket) resolveEntry(name, owner string) (string, string, error) {
+	var id, gen string
+	err := b.name.View(func(txn *storeTxn) error {
 		item, err := txn.Get([]byte(b.nameFormat(name, owner)))
 		if err != nil {
 			return err
 		}
-		dst := make([]byte, item.ValueSize())
-		if _, err := item.ValueCopy(dst); err != nil {
-			return err
-		}
-		id = string(dst)
-		return nil
+		return item.Value(func(val []byte) error {
+			id, gen = parseNameValue(val)
+			return nil
+		})
 	})
-	return id, err
+	return id, gen, notFound(err)
 }
 
 // deleteObject will delete all parts of an object
-// including metadata, name and payload entry.
-func (b Bucket) deleteObject(meta *Metadata) error {
+// including metadata, name and payload entry. The
+// meta data is deleted first so the object is
+// invisible before the other parts are removed.
+func (b *Bucket) deleteObject(meta *Metadata) error {
+	if err := checkRetention(meta); err != nil {
+		return err
+	}
+	if err := b.beforeDelete(meta); err != nil {
+		return err
+	}
+	return b.removeObject(meta)
+}
+
+// removeObject deletes all entries of the object described by meta
+// without checking its retention. Only one of concurrent removals of
+// the object deletes the meta data and updates the usage and the
+// change feed. The others fail with ErrObjectNotFound.
+func (b *Bucket) removeObject(meta *Metadata) error {
+	ok, err := b.claimObject(meta)
+	if !ok || err != nil {
+		return err
+	}
+	return b.objectRemoved(meta)
+}
+
+// detachObject is like removeObject but keeps the local
+// payload e.g. to be replaced by the payload of a replica.
+func (b *Bucket) detachObject(meta *Metadata) error {
+	ok, err := b.claimObject(meta)
+	if !ok || err != nil {
+		return err
+	}
+	return b.objectDetached(meta)
+}
+
+// claimObject deletes the meta data and the expiry marker of the
+// object. It reports false without an error if the reaper claimed
+// the expiry marker after the meta data was deleted because the
+// reaper removes the object then.
+func (b *Bucket) claimObject(meta *Metadata) (bool, error) {
 	id := meta.Get(MetaKeyID)
-	name := meta.Get(MetaKeyName)
-	owner := meta.Get(MetaKeyOwner)
-	if err := b.deleteName(name, owner); err != nil {
+	if err := b.deleteMeta(id); err != nil {
+		return false, err
+	}
+	if at, ok := expiryOf(meta); ok {
+		err := deleteExisting(b.name, expiryKey(at, id))
+		if errors.Is(err, ErrStoreKeyNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// objectRemoved deletes the remaining entries of the object and
+// updates the usage and the change feed after its meta data and
+// expiry marker were deleted.
+func (b *Bucket) objectRemoved(meta *Metadata) error {
+	if err := b.objectDetached(meta); err != nil {
+		return err
+	}
+	return b.deletePayload(meta.Get(MetaKeyID))
+}
+
+// objectDetached is like objectRemoved but keeps the local payload.
+func (b *Bucket) objectDetached(meta *Metadata) error {
+	if err := b.addUsage(b.usageOf(meta).neg()); err != nil {
+		return err
+	}
+	if err := b.recordChanges(EventDeleted, meta); err != nil {
 		return err
 	}
-	if err := b.deletePayload(id); err != nil {
+	if err := b.deleteName(meta); err != nil {
 		return err
 	}
-	return b.deleteMeta(id)
+	return b.deleteRemote(meta)
 }
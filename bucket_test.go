@@ -2,10 +2,24 @@ package objst
 
 import (
 	"bytes"
+	"context"
 	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
+	"github.com/google/uuid"
+	"github.com/naivary/objst/random"
 )
 
 func TestCreate(t *testing.T) {
@@ -34,6 +48,31 @@ func TestGetByID(t *testing.T) {
 	}
 }
 
+func TestHead(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	oH, err := tEnv.b.Head(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(oH.Payload()) != 0 {
+		t.Fatalf("payload should not be loaded. Got: %d bytes", len(oH.Payload()))
+	}
+	if oH.Size() != int64(len(o.Payload())) {
+		t.Fatalf("size is not %d. Got: %d", len(o.Payload()), oH.Size())
+	}
+	if oH.ETag() == "" || oH.ETag() != o.ETag() {
+		t.Fatalf("etag is not %s. Got: %s", o.ETag(), oH.ETag())
+	}
+	if _, err := tEnv.b.Head(tEnv.owner()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("head of a missing object should fail. Got: %v", err)
+	}
+}
+
 func TestDeleteByID(t *testing.T) {
 	o := tEnv.obj()
 	if err := tEnv.b.Create(o); err != nil {
@@ -48,6 +87,58 @@ func TestDeleteByID(t *testing.T) {
 	}
 }
 
+func TestConcurrentDelete(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := tEnv.b.GetMeta(o.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the removals are racing e.g. a lifecycle
+	// rule and a deletion of the user
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- tEnv.b.removeObject(meta)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	removed := 0
+	for err := range errs {
+		if err == nil {
+			removed++
+		} else if !errors.Is(err, ErrObjectNotFound) {
+			t.Fatal(err)
+		}
+	}
+	if removed != 1 {
+		t.Fatalf("only one removal should succeed. Got: %d", removed)
+	}
+	if u := tEnv.b.Usage(o.Owner()); u.Objects != 0 || u.Bytes != 0 {
+		t.Fatalf("usage should be decremented once. Got: %+v", u)
+	}
+	changes, _, err := tEnv.b.Changes(o.Owner(), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deletes := 0
+	for _, c := range changes {
+		if c.Op == ChangeDelete {
+			deletes++
+		}
+	}
+	if deletes != 1 {
+		t.Fatalf("deletion should be recorded once. Got: %d", deletes)
+	}
+}
+
 func TestDeleteByName(t *testing.T) {
 	o := tEnv.obj()
 	if err := tEnv.b.Create(o); err != nil {
@@ -95,6 +186,32 @@ func TestNameDuplication(t *testing.T) {
 	t.Fatal("should not create objects with the same name.")
 }
 
+func TestBatchCreate(t *testing.T) {
+	objs := tEnv.nObj(50)
+	if err := tEnv.b.BatchCreate(objs); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, obj := range objs {
+		if _, err := tEnv.b.GetByID(obj.ID()); err != nil {
+			t.Fatalf("object %s should be created: %v", obj.ID(), err)
+		}
+	}
+}
+
+func TestBatchCreateInvalidObject(t *testing.T) {
+	objs := tEnv.nObj(10)
+	objs[5].meta.set(MetaKeyName, "invalidname")
+	if err := tEnv.b.BatchCreate(objs); !errors.Is(err, ErrInvalidNamePattern) {
+		t.Fatalf("batch with an invalid object should fail. Got: %v", err)
+	}
+	for _, obj := range objs {
+		if _, err := tEnv.b.GetByID(obj.ID()); !errors.Is(err, badger.ErrKeyNotFound) {
+			t.Fatalf("no object of an invalid batch should be created")
+		}
+	}
+}
+
 func TestGetByName(t *testing.T) {
 	o1 := tEnv.obj()
 	if err := tEnv.b.Create(o1); err != nil {
@@ -227,88 +344,1141 @@ func TestQueryGet(t *testing.T) {
 	}
 }
 
-func TestQueryDelete(t *testing.T) {
-	const (
-		limit int     = 10
-		foo   MetaKey = "foo"
-		bar   string  = "bar"
-	)
-	objs := tEnv.nObj(20)
-	for i := 0; i < len(objs); i++ {
-		objs[i].SetMetaKey(foo, bar)
-		if i == limit {
-			break
+func TestQueryWhere(t *testing.T) {
+	const size MetaKey = "pages"
+	owner := tEnv.owner()
+	names := []string{"invoice-1.txt", "invoice-2.txt", "receipt-1.txt"}
+	for i, name := range names {
+		o, _ := NewObject(name, owner)
+		o.Write(tEnv.payload(10))
+		o.SetMetaKey(size, strconv.Itoa((i+1)*5))
+		if err := tEnv.b.Create(o); err != nil {
+			t.Error(err)
+			return
 		}
 	}
-	for _, obj := range objs {
-		if err := tEnv.b.Create(obj); err != nil {
+	tests := []struct {
+		name string
+		q    *Query
+		c    int
+	}{
+		{
+			name: "prefix",
+			q:    NewQuery().Where(MetaKeyOwner, OpEq, owner).Where(MetaKeyName, OpPrefix, "invoice-"),
+			c:    2,
+		},
+		{
+			name: "numeric greater than",
+			q:    NewQuery().Where(MetaKeyOwner, OpEq, owner).Where(size, OpGt, "5"),
+			c:    2,
+		},
+		{
+			name: "numeric less than",
+			q:    NewQuery().Where(MetaKeyOwner, OpEq, owner).Where(size, OpLt, "10"),
+			c:    1,
+		},
+		{
+			name: "not equal and contains",
+			q:    NewQuery().Where(MetaKeyOwner, OpEq, owner).Where(MetaKeyName, OpNe, "invoice-1.txt").Where(MetaKeyName, OpContains, "-1"),
+			c:    1,
+		},
+		{
+			name: "created after",
+			q:    NewQuery().Where(MetaKeyOwner, OpEq, owner).Where(MetaKeyCreatedAt, OpGt, time.Now().Add(-time.Hour).Format(time.RFC3339)),
+			c:    3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs, err := tEnv.b.Execute(test.q)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(objs) != test.c {
+				t.Fatalf("not the right number fetched. Got: %d. Expected: %d", len(objs), test.c)
+			}
+		})
+	}
+}
+
+func TestQueryTimeRange(t *testing.T) {
+	owner := tEnv.owner()
+	objs := make([]*Object, 0, 3)
+	for i := 0; i < 3; i++ {
+		o, _ := NewObject(tEnv.name(), owner)
+		o.Write(tEnv.payload(10))
+		if err := tEnv.b.Create(o); err != nil {
 			t.Error(err)
 			return
 		}
+		objs = append(objs, o)
+	}
+	mid, err := parseTime(objs[1].meta.Get(MetaKeyCreatedAt))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.UpdateMeta(objs[0].ID(), map[MetaKey]string{"foo": "bar"}); err != nil {
+		t.Error(err)
+		return
 	}
-
 	tests := []struct {
-		name    string
-		q       *Query
-		wantErr bool
+		name string
+		q    *Query
+		c    int
 	}{
 		{
-			name: "deleting one entry by id",
-			q:    NewQuery().ID(objs[0].ID()).Operation(OperationDelete),
+			name: "created after",
+			q:    NewQuery().Owner(owner).CreatedAfter(mid),
+			c:    1,
 		},
 		{
-			name: "deleting multiple entries",
-			q:    NewQuery().Param(foo, bar).Operation(OperationDelete),
+			name: "created before",
+			q:    NewQuery().Owner(owner).CreatedBefore(mid),
+			c:    1,
 		},
 		{
-			name: "deleting one entry by name",
-			q:    NewQuery().Name(objs[11].Name()).Owner(objs[11].Owner()).Operation(OperationDelete),
+			name: "created in range",
+			q:    NewQuery().Owner(owner).CreatedAfter(mid.Add(-time.Hour)).CreatedBefore(mid.Add(time.Hour)),
+			c:    3,
+		},
+		{
+			name: "updated after",
+			q:    NewQuery().Owner(owner).UpdatedAfter(mid),
+			c:    2,
 		},
 	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs, err := tEnv.b.Execute(test.q)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(objs) != test.c {
+				t.Fatalf("not the right number fetched. Got: %d. Expected: %d", len(objs), test.c)
+			}
+		})
+	}
+}
 
+func TestQueryOrderAndLimit(t *testing.T) {
+	const rank MetaKey = "rank"
+	owner := tEnv.owner()
+	objs := make([]*Object, 0, 5)
+	for i := 0; i < 5; i++ {
+		o, _ := NewObject(tEnv.name(), owner)
+		o.Write(tEnv.payload(10))
+		o.SetMetaKey(rank, strconv.Itoa(i*5))
+		if err := tEnv.b.Create(o); err != nil {
+			t.Error(err)
+			return
+		}
+		objs = append(objs, o)
+	}
+	tests := []struct {
+		name string
+		q    *Query
+		want []*Object
+	}{
+		{
+			name: "newest",
+			q:    NewQuery().Owner(owner).OrderBy(MetaKeyCreatedAt, Desc).Limit(2),
+			want: []*Object{objs[4], objs[3]},
+		},
+		{
+			name: "numeric ascending with offset",
+			q:    NewQuery().Owner(owner).OrderBy(rank, Asc).Offset(1).Limit(3),
+			want: []*Object{objs[1], objs[2], objs[3]},
+		},
+		{
+			name: "offset out of range",
+			q:    NewQuery().Owner(owner).Offset(5),
+			want: []*Object{},
+		},
+	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			_, err := tEnv.b.Execute(test.q)
-			if err != nil && !test.wantErr {
+			got, err := tEnv.b.Execute(test.q)
+			if err != nil {
 				t.Error(err)
 				return
 			}
+			if len(got) != len(test.want) {
+				t.Fatalf("not the right number fetched. Got: %d. Expected: %d", len(got), len(test.want))
+			}
+			for i := range got {
+				if got[i].ID() != test.want[i].ID() {
+					t.Fatalf("wrong order at %d. Got: %s. Expected: %s", i, got[i].Name(), test.want[i].Name())
+				}
+			}
 		})
 	}
+	if _, err := tEnv.b.Execute(NewQuery().Owner(owner).Limit(-1)); !errors.Is(err, ErrNegativeLimit) {
+		t.Fatalf("negative limit should be invalid. Got: %v", err)
+	}
 }
 
-func BenchmarkCreate(b *testing.B) {
-	for i := 0; i < b.N; i++ {
-		if err := tEnv.b.Create(tEnv.obj()); err != nil {
-			b.Error(err)
+func TestQueryNot(t *testing.T) {
+	owner := tEnv.owner()
+	contentTypes := []string{"image/png", "image/jpeg", "text/plain"}
+	for _, ct := range contentTypes {
+		o, _ := NewObject(tEnv.name(), owner)
+		o.Write(tEnv.payload(10))
+		o.SetMetaKey(MetaKeyContentType, ct)
+		if err := tEnv.b.Create(o); err != nil {
+			t.Error(err)
+			return
 		}
 	}
-	b.ReportAllocs()
+	tests := []struct {
+		name string
+		q    *Query
+		c    int
+	}{
+		{
+			name: "exclude one content type",
+			q:    NewQuery().Owner(owner).Not(MetaKeyContentType, "image/png"),
+			c:    2,
+		},
+		{
+			name: "exclude by pattern",
+			q:    NewQuery().Owner(owner).Not(MetaKeyContentType, "image/.*"),
+			c:    1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs, err := tEnv.b.Execute(test.q)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(objs) != test.c {
+				t.Fatalf("not the right number fetched. Got: %d. Expected: %d", len(objs), test.c)
+			}
+		})
+	}
 }
 
-func BenchmarkGet(b *testing.B) {
-	objs := tEnv.nObj(b.N)
+func TestQueryFilter(t *testing.T) {
+	const (
+		tag   MetaKey = "tag"
+		level MetaKey = "level"
+	)
+	owner := tEnv.owner()
+	objs := []struct {
+		name  string
+		tag   string
+		level string
+	}{
+		{"report-1.pdf", "finance", "1"},
+		{"report-2.pdf", "hr", "2"},
+		{"notes.txt", "finance", "3"},
+		{"draft.txt", "legal", "4"},
+	}
 	for _, obj := range objs {
-		if err := tEnv.b.Create(obj); err != nil {
-			b.Error(err)
+		o, _ := NewObject(obj.name, owner)
+		o.Write(tEnv.payload(10))
+		o.SetMetaKey(tag, obj.tag)
+		o.SetMetaKey(level, obj.level)
+		if err := tEnv.b.Create(o); err != nil {
+			t.Error(err)
 			return
 		}
 	}
-	b.ResetTimer()
-	for i := 0; i < b.N; i++ {
-		if _, err := tEnv.b.GetByID(objs[i].ID()); err != nil {
-			b.Error(err)
-		}
+	tests := []struct {
+		name string
+		q    *Query
+		c    int
+	}{
+		{
+			name: "and with nested or",
+			q:    NewQuery().Owner(owner).Filter(Or(Eq(tag, "finance"), Prefix(MetaKeyName, "draft"))),
+			c:    3,
+		},
+		{
+			name: "or of ands",
+			q: NewQuery().Filter(And(
+				Eq(MetaKeyOwner, owner),
+				Or(
+					And(Eq(tag, "finance"), Gt(level, "1")),
+					And(Eq(tag, "hr"), Lt(level, "3")),
+				),
+			)),
+			c: 2,
+		},
+		{
+			name: "not of or",
+			q:    NewQuery().Owner(owner).Filter(Not(Or(Eq(tag, "finance"), Eq(tag, "legal")))),
+			c:    1,
+		},
+		{
+			name: "empty or",
+			q:    NewQuery().Owner(owner).Filter(Or()),
+			c:    0,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs, err := tEnv.b.Execute(test.q)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(objs) != test.c {
+				t.Fatalf("not the right number fetched. Got: %d. Expected: %d", len(objs), test.c)
+			}
+		})
 	}
-	b.ReportAllocs()
 }
 
-func BenchmarkBatchCreate(b *testing.B) {
-	objs := tEnv.nObj(b.N)
-	b.ResetTimer()
-	if err := tEnv.b.BatchCreate(objs); err != nil {
-		b.Error(err)
-		return
+func TestQueryHasTag(t *testing.T) {
+	owner := tEnv.owner()
+	tags := [][]string{{"invoice", "paid"}, {"invoice"}, {"receipt", "invoice,paid"}}
+	for _, values := range tags {
+		o, _ := NewObject(tEnv.name(), owner)
+		o.Write(tEnv.payload(10))
+		for _, v := range values {
+			o.AddMetaValue(MetaKeyTags, v)
+		}
+		if err := tEnv.b.Create(o); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	tests := []struct {
+		name string
+		q    *Query
+		c    int
+	}{
+		{
+			name: "single tag",
+			q:    NewQuery().Owner(owner).HasTag("invoice"),
+			c:    2,
+		},
+		{
+			name: "multiple tags",
+			q:    NewQuery().Owner(owner).HasTag("invoice").HasTag("paid"),
+			c:    1,
+		},
+		{
+			name: "tag containing the separator",
+			q:    NewQuery().Owner(owner).HasTag("invoice,paid"),
+			c:    1,
+		},
+		{
+			name: "missing tag",
+			q:    NewQuery().Owner(owner).Filter(Not(Has(MetaKeyTags, "invoice"))),
+			c:    1,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs, err := tEnv.b.Execute(test.q)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(objs) != test.c {
+				t.Fatalf("number of objects is not %d. Got: %d", test.c, len(objs))
+			}
+		})
+	}
+}
+
+func TestQueryNamePattern(t *testing.T) {
+	owner := tEnv.owner()
+	names := []string{"reports/2024-01.pdf", "reports/2024-02.pdf", "reports/2023-12.pdf", "reports/2024/summary.txt"}
+	for _, name := range names {
+		o, _ := NewObject(name, owner)
+		o.Write(tEnv.payload(10))
+		if err := tEnv.b.Create(o); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	tests := []struct {
+		name string
+		q    *Query
+		c    int
+	}{
+		{
+			name: "glob",
+			q:    NewQuery().Owner(owner).NameGlob("reports/2024-*"),
+			c:    2,
+		},
+		{
+			name: "glob not matching separator",
+			q:    NewQuery().Owner(owner).NameGlob("reports/*.txt"),
+			c:    0,
+		},
+		{
+			name: "regexp",
+			q:    NewQuery().Owner(owner).NameRegexp(regexp.MustCompile(`^reports/2024`)),
+			c:    3,
+		},
+		{
+			name: "unanchored regexp",
+			q:    NewQuery().Owner(owner).NameRegexp(regexp.MustCompile(`\.pdf`)),
+			c:    3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			objs, err := tEnv.b.Execute(test.q)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(objs) != test.c {
+				t.Fatalf("not the right number fetched. Got: %d. Expected: %d", len(objs), test.c)
+			}
+		})
+	}
+}
+
+func TestQueryDelete(t *testing.T) {
+	const (
+		limit int     = 10
+		foo   MetaKey = "foo"
+		bar   string  = "bar"
+	)
+	objs := tEnv.nObj(20)
+	for i := 0; i < len(objs); i++ {
+		objs[i].SetMetaKey(foo, bar)
+		if i == limit {
+			break
+		}
+	}
+	for _, obj := range objs {
+		if err := tEnv.b.Create(obj); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	tests := []struct {
+		name    string
+		q       *Query
+		wantErr bool
+	}{
+		{
+			name: "deleting one entry by id",
+			q:    NewQuery().ID(objs[0].ID()).Operation(OperationDelete),
+		},
+		{
+			name: "deleting multiple entries",
+			q:    NewQuery().Param(foo, bar).Operation(OperationDelete),
+		},
+		{
+			name: "deleting one entry by name",
+			q:    NewQuery().Name(objs[11].Name()).Owner(objs[11].Owner()).Operation(OperationDelete),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := tEnv.b.Execute(test.q)
+			if err != nil && !test.wantErr {
+				t.Error(err)
+				return
+			}
+		})
+	}
+}
+
+func TestQueryDeleteDryRun(t *testing.T) {
+	owner := tEnv.owner()
+	objs := make([]*Object, 0, 3)
+	for i := 0; i < 3; i++ {
+		o, _ := NewObject(tEnv.name(), owner)
+		o.Write(tEnv.payload(10))
+		if err := tEnv.b.Create(o); err != nil {
+			t.Error(err)
+			return
+		}
+		objs = append(objs, o)
+	}
+	q := func() *Query {
+		return NewQuery().Owner(owner).Operation(OperationDelete)
+	}
+	res, err := tEnv.b.Execute(q().DryRun())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(res) != len(objs) {
+		t.Fatalf("dry run should return the matching objects. Got: %d. Expected: %d", len(res), len(objs))
+	}
+	for _, o := range objs {
+		if _, err := tEnv.b.GetByID(o.ID()); err != nil {
+			t.Fatalf("dry run should not delete the object %s: %v", o.ID(), err)
+		}
+	}
+	n, err := tEnv.b.Delete(q().DryRun())
+	if err != nil || n != len(objs) {
+		t.Fatalf("dry run should count the matching objects. Got: %d, %v", n, err)
+	}
+	n, err = tEnv.b.Delete(q())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n != len(objs) {
+		t.Fatalf("not the right number deleted. Got: %d. Expected: %d", n, len(objs))
+	}
+	res, err = tEnv.b.Execute(q())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(res) != 0 {
+		t.Fatalf("no object should be left to delete. Got: %d", len(res))
+	}
+}
+
+func TestCreateIfAbsent(t *testing.T) {
+	o1 := tEnv.obj()
+	if err := tEnv.b.CreateIfAbsent(o1); err != nil {
+		t.Error(err)
+		return
+	}
+	o2, _ := NewObject(o1.Name(), o1.Owner())
+	o2.Write(tEnv.payload(10))
+	if err := tEnv.b.CreateIfAbsent(o2); !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("object with an existing name should not be created. Got: %v", err)
+	}
+}
+
+func TestConcurrentCreateIfAbsent(t *testing.T) {
+	name, owner := tEnv.name(), tEnv.owner()
+	const n = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		o, _ := NewObject(name, owner)
+		o.Write(tEnv.payload(10))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- tEnv.b.CreateIfAbsent(o)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	created := 0
+	for err := range errs {
+		if err == nil {
+			created++
+		} else if !errors.Is(err, ErrPreconditionFailed) || !errors.Is(err, ErrDuplicateName) {
+			t.Fatalf("concurrent creates should fail with a failed precondition. Got: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("only one object should be created. Got: %d", created)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	dup, _ := NewObject(o.Name(), o.Owner())
+	dup.Write(tEnv.payload(10))
+	if err := tEnv.b.Create(dup); !errors.Is(err, ErrDuplicateName) {
+		t.Errorf("expected ErrDuplicateName. Got: %v", err)
+	}
+	if _, err := o.Write(tEnv.payload(1)); !errors.Is(err, ErrImmutable) {
+		t.Errorf("expected ErrImmutable. Got: %v", err)
+	}
+	if err := tEnv.b.UpdateMeta(uuid.NewString(), map[MetaKey]string{"foo": "bar"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound. Got: %v", err)
+	}
+	if _, err := tEnv.b.KV(o.Owner()).Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound. Got: %v", err)
+	}
+}
+
+func TestUpdateMetaIf(t *testing.T) {
+	const foo MetaKey = "foo"
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	etag := o.ETag()
+	if etag == "" {
+		t.Fatalf("etag should be set after create")
+	}
+	if err := tEnv.b.UpdateMetaIf(o.ID(), etag, map[MetaKey]string{foo: "bar"}); err != nil {
+		t.Error(err)
+		return
+	}
+	// the etag changed with the first update
+	err := tEnv.b.UpdateMetaIf(o.ID(), etag, map[MetaKey]string{foo: "baz"})
+	if !errors.Is(err, ErrPreconditionFailed) {
+		t.Fatalf("update with a stale etag should fail. Got: %v", err)
+	}
+	oG, err := tEnv.b.GetByID(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if oG.GetMetaKey(foo) != "bar" {
+		t.Fatalf("meta data is not updated. Got: %s. Expected: %s", oG.GetMetaKey(foo), "bar")
+	}
+	if oG.ETag() == etag {
+		t.Fatalf("etag should change after a meta data update")
+	}
+}
+
+func TestPatchPayload(t *testing.T) {
+	o := tEnv.emptyObj()
+	o.Write([]byte("hello world"))
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	tests := []struct {
+		name   string
+		offset int64
+		data   string
+		want   string
+	}{
+		{
+			name:   "overwrite range",
+			offset: 6,
+			data:   "objst",
+			want:   "hello objst",
+		},
+		{
+			name:   "grow payload",
+			offset: 11,
+			data:   "!",
+			want:   "hello objst!",
+		},
+		{
+			name:   "sparse write",
+			offset: 14,
+			data:   "?",
+			want:   "hello objst!\x00\x00?",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := tEnv.b.PatchPayload(o.ID(), test.offset, []byte(test.data)); err != nil {
+				t.Error(err)
+				return
+			}
+			oG, err := tEnv.b.GetByID(o.ID())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(oG.Payload()) != test.want {
+				t.Fatalf("payload is not patched. Got: %q. Expected: %q", oG.Payload(), test.want)
+			}
+			if err := tEnv.b.Verify(o.ID()); err != nil {
+				t.Fatalf("checksum should be updated after patch: %v", err)
+			}
+		})
+	}
+}
+
+func TestConcurrentPatchPayload(t *testing.T) {
+	o := tEnv.emptyObj()
+	o.Write(make([]byte, 16))
+	if err := tEnv.b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := tEnv.b.PatchPayload(o.ID(), int64(i), []byte{'a' + byte(i)}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	pl, err := tEnv.b.GetPayload(o.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "abcdefghijklmnop"; string(pl) != want {
+		t.Fatalf("payload should contain all patches. Got: %q. Expected: %q", pl, want)
+	}
+	if err := tEnv.b.Verify(o.ID()); err != nil {
+		t.Fatalf("checksum should describe the patched payload: %v", err)
+	}
+}
+
+func TestOwnerValidator(t *testing.T) {
+	email := "alice@example.com"
+	o, err := NewObject("report.txt", email)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	o.Write(tEnv.payload(10))
+	if err := tEnv.b.Create(o); !errors.Is(err, ErrInvalidOwner) {
+		t.Fatalf("owner which is not a uuid should be rejected by default. Got: %v", err)
+	}
+	if _, err := tEnv.b.Execute(NewQuery().Param(MetaKeyOwner, email)); !errors.Is(err, ErrInvalidOwner) {
+		t.Fatalf("query with an owner which is not a uuid should be rejected by default. Got: %v", err)
+	}
+
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.OwnerValidator = func(owner string) error {
+		if !strings.Contains(owner, "@") {
+			return fmt.Errorf("%w: %s is not an e-mail address", ErrInvalidOwner, owner)
+		}
+		return nil
+	}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	objs, err := b.Execute(NewQuery().Param(MetaKeyOwner, email))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(objs) != 1 || objs[0].ID() != o.ID() {
+		t.Fatalf("object of the owner should be found. Got: %d objects", len(objs))
+	}
+	o2, _ := NewObject("report.txt", tEnv.owner())
+	o2.Write(tEnv.payload(10))
+	if err := b.Create(o2); !errors.Is(err, ErrInvalidOwner) {
+		t.Fatalf("owner rejected by the custom validator should be invalid. Got: %v", err)
+	}
+}
+
+func BenchmarkCreate(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if err := tEnv.b.Create(tEnv.obj()); err != nil {
+			b.Error(err)
+		}
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkGet(b *testing.B) {
+	objs := tEnv.nObj(b.N)
+	for _, obj := range objs {
+		if err := tEnv.b.Create(obj); err != nil {
+			b.Error(err)
+			return
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := tEnv.b.GetByID(objs[i].ID()); err != nil {
+			b.Error(err)
+		}
+	}
+	b.ReportAllocs()
+}
+
+func BenchmarkBatchCreate(b *testing.B) {
+	objs := tEnv.nObj(b.N)
+	b.ResetTimer()
+	if err := tEnv.b.BatchCreate(objs); err != nil {
+		b.Error(err)
+		return
+	}
+	b.ReportAllocs()
+}
+
+func TestMetaValidator(t *testing.T) {
+	project := MetaKey("project")
+	errMissingProject := errors.New("missing project")
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.MetaValidator = func(meta *Metadata) error {
+		if meta.Get(project) == "" {
+			return errMissingProject
+		}
+		if strings.ToLower(meta.Get(project)) != meta.Get(project) {
+			return fmt.Errorf("project %s must be lower case", meta.Get(project))
+		}
+		return nil
+	}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	o := tEnv.obj()
+	err = b.Create(o)
+	if !errors.Is(err, ErrInvalidMeta) || !errors.Is(err, errMissingProject) {
+		t.Fatalf("object without a project should be rejected. Got: %v", err)
+	}
+	if _, err := b.GetByID(o.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("rejected object should not be created. Got: %v", err)
+	}
+	o = tEnv.obj()
+	o.SetMetaKey(project, "objst")
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.UpdateMeta(o.ID(), map[MetaKey]string{project: "OBJST"}); !errors.Is(err, ErrInvalidMeta) {
+		t.Fatalf("update with an upper case project should be rejected. Got: %v", err)
+	}
+	got, err := b.GetByID(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got.GetMetaKey(project) != "objst" {
+		t.Fatalf("rejected update should not be applied. Got: %q", got.GetMetaKey(project))
+	}
+
+	u, err := b.NewUpload(tEnv.obj())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := u.Write(tEnv.payload(10)); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := u.Commit(); !errors.Is(err, ErrInvalidMeta) {
+		t.Fatalf("upload without a project should be rejected. Got: %v", err)
+	}
+}
+
+func TestLazyLoad(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	objs, err := tEnv.b.Execute(NewQuery().ID(o.ID()))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(objs) != 1 {
+		t.Fatalf("query should return the object. Got: %d", len(objs))
+	}
+	got := objs[0]
+	if got.isLoaded || got.pl.Len() != 0 {
+		t.Fatalf("payload should not be loaded by the query")
+	}
+	if got.Size() != o.Size() {
+		t.Fatalf("size should be known without the payload. Got: %d. Expected: %d", got.Size(), o.Size())
+	}
+	pl, err := io.ReadAll(got)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(pl, o.Payload()) {
+		t.Fatalf("payload is not the same. Got: %s. Expected: %s", pl, o.Payload())
+	}
+	got.Release()
+	if got.pl.Len() != 0 {
+		t.Fatalf("payload should be released")
+	}
+	if !bytes.Equal(got.Payload(), o.Payload()) {
+		t.Fatalf("released payload should be loaded again")
+	}
+	got.Release()
+	if err := tEnv.b.DeleteByID(o.ID()); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := got.Load(); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("payload of a deleted object should not be loadable. Got: %v", err)
+	}
+}
+
+func TestMetaLimits(t *testing.T) {
+	o := tEnv.obj()
+	o.SetMetaKey(MetaKey(strings.Repeat("k", 257)), "v")
+	if err := tEnv.b.Create(o); !errors.Is(err, ErrInvalidMeta) || !errors.Is(err, ErrMetaKeyTooLong) {
+		t.Fatalf("too long key should be rejected. Got: %v", err)
+	}
+	o = tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	err := tEnv.b.UpdateMeta(o.ID(), map[MetaKey]string{"foo": strings.Repeat("v", 4097)})
+	if !errors.Is(err, ErrMetaValueTooLong) {
+		t.Fatalf("too long value should be rejected. Got: %v", err)
+	}
+
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	// the content type is a user defined key as well
+	opts.MetaLimits = MetaLimits{MaxKeys: 3, MaxBytes: 100}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	o = tEnv.obj()
+	o.SetMetaKey("a", "1")
+	o.SetMetaKey("b", "2")
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.UpdateMeta(o.ID(), map[MetaKey]string{"c": "3"}); !errors.Is(err, ErrTooManyMetaKeys) {
+		t.Fatalf("too many keys should be rejected. Got: %v", err)
+	}
+	if err := b.UpdateMeta(o.ID(), map[MetaKey]string{"a": strings.Repeat("v", 100)}); !errors.Is(err, ErrMetaTooLarge) {
+		t.Fatalf("too large meta data should be rejected. Got: %v", err)
+	}
+}
+
+func TestStoreOptions(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.PayloadStore = StoreOptions{InMemory: true, MemTableSize: 16 << 20, SyncWrites: true}
+	opts.MetaStore = StoreOptions{InMemory: true, DisableCompression: true, NumVersionsToKeep: 2, BlockCacheSize: 1 << 20}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	if p := b.payload.opts; !p.InMemory || p.MemTableSize != 16<<20 || !p.SyncWrites || p.Compression != opts.Compression {
+		t.Fatalf("payload store is not tuned as expected")
+	}
+	for _, s := range []*store{b.name, b.meta} {
+		if m := s.opts; !m.InMemory || m.Compression != options.None || m.NumVersionsToKeep != 2 || m.BlockCacheSize != 1<<20 || m.SyncWrites {
+			t.Fatalf("%s store is not tuned as expected", s.name)
+		}
+	}
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetByName(o.Name(), o.Owner()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(b.BasePath, metaDir)); !os.IsNotExist(err) {
+		t.Fatalf("in-memory store should not create a directory. Got: %v", err)
+	}
+}
+
+func TestSingleLayout(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.Layout = LayoutSingle
+	opts.KeyProvider = StaticKey(random.String(32))
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	if b.payload.handle != b.name.handle || b.name.handle != b.meta.handle {
+		t.Fatalf("stores of the single layout should share one database")
+	}
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.KV(o.Owner()).Set("theme", []byte("dark")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RotateEncryptionKey([]byte(random.String(32))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetByName(o.Name(), o.Owner()); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := b.ReindexAll(context.Background()); err != nil || n != 0 {
+		t.Fatalf("indexes of the single layout should be consistent. Got: %d, %v", n, err)
+	}
+	opts.KeyProvider = StaticKey(b.payload.opts.EncryptionKey)
+	if err := b.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(b.BasePath, dataDir)); !os.IsNotExist(err) {
+		t.Fatalf("single layout should not create the payload store. Got: %v", err)
+	}
+	// existing buckets keep their layout
+	opts.Layout = LayoutSeparate
+	b, err = OpenBucket(b.BasePath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Shutdown()
+	if b.payload.handle != b.meta.handle || len(b.meta.prefix) == 0 {
+		t.Fatalf("bucket should be opened in the single layout")
+	}
+	got, err := b.GetByName(o.Name(), o.Owner())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Payload(), o.Payload()) {
+		t.Fatalf("payload differs after reopening")
+	}
+	if v, err := b.KV(o.Owner()).Get("theme"); err != nil || string(v) != "dark" {
+		t.Fatalf("kv entry should be kept. Got: %s, %v", v, err)
+	}
+}
+
+func TestConcurrentCreateSameName(t *testing.T) {
+	const n = 16
+	name, owner := tEnv.name(), tEnv.owner()
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		obj, err := NewObject(name, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj.Write(tEnv.payload(10))
+		wg.Add(1)
+		go func(i int, obj *Object) {
+			defer wg.Done()
+			if i%2 == 0 {
+				errs[i] = tEnv.b.Create(obj)
+				return
+			}
+			errs[i] = tEnv.b.BatchCreate([]*Object{obj})
+		}(i, obj)
+	}
+	wg.Wait()
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+			continue
+		}
+		if !errors.Is(err, ErrDuplicateName) {
+			t.Fatalf("concurrent create should fail with ErrDuplicateName. Got: %v", err)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("exactly one create should succeed. Got: %d", created)
+	}
+	objs, err := tEnv.b.Execute(NewQuery().Owner(owner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("exactly one object should exist. Got: %d", len(objs))
+	}
+}
+
+// failingStore is a memory store whose writes fail while fail is set.
+type failingStore struct {
+	*memoryStore
+	fail *atomic.Bool
+}
+
+var errInjected = errors.New("injected failure")
+
+func (s failingStore) Update(fn func(txn Txn) error) error {
+	if s.fail.Load() {
+		return errInjected
+	}
+	return s.memoryStore.Update(fn)
+}
+
+func (s failingStore) NewBatch() Batch {
+	return failingBatch{Batch: s.memoryStore.NewBatch(), s: s}
+}
+
+type failingBatch struct {
+	Batch
+	s failingStore
+}
+
+func (b failingBatch) Flush() error {
+	if b.s.fail.Load() {
+		return errInjected
+	}
+	return b.Batch.Flush()
+}
+
+func TestCreateMetaFailure(t *testing.T) {
+	fail := new(atomic.Bool)
+	var payload *memoryStore
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.Layout = LayoutSeparate
+	opts.OpenStore = func(c StoreConfig) (Store, error) {
+		s, err := OpenMemoryStore(c)
+		if err != nil {
+			return nil, err
+		}
+		switch c.Name {
+		case payloadStore:
+			payload = s.(*memoryStore)
+		case metaStore:
+			return failingStore{memoryStore: s.(*memoryStore), fail: fail}, nil
+		}
+		return s, nil
+	}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	hasPayload := func(id string) bool {
+		snap, err := payload.snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, k := range snap.keys {
+			if strings.Contains(k, id) {
+				return true
+			}
+		}
+		return false
+	}
+
+	o, batch := tEnv.obj(), []*Object{tEnv.obj(), tEnv.obj()}
+	u, err := b.NewUpload(tEnv.emptyObj())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Write(tEnv.payload(100)); err != nil {
+		t.Fatal(err)
+	}
+	fail.Store(true)
+	if err := b.Create(o); !errors.Is(err, errInjected) {
+		t.Fatalf("create should fail. Got: %v", err)
+	}
+	if err := b.BatchCreate(batch); !errors.Is(err, errInjected) {
+		t.Fatalf("batch create should fail. Got: %v", err)
+	}
+	if err := u.Commit(); !errors.Is(err, errInjected) {
+		t.Fatalf("commit should fail. Got: %v", err)
+	}
+	fail.Store(false)
+	for _, obj := range append(batch, o, u.obj) {
+		if hasPayload(obj.ID()) {
+			t.Fatalf("payload of %s should be deleted", obj.ID())
+		}
+		if b.isNameExisting(obj.Name(), obj.Owner()) {
+			t.Fatalf("name of %s should be released", obj.ID())
+		}
 	}
-	b.ReportAllocs()
 }
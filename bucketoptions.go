@@ -1,11 +1,412 @@
 package objst
 
-import "github.com/dgraph-io/badger/v4"
+import (
+	"context"
+	"io"
+	"runtime"
+	"time"
 
-type BucketOptions badger.Options
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/options"
+)
+
+type BucketOptions struct {
+	// Options are the options of the underlying
+	// badger database storing the payload.
+	badger.Options
+
+	// PayloadStore tunes the store of the payloads e.g. for
+	// large values. It is applied on top of Options.
+	PayloadStore StoreOptions
+
+	// MetaStore tunes the name and the meta store which are
+	// containing many small entries.
+	MetaStore StoreOptions
+
+	// Layout is the layout of the stores of new buckets.
+	// Existing buckets are opened in the layout they were
+	// created with. Default: LayoutSeparate.
+	Layout Layout
+
+	// BatchConcurrency is the number of workers used
+	// by BatchCreate to validate and marshal objects
+	// in parallel. Default: runtime.NumCPU().
+	BatchConcurrency int
+
+	// ScanConcurrency is the number of workers scanning the
+	// meta data of a query in parallel if no index can be used.
+	// The keyspace of the meta store is split into ranges of
+	// ids which are scanned concurrently. Unordered queries
+	// with a limit are scanned sequentially because they can
+	// stop at the end of the requested page.
+	// Default: runtime.NumCPU().
+	ScanConcurrency int
+
+	// QueryIterator tunes the iteration over the meta data
+	// scanned by queries. It can be overridden by every query
+	// using Query.Iterator. Queries comparing only the ids of
+	// the objects never read the meta data of the objects.
+	// Default: prefetching the meta data of 10 objects.
+	QueryIterator IteratorOptions
+
+	// SpillThreshold is the number of matching ids of a delete
+	// query or an export which are held in memory. Further ids
+	// are spilled to a temporary file in SpillDir so the memory
+	// usage is bounded regardless of the number of matching
+	// objects. Ordered queries and queries with a limit or an
+	// offset are collected in memory before they are spilled.
+	// If it is not positive the ids are never spilled.
+	// Default: 100000.
+	SpillThreshold int
+
+	// SpillDir is the directory of the temporary files of
+	// SpillThreshold. Default: the directory of os.TempDir.
+	SpillDir string
+
+	// CacheSize is the maximum estimated size in bytes of the
+	// in-process LRU cache of the meta data and the small
+	// payloads of the objects. Objects are removed from the
+	// cache on every write. Modifications of the stores outside
+	// of the bucket are not detected for cached objects e.g. by
+	// MetaSigningKey. If it is not positive the cache is
+	// disabled. See Stats. Default: 0.
+	CacheSize int64
+
+	// CacheMaxPayloadSize is the maximum size in bytes of a
+	// payload to be cached. Larger payloads are read from the
+	// store on every access but their meta data is cached.
+	// Default: 64 KiB.
+	CacheMaxPayloadSize int64
+
+	// ChunkSize is the default maximum size in bytes of
+	// one payload chunk. The chunk size used for an object
+	// is recorded in its meta data so changing the default
+	// does not affect existing objects. If it is not
+	// positive the payload is stored as one chunk.
+	// Default: 1 MiB.
+	ChunkSize int64
+
+	// BlobThreshold is the size in bytes above which encoded
+	// payloads are stored as files in the base path of the
+	// bucket instead of the payload store. The files are named
+	// by the SHA-256 of their content so equal payloads share
+	// one file. This avoids bloating the value log of badger
+	// with huge payloads and allows to serve them using
+	// sendfile. See OpenPayloadFile. If it is not positive
+	// all payloads are stored in the payload store.
+	// Default: 0.
+	BlobThreshold int64
+
+	// Remote stores the payloads of the objects moved to the
+	// cold tier e.g. NewS3Remote. See Bucket.Tier. Default: nil.
+	Remote Remote
+
+	// KeyProvider provides the master key to encrypt all
+	// stores of the bucket at rest. If set it takes
+	// precedence over the EncryptionKey option.
+	KeyProvider KeyProvider
+
+	// Cipher is used to encrypt the payload of every
+	// object with its own data key. If nil the payloads
+	// are not encrypted. See NewAESGCMCipher.
+	Cipher Cipher
+
+	// FIPS restricts all cryptographic algorithms of the bucket
+	// to FIPS approved ones. Opening the bucket fails with
+	// ErrNotFIPSApproved if the Cipher doesn't implement
+	// FIPSCipher or if the MetaSigningKey is shorter than 112
+	// bits. The stores are encrypted at rest using AES and
+	// checksums, signatures and upload policies are using
+	// SHA-256 which are approved anyway. The mode is always
+	// on if built with the objst_fips build tag.
+	// Default: false.
+	FIPS bool
+
+	// PayloadCompression is the algorithm used to compress
+	// the payload of new objects before they are encrypted
+	// and stored. The algorithm is recorded in the meta data
+	// of the object so changing it does not affect existing
+	// objects. Payloads which are not getting smaller are
+	// stored uncompressed. Default: CompressionNone.
+	PayloadCompression Compression
+
+	// DictionaryObjectSize is the maximum payload size in
+	// bytes of objects which are compressed with the active
+	// zstd dictionary. See TrainDictionary. Default: 16 KiB.
+	DictionaryObjectSize int64
+
+	// LifecycleInterval is the interval in which the
+	// lifecycle rules are applied and expired objects are
+	// deleted. If it is not positive the rules are only
+	// applied by ApplyLifecycle. Default: 1 hour.
+	LifecycleInterval time.Duration
+
+	// UploadRetention is the duration the staged data of an
+	// upload is kept after the last write. Older uploads are
+	// purged by the lifecycle worker. If it is not positive
+	// uploads are only purged by PurgeStaging.
+	// Default: 24 hours.
+	UploadRetention time.Duration
+
+	// EventDeliveryInterval is the interval in which the
+	// pending changes are delivered to the subscriptions. If
+	// it is not positive the changes are only delivered by
+	// DeliverEvents. See Subscribe. Default: 5 seconds.
+	EventDeliveryInterval time.Duration
+
+	// EventDelivery delivers a batch of changes to the
+	// subscription. A returned error leaves the changes
+	// pending so they are delivered again by the next
+	// delivery. Default: DeliverWebhook.
+	EventDelivery func(ctx context.Context, s Subscription, changes []Change) error
+
+	// ArchiveDir is the directory objects are exported to
+	// by the LifecycleArchive action.
+	// Default: <BasePath>/archive.
+	ArchiveDir string
+
+	// SlowOpThreshold is the duration after which an
+	// operation is considered slow and recorded in the
+	// slow operation log. See SlowOps. If it is not
+	// positive no operation is recorded.
+	// Default: 100 milliseconds.
+	SlowOpThreshold time.Duration
+
+	// SlowOpLogSize is the number of the most recent
+	// slow operations kept in the slow operation log.
+	// Default: 128.
+	SlowOpLogSize int
+
+	// ChangeRetention is the duration the entries of the
+	// change feed are kept. See Changes. If it is not
+	// positive the entries are kept forever.
+	// Default: 7 days.
+	ChangeRetention time.Duration
+
+	// WatchBufferSize is the number of events buffered for
+	// every watcher. A watcher is closed if its buffer is
+	// full. See Watch. Default: 256.
+	WatchBufferSize int
+
+	// AuditLog records every mutation of the objects and the
+	// KVs in an append-only audit log. See Bucket.AuditLog.
+	// Default: false.
+	AuditLog bool
+
+	// OwnerValidator validates the owner of inserted objects
+	// and queries. Applications using e.g. e-mail addresses
+	// or opaque tenant ids as owners can provide their own.
+	// Default: ValidateUUIDOwner.
+	OwnerValidator OwnerValidator
+
+	// MetaValidator validates the meta data of created objects
+	// and of every update using UpdateMeta or UpdateMetaIf
+	// e.g. to enforce required keys, value formats or a maximum
+	// size of the meta data. It receives the meta data including
+	// the system meta data and must not modify it. The write is
+	// rejected with an error wrapping ErrInvalidMeta if it fails.
+	// Default: nil.
+	MetaValidator func(*Metadata) error
+
+	// Codec encodes the meta data of the objects in the meta
+	// store. Meta data encoded using gob by older versions is
+	// readable with every codec. Default: CodecProtobuf.
+	Codec Codec
+
+	// MetaLimits limits the user defined meta data of every
+	// object on creation and on every update. Writes exceeding
+	// a limit are rejected with an error wrapping ErrInvalidMeta
+	// and the error of the limit e.g. ErrMetaTooLarge.
+	// Default: DefaultMetaLimits().
+	MetaLimits MetaLimits
+
+	// MetaSigningKey enables the strict mode if set. The
+	// system meta data like the id, owner or createdAt of
+	// every object is signed using HMAC-SHA256 with the key
+	// and verified on read so any modification of the meta
+	// store outside of the bucket is reported as corruption
+	// by ErrMetaTampered. Objects written before the key was
+	// set are reported as tampered as well. Default: nil.
+	MetaSigningKey []byte
+
+	// UsageReconcileInterval is the interval in which the
+	// usage counters of the owners are recalculated to
+	// correct any drift. See ReconcileUsage. If it is not
+	// positive the counters are only recalculated by
+	// ReconcileUsage. Default: 1 hour.
+	UsageReconcileInterval time.Duration
+
+	// GCInterval is the interval in which the value logs of
+	// the stores are garbage collected. See RunGC. If it is
+	// not positive the value logs are only garbage collected
+	// by RunGC. Default: 10 minutes.
+	GCInterval time.Duration
+
+	// BackupInterval is the interval in which backups of the
+	// bucket are written to BackupDir or BackupWriter. See
+	// RunBackup. If it is not positive backups are only
+	// written by RunBackup. Default: 0.
+	BackupInterval time.Duration
+
+	// BackupDir is the directory the scheduled backups
+	// are written to if BackupWriter is nil.
+	BackupDir string
+
+	// BackupWriter returns the writer of the backup with the
+	// name. The backup is complete if closing the writer
+	// succeeds. BackupRemove is called with the names of
+	// pruned and failed backups if it is not nil.
+	BackupWriter func(name string) (io.WriteCloser, error)
+	BackupRemove func(name string) error
+
+	// BackupRetention is the maximum number of backups kept.
+	// The first backup is a full backup followed by incremental
+	// backups. If the retention is reached the next backup is a
+	// full backup and the older backups are pruned. If it is not
+	// positive all backups are incremental after the first
+	// and none are pruned. Default: 24.
+	BackupRetention int
+
+	// GCDiscardRatio is the minimum fraction of a value log
+	// file which has to be discardable to rewrite the file.
+	// It must be less than 1. Default: 0.5.
+	GCDiscardRatio float64
+
+	// StallCheckInterval is the interval in which the stores
+	// are checked for stalled writes. While a store is stalling
+	// all writes are rejected immediately with a
+	// *BackpressureError instead of blocking until the
+	// compactions caught up. If it is not positive the writes
+	// are blocking. Default: 100 milliseconds.
+	StallCheckInterval time.Duration
+
+	// DetectContentType sets the content type of created
+	// objects without MetaKeyContentType instead of rejecting
+	// them with ErrContentTypeNotExist. The content type is
+	// derived from the extension of the name and if it is
+	// unknown sniffed from the first 512 bytes of the payload
+	// using http.DetectContentType. Default: false.
+	DetectContentType bool
+
+	// OnModeration is called after every change of the
+	// moderation state of an object including objects
+	// created as pending. It is called synchronously by
+	// the write and must not block. See Quarantine.
+	OnModeration func(ModerationEvent)
+
+	// OpenStore opens the stores of the bucket instead of
+	// badger e.g. NewMemoryStore. The options of badger are
+	// ignored and the features relying on badger are returning
+	// ErrStoreUnsupported. See Store. Default: nil.
+	OpenStore func(StoreConfig) (Store, error)
+
+	// OnStoreEvent is called for the internal events of the
+	// badger stores e.g. compactions, write stalls and the
+	// results of the garbage collection. It is called
+	// synchronously by the goroutines of badger and must
+	// not block. Stalls are logged as warnings as well.
+	OnStoreEvent func(StoreEvent)
+}
 
 func NewDefaultBucketOptions() BucketOptions {
-	return BucketOptions(badger.DefaultOptions(""))
+	const mib = 1 << 20
+	return BucketOptions{
+		Options:                badger.DefaultOptions(""),
+		BatchConcurrency:       runtime.NumCPU(),
+		ScanConcurrency:        runtime.NumCPU(),
+		QueryIterator:          IteratorOptions{PrefetchValues: true, PrefetchSize: 10},
+		SpillThreshold:         100_000,
+		ChunkSize:              mib,
+		CacheMaxPayloadSize:    64 << 10,
+		LifecycleInterval:      time.Hour,
+		UploadRetention:        24 * time.Hour,
+		EventDeliveryInterval:  5 * time.Second,
+		EventDelivery:          DeliverWebhook,
+		DictionaryObjectSize:   16 << 10,
+		SlowOpThreshold:        100 * time.Millisecond,
+		SlowOpLogSize:          128,
+		ChangeRetention:        7 * 24 * time.Hour,
+		WatchBufferSize:        defaultWatchBufferSize,
+		OwnerValidator:         ValidateUUIDOwner,
+		Codec:                  CodecProtobuf,
+		MetaLimits:             DefaultMetaLimits(),
+		UsageReconcileInterval: time.Hour,
+		GCInterval:             10 * time.Minute,
+		GCDiscardRatio:         defaultGCDiscardRatio,
+		BackupRetention:        24,
+		StallCheckInterval:     100 * time.Millisecond,
+	}
+}
+
+// Layout is the layout of the stores of a bucket on disk.
+type Layout int
+
+const (
+	// LayoutSeparate stores the payloads, the names and
+	// the meta data in three badger databases.
+	LayoutSeparate Layout = iota
+	// LayoutSingle stores the payloads, the names and the meta
+	// data in one badger database separated by the key prefixes
+	// data/, name/ and meta/. It needs a third of the file
+	// handles, compactions and goroutines of LayoutSeparate.
+	// The database is tuned by PayloadStore.
+	LayoutSingle
+)
+
+// StoreOptions are the tuning options of a badger store of
+// the bucket. Zero values keep the default of badger. See
+// badger.Options for the details of the options.
+type StoreOptions struct {
+	// ValueLogFileSize is the maximum size of
+	// one file of the value log in bytes.
+	ValueLogFileSize int64
+	// MemTableSize is the size of the memtable in bytes.
+	MemTableSize int64
+	// Compression is the compression of the blocks
+	// of the tables. Use DisableCompression to store
+	// them uncompressed because options.None is zero.
+	Compression        options.CompressionType
+	DisableCompression bool
+	// BlockCacheSize is the size of the block cache in bytes.
+	BlockCacheSize int64
+	// InMemory keeps the store in memory only. All
+	// data of the store is lost on Shutdown.
+	InMemory bool
+	// SyncWrites syncs every write to disk before it returns.
+	SyncWrites bool
+	// NumVersionsToKeep is the number of
+	// versions of every key to keep.
+	NumVersionsToKeep int
+}
+
+// apply returns opts with the non-zero options of s.
+func (s StoreOptions) apply(opts badger.Options) badger.Options {
+	if s.ValueLogFileSize > 0 {
+		opts.ValueLogFileSize = s.ValueLogFileSize
+	}
+	if s.MemTableSize > 0 {
+		opts.MemTableSize = s.MemTableSize
+	}
+	if s.Compression != options.None {
+		opts.Compression = s.Compression
+	}
+	if s.DisableCompression {
+		opts.Compression = options.None
+	}
+	if s.BlockCacheSize > 0 {
+		opts.BlockCacheSize = s.BlockCacheSize
+	}
+	if s.InMemory {
+		// badger rejects directories for in-memory stores
+		opts = opts.WithInMemory(true).WithDir("").WithValueDir("")
+	}
+	if s.SyncWrites {
+		opts.SyncWrites = true
+	}
+	if s.NumVersionsToKeep > 0 {
+		opts.NumVersionsToKeep = s.NumVersionsToKeep
+	}
+	return opts
 }
 
 func (b *BucketOptions) overwriteDataDir(dir string) {
@@ -14,5 +415,18 @@ func (b *BucketOptions) overwriteDataDir(dir string) {
 }
 
 func (b BucketOptions) toBadgerOpts() badger.Options {
-	return badger.Options(b)
+	return b.PayloadStore.apply(b.Options)
+}
+
+// storeOpts returns the options of the name and meta
+// store. Both are using the default options tuned by
+// MetaStore but share the logger and encryption
+// settings of the bucket.
+func (b BucketOptions) storeOpts(dir string) badger.Options {
+	opts := badger.DefaultOptions(dir)
+	opts.Logger = b.Logger
+	opts.EncryptionKey = b.EncryptionKey
+	opts.EncryptionKeyRotationDuration = b.EncryptionKeyRotationDuration
+	opts.IndexCacheSize = b.IndexCacheSize
+	return b.MetaStore.apply(opts)
 }
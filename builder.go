@@ -0,0 +1,193 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+
+	"github.com/google/uuid"
+)
+
+// FieldError is the validation error of a field of an object
+// built by ObjectBuilder e.g. "name" or "meta.<key>".
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ObjectBuilder builds an object and validates all fields in
+// Build instead of scattering the validation across NewObject,
+// SetMetaKey and Write which are silently ignoring invalid meta
+// data.
+type ObjectBuilder struct {
+	id          string
+	name        string
+	owner       string
+	contentType string
+	meta        []metaPair
+	payload     []byte
+	r           io.Reader
+	validator   OwnerValidator
+	limits      MetaLimits
+}
+
+type metaPair struct {
+	k MetaKey
+	v string
+}
+
+// NewObjectBuilder returns a builder validating the owner using
+// ValidateUUIDOwner and the meta data using DefaultMetaLimits.
+func NewObjectBuilder() *ObjectBuilder {
+	return &ObjectBuilder{
+		validator: ValidateUUIDOwner,
+		limits:    DefaultMetaLimits(),
+	}
+}
+
+// ID sets the id of the object which has to be a UUID.
+// A random id is used if it is not set.
+func (ob *ObjectBuilder) ID(id string) *ObjectBuilder {
+	ob.id = id
+	return ob
+}
+
+func (ob *ObjectBuilder) Name(name string) *ObjectBuilder {
+	ob.name = name
+	return ob
+}
+
+func (ob *ObjectBuilder) Owner(owner string) *ObjectBuilder {
+	ob.owner = owner
+	return ob
+}
+
+// ContentType sets the media type of the payload. If it is not
+// set the content type is derived from the extension of the name.
+func (ob *ObjectBuilder) ContentType(ct string) *ObjectBuilder {
+	ob.contentType = ct
+	return ob
+}
+
+// Meta sets the user defined meta data with the key k.
+func (ob *ObjectBuilder) Meta(k MetaKey, v string) *ObjectBuilder {
+	ob.meta = append(ob.meta, metaPair{k: k, v: v})
+	return ob
+}
+
+// Payload sets the payload of the object.
+func (ob *ObjectBuilder) Payload(p []byte) *ObjectBuilder {
+	ob.payload, ob.r = p, nil
+	return ob
+}
+
+// FromReader reads the payload of the object from r in Build.
+func (ob *ObjectBuilder) FromReader(r io.Reader) *ObjectBuilder {
+	ob.payload, ob.r = nil, r
+	return ob
+}
+
+// OwnerValidator sets the validator of the owner which
+// should be the same as BucketOptions.OwnerValidator.
+func (ob *ObjectBuilder) OwnerValidator(v OwnerValidator) *ObjectBuilder {
+	ob.validator = v
+	return ob
+}
+
+// MetaLimits sets the limits of the user defined meta data
+// which should be the same as BucketOptions.MetaLimits.
+func (ob *ObjectBuilder) MetaLimits(l MetaLimits) *ObjectBuilder {
+	ob.limits = l
+	return ob
+}
+
+// Build validates all fields and returns the object. The returned
+// error joins a *FieldError for every invalid field so all of them
+// can be reported at once. The wrapped errors can be checked using
+// errors.Is e.g. ErrInvalidNamePattern.
+func (ob *ObjectBuilder) Build() (*Object, error) {
+	errs := make([]error, 0)
+	invalid := func(field string, err error) {
+		errs = append(errs, &FieldError{Field: field, Err: err})
+	}
+	id := ob.id
+	if id == "" {
+		id = uuid.NewString()
+	} else if !isValidUUID(id) {
+		invalid("id", ErrInvalidObjectID)
+	}
+	if ob.name == "" {
+		invalid("name", ErrMustIncludeOwnerAndName)
+	} else if !isValidObjectName(ob.name) {
+		invalid("name", ErrInvalidNamePattern)
+	}
+	if ob.owner == "" {
+		invalid("owner", ErrMustIncludeOwnerAndName)
+	} else if err := ob.validator(ob.owner); err != nil {
+		invalid("owner", err)
+	}
+	meta := NewMetadata()
+	for _, p := range ob.meta {
+		field := "meta." + p.k.String()
+		switch {
+		case p.k == "":
+			invalid(field, ErrEmptyMetaKey)
+		case meta.isSystemMetaKey(p.k):
+			invalid(field, ErrSystemMetaKey)
+		case p.v == "":
+			invalid(field, ErrEmptyMetaValue)
+		default:
+			meta.set(p.k, p.v)
+		}
+	}
+	// the content type set using Meta is
+	// overwritten by the one set explicitly
+	contentType := ob.contentType
+	if contentType == "" {
+		contentType = meta.Get(MetaKeyContentType)
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(ob.name))
+	}
+	if contentType == "" {
+		invalid("contentType", ErrContentTypeNotExist)
+	} else if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		invalid("contentType", fmt.Errorf("%w: %v", ErrInvalidContentType, err))
+	}
+	meta.set(MetaKeyContentType, contentType)
+	if err := ob.limits.check(meta); err != nil {
+		invalid("meta", err)
+	}
+	payload := ob.payload
+	var err error
+	if ob.r != nil {
+		payload, err = io.ReadAll(ob.r)
+	}
+	if err != nil {
+		invalid("payload", err)
+	} else if len(payload) == 0 {
+		invalid("payload", ErrEmptyPayload)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	meta.set(MetaKeyID, id)
+	meta.set(MetaKeyName, ob.name)
+	meta.set(MetaKeyOwner, ob.owner)
+	return &Object{
+		meta:      meta,
+		pl:        bytes.NewBuffer(payload),
+		isMutable: true,
+	}, nil
+}
@@ -0,0 +1,46 @@
+package objst
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestObjectBuilder(t *testing.T) {
+	o, err := NewObjectBuilder().
+		Name(tEnv.name()).
+		Owner(tEnv.owner()).
+		ContentType("text/plain").
+		Meta("foo", "bar").
+		FromReader(strings.NewReader("payload")).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(o.Payload()) != "payload" || o.GetMetaKey("foo") != "bar" || o.GetMetaKey(MetaKeyContentType) != "text/plain" {
+		t.Fatalf("object is not built as expected")
+	}
+	if err := tEnv.b.Create(o); err != nil {
+		t.Fatalf("built object should be insertable. Got: %v", err)
+	}
+
+	_, err = NewObjectBuilder().
+		Name("no extension").
+		Owner("not a uuid").
+		Meta(MetaKeyID, "id").
+		Meta("empty", "").
+		Build()
+	for _, want := range []error{ErrInvalidNamePattern, ErrInvalidOwner, ErrContentTypeNotExist, ErrSystemMetaKey, ErrEmptyMetaValue, ErrEmptyPayload} {
+		if !errors.Is(err, want) {
+			t.Errorf("expected error %q. Got: %v", want, err)
+		}
+	}
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "name" {
+		t.Fatalf("expected a field error of the name. Got: %v", err)
+	}
+	_, err = NewObjectBuilder().Name("a.txt").Owner(tEnv.owner()).ContentType("/plain").Payload([]byte("x")).Build()
+	if !errors.Is(err, ErrInvalidContentType) {
+		t.Fatalf("expected an invalid content type. Got: %v", err)
+	}
+}
@@ -0,0 +1,201 @@
+package objst
+
+import (
+	"errors"
+)
+
+// Bundle is a group of objects which are published atomically
+// e.g. the files of a website or a versioned set of documents.
+// Consumers either see all objects of the bundle under their
+// final names or none of them.
+type Bundle struct {
+	b         *Bucket
+	objs      []*Object
+	published bool
+}
+
+// NewBundle returns an empty bundle of the bucket.
+func (b *Bucket) NewBundle() *Bundle {
+	return &Bundle{b: b, objs: make([]*Object, 0)}
+}
+
+// Add stages the objects in the bundle. The objects are
+// validated when the bundle is published.
+func (bu *Bundle) Add(objs ...*Object) error {
+	if bu.published {
+		return ErrBundlePublished
+	}
+	for _, obj := range objs {
+		if !obj.isMutable {
+			return ErrObjectIsImmutable
+		}
+	}
+	bu.objs = append(bu.objs, objs...)
+	return nil
+}
+
+// Len returns the number of staged objects.
+func (bu *Bundle) Len() int {
+	return len(bu.objs)
+}
+
+// Publish publishes all objects of the bundle. Objects with the
+// same name and owner as an object of the bundle are replaced. The
+// meta data of all objects is inserted in one transaction and the
+// names are switched to the new objects in another one so reads by
+// name never see a mix of replaced and published objects. If any
+// object is invalid or a replaced object is locked, nothing is
+// published.
+func (bu *Bundle) Publish() error {
+	b := bu.b
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	op := b.startOp("PublishBundle")
+	for _, obj := range bu.objs {
+		op.IDs = append(op.IDs, obj.ID())
+		op.Size += int64(obj.pl.Len())
+	}
+	defer b.finishOp(op)
+	if bu.published {
+		return ErrBundlePublished
+	}
+	if len(bu.objs) == 0 {
+		return ErrEmptyBundle
+	}
+	if err := b.hasDuplicateNames(bu.objs); err != nil {
+		return err
+	}
+	replaced, err := bu.replaced()
+	if err != nil {
+		return err
+	}
+	entries, err := b.createBatchEntries(bu.objs, CreateOptions{replace: true})
+	if err != nil {
+		return err
+	}
+	deltas := make([]Usage, 0, len(bu.objs))
+	for _, obj := range bu.objs {
+		deltas = append(deltas, b.usageOf(obj.meta))
+	}
+	release, err := b.reserveQuota(deltas...)
+	if err != nil {
+		return err
+	}
+	defer release()
+	if err := bu.insertPayloads(entries); err != nil {
+		bu.rollback(false)
+		return err
+	}
+	err = b.meta.Update(func(txn *storeTxn) error {
+		for i, obj := range bu.objs {
+			e := newEntry([]byte(obj.ID()), entries[i].meta)
+			if err := txn.SetEntry(withExpiry(e, obj.meta)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	for _, obj := range bu.objs {
+		b.cache.invalidate(obj.ID())
+	}
+	if err != nil {
+		bu.rollback(false)
+		return err
+	}
+	err = b.name.Update(func(txn *storeTxn) error {
+		for _, obj := range bu.objs {
+			name := newEntry([]byte(b.nameFormat(obj.Name(), obj.Owner())), nameValue(obj.meta))
+			if err := txn.SetEntry(withExpiry(name, obj.meta)); err != nil {
+				return err
+			}
+			for _, k := range b.indexEntries(obj.meta) {
+				if err := txn.SetEntry(withExpiry(newEntry(k, nil), obj.meta)); err != nil {
+					return err
+				}
+			}
+			sum := newEntry(checksumKey(obj.Checksum(), obj.ID()), nil)
+			if err := txn.SetEntry(withExpiry(sum, obj.meta)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		bu.rollback(true)
+		return err
+	}
+	bu.published = true
+	for _, obj := range bu.objs {
+		obj.markAsImmutable()
+		if err := b.metaInserted(obj.ID(), obj.meta); err != nil {
+			return err
+		}
+	}
+	for _, meta := range replaced {
+		// objects deleted concurrently are already removed
+		if err := b.removeObject(meta); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// replaced returns the meta data of the objects which
+// are replaced by the bundle. ErrObjectLocked is returned
+// if any of them is retained.
+func (bu *Bundle) replaced() ([]*Metadata, error) {
+	replaced := make([]*Metadata, 0)
+	for _, obj := range bu.objs {
+		id, _, err := bu.b.resolveEntry(obj.Name(), obj.Owner())
+		if errors.Is(err, ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		meta, err := bu.b.GetMeta(id)
+		if errors.Is(err, ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := checkRetention(meta); err != nil {
+			return nil, err
+		}
+		replaced = append(replaced, meta)
+	}
+	return replaced, nil
+}
+
+func (bu *Bundle) insertPayloads(entries []batchEntry) error {
+	wb := bu.b.payload.NewWriteBatch()
+	defer wb.Cancel()
+	for _, e := range entries {
+		for _, pe := range e.payload {
+			if err := wb.SetEntry(pe); err != nil {
+				return err
+			}
+		}
+	}
+	return wb.Flush()
+}
+
+// rollback removes the payloads and, if they were
+// inserted, the meta data of the objects of the bundle.
+// Errors are only logged because the rollback is done
+// while returning the error which caused it.
+func (bu *Bundle) rollback(withMeta bool) {
+	for _, obj := range bu.objs {
+		err := bu.b.deletePayload(obj.ID())
+		if err == nil && withMeta {
+			err = bu.b.deleteMeta(obj.ID())
+		}
+		if err != nil && bu.b.opts.Logger != nil {
+			bu.b.opts.Logger.Warningf("rolling back %s of the bundle failed: %v", obj.ID(), err)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package objst
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBundle(t *testing.T) {
+	old := tEnv.obj()
+	owner := old.Owner()
+	if err := tEnv.b.Create(old); err != nil {
+		t.Fatal(err)
+	}
+	bu := tEnv.b.NewBundle()
+	if err := bu.Publish(); !errors.Is(err, ErrEmptyBundle) {
+		t.Fatalf("empty bundle should not be published. Got: %v", err)
+	}
+	replacement, err := NewObject(old.Name(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement.SetMetaKey(MetaKeyContentType, "text/plain")
+	replacement.Write(tEnv.payload(10))
+	added, err := NewObject(tEnv.name(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added.SetMetaKey(MetaKeyContentType, "text/plain")
+	added.Write(tEnv.payload(10))
+	if err := bu.Add(replacement, added); err != nil {
+		t.Fatal(err)
+	}
+	if err := bu.Publish(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tEnv.b.GetByName(old.Name(), owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID() != replacement.ID() {
+		t.Fatalf("name should reference the published object. Got: %s", got.ID())
+	}
+	if _, err := tEnv.b.GetByID(old.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("replaced object should be deleted. Got: %v", err)
+	}
+	if _, err := tEnv.b.GetByName(added.Name(), owner); err != nil {
+		t.Fatal(err)
+	}
+	if err := bu.Publish(); !errors.Is(err, ErrBundlePublished) {
+		t.Fatalf("bundle should only be published once. Got: %v", err)
+	}
+}
+
+func TestBundleLocked(t *testing.T) {
+	locked := tEnv.obj()
+	if err := tEnv.b.Create(locked); err != nil {
+		t.Fatal(err)
+	}
+	if err := tEnv.b.Lock(locked.ID(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	added := tEnv.obj()
+	replacement, err := NewObject(locked.Name(), locked.Owner())
+	if err != nil {
+		t.Fatal(err)
+	}
+	replacement.SetMetaKey(MetaKeyContentType, "text/plain")
+	replacement.Write(tEnv.payload(10))
+	bu := tEnv.b.NewBundle()
+	if err := bu.Add(added, replacement); err != nil {
+		t.Fatal(err)
+	}
+	if err := bu.Publish(); !errors.Is(err, ErrObjectLocked) {
+		t.Fatalf("locked object should not be replaced. Got: %v", err)
+	}
+	if _, err := tEnv.b.GetByID(added.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("no object of the bundle should be published. Got: %v", err)
+	}
+}
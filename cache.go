@@ -0,0 +1,233 @@
+package objst
+
+import (
+	"bytes"
+	"container/list"
+	"sync"
+	"time"
+)
+
+// CacheStats describes the usage of the object cache.
+// See BucketOptions.CacheSize.
+type CacheStats struct {
+	Entries int `json:"entries"`
+	// Size is the estimated size in bytes
+	// of the cached meta data and payloads.
+	Size   int64  `json:"size"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// objectCache is a size-bounded LRU cache of the meta data and
+// the small payloads of objects. Every write of an object removes
+// it from the cache. Values read from the stores are only cached if
+// no object was written since the read started which prevents a
+// concurrent read from caching the value replaced by the write.
+type objectCache struct {
+	mu sync.Mutex
+	// maxSize is the maximum estimated size in bytes
+	// of all entries and maxPayload the maximum size
+	// of a payload to be cached.
+	maxSize    int64
+	maxPayload int64
+	size       int64
+	// epoch is incremented by every invalidation.
+	// See ticket.
+	epoch   uint64
+	lru     *list.List
+	entries map[string]*list.Element
+	hits    uint64
+	misses  uint64
+}
+
+type cacheEntry struct {
+	id   string
+	meta map[MetaKey]string
+	// payload is the decoded payload of the generation
+	// of meta. It is nil if the payload isn't cached.
+	payload []byte
+	size    int64
+}
+
+// newObjectCache returns nil if the cache is disabled.
+// All methods of a nil cache are no-ops.
+func newObjectCache(maxSize, maxPayload int64) *objectCache {
+	if maxSize <= 0 {
+		return nil
+	}
+	return &objectCache{
+		maxSize:    maxSize,
+		maxPayload: maxPayload,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// ticket has to be retrieved before reading a value from the
+// stores which is cached afterwards using putMeta or putPayload.
+func (c *objectCache) ticket() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// meta returns a copy of the cached meta data of the object.
+func (c *objectCache) meta(id string) (*Metadata, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.lookup(id)
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	meta := NewMetadata()
+	meta.FromMap(e.meta)
+	return meta, true
+}
+
+// payload returns a copy of the cached payload of the
+// object if it belongs to the generation of meta.
+func (c *objectCache) payload(meta *Metadata) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.lookup(meta.Get(MetaKeyID))
+	if !ok || e.payload == nil || e.meta[MetaKeyGeneration] != meta.Get(MetaKeyGeneration) {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	return bytes.Clone(e.payload), true
+}
+
+// lookup returns the entry of the object and marks it as recently
+// used. Entries of expired objects are removed. c.mu must be held.
+func (c *objectCache) lookup(id string) (*cacheEntry, bool) {
+	el, ok := c.entries[id]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*cacheEntry)
+	meta := Metadata{data: e.meta}
+	if at, ok := expiryOf(&meta); ok && !time.Now().Before(at) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return e, true
+}
+
+// putMeta caches a copy of the meta data of the object if
+// no object was invalidated since the ticket was retrieved.
+func (c *objectCache) putMeta(ticket uint64, meta *Metadata) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ticket != c.epoch {
+		return
+	}
+	id := meta.Get(MetaKeyID)
+	if el, ok := c.entries[id]; ok {
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.add(&cacheEntry{id: id, meta: meta.ToMap()})
+}
+
+// putPayload caches the meta data and the payload of the object
+// if the payload is small enough and no object was invalidated
+// since the ticket was retrieved.
+func (c *objectCache) putPayload(ticket uint64, meta *Metadata, pl []byte) {
+	if c == nil || int64(len(pl)) > c.maxPayload {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if ticket != c.epoch {
+		return
+	}
+	id := meta.Get(MetaKeyID)
+	if el, ok := c.entries[id]; ok {
+		c.remove(el)
+	}
+	c.add(&cacheEntry{id: id, meta: meta.ToMap(), payload: bytes.Clone(pl)})
+}
+
+// add inserts the entry and evicts the least recently
+// used entries exceeding the size. c.mu must be held.
+func (c *objectCache) add(e *cacheEntry) {
+	e.size = int64(len(e.payload))
+	for k, v := range e.meta {
+		e.size += int64(len(k) + len(v))
+	}
+	if e.size > c.maxSize {
+		return
+	}
+	c.entries[e.id] = c.lru.PushFront(e)
+	c.size += e.size
+	for c.size > c.maxSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *objectCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, e.id)
+	c.size -= e.size
+}
+
+// invalidate removes the objects from the cache. It has
+// to be called after every write of the meta data or the
+// payload of an object.
+func (c *objectCache) invalidate(ids ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	for _, id := range ids {
+		if el, ok := c.entries[id]; ok {
+			c.remove(el)
+		}
+	}
+}
+
+// clear removes all objects from the cache e.g.
+// after the stores were restored from a backup.
+func (c *objectCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	c.lru.Init()
+	c.entries = make(map[string]*list.Element)
+	c.size = 0
+}
+
+func (c *objectCache) stats() CacheStats {
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{
+		Entries: len(c.entries),
+		Size:    c.size,
+		Hits:    c.hits,
+		Misses:  c.misses,
+	}
+}
@@ -0,0 +1,96 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestObjectCache(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.CacheSize = 1 << 20
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := b.GetByID(o.ID()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := b.Stats().Cache
+	if stats.Entries != 1 || stats.Hits == 0 {
+		t.Fatalf("object should be served from the cache. Got: %+v", stats)
+	}
+
+	if err := b.UpdateMeta(o.ID(), map[MetaKey]string{"foo": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := b.GetMeta(o.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Get("foo") != "bar" {
+		t.Fatal("updated meta data should be read after the update")
+	}
+	if err := b.PatchPayload(o.ID(), 0, []byte("patched")); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := b.Read(o.ID(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("patched")) {
+		t.Fatalf("patched payload should be read after the patch. Got: %s", buf.Bytes())
+	}
+	if err := b.DeleteByID(o.ID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetByID(o.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("deleted object should not be served from the cache. Got: %v", err)
+	}
+}
+
+func TestObjectCacheEviction(t *testing.T) {
+	c := newObjectCache(100, 60)
+	newMeta := func(id string) *Metadata {
+		meta := NewMetadata()
+		meta.set(MetaKeyID, id)
+		return meta
+	}
+	small, large := newMeta("a"), newMeta("b")
+	c.putPayload(c.ticket(), small, make([]byte, 40))
+	c.putPayload(c.ticket(), large, make([]byte, 61))
+	if _, ok := c.payload(large); ok {
+		t.Fatal("payload larger than the maximum payload size should not be cached")
+	}
+	ticket := c.ticket()
+	c.invalidate(large.Get(MetaKeyID))
+	c.putMeta(ticket, large)
+	if _, ok := c.meta(large.Get(MetaKeyID)); ok {
+		t.Fatal("value read before an invalidation should not be cached")
+	}
+	c.putPayload(c.ticket(), large, make([]byte, 60))
+	if _, ok := c.meta(small.Get(MetaKeyID)); ok {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	if stats := c.stats(); stats.Entries != 1 || stats.Size > 100 {
+		t.Fatalf("cache should be bounded by its size. Got: %+v", stats)
+	}
+
+	expired := newMeta("c")
+	expired.set(MetaKeyExpiresAt, formatTime(time.Now().Add(-time.Second)))
+	c.putMeta(c.ticket(), expired)
+	if _, ok := c.meta(expired.Get(MetaKeyID)); ok {
+		t.Fatal("expired object should not be served from the cache")
+	}
+}
@@ -0,0 +1,228 @@
+package objst
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// changePrefix is the prefix of the change feed entries in
+	// the name store. Object names cannot start with "#" so the
+	// entries never collide with a name entry.
+	changePrefix = "#changes/"
+	// changeSeqKey is the key of the highest sequence number
+	// of the change feed. It is stored separately because the
+	// entries of the change feed may expire.
+	changeSeqKey = "#changeseq"
+)
+
+// ChangeOp is the kind of change of an object.
+type ChangeOp string
+
+const (
+	// ChangePut means that the object was created
+	// or its meta data or payload was updated.
+	ChangePut ChangeOp = "put"
+	// ChangeDelete means that the object was deleted.
+	ChangeDelete ChangeOp = "delete"
+)
+
+// Change is an entry of the change feed of the bucket.
+type Change struct {
+	// Seq is the sequence number of the change. Sequence
+	// numbers are increasing but not necessarily contiguous.
+	Seq uint64   `json:"seq"`
+	Op  ChangeOp `json:"op"`
+	ID  string   `json:"id"`
+	// Info is the current ObjectInfo of the object
+	// and is nil for ChangeDelete.
+	Info *ObjectInfo `json:"info,omitempty"`
+}
+
+// changeEntry is the persisted entry of the change
+// feed. The sequence number is part of the key.
+type changeEntry struct {
+	Op    ChangeOp `json:"op"`
+	ID    string   `json:"id"`
+	Owner string   `json:"owner"`
+}
+
+// changeKey returns the key of the change feed entry in the
+// format #changes/<seq>. The sequence number is zero padded
+// so the entries are ordered by it.
+func changeKey(seq uint64) []byte {
+	return []byte(fmt.Sprintf("%s%020d", changePrefix, seq))
+}
+
+// Changes returns the changes of the objects of the owner with a
+// sequence number greater than since in the order they happened,
+// which allows offline clients to reconcile their local listings
+// cheaply. Only the latest change of every object is returned and
+// the ObjectInfo is the current one. At most limit changes are
+// returned if limit is positive. The returned sequence number has
+// to be passed as since by the next call. Entries are kept for
+// ChangeRetention so clients which haven't synced in the meantime
+// have to list all objects again. An empty owner returns the
+// changes of all owners.
+func (b *Bucket) Changes(owner string, since uint64, limit int) ([]Change, uint64, error) {
+	return b.ChangesWithOptions(ChangesOptions{Owner: owner, Since: since, Limit: limit})
+}
+
+// ChangesOptions are the options of ChangesWithOptions.
+// Empty fields are not filtering.
+type ChangesOptions struct {
+	Owner string
+	// Op and ID limit the changes to the recorded
+	// changes of the kind or of the object.
+	Op ChangeOp
+	ID string
+	// Since is the sequence number returned by the previous call.
+	Since uint64
+	// Limit is the maximum number of matching changes if positive.
+	Limit int
+}
+
+// ChangesWithOptions is like Changes but the changes can also be
+// filtered by their kind and object. The filters are applied while
+// scanning the change feed so Limit only counts matching changes and
+// the returned sequence number never skips a matching change.
+func (b *Bucket) ChangesWithOptions(opts ChangesOptions) ([]Change, uint64, error) {
+	owner, since, limit := opts.Owner, opts.Since, opts.Limit
+	if limit < 0 {
+		return nil, since, ErrNegativeLimit
+	}
+	changes := make([]Change, 0)
+	next := since
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(changePrefix)
+		for it.Seek(changeKey(since + 1)); it.ValidForPrefix(prefix); it.Next() {
+			seq, err := strconv.ParseUint(strings.TrimPrefix(string(it.Item().Key()), changePrefix), 10, 64)
+			if err != nil {
+				return err
+			}
+			var e changeEntry
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &e)
+			}); err != nil {
+				return err
+			}
+			next = seq
+			if (owner != "" && e.Owner != owner) || (opts.Op != "" && e.Op != opts.Op) || (opts.ID != "" && e.ID != opts.ID) {
+				continue
+			}
+			changes = append(changes, Change{Seq: seq, Op: e.Op, ID: e.ID})
+			if limit > 0 && len(changes) == limit {
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, since, err
+	}
+	changes, err = b.latestChanges(changes)
+	return changes, next, err
+}
+
+// latestChanges removes all changes which are superseded by a
+// later change of the same object and adds the current ObjectInfo
+// to the remaining ChangePut changes. Objects which were deleted
+// after the change are reported as deleted.
+func (b *Bucket) latestChanges(changes []Change) ([]Change, error) {
+	last := make(map[string]int, len(changes))
+	for i, c := range changes {
+		last[c.ID] = i
+	}
+	latest := make([]Change, 0, len(last))
+	for i, c := range changes {
+		if last[c.ID] != i {
+			continue
+		}
+		if c.Op == ChangePut {
+			meta, err := b.GetMeta(c.ID)
+			if errors.Is(err, ErrObjectNotFound) {
+				c.Op = ChangeDelete
+				latest = append(latest, c)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			obj := &Object{meta: meta, pl: new(bytes.Buffer)}
+			c.Info = obj.Info()
+		}
+		latest = append(latest, c)
+	}
+	return latest, nil
+}
+
+// recordChanges appends a change for every object with the given
+// meta data to the change feed and emits the events of the kind t
+// to the watchers. changeMu is held until the entries are committed
+// so the entries are visible in the order of their sequence numbers.
+func (b *Bucket) recordChanges(t EventType, metas ...*Metadata) error {
+	b.changeMu.Lock()
+	defer b.changeMu.Unlock()
+	op := ChangePut
+	if t == EventDeleted {
+		op = ChangeDelete
+	}
+	seq := b.changeSeq
+	err := b.updateAudited(func(txn *storeTxn) error {
+		for _, meta := range metas {
+			seq++
+			data, err := json.Marshal(changeEntry{
+				Op:    op,
+				ID:    meta.Get(MetaKeyID),
+				Owner: meta.Get(MetaKeyOwner),
+			})
+			if err != nil {
+				return err
+			}
+			e := newEntry(changeKey(seq), data)
+			if b.opts.ChangeRetention > 0 {
+				e = e.withTTL(b.opts.ChangeRetention)
+			}
+			if err := txn.SetEntry(e); err != nil {
+				return err
+			}
+		}
+		return txn.Set([]byte(changeSeqKey), binary.BigEndian.AppendUint64(nil, seq))
+	}, auditEntries(t, metas)...)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for i, meta := range metas {
+		b.publish(t, b.changeSeq+uint64(i)+1, meta, now)
+	}
+	b.changeSeq = seq
+	return nil
+}
+
+// loadChangeSeq loads the highest sequence number of the change feed.
+func (b *Bucket) loadChangeSeq() error {
+	b.changeMu.Lock()
+	defer b.changeMu.Unlock()
+	return b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(changeSeqKey))
+		if errors.Is(err, ErrStoreKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			b.changeSeq = binary.BigEndian.Uint64(val)
+			return nil
+		})
+	})
+}
@@ -0,0 +1,139 @@
+package objst
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestChanges(t *testing.T) {
+	owner := tEnv.owner()
+	objs := make([]*Object, 0, 3)
+	for i := 0; i < 3; i++ {
+		o, _ := NewObject(tEnv.name(), owner)
+		o.Write(tEnv.payload(10))
+		objs = append(objs, o)
+	}
+	if err := tEnv.b.BatchCreate(objs[:2]); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.UpdateMeta(objs[0].ID(), map[MetaKey]string{"foo": "bar"}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.DeleteByID(objs[1].ID()); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.Create(objs[2]); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.Create(tEnv.obj()); err != nil {
+		t.Error(err)
+		return
+	}
+	changes, seq, err := tEnv.b.Changes(owner, 0, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	type change struct {
+		Op ChangeOp
+		ID string
+	}
+	got := make([]change, 0, len(changes))
+	for _, c := range changes {
+		got = append(got, change{Op: c.Op, ID: c.ID})
+	}
+	want := []change{
+		{Op: ChangePut, ID: objs[0].ID()},
+		{Op: ChangeDelete, ID: objs[1].ID()},
+		{Op: ChangePut, ID: objs[2].ID()},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("changes are not the same: %s", diff)
+	}
+	if changes[0].Info == nil || changes[0].Info.UserMeta["foo"] != "bar" {
+		t.Fatalf("change should contain the current info. Got: %+v", changes[0].Info)
+	}
+	if changes[1].Info != nil {
+		t.Fatalf("delete should not contain an info. Got: %+v", changes[1].Info)
+	}
+	changes, next, err := tEnv.b.Changes(owner, seq, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(changes) != 0 || next < seq {
+		t.Fatalf("no changes should be returned since %d. Got: %d changes and %d", seq, len(changes), next)
+	}
+	// the create of the second object is reported as deleted
+	changes, next, err = tEnv.b.Changes(owner, 0, 2)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(changes) != 2 || changes[1].Op != ChangeDelete {
+		t.Fatalf("first page is not correct. Got: %+v", changes)
+	}
+	changes, _, err = tEnv.b.Changes(owner, next, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(changes) != 3 {
+		t.Fatalf("second page should contain the remaining changes. Got: %+v", changes)
+	}
+}
+
+func TestHTTPChanges(t *testing.T) {
+	owner := tEnv.owner()
+	o, _ := NewObject(tEnv.name(), owner)
+	o.Write(tEnv.payload(10))
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	target, err := url.JoinPath(tEnv.ts.URL, route, "changes")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	injectOwner := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), CtxKeyOwner, owner)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	r := httptest.NewRequest(http.MethodGet, target+"?since=0&limit=10", nil)
+	w := httptest.NewRecorder()
+	injectOwner(tEnv.h).ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusOK, w.Code)
+	}
+	res := changesModel{}
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(res.Changes) != 1 || res.Changes[0].ID != o.ID() || res.Changes[0].Info.Name != o.Name() {
+		t.Fatalf("changes are not correct. Got: %+v", res.Changes)
+	}
+	if res.Seq != res.Changes[0].Seq {
+		t.Fatalf("seq should be the seq of the last change. Got: %d", res.Seq)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, target+"?since=abc", nil)
+	w = httptest.NewRecorder()
+	injectOwner(tEnv.h).ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusBadRequest, w.Code)
+	}
+}
@@ -0,0 +1,89 @@
+package objst
+
+import (
+	"errors"
+	"strings"
+)
+
+// checksumIndexPrefix is the prefix of the checksum index
+// entries in the name store. Object names cannot start with
+// "#" so the entries never collide with a name entry.
+const checksumIndexPrefix = "#checksum/"
+
+// checksumKey returns the key of the checksum
+// index entry in the format #checksum/<sum>/<id>.
+func checksumKey(sum, id string) []byte {
+	return []byte(checksumIndexPrefix + sum + "/" + id)
+}
+
+// FindByChecksum returns the ids of all objects whose payload
+// has the given checksum. It allows clients to detect if the
+// content already exists before uploading it.
+func (b *Bucket) FindByChecksum(sum string) ([]string, error) {
+	ids := make([]string, 0)
+	prefix := []byte(checksumIndexPrefix + sum + "/")
+	err := b.name.View(func(txn *storeTxn) error {
+		opts := defaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			ids = append(ids, strings.TrimPrefix(string(it.Item().Key()), string(prefix)))
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// MissingChecksums returns the checksums for which the owner
+// has no object. Backup and sync clients can use it to upload
+// only the content which is not already stored in the bucket.
+func (b *Bucket) MissingChecksums(owner string, sums []string) ([]string, error) {
+	missing := make([]string, 0)
+	for _, sum := range sums {
+		ok, err := b.hasChecksum(owner, sum)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			missing = append(missing, sum)
+		}
+	}
+	return missing, nil
+}
+
+// hasChecksum reports if the owner has
+// an object with the given checksum.
+func (b *Bucket) hasChecksum(owner, sum string) (bool, error) {
+	ids, err := b.FindByChecksum(sum)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		meta, err := b.GetMeta(id)
+		if errors.Is(err, ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if meta.Get(MetaKeyOwner) == owner {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// reindexChecksum moves the checksum index entry of
+// the object with the given id from oldSum to newSum.
+func (b *Bucket) reindexChecksum(id, oldSum, newSum string) error {
+	if oldSum == newSum {
+		return nil
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		if err := txn.Delete(checksumKey(oldSum, id)); err != nil {
+			return err
+		}
+		return txn.Set(checksumKey(newSum, id), nil)
+	})
+}
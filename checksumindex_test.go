@@ -0,0 +1,53 @@
+package objst
+
+import (
+	"golang.org/x/exp/slices"
+	"testing"
+)
+
+func TestFindByChecksum(t *testing.T) {
+	o := tEnv.obj()
+	oB, _ := NewObject(tEnv.name(), tEnv.owner())
+	oB.Write(o.Payload())
+	if err := tEnv.b.BatchCreate([]*Object{o, oB}); err != nil {
+		t.Error(err)
+		return
+	}
+	ids, err := tEnv.b.FindByChecksum(o.Checksum())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(ids) != 2 || !slices.Contains(ids, o.ID()) || !slices.Contains(ids, oB.ID()) {
+		t.Fatalf("both objects should be found. Got: %v", ids)
+	}
+	if err := tEnv.b.PatchPayload(o.ID(), 0, []byte("patched")); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.DeleteByID(oB.ID()); err != nil {
+		t.Error(err)
+		return
+	}
+	ids, err = tEnv.b.FindByChecksum(oB.Checksum())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(ids) != 0 {
+		t.Fatalf("patched and deleted objects should not be found. Got: %v", ids)
+	}
+	meta, err := tEnv.b.GetMeta(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ids, err = tEnv.b.FindByChecksum(meta.Get(MetaKeyChecksum))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(ids) != 1 || ids[0] != o.ID() {
+		t.Fatalf("patched object should be found by the new checksum. Got: %v", ids)
+	}
+}
@@ -0,0 +1,184 @@
+package objst
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// CreateOptions are the options which can be
+// set for the creation of a single object.
+type CreateOptions struct {
+	// ChunkSize is the maximum size in bytes of one payload
+	// chunk. If it is not set the ChunkSize of the bucket
+	// options will be used.
+	ChunkSize int64
+
+	// Pending quarantines the object until it is approved
+	// or rejected. See Bucket.Approve and Bucket.Reject.
+	Pending bool
+
+	// TTL lets the object expire after the duration
+	// if it is positive. See Bucket.Expire.
+	TTL time.Duration
+
+	// Plaintext stores the payload unencrypted even if the
+	// bucket has a Cipher e.g. for public objects. The payload
+	// stays unencrypted if it is patched or re-encoded.
+	Plaintext bool
+
+	// replace allows objects to take the name of an
+	// existing object. It is only used by bundles.
+	replace bool
+}
+
+// chunkPrefix returns the prefix of all
+// payload chunks of the object with the id.
+func chunkPrefix(id string) []byte {
+	return []byte(id + "/")
+}
+
+// chunkKey returns the key of the i-th payload chunk. The
+// index is zero padded to keep the lexicographical order of
+// the keys equal to the order of the chunks.
+func chunkKey(id string, i int64) []byte {
+	return []byte(fmt.Sprintf("%s/%010d", id, i))
+}
+
+// chunkSizeOf returns the chunk size recorded in the meta
+// data. Zero is returned for objects stored as one value
+// without any chunks.
+func chunkSizeOf(meta *Metadata) int64 {
+	n, _ := strconv.ParseInt(meta.Get(MetaKeyChunkSize), 10, 64)
+	return n
+}
+
+// payloadEntries splits the payload into chunks
+// of chunkSize and returns them as entries.
+func payloadEntries(id string, chunkSize int64, pl []byte) []*Entry {
+	entries := make([]*Entry, 0, int64(len(pl))/chunkSize+1)
+	for i := int64(0); i*chunkSize < int64(len(pl)); i++ {
+		end := (i + 1) * chunkSize
+		if end > int64(len(pl)) {
+			end = int64(len(pl))
+		}
+		entries = append(entries, newEntry(chunkKey(id, i), pl[i*chunkSize:end]))
+	}
+	return entries
+}
+
+// readChunks reads all chunks of the payload in order. Payloads
+// which were stored before chunking existed are read from the
+// single value stored under the id.
+func readChunks(txn *storeTxn, id string) ([]byte, error) {
+	var pl []byte
+	found := false
+	it := txn.NewIterator(defaultIteratorOptions)
+	defer it.Close()
+	prefix := chunkPrefix(id)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		found = true
+		err := it.Item().Value(func(val []byte) error {
+			pl = append(pl, val...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if found {
+		return pl, nil
+	}
+	item, err := txn.Get([]byte(id))
+	if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+// viewChunks calls fn with the value of every chunk of the payload
+// in order without copying it. Like readChunks payloads stored before
+// chunking existed are passed as the single value stored under the id.
+func viewChunks(txn *storeTxn, id string, fn func(chunk []byte) error) error {
+	found := false
+	it := txn.NewIterator(defaultIteratorOptions)
+	defer it.Close()
+	prefix := chunkPrefix(id)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		found = true
+		if err := it.Item().Value(fn); err != nil {
+			return err
+		}
+	}
+	if found {
+		return nil
+	}
+	item, err := txn.Get([]byte(id))
+	if err != nil {
+		return err
+	}
+	return item.Value(fn)
+}
+
+// payloadSize returns the size of the chunked
+// payload without reading the values.
+func payloadSize(txn *storeTxn, id string) int64 {
+	var size int64
+	opts := defaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	prefix := chunkPrefix(id)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		size += it.Item().ValueSize()
+	}
+	return size
+}
+
+// patchChunks writes data at offset into the chunked payload
+// only touching the chunks which are overlapping with the
+// written range or which have to grow to fill a gap.
+func patchChunks(txn *storeTxn, id string, chunkSize, offset int64, data []byte) error {
+	size := payloadSize(txn, id)
+	end := offset + int64(len(data))
+	newSize := size
+	if end > newSize {
+		newSize = end
+	}
+	start := offset
+	if size < start {
+		start = size
+	}
+	for i := start / chunkSize; i*chunkSize < end; i++ {
+		lo := i * chunkSize
+		hi := lo + chunkSize
+		if hi > newSize {
+			hi = newSize
+		}
+		chunk := make([]byte, hi-lo)
+		item, err := txn.Get(chunkKey(id, i))
+		if err == nil {
+			err = item.Value(func(val []byte) error {
+				copy(chunk, val)
+				return nil
+			})
+		}
+		if err != nil && err != ErrStoreKeyNotFound {
+			return err
+		}
+		from, to := lo, hi
+		if offset > from {
+			from = offset
+		}
+		if end < to {
+			to = end
+		}
+		if from < to {
+			copy(chunk[from-lo:], data[from-offset:to-offset])
+		}
+		if err := txn.Set(chunkKey(id, i), chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCreateWithChunkSize(t *testing.T) {
+	tests := []struct {
+		name      string
+		chunkSize int64
+		payload   []byte
+	}{
+		{
+			name:      "multiple chunks",
+			chunkSize: 4,
+			payload:   tEnv.payload(10),
+		},
+		{
+			name:      "exact chunk boundary",
+			chunkSize: 5,
+			payload:   tEnv.payload(10),
+		},
+		{
+			name:      "bucket default",
+			chunkSize: 0,
+			payload:   tEnv.payload(10),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			o := tEnv.emptyObj()
+			o.Write(test.payload)
+			if err := tEnv.b.CreateWithOptions(o, CreateOptions{ChunkSize: test.chunkSize}); err != nil {
+				t.Error(err)
+				return
+			}
+			oG, err := tEnv.b.GetByID(o.ID())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !bytes.Equal(oG.Payload(), test.payload) {
+				t.Fatalf("payload is not the same. Got: %s. Expected: %s", oG.Payload(), test.payload)
+			}
+			want := test.chunkSize
+			if want == 0 {
+				want = tEnv.b.opts.ChunkSize
+			}
+			if chunkSizeOf(oG.meta) != want {
+				t.Fatalf("chunk size is not recorded. Got: %d. Expected: %d", chunkSizeOf(oG.meta), want)
+			}
+		})
+	}
+}
+
+func TestPatchPayloadChunks(t *testing.T) {
+	o := tEnv.emptyObj()
+	o.Write([]byte("0123456789"))
+	if err := tEnv.b.CreateWithOptions(o, CreateOptions{ChunkSize: 4}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.PatchPayload(o.ID(), 3, []byte("abcdef")); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.PatchPayload(o.ID(), 12, []byte("xy")); err != nil {
+		t.Error(err)
+		return
+	}
+	pl, err := tEnv.b.GetPayload(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	want := "012abcdef9\x00\x00xy"
+	if string(pl) != want {
+		t.Fatalf("payload is not patched. Got: %q. Expected: %q", pl, want)
+	}
+}
+
+func TestViewPayload(t *testing.T) {
+	o := tEnv.emptyObj()
+	pl := tEnv.payload(10)
+	o.Write(pl)
+	if err := tEnv.b.CreateWithOptions(o, CreateOptions{ChunkSize: 4}); err != nil {
+		t.Fatal(err)
+	}
+	chunks := 0
+	var got []byte
+	err := tEnv.b.ViewPayload(o.ID(), func(chunk []byte) error {
+		chunks++
+		got = append(got, chunk...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunks != 3 || !bytes.Equal(got, pl) {
+		t.Fatalf("payload should be passed in 3 chunks. Got %d chunks: %s. Expected: %s", chunks, got, pl)
+	}
+	var buf bytes.Buffer
+	if err := tEnv.b.Read(o.ID(), &buf); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), pl) {
+		t.Fatalf("payload is not the same. Got: %s. Expected: %s", buf.Bytes(), pl)
+	}
+	if err := tEnv.b.DeleteByID(o.ID()); err != nil {
+		t.Fatal(err)
+	}
+	err = tEnv.b.ViewPayload(o.ID(), func([]byte) error { return nil })
+	if !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("deleted object should not be found. Got: %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package objst
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+)
+
+// Cipher encrypts the payload of every object with its own
+// data key which is wrapped by a master key (envelope
+// encryption). The payload can't be read without the cipher
+// even if the underlying badger files are accessed directly.
+type Cipher interface {
+	// Encrypt encrypts the plaintext with a new data key. The
+	// returned envelope contains everything except the master
+	// key which is needed to decrypt the ciphertext again.
+	Encrypt(plaintext []byte) ([]byte, Envelope, error)
+
+	// Decrypt decrypts a ciphertext created by Encrypt.
+	Decrypt(ciphertext []byte, env Envelope) ([]byte, error)
+}
+
+// Envelope is stored in the system meta data
+// of an object with an encrypted payload.
+type Envelope struct {
+	// WrappedKey is the data key encrypted
+	// by the master key of the cipher.
+	WrappedKey []byte
+
+	// Nonce used to encrypt the payload.
+	Nonce []byte
+}
+
+type aesGCMCipher struct {
+	master cipher.AEAD
+}
+
+// NewAESGCMCipher returns a Cipher using AES-GCM with 256 bit
+// data keys. The master key must be 16, 24 or 32 bytes long.
+func NewAESGCMCipher(masterKey []byte) (Cipher, error) {
+	master, err := newGCM(masterKey)
+	if err != nil {
+		return nil, err
+	}
+	return &aesGCMCipher{master: master}, nil
+}
+
+func (a aesGCMCipher) Encrypt(plaintext []byte) ([]byte, Envelope, error) {
+	const dataKeySize = 32
+	dataKey, err := randomBytes(dataKeySize)
+	if err != nil {
+		return nil, Envelope{}, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, Envelope{}, err
+	}
+	nonce, err := randomBytes(aead.NonceSize())
+	if err != nil {
+		return nil, Envelope{}, err
+	}
+	keyNonce, err := randomBytes(a.master.NonceSize())
+	if err != nil {
+		return nil, Envelope{}, err
+	}
+	env := Envelope{
+		// the nonce of the wrapped key is
+		// prepended to the wrapped key.
+		WrappedKey: a.master.Seal(keyNonce, keyNonce, dataKey, nil),
+		Nonce:      nonce,
+	}
+	return aead.Seal(nil, nonce, plaintext, nil), env, nil
+}
+
+// FIPSApproved reports true because AES-GCM is FIPS approved.
+func (a aesGCMCipher) FIPSApproved() bool {
+	return true
+}
+
+func (a aesGCMCipher) Decrypt(ciphertext []byte, env Envelope) ([]byte, error) {
+	if len(env.WrappedKey) < a.master.NonceSize() {
+		return nil, ErrInvalidEnvelope
+	}
+	keyNonce, wrapped := env.WrappedKey[:a.master.NonceSize()], env.WrappedKey[a.master.NonceSize():]
+	dataKey, err := a.master.Open(nil, keyNonce, wrapped, nil)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrInvalidEnvelope
+	}
+	return aead.Open(nil, env.Nonce, ciphertext, nil)
+}
+
+type keyRing []Cipher
+
+// NewKeyRing returns a Cipher which encrypts with the first
+// cipher and decrypts with the first cipher which is able to
+// decrypt the payload. It allows to read payloads encrypted
+// with previous master keys while they are re-encrypted with
+// the new one. See Pipeline.
+func NewKeyRing(current Cipher, previous ...Cipher) Cipher {
+	return append(keyRing{current}, previous...)
+}
+
+func (k keyRing) Encrypt(plaintext []byte) ([]byte, Envelope, error) {
+	return k[0].Encrypt(plaintext)
+}
+
+// FIPSApproved reports if all ciphers of the key ring are approved.
+func (k keyRing) FIPSApproved() bool {
+	for _, c := range k {
+		if fc, ok := c.(FIPSCipher); !ok || !fc.FIPSApproved() {
+			return false
+		}
+	}
+	return true
+}
+
+func (k keyRing) Decrypt(ciphertext []byte, env Envelope) ([]byte, error) {
+	var err error
+	for _, c := range k {
+		var pl []byte
+		pl, err = c.Decrypt(ciphertext, env)
+		if err == nil {
+			return pl, nil
+		}
+	}
+	return nil, err
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+// envelopeOf returns the envelope stored in the meta data. The
+// bool is false if the payload of the object is not encrypted.
+func envelopeOf(meta *Metadata) (Envelope, bool) {
+	if !meta.Has(MetaKeyDataKey) {
+		return Envelope{}, false
+	}
+	key, err := base64.StdEncoding.DecodeString(meta.Get(MetaKeyDataKey))
+	if err != nil {
+		return Envelope{}, true
+	}
+	nonce, err := base64.StdEncoding.DecodeString(meta.Get(MetaKeyNonce))
+	if err != nil {
+		return Envelope{}, true
+	}
+	return Envelope{WrappedKey: key, Nonce: nonce}, true
+}
+
+func setEnvelope(meta *Metadata, env Envelope) {
+	meta.set(MetaKeyDataKey, base64.StdEncoding.EncodeToString(env.WrappedKey))
+	meta.set(MetaKeyNonce, base64.StdEncoding.EncodeToString(env.Nonce))
+}
+
+// encryptPayload encrypts the payload if the cipher
+// is not nil and records the envelope in the meta data.
+func encryptPayload(ci Cipher, meta *Metadata, pl []byte) ([]byte, error) {
+	if ci == nil {
+		return pl, nil
+	}
+	ct, env, err := ci.Encrypt(pl)
+	if err != nil {
+		return nil, err
+	}
+	setEnvelope(meta, env)
+	return ct, nil
+}
+
+// isPlaintext reports whether the payload of the object
+// opted out of the encryption. See CreateOptions.Plaintext.
+func isPlaintext(meta *Metadata) bool {
+	return meta.Get(MetaKeyPlaintext) == "true"
+}
+
+// plaintextMeta returns empty meta data to re-encode the
+// payload of the object described by meta which is only
+// keeping the opt-out of the encryption.
+func plaintextMeta(meta *Metadata) *Metadata {
+	encoded := NewMetadata()
+	if isPlaintext(meta) {
+		encoded.set(MetaKeyPlaintext, "true")
+	}
+	return encoded
+}
+
+// decryptPayload decrypts the payload iff the
+// meta data is containing an envelope.
+func (b *Bucket) decryptPayload(meta *Metadata, pl []byte) ([]byte, error) {
+	env, ok := envelopeOf(meta)
+	if !ok {
+		return pl, nil
+	}
+	if b.opts.Cipher == nil {
+		return nil, ErrMissingCipher
+	}
+	return b.opts.Cipher.Decrypt(pl, env)
+}
+
+// encryptLegacyPipeline is the name of the pipeline started by
+// EncryptLegacy.
+const encryptLegacyPipeline = "encrypt-legacy"
+
+// EncryptLegacy encrypts the payloads of the objects created before
+// the Cipher of the bucket was configured in the background. The
+// objects are readable while they are encrypted because unencrypted
+// payloads are read as they are. Objects which opted out using
+// CreateOptions.Plaintext are skipped. The pipeline is stopped by
+// StopPipeline and resumed by calling EncryptLegacy again.
+func (b *Bucket) EncryptLegacy(throttle time.Duration) error {
+	if b.opts.Cipher == nil {
+		return ErrCipherNotConfigured
+	}
+	return b.StartPipeline(Pipeline{
+		Name:          encryptLegacyPipeline,
+		Compression:   b.opts.PayloadCompression,
+		Cipher:        b.opts.Cipher,
+		Throttle:      throttle,
+		SkipEncrypted: true,
+	})
+}
+
+// EncryptLegacyProgress returns the progress of EncryptLegacy.
+// ErrPipelineNotFound is returned if it was never started.
+func (b *Bucket) EncryptLegacyProgress() (PipelineProgress, error) {
+	return b.PipelineProgress(encryptLegacyPipeline)
+}
@@ -0,0 +1,137 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/naivary/objst/random"
+)
+
+func TestCipher(t *testing.T) {
+	c, err := NewAESGCMCipher([]byte(random.String(32)))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.Cipher = c
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	o := tEnv.obj()
+	pl := o.Payload()
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	var raw []byte
+	err = b.payload.View(func(txn *storeTxn) error {
+		raw, err = readChunks(txn, o.ID())
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if bytes.Contains(raw, pl) {
+		t.Fatalf("stored payload should be encrypted")
+	}
+	oG, err := b.GetByID(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(oG.Payload(), pl) {
+		t.Fatalf("payload is not decrypted. Got: %s. Expected: %s", oG.Payload(), pl)
+	}
+	if err := b.PatchPayload(o.ID(), 0, []byte("patched")); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.Verify(o.ID()); err != nil {
+		t.Fatalf("patched encrypted object should be valid: %v", err)
+	}
+
+	// reading without the cipher must fail
+	b.opts.Cipher = nil
+	if _, err := b.GetByID(o.ID()); !errors.Is(err, ErrMissingCipher) {
+		t.Fatalf("encrypted payload should not be readable without cipher. Got: %v", err)
+	}
+}
+
+func TestEncryptLegacy(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	if err := b.EncryptLegacy(0); !errors.Is(err, ErrCipherNotConfigured) {
+		t.Fatalf("legacy objects cannot be encrypted without a cipher. Got: %v", err)
+	}
+	legacy := tEnv.obj()
+	if err := b.Create(legacy); err != nil {
+		t.Fatal(err)
+	}
+	// enabling the cipher later in the life of the bucket
+	b.opts.Cipher, err = NewAESGCMCipher([]byte(random.String(32)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, encrypted := tEnv.obj(), tEnv.obj()
+	if err := b.CreateWithOptions(plain, CreateOptions{Plaintext: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Create(encrypted); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range []*Object{legacy, plain, encrypted} {
+		got, err := b.GetByID(o.ID())
+		if err != nil {
+			t.Fatalf("mixed mode read of %s failed: %v", o.ID(), err)
+		}
+		if !bytes.Equal(got.Payload(), o.Payload()) {
+			t.Fatalf("payload of %s differs", o.ID())
+		}
+	}
+	if err := b.EncryptLegacy(0); err != nil {
+		t.Fatal(err)
+	}
+	b.pipelineWg.Wait()
+	prog, err := b.EncryptLegacyProgress()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prog.Done || prog.Processed != 1 || prog.Skipped != 2 {
+		t.Fatalf("only the legacy object should be encrypted. Got: %+v", prog)
+	}
+	for _, tc := range []struct {
+		obj       *Object
+		encrypted bool
+	}{{legacy, true}, {plain, false}, {encrypted, true}} {
+		meta, err := b.GetMeta(tc.obj.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := envelopeOf(meta); ok != tc.encrypted {
+			t.Fatalf("payload of %s should be encrypted: %t", tc.obj.ID(), tc.encrypted)
+		}
+		got, err := b.GetByID(tc.obj.ID())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Payload(), tc.obj.Payload()) {
+			t.Fatalf("payload of %s differs after the encryption", tc.obj.ID())
+		}
+	}
+}
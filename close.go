@@ -0,0 +1,87 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Close shuts the bucket down gracefully. The background workers
+// are stopped and the queued repairs of name entries are done
+// before new writes are rejected with ErrBucketClosed. Afterwards
+// Close waits until the in-flight operations are done or the
+// context is done and closes the stores. The stores are closed
+// even if the context is done first which is reported by the
+// returned error. Closing a closed bucket returns ErrBucketClosed.
+func (b *Bucket) Close(ctx context.Context) error {
+	if !b.closed.CompareAndSwap(false, true) {
+		return ErrBucketClosed
+	}
+	b.stopWorkers()
+	errs := make([]error, 0, 2)
+	if err := b.flushNameRepairs(ctx); err != nil {
+		errs = append(errs, err)
+	} else if err := b.rejectWrites(ctx); err != nil {
+		errs = append(errs, err)
+	} else if err := b.drainOps(ctx); err != nil {
+		errs = append(errs, err)
+	}
+	if err := b.closeStores(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// flushNameRepairs repairs the queued name entries
+// which were not repaired by the stopped worker.
+func (b *Bucket) flushNameRepairs(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("repairing the queued names: %w", err)
+		}
+		select {
+		case meta := <-b.nameRepairs:
+			if err := b.repairName(meta); err != nil && b.opts.Logger != nil {
+				b.opts.Logger.Warningf("repairing the name of %s failed: %v", meta.Get(MetaKeyID), err)
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// rejectWrites waits for the in-flight writes
+// and rejects all following writes.
+func (b *Bucket) rejectWrites(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.writeMu.Lock()
+		defer b.writeMu.Unlock()
+		b.closing = true
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the in-flight writes: %w", ctx.Err())
+	}
+}
+
+// drainOps waits until the in-flight operations are done.
+func (b *Bucket) drainOps(ctx context.Context) error {
+	b.opsMu.Lock()
+	if b.ops == 0 {
+		b.opsMu.Unlock()
+		return nil
+	}
+	drained := make(chan struct{})
+	b.drained = drained
+	b.opsMu.Unlock()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("waiting for the in-flight operations: %w", ctx.Err())
+	}
+}
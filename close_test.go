@@ -0,0 +1,79 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	obj := tEnv.obj()
+	if err := b.Create(obj); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(context.Background()); !errors.Is(err, ErrBucketClosed) {
+		t.Fatalf("second close should return ErrBucketClosed. Got: %v", err)
+	}
+}
+
+func TestCloseDrain(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	// an in-flight write holds the write lock
+	done, err := b.beginWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := b.startOp("Test")
+	closed := make(chan error, 1)
+	go func() {
+		closed <- b.Close(context.Background())
+	}()
+	select {
+	case err := <-closed:
+		t.Fatalf("close returned before the write was done: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	done()
+	b.finishOp(op)
+	if err := <-closed; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.beginWrite(); !errors.Is(err, ErrBucketClosed) {
+		t.Fatalf("writes should be rejected after close. Got: %v", err)
+	}
+}
+
+func TestCloseDeadline(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	op := b.startOp("Test")
+	defer b.finishOp(op)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := b.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("close should report the deadline. Got: %v", err)
+	}
+}
@@ -0,0 +1,46 @@
+// Command objst-verify verifies archives created by Bucket.Export
+// against their manifest without opening a bucket:
+//
+//	objst-verify backup.tar [backup.zip ...]
+//
+// It exits with 1 if an archive can't be read or doesn't
+// match its manifest.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/naivary/objst"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: objst-verify <archive>...")
+		os.Exit(2)
+	}
+	failed := false
+	for _, path := range os.Args[1:] {
+		if err := verify(path); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func verify(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	m, err := objst.VerifyArchive(f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s: ok, version %d, %d files\n", path, m.Version, len(m.Entries))
+	return nil
+}
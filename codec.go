@@ -0,0 +1,265 @@
+package objst
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Codec encodes the meta data of the objects in the meta store.
+// A compact binary codec reduces the size of the meta store and
+// speeds up queries scanning the meta data while a codec like
+// JSON allows other languages to read the meta store.
+type Codec interface {
+	// Name returns the name of the codec e.g. "gob".
+	Name() string
+	Marshal(pairs map[MetaKey]string) ([]byte, error)
+	Unmarshal(data []byte) (map[MetaKey]string, error)
+}
+
+var (
+	// CodecGob encodes the meta data using encoding/gob.
+	CodecGob Codec = gobCodec{}
+	// CodecJSON encodes the meta data as a JSON object.
+	CodecJSON Codec = jsonCodec{}
+	// CodecProtobuf encodes the meta data as the protobuf
+	// message `message Metadata { map<string, string> data = 1; }`.
+	CodecProtobuf Codec = protobufCodec{}
+	// CodecMsgpack encodes the meta data as a msgpack map of strings.
+	CodecMsgpack Codec = msgpackCodec{}
+)
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+
+func (gobCodec) Marshal(pairs map[MetaKey]string) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(pairs)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte) (map[MetaKey]string, error) {
+	pairs := make(map[MetaKey]string)
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&pairs)
+	return pairs, err
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+
+func (jsonCodec) Marshal(pairs map[MetaKey]string) ([]byte, error) {
+	return json.Marshal(pairs)
+}
+
+func (jsonCodec) Unmarshal(data []byte) (map[MetaKey]string, error) {
+	pairs := make(map[MetaKey]string)
+	err := json.Unmarshal(data, &pairs)
+	return pairs, err
+}
+
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return "protobuf" }
+
+func (protobufCodec) Marshal(pairs map[MetaKey]string) ([]byte, error) {
+	var data []byte
+	// the keys are sorted to get a deterministic encoding
+	for _, k := range sortedKeys(pairs) {
+		var entry []byte
+		entry = protowire.AppendTag(entry, 1, protowire.BytesType)
+		entry = protowire.AppendString(entry, string(k))
+		entry = protowire.AppendTag(entry, 2, protowire.BytesType)
+		entry = protowire.AppendString(entry, pairs[k])
+		data = protowire.AppendTag(data, 1, protowire.BytesType)
+		data = protowire.AppendBytes(data, entry)
+	}
+	return data, nil
+}
+
+func (protobufCodec) Unmarshal(data []byte) (map[MetaKey]string, error) {
+	pairs := make(map[MetaKey]string)
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		if num != 1 || typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		entry, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+		var k, v string
+		for len(entry) > 0 {
+			num, typ, n := protowire.ConsumeTag(entry)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			entry = entry[n:]
+			if typ != protowire.BytesType || (num != 1 && num != 2) {
+				n = protowire.ConsumeFieldValue(num, typ, entry)
+				if n < 0 {
+					return nil, protowire.ParseError(n)
+				}
+				entry = entry[n:]
+				continue
+			}
+			s, n := protowire.ConsumeString(entry)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			entry = entry[n:]
+			if num == 1 {
+				k = s
+			} else {
+				v = s
+			}
+		}
+		pairs[MetaKey(k)] = v
+	}
+	return pairs, nil
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+
+func (msgpackCodec) Marshal(pairs map[MetaKey]string) ([]byte, error) {
+	n := len(pairs)
+	var data []byte
+	switch {
+	case n < 16:
+		data = append(data, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		data = binary.BigEndian.AppendUint16(append(data, 0xde), uint16(n))
+	default:
+		data = binary.BigEndian.AppendUint32(append(data, 0xdf), uint32(n))
+	}
+	for _, k := range sortedKeys(pairs) {
+		data = appendMsgpackString(data, string(k))
+		data = appendMsgpackString(data, pairs[k])
+	}
+	return data, nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte) (map[MetaKey]string, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty msgpack data", ErrInvalidCodecData)
+	}
+	var n int
+	switch b := data[0]; {
+	case b&0xf0 == 0x80:
+		n, data = int(b&0x0f), data[1:]
+	case b == 0xde && len(data) >= 3:
+		n, data = int(binary.BigEndian.Uint16(data[1:])), data[3:]
+	case b == 0xdf && len(data) >= 5:
+		n, data = int(binary.BigEndian.Uint32(data[1:])), data[5:]
+	default:
+		return nil, fmt.Errorf("%w: msgpack data is not a map", ErrInvalidCodecData)
+	}
+	pairs := make(map[MetaKey]string, n)
+	for i := 0; i < n; i++ {
+		var k, v string
+		var err error
+		if k, data, err = consumeMsgpackString(data); err != nil {
+			return nil, err
+		}
+		if v, data, err = consumeMsgpackString(data); err != nil {
+			return nil, err
+		}
+		pairs[MetaKey(k)] = v
+	}
+	return pairs, nil
+}
+
+func appendMsgpackString(data []byte, s string) []byte {
+	n := len(s)
+	switch {
+	case n < 32:
+		data = append(data, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		data = append(data, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		data = binary.BigEndian.AppendUint16(append(data, 0xda), uint16(n))
+	default:
+		data = binary.BigEndian.AppendUint32(append(data, 0xdb), uint32(n))
+	}
+	return append(data, s...)
+}
+
+// consumeMsgpackString returns the string at
+// the beginning of data and the remaining data.
+func consumeMsgpackString(data []byte) (string, []byte, error) {
+	if len(data) == 0 {
+		return "", nil, fmt.Errorf("%w: unexpected end of msgpack data", ErrInvalidCodecData)
+	}
+	var n, hdr int
+	switch b := data[0]; {
+	case b&0xe0 == 0xa0:
+		n, hdr = int(b&0x1f), 1
+	case b == 0xd9 && len(data) >= 2:
+		n, hdr = int(data[1]), 2
+	case b == 0xda && len(data) >= 3:
+		n, hdr = int(binary.BigEndian.Uint16(data[1:])), 3
+	case b == 0xdb && len(data) >= 5:
+		n, hdr = int(binary.BigEndian.Uint32(data[1:])), 5
+	default:
+		return "", nil, fmt.Errorf("%w: msgpack value is not a string", ErrInvalidCodecData)
+	}
+	if len(data) < hdr+n {
+		return "", nil, fmt.Errorf("%w: unexpected end of msgpack data", ErrInvalidCodecData)
+	}
+	return string(data[hdr : hdr+n]), data[hdr+n:], nil
+}
+
+func sortedKeys(pairs map[MetaKey]string) []MetaKey {
+	keys := maps.Keys(pairs)
+	slices.Sort(keys)
+	return keys
+}
+
+// encodeMeta encodes the meta data using the codec of the bucket.
+func (b *Bucket) encodeMeta(meta *Metadata) ([]byte, error) {
+	return b.opts.Codec.Marshal(meta.data)
+}
+
+// decodeMeta decodes the meta data encoded by encodeMeta.
+func (b *Bucket) decodeMeta(meta *Metadata, data []byte) error {
+	pairs, err := decodePairs(b.opts.Codec, data)
+	if err != nil {
+		return err
+	}
+	meta.data = pairs
+	return nil
+}
+
+// decodePairs decodes the data using the codec c. Meta data
+// encoded using gob which was the only encoding of older versions
+// is still readable with any codec. It is detected by a decoding
+// error or a missing id which every stored object has.
+func decodePairs(c Codec, data []byte) (map[MetaKey]string, error) {
+	pairs, err := c.Unmarshal(data)
+	if (err != nil || pairs[MetaKeyID] == "") && c != CodecGob {
+		if legacy, gobErr := CodecGob.Unmarshal(data); gobErr == nil {
+			return legacy, nil
+		}
+	}
+	return pairs, err
+}
@@ -0,0 +1,141 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/maps"
+)
+
+func TestCodec(t *testing.T) {
+	pairs := map[MetaKey]string{
+		"empty": "",
+		"long":  strings.Repeat("v", 70000),
+		"utf8":  "äöü/☃",
+	}
+	for i := 0; i < 20; i++ {
+		pairs[MetaKey(fmt.Sprintf("key-%d", i))] = strings.Repeat("x", i*20)
+	}
+	for _, c := range []Codec{CodecGob, CodecJSON, CodecProtobuf, CodecMsgpack} {
+		t.Run(c.Name(), func(t *testing.T) {
+			data, err := c.Marshal(pairs)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			got, err := c.Unmarshal(data)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !maps.Equal(got, pairs) {
+				t.Fatalf("decoded meta data is not the same")
+			}
+		})
+	}
+	if _, err := CodecMsgpack.Unmarshal([]byte{0x81, 0xa3, 'k'}); !errors.Is(err, ErrInvalidCodecData) {
+		t.Fatalf("truncated data should be invalid. Got: %v", err)
+	}
+}
+
+func TestBucketCodec(t *testing.T) {
+	for _, c := range []Codec{CodecJSON, CodecProtobuf, CodecMsgpack} {
+		t.Run(c.Name(), func(t *testing.T) {
+			opts := NewDefaultBucketOptions()
+			opts.Logger = nil
+			opts.Codec = c
+			b, err := NewBucket(opts)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer os.RemoveAll(b.BasePath)
+			defer b.Shutdown()
+			o := tEnv.obj()
+			o.SetMetaKey("foo", "bar")
+			if err := b.Create(o); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := b.UpdateMeta(o.ID(), map[MetaKey]string{"foo": "baz"}); err != nil {
+				t.Error(err)
+				return
+			}
+			objs, err := b.Execute(NewQuery().Param("foo", "baz"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(objs) != 1 || objs[0].ID() != o.ID() || objs[0].Name() != o.Name() {
+				t.Fatalf("object should be found by its meta data. Got: %d", len(objs))
+			}
+		})
+	}
+}
+
+func TestLegacyMeta(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	o := tEnv.obj()
+	o.SetMetaKey("foo", "bar")
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	err = b.meta.Update(func(txn *storeTxn) error {
+		meta, err := b.GetMeta(o.ID())
+		if err != nil {
+			return err
+		}
+		data, err := CodecGob.Marshal(meta.data)
+		if err != nil {
+			return err
+		}
+		return txn.Set([]byte(o.ID()), data)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.GetByID(o.ID())
+	if err != nil {
+		t.Fatalf("meta data encoded using gob should be readable. Got: %v", err)
+	}
+	if got.GetMetaKey("foo") != "bar" || got.Name() != o.Name() {
+		t.Fatalf("decoded meta data is not the same")
+	}
+	objs, err := b.Execute(NewQuery().Param("foo", "bar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 {
+		t.Fatalf("object with legacy meta data should be found. Got: %d", len(objs))
+	}
+}
+
+func TestObjectBinary(t *testing.T) {
+	o := tEnv.obj()
+	o.SetMetaKey("foo", "bar")
+	data, err := o.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := new(Object)
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if got.ID() != o.ID() || got.GetMetaKey("foo") != "bar" || !bytes.Equal(got.Payload(), o.Payload()) {
+		t.Fatalf("decoded object is not the same")
+	}
+	if err := got.UnmarshalBinary([]byte{0x0a, 0x05}); err == nil {
+		t.Fatalf("truncated data should be invalid")
+	}
+}
@@ -0,0 +1,159 @@
+package objst
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the algorithm used to compress
+// the payload of objects before they are stored.
+type Compression string
+
+const (
+	CompressionNone   Compression = ""
+	CompressionGzip   Compression = "gzip"
+	CompressionZstd   Compression = "zstd"
+	CompressionSnappy Compression = "snappy"
+)
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// initZstd creates the shared zstd encoder and decoder which
+// are safe for concurrent use of EncodeAll and DecodeAll.
+func initZstd() error {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdErr
+}
+
+func compress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdEncoder.EncodeAll(data, nil), nil
+	case CompressionSnappy:
+		return snappy.Encode(nil, data), nil
+	default:
+		return nil, ErrUnknownCompression
+	}
+}
+
+func decompress(c Compression, data []byte) ([]byte, error) {
+	switch c {
+	case CompressionNone:
+		return data, nil
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case CompressionZstd:
+		if err := initZstd(); err != nil {
+			return nil, err
+		}
+		return zstdDecoder.DecodeAll(data, nil)
+	case CompressionSnappy:
+		return snappy.Decode(nil, data)
+	default:
+		return nil, ErrUnknownCompression
+	}
+}
+
+// encodePayload compresses and encrypts the payload as
+// configured and records the needed information to
+// decode the payload in the meta data.
+func (b *Bucket) encodePayload(meta *Metadata, pl []byte) ([]byte, error) {
+	return b.encodePayloadWith(meta, pl, b.opts.PayloadCompression, b.opts.Cipher)
+}
+
+// encodePayloadWith is like encodePayload but compresses the payload
+// with c and encrypts it with ci instead of the configured ones.
+func (b *Bucket) encodePayloadWith(meta *Metadata, pl []byte, c Compression, ci Cipher) ([]byte, error) {
+	meta.set(MetaKeySize, strconv.Itoa(len(pl)))
+	meta.del(MetaKeyCompression)
+	meta.del(MetaKeyDictionary)
+	meta.del(MetaKeyDataKey)
+	meta.del(MetaKeyNonce)
+	if isPlaintext(meta) {
+		ci = nil
+	}
+	var (
+		data    []byte
+		version uint32
+		err     error
+	)
+	if c == CompressionZstd && int64(len(pl)) <= b.opts.DictionaryObjectSize {
+		data, version = b.compressWithDictionary(pl)
+	}
+	if version == 0 {
+		data, err = compress(c, pl)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// incompressible payloads are stored as they are
+	if len(data) < len(pl) {
+		meta.set(MetaKeyCompression, string(c))
+		if version > 0 {
+			meta.set(MetaKeyDictionary, strconv.FormatUint(uint64(version), 10))
+		}
+	} else {
+		data = pl
+	}
+	return encryptPayload(ci, meta, data)
+}
+
+// decodePayload reverses encodePayload.
+func (b *Bucket) decodePayload(meta *Metadata, data []byte) ([]byte, error) {
+	data, err := b.decryptPayload(meta, data)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Has(MetaKeyDictionary) {
+		version, err := strconv.ParseUint(meta.Get(MetaKeyDictionary), 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		return b.decompressWithDictionary(uint32(version), data)
+	}
+	return decompress(Compression(meta.Get(MetaKeyCompression)), data)
+}
+
+// isEncoded reports if the stored payload differs from the
+// actual payload because it is compressed or encrypted.
+func isEncoded(meta *Metadata) bool {
+	_, encrypted := envelopeOf(meta)
+	return encrypted || meta.Has(MetaKeyCompression)
+}
@@ -0,0 +1,108 @@
+package objst
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"testing"
+)
+
+func TestPayloadCompression(t *testing.T) {
+	for _, c := range []Compression{CompressionGzip, CompressionZstd, CompressionSnappy} {
+		t.Run(string(c), func(t *testing.T) {
+			opts := NewDefaultBucketOptions()
+			opts.Logger = nil
+			opts.ChunkSize = 64
+			opts.PayloadCompression = c
+			b, err := NewBucket(opts)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer os.RemoveAll(b.BasePath)
+			defer b.Shutdown()
+
+			o := tEnv.obj()
+			pl := bytes.Repeat([]byte("objst"), 200)
+			o.Reset()
+			o.Write(pl)
+			if err := b.Create(o); err != nil {
+				t.Error(err)
+				return
+			}
+			meta, err := b.GetMeta(o.ID())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if meta.Get(MetaKeyCompression) != string(c) {
+				t.Fatalf("compression should be recorded. Got: %s", meta.Get(MetaKeyCompression))
+			}
+			if meta.Get(MetaKeySize) != strconv.Itoa(len(pl)) {
+				t.Fatalf("original size should be recorded. Got: %s", meta.Get(MetaKeySize))
+			}
+			var raw []byte
+			err = b.payload.View(func(txn *storeTxn) error {
+				raw, err = readChunks(txn, o.ID())
+				return err
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(raw) >= len(pl) {
+				t.Fatalf("stored payload should be compressed. Got %d bytes", len(raw))
+			}
+			oG, err := b.GetByID(o.ID())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !bytes.Equal(oG.Payload(), pl) {
+				t.Fatalf("payload is not decompressed")
+			}
+			var buf bytes.Buffer
+			if err := b.Read(o.ID(), &buf); err != nil {
+				t.Error(err)
+				return
+			}
+			if !bytes.Equal(buf.Bytes(), pl) {
+				t.Fatalf("read payload is not decompressed")
+			}
+			if err := b.PatchPayload(o.ID(), int64(len(pl)), []byte("patched")); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := b.Verify(o.ID()); err != nil {
+				t.Fatalf("patched compressed object should be valid: %v", err)
+			}
+		})
+	}
+}
+
+func TestPayloadCompressionIncompressible(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.PayloadCompression = CompressionGzip
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	meta, err := b.GetMeta(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if meta.Has(MetaKeyCompression) {
+		t.Fatalf("small payload should be stored uncompressed")
+	}
+}
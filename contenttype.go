@@ -0,0 +1,26 @@
+package objst
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+)
+
+// sniffLen is the maximum number of bytes
+// considered by http.DetectContentType.
+const sniffLen = 512
+
+// detectContentType sets the content type of the object if
+// it is missing and BucketOptions.DetectContentType is enabled.
+// The extension of the name takes precedence over head which
+// are the first bytes of the payload.
+func (b *Bucket) detectContentType(obj *Object, head []byte) {
+	if !b.opts.DetectContentType || obj.HasMetaKey(MetaKeyContentType) {
+		return
+	}
+	contentType := mime.TypeByExtension(filepath.Ext(obj.Name()))
+	if contentType == "" {
+		contentType = http.DetectContentType(head)
+	}
+	obj.meta.set(MetaKeyContentType, contentType)
+}
@@ -0,0 +1,70 @@
+package objst
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestDetectContentType(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.DetectContentType = true
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	o, _ := NewObject("image.unknownext", tEnv.owner())
+	o.Write(png)
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if got := o.GetMetaKey(MetaKeyContentType); got != "image/png" {
+		t.Fatalf("content type should be sniffed. Got: %s", got)
+	}
+
+	o, _ = NewObject("notes.unknownext", tEnv.owner())
+	u, err := b.NewUpload(o)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	u.Write([]byte("plain "))
+	u.Write([]byte("text"))
+	if err := u.Commit(); err != nil {
+		t.Error(err)
+		return
+	}
+	meta, err := b.GetMeta(u.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got := meta.Get(MetaKeyContentType); got != "text/plain; charset=utf-8" {
+		t.Fatalf("content type of the upload should be sniffed. Got: %s", got)
+	}
+
+	// an explicit content type is kept
+	o, _ = NewObject("data.unknownext", tEnv.owner())
+	o.SetMetaKey(MetaKeyContentType, "application/x-custom")
+	o.Write(png)
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if got := o.GetMetaKey(MetaKeyContentType); got != "application/x-custom" {
+		t.Fatalf("content type should not be overwritten. Got: %s", got)
+	}
+
+	o, _ = NewObject("image.unknownext", tEnv.owner())
+	o.Write(png)
+	if err := tEnv.b.Create(o); !errors.Is(err, ErrContentTypeNotExist) {
+		t.Fatalf("content type should only be detected if enabled. Got: %v", err)
+	}
+}
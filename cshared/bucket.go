@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/naivary/objst"
+)
+
+// errInvalidHandle is returned for handles
+// which are unknown or already closed.
+var errInvalidHandle = errors.New("invalid bucket handle")
+
+// buckets maps the handles passed to C to the open buckets.
+// Handles are never reused so a closed handle stays invalid.
+var buckets = struct {
+	sync.RWMutex
+	next uintptr
+	m    map[uintptr]*objst.Bucket
+}{m: make(map[uintptr]*objst.Bucket)}
+
+func open(path string) (uintptr, error) {
+	opts := objst.NewDefaultBucketOptions()
+	var b *objst.Bucket
+	var err error
+	if path == "" {
+		b, err = objst.NewBucket(opts)
+	} else {
+		b, err = objst.OpenBucket(path, opts)
+	}
+	if err != nil {
+		return 0, err
+	}
+	buckets.Lock()
+	defer buckets.Unlock()
+	buckets.next++
+	buckets.m[buckets.next] = b
+	return buckets.next, nil
+}
+
+func bucket(h uintptr) (*objst.Bucket, error) {
+	buckets.RLock()
+	defer buckets.RUnlock()
+	b, ok := buckets.m[h]
+	if !ok {
+		return nil, errInvalidHandle
+	}
+	return b, nil
+}
+
+func basePath(h uintptr) (string, error) {
+	b, err := bucket(h)
+	if err != nil {
+		return "", err
+	}
+	return b.BasePath, nil
+}
+
+func closeBucket(h uintptr) error {
+	buckets.Lock()
+	b, ok := buckets.m[h]
+	delete(buckets.m, h)
+	buckets.Unlock()
+	if !ok {
+		return errInvalidHandle
+	}
+	return b.Shutdown()
+}
+
+func put(h uintptr, name, owner, contentType string, data []byte) (string, error) {
+	b, err := bucket(h)
+	if err != nil {
+		return "", err
+	}
+	obj, err := objst.NewObject(name, owner)
+	if err != nil {
+		return "", err
+	}
+	if contentType != "" {
+		obj.SetMetaKey(objst.MetaKeyContentType, contentType)
+	}
+	if _, err := obj.Write(data); err != nil {
+		return "", err
+	}
+	if err := b.Create(obj); err != nil {
+		return "", err
+	}
+	return obj.ID(), nil
+}
+
+func get(h uintptr, id string) ([]byte, error) {
+	b, err := bucket(h)
+	if err != nil {
+		return nil, err
+	}
+	return b.GetPayload(id)
+}
+
+func remove(h uintptr, id string) error {
+	b, err := bucket(h)
+	if err != nil {
+		return err
+	}
+	return b.DeleteByID(id)
+}
+
+func query(h uintptr, owner, nameGlob string) ([]byte, error) {
+	b, err := bucket(h)
+	if err != nil {
+		return nil, err
+	}
+	q := objst.NewQuery().Owner(owner)
+	if nameGlob != "" {
+		q.NameGlob(nameGlob)
+	}
+	objs, err := b.Execute(q)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*objst.ObjectInfo, 0, len(objs))
+	for _, obj := range objs {
+		infos = append(infos, obj.Info())
+	}
+	return json.Marshal(infos)
+}
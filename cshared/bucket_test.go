@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/naivary/objst"
+)
+
+func TestBucketHandle(t *testing.T) {
+	h, err := open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := basePath(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+	owner := uuid.NewString()
+	id, err := put(h, "report.txt", owner, "", []byte("payload"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := closeBucket(h); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(h, id); !errors.Is(err, errInvalidHandle) {
+		t.Fatalf("closed handle should be invalid. Got: %v", err)
+	}
+
+	h, err = open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeBucket(h)
+	pl, err := get(h, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(pl) != "payload" {
+		t.Fatalf("payload should be read. Got: %s", pl)
+	}
+	data, err := query(h, owner, "*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := make([]objst.ObjectInfo, 0)
+	if err := json.Unmarshal(data, &infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].ID != id {
+		t.Fatalf("query should list the object. Got: %s", data)
+	}
+	if err := remove(h, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := get(h, id); !errors.Is(err, objst.ErrObjectNotFound) {
+		t.Fatalf("object should be deleted. Got: %v", err)
+	}
+}
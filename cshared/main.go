@@ -0,0 +1,128 @@
+// Command cshared is built as a C shared library exposing the core
+// operations of a bucket so services written in other languages
+// e.g. Python or Rust can use objst in-process without HTTP:
+//
+//	go build -buildmode=c-shared -o libobjst.so ./cshared
+//
+// The build generates the header libobjst.h. Buckets are referenced
+// by handles. Every function returns NULL on success and the error
+// message otherwise. Returned strings and buffers are allocated using
+// malloc and have to be released using objst_free.
+package main
+
+/*
+#include <stdint.h>
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"unsafe"
+)
+
+func main() {}
+
+// objst_open opens the bucket stored in path. If path is NULL or
+// empty a new bucket is created. The handle of the bucket is stored
+// in bucket and has to be closed using objst_close.
+//
+//export objst_open
+func objst_open(path *C.char, bucket *C.uintptr_t) *C.char {
+	h, err := open(goString(path))
+	if err != nil {
+		return cError(err)
+	}
+	*bucket = C.uintptr_t(h)
+	return nil
+}
+
+// objst_base_path stores the base path of the bucket
+// in path which can be passed to objst_open later.
+//
+//export objst_base_path
+func objst_base_path(bucket C.uintptr_t, path **C.char) *C.char {
+	p, err := basePath(uintptr(bucket))
+	if err != nil {
+		return cError(err)
+	}
+	*path = C.CString(p)
+	return nil
+}
+
+// objst_close shuts the bucket down and releases its handle.
+//
+//export objst_close
+func objst_close(bucket C.uintptr_t) *C.char {
+	return cError(closeBucket(uintptr(bucket)))
+}
+
+// objst_put creates an object of the owner with the payload data of
+// length size and stores its id in id. If contentType is NULL or
+// empty it is derived from the extension of the name.
+//
+//export objst_put
+func objst_put(bucket C.uintptr_t, name, owner, contentType *C.char, data unsafe.Pointer, size C.size_t, id **C.char) *C.char {
+	pl := C.GoBytes(data, C.int(size))
+	objID, err := put(uintptr(bucket), goString(name), goString(owner), goString(contentType), pl)
+	if err != nil {
+		return cError(err)
+	}
+	*id = C.CString(objID)
+	return nil
+}
+
+// objst_get stores the payload of the object with
+// the id in data and its length in size.
+//
+//export objst_get
+func objst_get(bucket C.uintptr_t, id *C.char, data *unsafe.Pointer, size *C.size_t) *C.char {
+	pl, err := get(uintptr(bucket), goString(id))
+	if err != nil {
+		return cError(err)
+	}
+	*data = C.CBytes(pl)
+	*size = C.size_t(len(pl))
+	return nil
+}
+
+// objst_delete deletes the object with the id.
+//
+//export objst_delete
+func objst_delete(bucket C.uintptr_t, id *C.char) *C.char {
+	return cError(remove(uintptr(bucket), goString(id)))
+}
+
+// objst_query stores the JSON array of the information of the
+// objects of the owner in result. If nameGlob is not NULL or
+// empty only the objects whose name matches it are listed.
+//
+//export objst_query
+func objst_query(bucket C.uintptr_t, owner, nameGlob *C.char, result **C.char) *C.char {
+	data, err := query(uintptr(bucket), goString(owner), goString(nameGlob))
+	if err != nil {
+		return cError(err)
+	}
+	*result = C.CString(string(data))
+	return nil
+}
+
+// objst_free releases a string or buffer returned by objst.
+//
+//export objst_free
+func objst_free(p unsafe.Pointer) {
+	C.free(p)
+}
+
+func goString(s *C.char) string {
+	if s == nil {
+		return ""
+	}
+	return C.GoString(s)
+}
+
+func cError(err error) *C.char {
+	if err == nil {
+		return nil
+	}
+	return C.CString(err.Error())
+}
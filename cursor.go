@@ -0,0 +1,104 @@
+package objst
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// match is an object matching a query.
+type match struct {
+	id string
+	// value is the value of the key
+	// the results are ordered by.
+	value string
+}
+
+// cursor is the position after the last object of a page. The
+// results are ordered by the value of the key the query is ordered
+// by and equal values by the id of the object. Unordered results
+// are ordered by id only. Because of that the next page starts
+// after the same object even if objects are inserted concurrently.
+type cursor struct {
+	Key   MetaKey `json:"k,omitempty"`
+	Order Order   `json:"o,omitempty"`
+	Value string  `json:"v,omitempty"`
+	ID    string  `json:"id"`
+}
+
+// Page is a page of the objects matching a query.
+type Page struct {
+	Objects []*Object
+	// NextCursor is the cursor of the next page which has to be
+	// passed to Query.After. It is empty if there is no next page.
+	NextCursor string
+}
+
+// ExecutePage is like Execute but returns the cursor of the next
+// page if the query is limited. Pass the cursor to After of the
+// same query to get the next page. In contrast to Offset the pages
+// are stable if objects are inserted concurrently.
+func (b *Bucket) ExecutePage(q *Query) (*Page, error) {
+	op := b.startOp("ExecutePage")
+	op.Query = q.String()
+	defer b.finishOp(op)
+	if err := q.isValid(b.opts.OwnerValidator); err != nil {
+		return nil, err
+	}
+	if q.op != OperationGet {
+		return nil, ErrPageOperation
+	}
+	objs, next, err := b.queryObjs(q)
+	if err != nil && !isPartial(err) {
+		return nil, err
+	}
+	return &Page{Objects: objs, NextCursor: next}, err
+}
+
+// cursor decodes the cursor of the query. It returns nil
+// if the query has no cursor and ErrInvalidCursor if the
+// cursor is malformed or belongs to another ordering.
+func (q *Query) cursor() (*cursor, error) {
+	if q.afterCursor == "" {
+		return nil, nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(q.afterCursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &cursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Key != q.orderBy || (q.orderBy != "" && c.Order != q.order) {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// encodeCursor returns the cursor pointing after the match.
+func (q *Query) encodeCursor(m match) string {
+	c := cursor{ID: m.id}
+	if q.orderBy != "" {
+		c.Key = q.orderBy
+		c.Order = q.order
+		c.Value = m.value
+	}
+	// a cursor consists only of strings and
+	// integers so marshaling cannot fail.
+	data, _ := json.Marshal(&c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// after returns the ordered matches after the cursor.
+func (q *Query) after(c *cursor, matches []match) []match {
+	for i, m := range matches {
+		cmp := compareMetaValues(q.orderBy, m.value, c.Value)
+		if q.order == Desc {
+			cmp = -cmp
+		}
+		if cmp > 0 || (cmp == 0 && m.id > c.ID) {
+			return matches[i:]
+		}
+	}
+	return matches[:0]
+}
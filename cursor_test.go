@@ -0,0 +1,85 @@
+package objst
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/exp/slices"
+)
+
+func TestExecutePage(t *testing.T) {
+	const rank MetaKey = "rank"
+	owner := tEnv.owner()
+	create := func(r string) string {
+		o, _ := NewObject(tEnv.name(), owner)
+		o.Write(tEnv.payload(10))
+		o.SetMetaKey(rank, r)
+		if err := tEnv.b.Create(o); err != nil {
+			t.Fatal(err)
+		}
+		return o.ID()
+	}
+	ids := make([]string, 0, 5)
+	for _, r := range []string{"3", "1", "2", "1", "5"} {
+		ids = append(ids, create(r))
+	}
+	// pages collects all pages of the query and inserts an
+	// object with the rank r after the first page.
+	pages := func(q func() *Query, r string) []*Object {
+		objs := make([]*Object, 0)
+		var next string
+		for i := 0; ; i++ {
+			page, err := tEnv.b.ExecutePage(q().After(next))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Objects) > 2 {
+				t.Fatalf("page is larger than the limit. Got: %d", len(page.Objects))
+			}
+			objs = append(objs, page.Objects...)
+			if i == 0 {
+				create(r)
+			}
+			if page.NextCursor == "" {
+				return objs
+			}
+			next = page.NextCursor
+		}
+	}
+
+	unordered := pages(func() *Query { return NewQuery().Owner(owner).Limit(2) }, "4")
+	got := make([]string, 0, len(unordered))
+	for _, o := range unordered {
+		got = append(got, o.ID())
+	}
+	if !slices.IsSorted(got) || len(slices.Compact(slices.Clone(got))) != len(got) {
+		t.Fatalf("unordered pages should be ordered by id without duplicates. Got: %v", got)
+	}
+	for _, id := range ids {
+		if !slices.Contains(got, id) {
+			t.Fatalf("object %s is missing in the pages", id)
+		}
+	}
+
+	ordered := pages(func() *Query { return NewQuery().Owner(owner).Filter(Ne(rank, "4")).OrderBy(rank, Asc).Limit(2) }, "0")
+	ranks := make([]string, 0, len(ordered))
+	for _, o := range ordered {
+		ranks = append(ranks, o.GetMetaKey(rank))
+	}
+	// the object inserted before the cursor is skipped
+	if diff := cmp.Diff([]string{"1", "1", "2", "3", "5"}, ranks); diff != "" {
+		t.Fatalf("ordered pages are not correct: %s", diff)
+	}
+
+	page, err := tEnv.b.ExecutePage(NewQuery().Owner(owner).Limit(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tEnv.b.ExecutePage(NewQuery().Owner(owner).OrderBy(rank, Asc).After(page.NextCursor)); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("cursor of another ordering should be rejected. Got: %v", err)
+	}
+	if _, err := tEnv.b.ExecutePage(NewQuery().Owner(owner).After("garbage")); !errors.Is(err, ErrInvalidCursor) {
+		t.Fatalf("malformed cursor should be rejected. Got: %v", err)
+	}
+}
@@ -0,0 +1,268 @@
+package objst
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// manifestKey is the key of the manifest in the name store.
+	// Object names cannot start with "#" so the key never
+	// collides with a name entry.
+	manifestKey = "#manifest"
+	// dictionaryPrefix is the prefix of the zstd
+	// dictionaries stored in the name store.
+	dictionaryPrefix = "#dict/"
+)
+
+// manifest describes the bucket wide state which is not
+// bound to a single object e.g. the zstd dictionaries
+// and the secondary indexes.
+type manifest struct {
+	// Dictionaries are the versions of all trained
+	// dictionaries. Old versions are kept because
+	// existing objects may be compressed with them.
+	Dictionaries []uint32 `json:"dictionaries"`
+	// ActiveDictionary is the version of the dictionary
+	// used to compress new objects. Zero means none.
+	ActiveDictionary uint32 `json:"activeDictionary"`
+	// Indexes are the meta data keys with
+	// a complete secondary index.
+	Indexes []MetaKey `json:"indexes"`
+}
+
+// dictionaries caches the encoder of the active dictionary
+// and the decoders of all dictionaries used for reads.
+type dictionaries struct {
+	active   uint32
+	encoder  *zstd.Encoder
+	decoders map[uint32]*zstd.Decoder
+}
+
+func dictionaryKey(version uint32) []byte {
+	return []byte(dictionaryPrefix + strconv.FormatUint(uint64(version), 10))
+}
+
+// TrainDictionary trains a new zstd dictionary of at most maxSize
+// bytes from the payloads of the objects which are not larger than
+// DictionaryObjectSize and activates it. New objects which are not
+// larger than DictionaryObjectSize are compressed with the active
+// dictionary iff PayloadCompression is CompressionZstd which improves
+// the compression ratio of many similar small objects like JSON
+// documents substantially. The version of the dictionary is returned.
+func (b *Bucket) TrainDictionary(ctx context.Context, maxSize int) (uint32, error) {
+	done, err := b.beginWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+	if maxSize <= 0 {
+		return 0, ErrInvalidDictionarySize
+	}
+	content, err := b.dictionarySamples(ctx, maxSize)
+	if err != nil {
+		return 0, err
+	}
+	b.dictMu.Lock()
+	defer b.dictMu.Unlock()
+	m, err := b.manifest()
+	if err != nil {
+		return 0, err
+	}
+	version := uint32(1)
+	if n := len(m.Dictionaries); n > 0 {
+		version = m.Dictionaries[n-1] + 1
+	}
+	enc, err := newDictionaryEncoder(version, content)
+	if err != nil {
+		return 0, err
+	}
+	m.Dictionaries = append(m.Dictionaries, version)
+	m.ActiveDictionary = version
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return 0, err
+	}
+	err = b.name.Update(func(txn *storeTxn) error {
+		if err := txn.Set(dictionaryKey(version), content); err != nil {
+			return err
+		}
+		return txn.Set([]byte(manifestKey), data)
+	})
+	if err != nil {
+		return 0, err
+	}
+	b.dicts.active = version
+	b.dicts.encoder = enc
+	return version, nil
+}
+
+// DictionaryVersions returns the versions of all
+// trained dictionaries and the active version.
+func (b *Bucket) DictionaryVersions() ([]uint32, uint32, error) {
+	b.dictMu.RLock()
+	defer b.dictMu.RUnlock()
+	m, err := b.manifest()
+	return m.Dictionaries, m.ActiveDictionary, err
+}
+
+// dictionarySamples returns the raw content of a dictionary
+// which is the concatenation of the sampled payloads. The
+// payloads are sampled until maxSize bytes are collected.
+func (b *Bucket) dictionarySamples(ctx context.Context, maxSize int) ([]byte, error) {
+	content := make([]byte, 0, maxSize)
+	errEnough := errors.New("enough samples")
+	err := b.forEachID(ctx, func(id string) error {
+		meta, err := b.GetMeta(id)
+		if err != nil {
+			return err
+		}
+		if b.sizeOf(meta) > b.opts.DictionaryObjectSize {
+			return nil
+		}
+		pl, err := b.readPayload(meta)
+		if err != nil {
+			return err
+		}
+		content = append(content, pl...)
+		if len(content) >= maxSize {
+			return errEnough
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errEnough) {
+		return nil, err
+	}
+	if len(content) == 0 {
+		return nil, ErrNoDictionarySamples
+	}
+	if len(content) > maxSize {
+		content = content[:maxSize]
+	}
+	return content, nil
+}
+
+// manifest reads the manifest of the bucket.
+// The caller has to hold dictMu.
+func (b *Bucket) manifest() (manifest, error) {
+	var m manifest
+	err := b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(manifestKey))
+		if errors.Is(err, ErrStoreKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &m)
+		})
+	})
+	return m, err
+}
+
+// loadDictionary loads the active dictionary of the manifest.
+func (b *Bucket) loadDictionary() error {
+	b.dictMu.Lock()
+	defer b.dictMu.Unlock()
+	m, err := b.manifest()
+	if err != nil || m.ActiveDictionary == 0 {
+		return err
+	}
+	content, err := b.dictionary(m.ActiveDictionary)
+	if err != nil {
+		return err
+	}
+	enc, err := newDictionaryEncoder(m.ActiveDictionary, content)
+	if err != nil {
+		return err
+	}
+	b.dicts.active = m.ActiveDictionary
+	b.dicts.encoder = enc
+	return nil
+}
+
+func (b *Bucket) dictionary(version uint32) ([]byte, error) {
+	var content []byte
+	err := b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get(dictionaryKey(version))
+		if err != nil {
+			return err
+		}
+		content, err = item.ValueCopy(nil)
+		return err
+	})
+	return content, err
+}
+
+// newDictionaryEncoder creates an encoder using the raw dictionary.
+// The default level of zstd is not making use of raw dictionaries
+// so a better compression level has to be used.
+func newDictionaryEncoder(version uint32, content []byte) (*zstd.Encoder, error) {
+	return zstd.NewWriter(nil,
+		zstd.WithEncoderDictRaw(version, content),
+		zstd.WithEncoderLevel(zstd.SpeedBetterCompression),
+	)
+}
+
+// compressWithDictionary compresses the payload with the active
+// dictionary. If no dictionary is active zero is returned as the
+// version and the payload is not compressed.
+func (b *Bucket) compressWithDictionary(pl []byte) ([]byte, uint32) {
+	b.dictMu.RLock()
+	defer b.dictMu.RUnlock()
+	if b.dicts.encoder == nil {
+		return nil, 0
+	}
+	return b.dicts.encoder.EncodeAll(pl, nil), b.dicts.active
+}
+
+// decompressWithDictionary decompresses the payload
+// with the dictionary of the given version.
+func (b *Bucket) decompressWithDictionary(version uint32, data []byte) ([]byte, error) {
+	b.dictMu.RLock()
+	dec, ok := b.dicts.decoders[version]
+	b.dictMu.RUnlock()
+	if ok {
+		return dec.DecodeAll(data, nil)
+	}
+	b.dictMu.Lock()
+	defer b.dictMu.Unlock()
+	if dec, ok := b.dicts.decoders[version]; ok {
+		return dec.DecodeAll(data, nil)
+	}
+	content, err := b.dictionary(version)
+	if err != nil {
+		return nil, err
+	}
+	dec, err = zstd.NewReader(nil, zstd.WithDecoderDictRaw(version, content))
+	if err != nil {
+		return nil, err
+	}
+	if b.dicts.decoders == nil {
+		b.dicts.decoders = make(map[uint32]*zstd.Decoder)
+	}
+	b.dicts.decoders[version] = dec
+	return dec.DecodeAll(data, nil)
+}
+
+// closeDictionaries releases the resources
+// of the cached encoder and decoders.
+func (b *Bucket) closeDictionaries() error {
+	b.dictMu.Lock()
+	defer b.dictMu.Unlock()
+	for _, dec := range b.dicts.decoders {
+		dec.Close()
+	}
+	b.dicts.decoders = nil
+	if b.dicts.encoder == nil {
+		return nil
+	}
+	err := b.dicts.encoder.Close()
+	b.dicts.encoder = nil
+	return err
+}
@@ -0,0 +1,94 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestTrainDictionary(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.PayloadCompression = CompressionZstd
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	if _, err := b.TrainDictionary(context.Background(), 1024); !errors.Is(err, ErrNoDictionarySamples) {
+		t.Fatalf("training without objects should fail. Got: %v", err)
+	}
+	doc := func(i int) []byte {
+		return []byte(fmt.Sprintf(`{"invoice":%d,"customer":"customer-%d","currency":"EUR","status":"paid"}`, i, i))
+	}
+	owner := tEnv.owner()
+	for i := 0; i < 50; i++ {
+		o, _ := NewObject(fmt.Sprintf("invoice-%d.json", i), owner)
+		o.SetMetaKey(MetaKeyContentType, "application/json")
+		o.Write(doc(i))
+		if err := b.Create(o); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	version, err := b.TrainDictionary(context.Background(), 4096)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	versions, active, err := b.DictionaryVersions()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if active != version || len(versions) != 1 || versions[0] != version {
+		t.Fatalf("trained dictionary should be active. Got: %v, %d", versions, active)
+	}
+
+	o, _ := NewObject("invoice-new.json", owner)
+	o.SetMetaKey(MetaKeyContentType, "application/json")
+	pl := doc(100)
+	o.Write(pl)
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	meta, err := b.GetMeta(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if meta.Get(MetaKeyDictionary) != fmt.Sprint(version) {
+		t.Fatalf("object should be compressed with the dictionary. Got: %s", meta.Get(MetaKeyDictionary))
+	}
+	var raw []byte
+	err = b.payload.View(func(txn *storeTxn) error {
+		raw, err = readChunks(txn, o.ID())
+		return err
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	plain, err := compress(CompressionZstd, pl)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(raw) >= len(plain) {
+		t.Fatalf("dictionary should improve the ratio. Got %d bytes, without dictionary %d bytes", len(raw), len(plain))
+	}
+	oG, err := b.GetByID(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(oG.Payload()) != string(pl) {
+		t.Fatalf("payload is not decompressed. Got: %s. Expected: %s", oG.Payload(), pl)
+	}
+}
@@ -0,0 +1,71 @@
+package objst
+
+import (
+	"errors"
+	"fmt"
+)
+
+// defaultIndexCacheSize is the size of the index cache
+// used for encrypted stores if none is configured.
+const defaultIndexCacheSize = 64 << 20
+
+// KeyProvider provides the master key which is used
+// to encrypt the stores of a bucket at rest. After a
+// call to Bucket.RotateEncryptionKey the provider
+// should return the new key.
+type KeyProvider interface {
+	// EncryptionKey returns the master key. The key must
+	// be 16, 24 or 32 bytes long to select AES-128,
+	// AES-192 or AES-256.
+	EncryptionKey() ([]byte, error)
+}
+
+// StaticKey is a KeyProvider which
+// is always returning the same key.
+type StaticKey []byte
+
+func (s StaticKey) EncryptionKey() ([]byte, error) {
+	return s, nil
+}
+
+// RotateEncryptionKey replaces the master key of all stores with
+// newKey. Only the key registry of each store has to be re-encrypted
+// but badger reads it only on open so every database is closed and
+// reopened in turn. Writes are blocked until the rotation is done and
+// other operations wait while their store is reopened, which usually
+// takes milliseconds. If the rotation of a store fails the stores
+// which were already rotated are rotated back to the old key so all
+// stores can be opened with the same key.
+func (b *Bucket) RotateEncryptionKey(newKey []byte) error {
+	if !isValidEncryptionKey(newKey) {
+		return ErrInvalidEncryptionKey
+	}
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	oldKey := b.payload.opts.EncryptionKey
+	if len(oldKey) == 0 {
+		return ErrEncryptionDisabled
+	}
+	rotated := make([]*store, 0, 3)
+	for _, s := range b.databases() {
+		if err := s.rotateEncryptionKey(newKey); err != nil {
+			for _, r := range rotated {
+				if rbErr := r.rotateEncryptionKey(oldKey); rbErr != nil {
+					err = errors.Join(err, fmt.Errorf("rolling back the rotation of %s: %w", r.opts.Dir, rbErr))
+				}
+			}
+			return err
+		}
+		rotated = append(rotated, s)
+	}
+	return nil
+}
+
+func isValidEncryptionKey(key []byte) bool {
+	switch len(key) {
+	case 16, 24, 32:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,110 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/naivary/objst/random"
+)
+
+func TestRotateEncryptionKey(t *testing.T) {
+	oldKey := []byte(random.String(32))
+	newKey := []byte(random.String(32))
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.KeyProvider = StaticKey(oldKey)
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.RotateEncryptionKey([]byte("short")); !errors.Is(err, ErrInvalidEncryptionKey) {
+		t.Fatalf("invalid key should be rejected. Got: %v", err)
+	}
+	if err := b.RotateEncryptionKey(newKey); err != nil {
+		t.Error(err)
+		return
+	}
+	oG, err := b.GetByID(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(oG.Payload(), o.Payload()) {
+		t.Fatalf("payload is not the same after rotation. Got: %s. Expected: %s", oG.Payload(), o.Payload())
+	}
+	if err := b.Shutdown(); err != nil {
+		t.Error(err)
+		return
+	}
+	storeOpts := b.meta.opts
+	storeOpts.EncryptionKey = oldKey
+	if _, err := badger.Open(storeOpts); err == nil {
+		t.Fatalf("store should not be readable with the old key")
+	}
+	storeOpts.EncryptionKey = newKey
+	db, err := badger.Open(storeOpts)
+	if err != nil {
+		t.Fatalf("store should be readable with the new key: %v", err)
+	}
+	db.Close()
+}
+
+func TestRotateEncryptionKeyRollback(t *testing.T) {
+	oldKey := []byte(random.String(32))
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.KeyProvider = StaticKey(oldKey)
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	// the rewritten key registry of the meta store, which
+	// is rotated last, can't be written.
+	blocker := filepath.Join(b.meta.opts.Dir, badger.KeyRegistryRewriteFileName)
+	if err := os.Mkdir(blocker, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.RotateEncryptionKey([]byte(random.String(32))); err == nil {
+		t.Fatal("rotation should fail")
+	}
+	if err := os.Remove(blocker); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetByID(o.ID()); err != nil {
+		t.Fatalf("bucket should stay usable after a failed rotation: %v", err)
+	}
+	if err := b.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range b.databases() {
+		storeOpts := s.opts
+		storeOpts.EncryptionKey = oldKey
+		db, err := badger.Open(storeOpts)
+		if err != nil {
+			t.Fatalf("%s should be readable with the old key: %v", storeOpts.Dir, err)
+		}
+		db.Close()
+	}
+}
+
+func TestRotateEncryptionKeyDisabled(t *testing.T) {
+	if err := tEnv.b.RotateEncryptionKey([]byte(random.String(32))); !errors.Is(err, ErrEncryptionDisabled) {
+		t.Fatalf("rotation of an unencrypted bucket should fail. Got: %v", err)
+	}
+}
@@ -3,25 +3,144 @@ package objst
 import (
 	"errors"
 	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Generic errors which are wrapped by the more specific errors
+// of the bucket and the objects so callers can branch on the kind
+// of the failure using errors.Is e.g. ErrObjectNotFound and
+// ErrKVKeyNotFound are both matching ErrNotFound.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrDuplicateName = errors.New("object with the same name exists for the owner")
+	ErrDuplicateID   = errors.New("object with the same id exists")
+	ErrImmutable     = errors.New("immutable")
 )
 
 // Object errors
 var (
 	ErrContentTypeNotExist     = errors.New("missing content type metadata")
 	ErrEmptyPayload            = errors.New("object doesn't contain any payload")
-	ErrObjectIsImmutable       = errors.New("object is immutable. Create a new object")
-	ErrMustIncludeOwnerAndName = errors.New("object is immutable. Create a new object")
+	ErrObjectIsImmutable       = fmt.Errorf("object is %w. Create a new object", ErrImmutable)
+	ErrMustIncludeOwnerAndName = errors.New("object must include an owner and a name")
 	ErrInvalidNamePattern      = fmt.Errorf("object name must match the following regex pattern: %s", objectNamePattern)
+	ErrPreconditionFailed      = errors.New("precondition failed: the object does not match the expected state")
+	ErrChecksumMismatch        = errors.New("checksum of the payload does not match the stored checksum")
+	ErrNegativeOffset          = errors.New("offset must not be negative")
+	ErrObjectNotFound          = fmt.Errorf("object %w", ErrNotFound)
+	ErrObjectLocked            = errors.New("object is locked until its retention period passed")
+	ErrInvalidObjectID         = errors.New("id of the object must be a valid UUID")
+	ErrInvalidOwner            = errors.New("owner is invalid")
+	ErrInvalidMeta             = errors.New("meta data is invalid")
+	ErrMetaTampered            = errors.New("system meta data was modified outside of the bucket")
+	ErrExpiresBeforeRetention  = errors.New("object would expire before its retention period passed")
+	ErrInvalidWhence           = errors.New("whence must be io.SeekStart, io.SeekCurrent or io.SeekEnd")
+	ErrTooManyMetaKeys         = errors.New("too many user defined meta data keys")
+	ErrMetaKeyTooLong          = errors.New("meta data key is too long")
+	ErrMetaValueTooLong        = errors.New("meta data value is too long")
+	ErrMetaTooLarge            = errors.New("user defined meta data is too large")
+	ErrInvalidContentType      = errors.New("content type is not a valid media type")
+	ErrEmptyMetaKey            = errors.New("meta data key must not be empty")
+	ErrEmptyMetaValue          = errors.New("meta data value must not be empty")
+	ErrSystemMetaKey           = errors.New("meta data key is managed by objst")
+)
+
+// Bucket errors
+var (
+	ErrEncryptionDisabled     = errors.New("bucket is not encrypted at rest")
+	ErrInvalidEncryptionKey   = errors.New("encryption key must be 16, 24 or 32 bytes long")
+	ErrMissingCipher          = errors.New("payload is encrypted but no cipher is configured")
+	ErrInvalidEnvelope        = errors.New("envelope of the encrypted payload is invalid")
+	ErrNotFIPSApproved        = errors.New("cryptographic algorithm or key is not approved in the FIPS mode")
+	ErrBucketFrozen           = errors.New("bucket is frozen and rejects writes")
+	ErrUnknownCompression     = errors.New("unknown payload compression")
+	ErrUnknownArchiveFormat   = errors.New("unknown archive format")
+	ErrArchiveCorrupted       = errors.New("archive does not match its manifest")
+	ErrArchiveVersion         = errors.New("unsupported version of the archive format")
+	ErrInvalidBackup          = errors.New("backup is invalid or truncated")
+	ErrBackupLayout           = errors.New("backup was created by a bucket of another store layout")
+	ErrNoBackupTarget         = errors.New("neither a backup directory nor a backup writer is configured")
+	ErrInvalidSnapshotName    = errors.New("name of the snapshot must be a file name not starting with a dot")
+	ErrSnapshotExists         = errors.New("snapshot with the same name exists")
+	ErrSnapshotNotFound       = fmt.Errorf("snapshot %w", ErrNotFound)
+	ErrMigrationTarget        = errors.New("target of the migration exists")
+	ErrQuotaExceeded          = errors.New("quota of the owner exceeded")
+	ErrUnknownLifecycleAction = errors.New("unknown lifecycle action")
+	ErrNegativeDuration       = errors.New("duration must not be negative")
+	ErrInvalidDictionarySize  = errors.New("dictionary size must be positive")
+	ErrNoDictionarySamples    = errors.New("no objects to train the dictionary with")
+	ErrSameBucket             = errors.New("source and destination bucket are the same")
+	ErrEmptyIndexKey          = errors.New("key of the index must not be empty")
+	ErrInvalidModeration      = errors.New("invalid transition of the moderation state")
+	ErrEmptyPipelineName      = errors.New("name of the pipeline must not be empty")
+	ErrPipelineRunning        = errors.New("pipeline is already running")
+	ErrPipelineNotFound       = fmt.Errorf("pipeline %w. It never ran", ErrNotFound)
+	ErrInvalidCodecData       = errors.New("data cannot be decoded by the codec")
+	ErrEmptyKVKey             = errors.New("key must not be empty")
+	ErrKVKeyNotFound          = fmt.Errorf("key %w", ErrNotFound)
+	ErrKVValueTooLarge        = fmt.Errorf("value exceeds the maximum size of %d bytes", MaxKVValueSize)
+	ErrBucketNotExist         = errors.New("bucket does not exist")
+	ErrStoreLocked            = errors.New("store is locked")
+	ErrStoreTruncated         = errors.New("logs of the store have to be truncated")
+	ErrStoreCorrupted         = errors.New("store is corrupted")
+	ErrStoreKeyNotFound       = fmt.Errorf("store: %w", badger.ErrKeyNotFound)
+	ErrStoreConflict          = fmt.Errorf("store: %w", badger.ErrConflict)
+	ErrStoreUnsupported       = errors.New("feature is not supported by the store of the bucket")
+	ErrStoreReadOnly          = errors.New("store or transaction is read-only")
+	ErrPayloadNotFile         = errors.New("payload is not stored as a plain file")
+	ErrNoRemote               = errors.New("no remote is configured for the cold tier")
+	ErrRemoteNotFound         = fmt.Errorf("remote object %w", ErrNotFound)
+	ErrUnknownTier            = errors.New("unknown tier")
+	ErrColdObject             = errors.New("payload of the object is in the cold tier")
+	ErrUnknownRecovery        = errors.New("unknown recovery option")
+	ErrEmptyBundle            = errors.New("bundle does not contain any object")
+	ErrBundlePublished        = errors.New("bundle is already published")
+	ErrCipherNotConfigured    = errors.New("no cipher is configured to encrypt the payloads")
+	ErrBackpressure           = errors.New("writes are stalled by compactions")
+	ErrBucketClosed           = errors.New("bucket is closed")
+	ErrUploadNotFound         = fmt.Errorf("upload %w", ErrNotFound)
+	ErrUploadNotResumable     = errors.New("upload can't be resumed")
+	ErrLifecycleRuleNotFound  = fmt.Errorf("lifecycle rule %w", ErrNotFound)
+	ErrInvalidRetentionClass  = errors.New("retention class must have a name and a positive duration")
+	ErrRetentionClassNotFound = fmt.Errorf("retention class %w", ErrNotFound)
+	ErrInvalidSubscription    = errors.New("subscription is invalid")
+	ErrSubscriptionNotFound   = fmt.Errorf("subscription %w", ErrNotFound)
+	ErrWebhookRejected        = errors.New("webhook rejected the events")
+	ErrStandby                = errors.New("bucket is a standby and rejects writes")
+	ErrStaleEpoch             = errors.New("epoch is stale")
+	ErrFenced                 = errors.New("bucket was fenced by a newer epoch and is a standby now")
+	ErrNoReplicationTarget    = errors.New("target of the replication must not be nil")
+	ErrReplicatorExists       = errors.New("replicator with the same name is running")
+	ErrReplicatorNotFound     = fmt.Errorf("replicator %w", ErrNotFound)
+	ErrInvalidReplica         = errors.New("replica is invalid")
+	ErrReplicaRejected        = errors.New("target rejected the replicas")
+	ErrVetoed                 = errors.New("operation was vetoed by a hook")
+	ErrAuditLogDisabled       = errors.New("audit log is not enabled")
 )
 
 // HTTP errors
 var (
-	ErrMissingOwner      = errors.New("missing owner in the request context")
-	ErrUknownContentType = errors.New("content type of the file is not an official mime-type and no contentType key could be found in the form")
+	ErrMissingOwner        = errors.New("missing owner in the request context")
+	ErrUknownContentType   = errors.New("content type of the file is not an official mime-type and no contentType key could be found in the form")
+	ErrMissingPolicySecret = errors.New("missing secret to sign and verify upload policies")
+	ErrInvalidPolicy       = errors.New("upload policy is invalid")
+	ErrPolicyExpired       = errors.New("upload policy is expired")
+	ErrPolicyViolated      = errors.New("upload violates the upload policy")
+	ErrInvalidAPIKey       = errors.New("api key is invalid")
+	ErrAPIKeyRevoked       = errors.New("api key is revoked")
+	ErrAPIKeyNotFound      = fmt.Errorf("api key %w", ErrNotFound)
+	ErrAPIKeyForbidden     = errors.New("api key is not permitted to perform the request")
 )
 
 // Query errors
 var (
 	ErrEmptyQuery          = errors.New("empty query")
 	ErrNameOwnerCtxMissing = errors.New("name is set but missing owner")
+	ErrUnknownOperator     = errors.New("unknown comparison operator")
+	ErrUnknownOrder        = errors.New("unknown sort order")
+	ErrNegativeLimit       = errors.New("limit and offset must not be negative")
+	ErrNilExpr             = errors.New("expression must not be nil")
+	ErrInvalidCursor       = errors.New("cursor is invalid or belongs to another ordering")
+	ErrPageOperation       = errors.New("only get queries can be paginated")
 )
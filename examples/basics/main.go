@@ -17,22 +17,23 @@ func main() {
 		panic(err)
 	}
 
-	// create a new object and write the
-	// payload of the file `test.txt` to it.
+	// create a new object with the
+	// payload of the file `test.txt`.
 	owner := uuid.NewString()
-	// naming the object test.txt will set the
-	// objst.MetaKeyContentType automatically.
-	obj, err := objst.NewObject("test.txt", owner)
-	if err != nil {
-		panic(err)
-	}
 	file, err := os.Open("test.txt")
 	if err != nil {
 		panic(err)
 	}
-	// use the io.ReadFrom interface of the object
-	// to read from an io.Reader e.g. `os.File`
-	if _, err := obj.ReadFrom(file); err != nil {
+	// naming the object test.txt will set the
+	// objst.MetaKeyContentType automatically.
+	// All fields are validated by Build.
+	obj, err := objst.NewObjectBuilder().
+		Name("test.txt").
+		Owner(owner).
+		Meta("project", "basics").
+		FromReader(file).
+		Build()
+	if err != nil {
 		panic(err)
 	}
 
@@ -0,0 +1,184 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// expiryPrefix is the prefix of the expiry markers in the name
+// store. The markers are ordered by the expiry time of the object
+// so the reaper only has to iterate the markers which are due.
+const expiryPrefix = "#expiry/"
+
+// expiryKey returns the key of the expiry marker of the object
+// in the format #expiry/<zero padded unix seconds>/<id>.
+func expiryKey(at time.Time, id string) []byte {
+	return []byte(fmt.Sprintf("%s%020d/%s", expiryPrefix, at.Unix(), id))
+}
+
+// expiryOf returns the time the object expires
+// and false if the object never expires.
+func expiryOf(meta *Metadata) (time.Time, bool) {
+	at, err := parseTime(meta.Get(MetaKeyExpiresAt))
+	return at, err == nil
+}
+
+// withExpiry lets the store expire the entry at the expiry time of
+// the object. Expired entries are invisible to reads and iterations
+// right away and are dropped by the compaction of badger.
+func withExpiry(e *Entry, meta *Metadata) *Entry {
+	at, ok := expiryOf(meta)
+	if !ok {
+		return e
+	}
+	// entries expire with a precision of seconds
+	e.ExpiresAt = uint64(at.Unix())
+	if at.Nanosecond() > 0 {
+		e.ExpiresAt++
+	}
+	return e
+}
+
+// Expire lets the object with the given id expire at the given time.
+// Expired objects are not visible anymore even before the reaper
+// deleted them physically. See ReapExpired. A zero time removes the
+// expiry. ErrExpiresBeforeRetention is returned if the object is
+// locked beyond the expiry time.
+func (b *Bucket) Expire(id string, at time.Time) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	defer b.finishOp(b.startOp("Expire", id))
+	var meta *Metadata
+	err = b.updateMeta(id, func(m *Metadata) error {
+		if at.IsZero() {
+			m.del(MetaKeyExpiresAt)
+		} else if retainUntil, ok := retentionOf(m); ok && at.Before(retainUntil) {
+			return fmt.Errorf("%w: %s", ErrExpiresBeforeRetention, id)
+		} else {
+			m.set(MetaKeyExpiresAt, formatTime(at))
+		}
+		meta = m
+		return nil
+	})
+	if err != nil {
+		return notFound(err)
+	}
+	// the name, checksum and index entries
+	// have to expire with the meta data.
+	return b.insertName(meta)
+}
+
+// ReapExpired deletes all expired objects physically and returns
+// the number of deleted objects. It is called every LifecycleInterval
+// by the lifecycle worker.
+func (b *Bucket) ReapExpired(ctx context.Context) (int, error) {
+	due := []byte(fmt.Sprintf("%s%020d/", expiryPrefix, time.Now().Unix()+1))
+	markers := make([]*Metadata, 0)
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(expiryPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			if string(item.Key()) >= string(due) {
+				return nil
+			}
+			meta := NewMetadata()
+			if err := item.Value(func(val []byte) error {
+				return b.unmarshalMeta(meta, val)
+			}); err != nil {
+				return err
+			}
+			markers = append(markers, meta)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	reaped := 0
+	for _, meta := range markers {
+		if err := ctx.Err(); err != nil {
+			return reaped, err
+		}
+		ok, err := b.reap(meta)
+		if err != nil {
+			return reaped, err
+		}
+		if ok {
+			reaped++
+		}
+	}
+	return reaped, nil
+}
+
+// reap deletes the expired object described by the meta data of
+// its expiry marker. False is returned if the object is still
+// visible because the store didn't expire it yet or if it was
+// deleted concurrently.
+func (b *Bucket) reap(meta *Metadata) (bool, error) {
+	done, err := b.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	id := meta.Get(MetaKeyID)
+	defer b.finishOp(b.startOp("Reap", id))
+	if _, err := b.GetMeta(id); err == nil {
+		return false, nil
+	} else if !errors.Is(err, ErrObjectNotFound) {
+		return false, err
+	}
+	// the expiry marker is claimed because the
+	// meta data was already deleted by the store.
+	// The object was deleted concurrently if the
+	// marker doesn't exist anymore.
+	at, _ := expiryOf(meta)
+	err = deleteExisting(b.name, expiryKey(at, id))
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, b.objectRemoved(meta)
+}
+
+// updateExpiryMarker replaces the expiry marker of the object
+// described by old with the one described by meta. The marker
+// contains the meta data of the object which is needed by the
+// reaper after the meta data itself expired.
+func (b *Bucket) updateExpiryMarker(old, meta *Metadata) error {
+	oldAt, hadExpiry := expiryOf(old)
+	at, hasExpiry := expiryOf(meta)
+	if !hadExpiry && !hasExpiry {
+		return nil
+	}
+	var data []byte
+	if hasExpiry {
+		var err error
+		data, err = b.encodeMeta(meta)
+		if err != nil {
+			return err
+		}
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		if hadExpiry && (!hasExpiry || !oldAt.Equal(at)) {
+			if err := txn.Delete(expiryKey(oldAt, old.Get(MetaKeyID))); err != nil {
+				return err
+			}
+		}
+		if !hasExpiry {
+			return nil
+		}
+		return txn.Set(expiryKey(at, meta.Get(MetaKeyID)), data)
+	})
+}
@@ -0,0 +1,126 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestExpire(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	owner := tEnv.owner()
+	o, _ := NewObject("expired.txt", owner)
+	o.Write(tEnv.payload(10))
+	if err := b.CreateWithOptions(o, CreateOptions{TTL: time.Hour}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.UpdateMeta(o.ID(), map[MetaKey]string{"foo": "bar"}); err != nil {
+		t.Error(err)
+		return
+	}
+	err = b.meta.View(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(o.ID()))
+		if err != nil {
+			return err
+		}
+		if item.ExpiresAt() == 0 {
+			t.Fatalf("update should keep the expiry of the meta data")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.Lock(o.ID(), time.Now().Add(2*time.Hour)); !errors.Is(err, ErrExpiresBeforeRetention) {
+		t.Fatalf("retention beyond the expiry should be rejected. Got: %v", err)
+	}
+
+	// expired objects are invisible before they are reaped
+	if err := b.Expire(o.ID(), time.Now().Add(-time.Second)); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := b.GetByID(o.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("expired object should not be found. Got: %v", err)
+	}
+	if _, err := b.GetByName(o.Name(), owner); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("expired object should not be found by name. Got: %v", err)
+	}
+	objs, err := b.Execute(NewQuery().Owner(owner))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(objs) != 0 {
+		t.Fatalf("expired object should not be queried. Got: %d objects", len(objs))
+	}
+	if ids, _ := b.FindByChecksum(o.Checksum()); len(ids) != 0 {
+		t.Fatalf("expired object should not be found by checksum. Got: %v", ids)
+	}
+	renewed, _ := NewObject(o.Name(), owner)
+	renewed.Write(tEnv.payload(5))
+	if err := b.Create(renewed); err != nil {
+		t.Fatalf("name of the expired object should be free. Got: %v", err)
+	}
+	if u := b.Usage(owner); u.Objects != 2 {
+		t.Fatalf("expired object should be counted until it is reaped. Got: %+v", u)
+	}
+
+	n, err := b.ReapExpired(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n != 1 {
+		t.Fatalf("one object should be reaped. Got: %d", n)
+	}
+	if u := b.Usage(owner); u.Objects != 1 || u.Bytes != 5 {
+		t.Fatalf("reaped object should not be counted. Got: %+v", u)
+	}
+	err = b.payload.View(func(txn *storeTxn) error {
+		_, err := readChunks(txn, o.ID())
+		return err
+	})
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Fatalf("payload of the reaped object should be deleted. Got: %v", err)
+	}
+	got, err := b.GetByName(o.Name(), owner)
+	if err != nil || got.ID() != renewed.ID() {
+		t.Fatalf("reaping should keep the object with the same name. Got: %v", err)
+	}
+	if n, _ := b.ReapExpired(context.Background()); n != 0 {
+		t.Fatalf("no object should be left to reap. Got: %d", n)
+	}
+
+	if err := b.Expire(renewed.ID(), time.Now().Add(time.Hour)); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.Expire(renewed.ID(), time.Time{}); err != nil {
+		t.Error(err)
+		return
+	}
+	meta, err := b.GetMeta(renewed.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if meta.Has(MetaKeyExpiresAt) {
+		t.Fatalf("expiry should be removed. Got: %s", meta.Get(MetaKeyExpiresAt))
+	}
+}
@@ -0,0 +1,250 @@
+package objst
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the format of an archive created by Export.
+type ArchiveFormat int
+
+const (
+	// ArchiveTar stores the meta data of every object in the
+	// PAX records of its header prefixed by paxPrefix.
+	ArchiveTar ArchiveFormat = iota
+	// ArchiveZip stores the meta data of the objects
+	// only in the manifest of the archive.
+	ArchiveZip
+)
+
+// paxPrefix is the prefix of the PAX records
+// storing the meta data of an object.
+const paxPrefix = "OBJST."
+
+// Export writes the objects matching the query as files named by
+// the name of the objects into an archive of the format to w. The
+// payloads are streamed without loading them at once if possible.
+// The last file of the archive is the manifest listing the checksum
+// of every file. See VerifyArchive. Exporting the same objects
+// results in the same archive.
+//
+// Objects deleted during the export are skipped and objects replaced
+// while they are written fail the export with an error wrapping
+// ErrObjectNotFound. The names of objects of different owners may
+// be the same, so the owner of every file is part of its meta data.
+// Archives can be restored using Import. Tar archives can be imported
+// as objects of another owner using ImportTar.
+func (b *Bucket) Export(ctx context.Context, w io.Writer, q *Query, format ArchiveFormat) error {
+	op := b.startOp("Export")
+	op.Query = q.String()
+	defer b.finishOp(op)
+	if err := q.isValid(b.opts.OwnerValidator); err != nil {
+		return err
+	}
+	var aw archiveWriter
+	switch format {
+	case ArchiveTar:
+		aw = &tarArchive{tw: tar.NewWriter(w)}
+	case ArchiveZip:
+		aw = &zipArchive{zw: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("%w: %d", ErrUnknownArchiveFormat, format)
+	}
+	manifest := newManifest()
+	// the query of the caller is not modified
+	scan := *q
+	scan.ctx = ctx
+	spool, err := b.spoolMatchingIDs(&scan)
+	if err != nil {
+		return err
+	}
+	defer spool.Close()
+	err = spool.each(func(id string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		meta, err := b.GetMeta(id)
+		if errors.Is(err, ErrObjectNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		size, err := strconv.ParseInt(meta.Get(MetaKeySize), 10, 64)
+		// objects of older versions don't record their size
+		if err != nil {
+			pl, err := b.readPayload(meta)
+			if err != nil {
+				return err
+			}
+			size = int64(len(pl))
+		}
+		info := b.composeLazyObject(meta).Info()
+		info.Size = size
+		fw, err := aw.create(info)
+		if err != nil {
+			return err
+		}
+		h := sha256.New()
+		err = b.viewPayload(meta, func(chunk []byte) error {
+			h.Write(chunk)
+			_, err := fw.Write(chunk)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", id, err)
+		}
+		manifest.Entries = append(manifest.Entries, ManifestEntry{
+			Path:   info.Name,
+			Size:   size,
+			SHA256: hex.EncodeToString(h.Sum(nil)),
+			Object: info,
+		})
+		op.Size += size
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	return aw.close(data, manifest.modTime())
+}
+
+// archiveWriter writes the files of the objects into an archive.
+type archiveWriter interface {
+	// create adds the file of the object to the archive and
+	// returns the writer the payload has to be written to.
+	create(info *ObjectInfo) (io.Writer, error)
+
+	// close adds the manifest as the last file and
+	// closes the archive without closing its writer.
+	close(manifest []byte, modTime time.Time) error
+}
+
+type tarArchive struct {
+	tw *tar.Writer
+}
+
+func (t *tarArchive) create(info *ObjectInfo) (io.Writer, error) {
+	records := map[string]string{
+		paxPrefix + string(MetaKeyID):          info.ID,
+		paxPrefix + string(MetaKeyOwner):       info.Owner,
+		paxPrefix + string(MetaKeyContentType): info.ContentType,
+		paxPrefix + string(MetaKeyChecksum):    info.Checksum,
+		paxPrefix + string(MetaKeyCreatedAt):   info.CreatedAt.Format(time.RFC3339Nano),
+	}
+	for k, v := range info.UserMeta {
+		records[paxPrefix+"meta."+string(k)] = v
+	}
+	hdr := &tar.Header{
+		Typeflag:   tar.TypeReg,
+		Name:       info.Name,
+		Size:       info.Size,
+		Mode:       0o644,
+		ModTime:    modTime(info),
+		Format:     tar.FormatPAX,
+		PAXRecords: records,
+	}
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return nil, err
+	}
+	return t.tw, nil
+}
+
+func (t *tarArchive) close(manifest []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     ManifestName,
+		Size:     int64(len(manifest)),
+		Mode:     0o644,
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}
+	if err := t.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := t.tw.Write(manifest); err != nil {
+		return err
+	}
+	return t.tw.Close()
+}
+
+type zipArchive struct {
+	zw *zip.Writer
+}
+
+func (z *zipArchive) create(info *ObjectInfo) (io.Writer, error) {
+	return z.zw.CreateHeader(&zip.FileHeader{
+		Name:     info.Name,
+		Method:   zip.Deflate,
+		Modified: modTime(info),
+	})
+}
+
+func (z *zipArchive) close(manifest []byte, modTime time.Time) error {
+	w, err := z.zw.CreateHeader(&zip.FileHeader{
+		Name:     ManifestName,
+		Method:   zip.Deflate,
+		Modified: modTime,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(manifest); err != nil {
+		return err
+	}
+	return z.zw.Close()
+}
+
+// paxInfo returns the information of the object of the file
+// with the name stored in the PAX records by Export.
+func paxInfo(name string, records map[string]string) *ObjectInfo {
+	info := &ObjectInfo{
+		ID:          records[paxPrefix+string(MetaKeyID)],
+		Name:        name,
+		Owner:       records[paxPrefix+string(MetaKeyOwner)],
+		ContentType: records[paxPrefix+string(MetaKeyContentType)],
+		Checksum:    records[paxPrefix+string(MetaKeyChecksum)],
+		UserMeta:    make(map[MetaKey]string),
+	}
+	for k, v := range records {
+		if key, ok := strings.CutPrefix(k, paxPrefix+"meta."); ok {
+			info.UserMeta[MetaKey(key)] = v
+		}
+	}
+	return info
+}
+
+// importMeta returns the content type and the user defined
+// meta data of the object which are restored by an import.
+func (o *ObjectInfo) importMeta() map[MetaKey]string {
+	pairs := make(map[MetaKey]string, len(o.UserMeta)+1)
+	for k, v := range o.UserMeta {
+		pairs[k] = v
+	}
+	if o.ContentType != "" {
+		pairs[MetaKeyContentType] = o.ContentType
+	}
+	return pairs
+}
+
+// modTime returns the time of the last modification of the object.
+func modTime(info *ObjectInfo) time.Time {
+	if info.UpdatedAt.IsZero() {
+		return info.CreatedAt
+	}
+	return info.UpdatedAt
+}
@@ -0,0 +1,201 @@
+package objst
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestExport(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	owner := tEnv.owner()
+	payloads := map[string]string{"docs/a.txt": "first", "b.txt": "second"}
+	for name, pl := range payloads {
+		obj, err := NewObject(name, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj.SetMetaKey(MetaKeyContentType, "text/plain")
+		obj.SetMetaKey("team", "infra")
+		if _, err := obj.Write([]byte(pl)); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.Create(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Create(tEnv.obj()); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	var buf bytes.Buffer
+	if err := b.Export(ctx, &buf, NewQuery().Owner(owner), ArchiveTar); err != nil {
+		t.Fatal(err)
+	}
+	archive := bytes.Clone(buf.Bytes())
+	tr := tar.NewReader(&buf)
+	n := 0
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Name == ManifestName {
+			continue
+		}
+		if string(data) != payloads[hdr.Name] {
+			t.Fatalf("payload of %s is not %s. Got: %s", hdr.Name, payloads[hdr.Name], data)
+		}
+		if hdr.PAXRecords[paxPrefix+string(MetaKeyOwner)] != owner || hdr.PAXRecords[paxPrefix+"meta.team"] != "infra" {
+			t.Fatalf("meta data should be stored in the PAX records. Got: %v", hdr.PAXRecords)
+		}
+		n++
+	}
+	if n != len(payloads) {
+		t.Fatalf("expected %d files. Got: %d", len(payloads), n)
+	}
+	other := tEnv.owner()
+	report, err := b.ImportTar(ctx, bytes.NewReader(archive), other, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(ImportCreated) != len(payloads) {
+		t.Fatalf("exported archive should be importable. Got: %+v", report.Results)
+	}
+	imported, err := b.GetByName("b.txt", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported.GetMetaKey("team") != "infra" {
+		t.Fatal("user defined meta data should be restored by the import")
+	}
+
+	buf.Reset()
+	if err := b.Export(ctx, &buf, NewQuery().Owner(owner), ArchiveZip); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(payloads)+1 || zr.File[len(zr.File)-1].Name != ManifestName {
+		t.Fatalf("zip should contain the objects and the manifest. Got: %d files", len(zr.File))
+	}
+	f, err := zr.File[len(zr.File)-1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var manifest Manifest
+	if err := json.NewDecoder(f).Decode(&manifest); err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Version != ArchiveVersion || len(manifest.Entries) != len(payloads) || manifest.Entries[0].Path != zr.File[0].Name {
+		t.Fatalf("manifest should list the objects in the order of the archive. Got: %+v", manifest)
+	}
+	if err := b.Export(ctx, io.Discard, NewQuery().Owner(owner), ArchiveFormat(42)); !errors.Is(err, ErrUnknownArchiveFormat) {
+		t.Fatalf("unknown format should be rejected. Got: %v", err)
+	}
+}
+
+func TestImportExported(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	owner := tEnv.owner()
+	ids := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		obj, err := NewObject(tEnv.name(), owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		obj.SetMetaKey("team", "infra")
+		obj.Write(tEnv.payload(10))
+		if err := b.Create(obj); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, obj.ID())
+	}
+	ctx := context.Background()
+	for _, format := range []ArchiveFormat{ArchiveTar, ArchiveZip} {
+		var buf bytes.Buffer
+		if err := b.Export(ctx, &buf, NewQuery().Owner(owner), format); err != nil {
+			t.Fatal(err)
+		}
+		archive := buf.Bytes()
+		if _, err := b.Import(ctx, bytes.NewReader(archive), ImportOptions{}); !errors.Is(err, ErrDuplicateName) {
+			t.Fatalf("collision should stop the import. Got: %v", err)
+		}
+		report, err := b.Import(ctx, bytes.NewReader(archive), ImportOptions{Collision: CollisionSkip})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Count(ImportSkipped) != len(ids) {
+			t.Fatalf("existing objects should be skipped. Got: %+v", report.Results)
+		}
+		if _, err := b.Delete(NewQuery().Owner(owner).Operation(OperationDelete)); err != nil {
+			t.Fatal(err)
+		}
+		report, err = b.Import(ctx, bytes.NewReader(archive), ImportOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Count(ImportCreated) != len(ids) {
+			t.Fatalf("all objects should be restored. Got: %+v", report.Results)
+		}
+		for _, id := range ids {
+			obj, err := b.GetByID(id)
+			if err != nil {
+				t.Fatalf("object should be restored with its id: %v", err)
+			}
+			if obj.Owner() != owner || obj.GetMetaKey("team") != "infra" {
+				t.Fatalf("owner and meta data should be restored. Got: %s %s", obj.Owner(), obj.GetMetaKey("team"))
+			}
+		}
+		report, err = b.Import(ctx, bytes.NewReader(archive), ImportOptions{Collision: CollisionRename})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, res := range report.Results {
+			if res.Action != ImportRenamed || res.Name == res.Path || slices.Contains(ids, res.ID) {
+				t.Fatalf("renamed object should get a new name and id. Got: %+v", res)
+			}
+		}
+		// the objects are restored again for the next format
+		if _, err := b.Delete(NewQuery().Owner(owner).Operation(OperationDelete)); err != nil {
+			t.Fatal(err)
+		}
+		report, err = b.Import(ctx, bytes.NewReader(archive), ImportOptions{})
+		if err != nil || report.Count(ImportCreated) != len(ids) {
+			t.Fatalf("objects should be restored again. Got: %+v, %v", report, err)
+		}
+	}
+}
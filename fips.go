@@ -0,0 +1,63 @@
+package objst
+
+import "fmt"
+
+// minFIPSKeySize is the minimum size in bytes
+// of the HMAC keys in the FIPS mode (112 bits).
+const minFIPSKeySize = 14
+
+// FIPSCipher is implemented by ciphers which are only using FIPS
+// approved algorithms e.g. the cipher of NewAESGCMCipher. Other
+// ciphers are rejected in the FIPS mode.
+type FIPSCipher interface {
+	Cipher
+
+	// FIPSApproved reports if the cipher is
+	// only using FIPS approved algorithms.
+	FIPSApproved() bool
+}
+
+// isFIPS reports if the bucket is restricted to FIPS approved
+// algorithms which is the case for binaries built with the
+// objst_fips build tag or if BucketOptions.FIPS is set.
+func (b BucketOptions) isFIPS() bool {
+	return fipsBuild || b.FIPS
+}
+
+// validateFIPS returns an error wrapping ErrNotFIPSApproved
+// if the options are using a cryptographic algorithm or key
+// which isn't approved in the FIPS mode.
+func (b BucketOptions) validateFIPS() error {
+	if !b.isFIPS() {
+		return nil
+	}
+	if err := validateFIPSCipher(true, b.Cipher); err != nil {
+		return err
+	}
+	if n := len(b.MetaSigningKey); n > 0 && n < minFIPSKeySize {
+		return fmt.Errorf("%w: meta signing key is shorter than %d bytes", ErrNotFIPSApproved, minFIPSKeySize)
+	}
+	return nil
+}
+
+// validateFIPSCipher returns an error wrapping ErrNotFIPSApproved
+// if the cipher isn't a FIPSCipher and the mode is on. A nil
+// cipher is valid because nothing is encrypted.
+func validateFIPSCipher(fips bool, c Cipher) error {
+	if !(fipsBuild || fips) || c == nil {
+		return nil
+	}
+	if fc, ok := c.(FIPSCipher); !ok || !fc.FIPSApproved() {
+		return fmt.Errorf("%w: cipher %T", ErrNotFIPSApproved, c)
+	}
+	return nil
+}
+
+// validateFIPSKey returns an error wrapping ErrNotFIPSApproved if
+// the HMAC key is too short for the FIPS mode and the mode is on.
+func validateFIPSKey(fips bool, key []byte) error {
+	if (fipsBuild || fips) && len(key) < minFIPSKeySize {
+		return fmt.Errorf("%w: key is shorter than %d bytes", ErrNotFIPSApproved, minFIPSKeySize)
+	}
+	return nil
+}
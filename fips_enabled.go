@@ -0,0 +1,7 @@
+//go:build objst_fips
+
+package objst
+
+// fipsBuild enables the FIPS mode for all buckets
+// of binaries built with the objst_fips build tag.
+const fipsBuild = true
@@ -0,0 +1,67 @@
+package objst
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+type plainCipher struct{}
+
+func (plainCipher) Encrypt(plaintext []byte) ([]byte, Envelope, error) {
+	return plaintext, Envelope{}, nil
+}
+
+func (plainCipher) Decrypt(ciphertext []byte, env Envelope) ([]byte, error) {
+	return ciphertext, nil
+}
+
+func TestFIPSMode(t *testing.T) {
+	aesCipher, err := NewAESGCMCipher(make([]byte, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		cipher  Cipher
+		signKey []byte
+		wantErr bool
+	}{
+		{name: "approved cipher", cipher: aesCipher, signKey: make([]byte, 32)},
+		{name: "unknown cipher", cipher: plainCipher{}, wantErr: true},
+		{name: "key ring", cipher: NewKeyRing(aesCipher, aesCipher)},
+		{name: "key ring with unknown cipher", cipher: NewKeyRing(aesCipher, plainCipher{}), wantErr: true},
+		{name: "short signing key", signKey: []byte("secret"), wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			opts := NewDefaultBucketOptions()
+			opts.Logger = nil
+			opts.FIPS = true
+			opts.Cipher = tc.cipher
+			opts.MetaSigningKey = tc.signKey
+			b, err := NewBucket(opts)
+			if tc.wantErr {
+				if !errors.Is(err, ErrNotFIPSApproved) {
+					t.Fatalf("bucket should be rejected in the FIPS mode. Got: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(b.BasePath)
+			defer b.Shutdown()
+			err = b.StartPipeline(Pipeline{Name: "reencrypt", Cipher: plainCipher{}})
+			if !errors.Is(err, ErrNotFIPSApproved) {
+				t.Fatalf("pipeline should be rejected in the FIPS mode. Got: %v", err)
+			}
+		})
+	}
+	if err := validateFIPSKey(true, []byte("secret")); !errors.Is(err, ErrNotFIPSApproved) {
+		t.Fatalf("short key should be rejected in the FIPS mode. Got: %v", err)
+	}
+	if err := validateFIPSKey(true, make([]byte, minFIPSKeySize)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,224 @@
+// Package fixtures generates reproducible objects for tests,
+// benchmarks and fuzzing. All objects of a Generator are derived
+// from its seed, so the same options always result in the same
+// ids, owners, names, payloads and meta data. The golden forms
+// of the objects can be written to a file and used to verify
+// that objects survive e.g. a migration unchanged.
+package fixtures
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/naivary/objst"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// letters are the bytes of the
+	// payload of textual objects.
+	letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789 \n"
+	// zipfS is the skew of the distribution of the meta data values.
+	zipfS = 1.5
+)
+
+var (
+	ErrInvalidSize      = errors.New("min size must be positive and not greater than max size")
+	ErrNoOwners         = errors.New("number of owners must be positive")
+	ErrNoContentTypes   = errors.New("at least one content type is required")
+	ErrNoMetaValues     = errors.New("at least one value is required for every meta data key")
+	ErrGoldenMismatch   = errors.New("object does not match its golden form")
+	ErrInvalidExtension = errors.New("extension must start with a dot followed by lowercase letters")
+)
+
+// ContentType is a content type of the
+// generated objects with its file extension.
+type ContentType struct {
+	// Ext is the extension of the
+	// object names e.g. ".json".
+	Ext string
+	// Type is the value of
+	// objst.MetaKeyContentType.
+	Type string
+}
+
+// isText reports whether the payload of
+// the content type should be printable.
+func (c ContentType) isText() bool {
+	return strings.HasPrefix(c.Type, "text/") || c.Type == "application/json"
+}
+
+type Options struct {
+	// Seed of the generator. Generators
+	// with the same options and seed are
+	// generating the same objects.
+	Seed int64
+
+	// Owners is the number of distinct owners
+	// the objects are distributed over uniformly.
+	Owners int
+
+	// MinSize and MaxSize are the bounds of the payload size in
+	// bytes. The sizes are log-uniformly distributed so small
+	// objects are more common like in most real buckets.
+	MinSize int
+	MaxSize int
+
+	// ContentTypes of the objects which
+	// are chosen uniformly.
+	ContentTypes []ContentType
+
+	// Meta are the user defined meta data of the objects. Every
+	// object gets a value for every key which is drawn from a
+	// zipf distribution, so the first value is the most common.
+	Meta map[objst.MetaKey][]string
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Seed:    1,
+		Owners:  4,
+		MinSize: 1,
+		MaxSize: 64 << 10,
+		ContentTypes: []ContentType{
+			{Ext: ".txt", Type: "text/plain"},
+			{Ext: ".json", Type: "application/json"},
+			{Ext: ".png", Type: "image/png"},
+			{Ext: ".pdf", Type: "application/pdf"},
+		},
+		Meta: map[objst.MetaKey][]string{
+			"tag":    {"report", "invoice", "photo", "backup", "misc"},
+			"status": {"active", "archived"},
+		},
+	}
+}
+
+func (o Options) isValid() error {
+	if o.MinSize < 1 || o.MinSize > o.MaxSize {
+		return ErrInvalidSize
+	}
+	if o.Owners < 1 {
+		return ErrNoOwners
+	}
+	if len(o.ContentTypes) == 0 {
+		return ErrNoContentTypes
+	}
+	for _, c := range o.ContentTypes {
+		if len(c.Ext) < 2 || c.Ext[0] != '.' || strings.Trim(c.Ext[1:], "abcdefghijklmnopqrstuvwxyz") != "" {
+			return fmt.Errorf("%w: %s", ErrInvalidExtension, c.Ext)
+		}
+	}
+	for k, values := range o.Meta {
+		if len(values) == 0 {
+			return fmt.Errorf("%w: %s", ErrNoMetaValues, k)
+		}
+	}
+	return nil
+}
+
+// Generator generates reproducible objects. A
+// Generator is not safe for concurrent use.
+type Generator struct {
+	opts   Options
+	rnd    *rand.Rand
+	owners []string
+	// keys are the sorted meta data keys so
+	// the values are drawn in a stable order.
+	keys []objst.MetaKey
+	zipf map[objst.MetaKey]*rand.Zipf
+	// n is the number of generated objects.
+	n int
+}
+
+func New(opts Options) (*Generator, error) {
+	if err := opts.isValid(); err != nil {
+		return nil, err
+	}
+	g := &Generator{
+		opts:   opts,
+		rnd:    rand.New(rand.NewSource(opts.Seed)),
+		owners: make([]string, 0, opts.Owners),
+		keys:   make([]objst.MetaKey, 0, len(opts.Meta)),
+		zipf:   make(map[objst.MetaKey]*rand.Zipf, len(opts.Meta)),
+	}
+	for i := 0; i < opts.Owners; i++ {
+		g.owners = append(g.owners, g.uuid())
+	}
+	for k := range opts.Meta {
+		g.keys = append(g.keys, k)
+	}
+	slices.Sort(g.keys)
+	for _, k := range g.keys {
+		g.zipf[k] = rand.NewZipf(g.rnd, zipfS, 1, uint64(len(opts.Meta[k])-1))
+	}
+	return g, nil
+}
+
+// Owners returns the owners of the generated objects.
+func (g *Generator) Owners() []string {
+	return slices.Clone(g.owners)
+}
+
+// Object returns the next object of the generator. The name
+// of the object is in the format fixture-<n><ext> where n
+// is the number of previously generated objects.
+func (g *Generator) Object() *objst.Object {
+	owner := g.owners[g.rnd.Intn(len(g.owners))]
+	id := g.uuid()
+	ct := g.opts.ContentTypes[g.rnd.Intn(len(g.opts.ContentTypes))]
+	name := fmt.Sprintf("fixture-%06d%s", g.n, ct.Ext)
+	g.n++
+	// the options are validated so the id,
+	// name and owner are always valid.
+	obj, _ := objst.NewObjectWithID(id, name, owner)
+	// the content type of NewObject depends
+	// on the mime types of the system.
+	obj.SetMetaKey(objst.MetaKeyContentType, ct.Type)
+	for _, k := range g.keys {
+		obj.SetMetaKey(k, g.opts.Meta[k][g.zipf[k].Uint64()])
+	}
+	obj.Write(g.payload(g.size(), ct.isText()))
+	return obj
+}
+
+// Objects returns the next n objects of the generator.
+func (g *Generator) Objects(n int) []*objst.Object {
+	objs := make([]*objst.Object, 0, n)
+	for i := 0; i < n; i++ {
+		objs = append(objs, g.Object())
+	}
+	return objs
+}
+
+func (g *Generator) uuid() string {
+	// reading from a *rand.Rand never fails.
+	id, _ := uuid.NewRandomFromReader(g.rnd)
+	return id.String()
+}
+
+// size returns a log-uniformly distributed
+// size between MinSize and MaxSize.
+func (g *Generator) size() int {
+	span := float64(g.opts.MaxSize - g.opts.MinSize + 1)
+	size := g.opts.MinSize + int(math.Exp(g.rnd.Float64()*math.Log(span))) - 1
+	if size > g.opts.MaxSize {
+		return g.opts.MaxSize
+	}
+	return size
+}
+
+func (g *Generator) payload(n int, text bool) []byte {
+	pl := make([]byte, n)
+	g.rnd.Read(pl)
+	if !text {
+		return pl
+	}
+	for i, c := range pl {
+		pl[i] = letters[int(c)%len(letters)]
+	}
+	return pl
+}
@@ -0,0 +1,128 @@
+package fixtures
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/naivary/objst"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+const goldenObjects = 32
+
+func newTestBucket(tb testing.TB) *objst.Bucket {
+	tb.Helper()
+	opts := objst.NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := objst.NewBucket(opts)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		b.Shutdown()
+		os.RemoveAll(b.BasePath)
+	})
+	return b
+}
+
+func TestGenerator(t *testing.T) {
+	g1, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	g2, _ := New(DefaultOptions())
+	for i, obj := range g1.Objects(goldenObjects) {
+		other := g2.Object()
+		if err := NewGolden(other).Verify(obj); err != nil {
+			t.Fatalf("object %d differs for the same seed: %v", i, err)
+		}
+		if !bytes.Equal(obj.Payload(), other.Payload()) {
+			t.Fatalf("payload of object %d differs for the same seed", i)
+		}
+	}
+	opts := DefaultOptions()
+	opts.Seed = 2
+	g3, _ := New(opts)
+	if NewGolden(g3.Object()).ID == NewGolden(g1.Objects(1)[0]).ID {
+		t.Fatalf("objects of different seeds should differ")
+	}
+	opts = DefaultOptions()
+	opts.MinSize = 0
+	if _, err := New(opts); !errors.Is(err, ErrInvalidSize) {
+		t.Fatalf("zero min size should be invalid. Got: %v", err)
+	}
+	opts = DefaultOptions()
+	opts.ContentTypes = []ContentType{{Ext: "txt", Type: "text/plain"}}
+	if _, err := New(opts); !errors.Is(err, ErrInvalidExtension) {
+		t.Fatalf("extension without a dot should be invalid. Got: %v", err)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	g, err := New(DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := g.Objects(goldenObjects)
+	path := filepath.Join("testdata", "golden.jsonl")
+	if *update {
+		buf := new(bytes.Buffer)
+		if err := WriteGolden(buf, objs); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	goldens, err := ReadGolden(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goldens) != len(objs) {
+		t.Fatalf("number of golden objects is not %d. Got: %d", len(objs), len(goldens))
+	}
+	b := newTestBucket(t)
+	if err := b.BatchCreate(objs); err != nil {
+		t.Fatal(err)
+	}
+	for i, golden := range goldens {
+		if err := golden.Verify(objs[i]); err != nil {
+			t.Fatalf("generated object changed. Run the tests with -update if it's intended: %v", err)
+		}
+		obj, err := b.GetByID(golden.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := golden.Verify(obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	goldens[0].Checksum = ""
+	if err := goldens[0].Verify(objs[0]); !errors.Is(err, ErrGoldenMismatch) {
+		t.Fatalf("changed golden form should mismatch. Got: %v", err)
+	}
+}
+
+func BenchmarkBatchCreate(b *testing.B) {
+	bucket := newTestBucket(b)
+	g, err := New(DefaultOptions())
+	if err != nil {
+		b.Fatal(err)
+	}
+	objs := g.Objects(b.N)
+	b.ResetTimer()
+	if err := bucket.BatchCreate(objs); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+}
@@ -0,0 +1,93 @@
+package fixtures
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/naivary/objst"
+	"golang.org/x/exp/maps"
+)
+
+// Golden is the serialized form of an object which only contains
+// the fields which are stable across buckets. The times and the
+// system managed meta data like the etag are excluded.
+type Golden struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Owner       string `json:"owner"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	// Checksum is the hex encoded SHA-256 of the payload.
+	Checksum string                   `json:"checksum"`
+	UserMeta map[objst.MetaKey]string `json:"userMeta"`
+}
+
+// NewGolden returns the golden form of the object.
+func NewGolden(o *objst.Object) Golden {
+	info := o.Info()
+	sum := sha256.Sum256(o.Payload())
+	return Golden{
+		ID:          info.ID,
+		Name:        info.Name,
+		Owner:       info.Owner,
+		ContentType: info.ContentType,
+		Size:        int64(len(o.Payload())),
+		Checksum:    hex.EncodeToString(sum[:]),
+		UserMeta:    info.UserMeta,
+	}
+}
+
+// Verify returns an error wrapping ErrGoldenMismatch
+// if the object doesn't match the golden form.
+func (g Golden) Verify(o *objst.Object) error {
+	got := NewGolden(o)
+	switch {
+	case got.ID != g.ID:
+		return fmt.Errorf("%w: id is %s instead of %s", ErrGoldenMismatch, got.ID, g.ID)
+	case got.Name != g.Name:
+		return fmt.Errorf("%w: name of %s is %s instead of %s", ErrGoldenMismatch, g.ID, got.Name, g.Name)
+	case got.Owner != g.Owner:
+		return fmt.Errorf("%w: owner of %s is %s instead of %s", ErrGoldenMismatch, g.ID, got.Owner, g.Owner)
+	case got.ContentType != g.ContentType:
+		return fmt.Errorf("%w: content type of %s is %s instead of %s", ErrGoldenMismatch, g.ID, got.ContentType, g.ContentType)
+	case got.Size != g.Size || got.Checksum != g.Checksum:
+		return fmt.Errorf("%w: payload of %s differs", ErrGoldenMismatch, g.ID)
+	case !maps.Equal(got.UserMeta, g.UserMeta):
+		return fmt.Errorf("%w: meta data of %s is %v instead of %v", ErrGoldenMismatch, g.ID, got.UserMeta, g.UserMeta)
+	}
+	return nil
+}
+
+// WriteGolden writes the golden forms of the
+// objects as JSON lines in the given order.
+func WriteGolden(w io.Writer, objs []*objst.Object) error {
+	enc := json.NewEncoder(w)
+	for _, obj := range objs {
+		if err := enc.Encode(NewGolden(obj)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadGolden reads the golden forms written by WriteGolden.
+func ReadGolden(r io.Reader) ([]Golden, error) {
+	goldens := make([]Golden, 0)
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var g Golden
+		err := dec.Decode(&g)
+		if errors.Is(err, io.EOF) {
+			return goldens, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		goldens = append(goldens, g)
+	}
+}
@@ -0,0 +1,53 @@
+package objst
+
+// Freeze rejects all writes with ErrBucketFrozen until
+// Unfreeze is called while reads are still possible. It
+// blocks until all in-flight writes are done so operators
+// get a consistent state of the bucket e.g. for backups,
+// migrations or compactions.
+func (b *Bucket) Freeze() {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	b.frozen = true
+}
+
+// Unfreeze allows writes again after Freeze. A bucket
+// opened read-only using RecoveryReadOnly stays frozen.
+func (b *Bucket) Unfreeze() {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	b.frozen = b.readOnly
+}
+
+// IsFrozen reports if the bucket is rejecting writes.
+func (b *Bucket) IsFrozen() bool {
+	b.writeMu.RLock()
+	defer b.writeMu.RUnlock()
+	return b.frozen
+}
+
+// beginWrite has to be called by every public write
+// operation before any data is changed. The returned
+// function has to be called when the write is done.
+// Write operations must not call each other after
+// beginWrite to prevent a deadlock with Freeze.
+func (b *Bucket) beginWrite() (func(), error) {
+	b.writeMu.RLock()
+	if b.closing {
+		b.writeMu.RUnlock()
+		return nil, ErrBucketClosed
+	}
+	if b.frozen {
+		b.writeMu.RUnlock()
+		return nil, ErrBucketFrozen
+	}
+	if b.role == RoleStandby {
+		b.writeMu.RUnlock()
+		return nil, ErrStandby
+	}
+	if err := b.backpressure(); err != nil {
+		b.writeMu.RUnlock()
+		return nil, err
+	}
+	return b.writeMu.RUnlock, nil
+}
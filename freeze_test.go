@@ -0,0 +1,53 @@
+package objst
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestFreeze(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	tEnv.b.Freeze()
+	defer tEnv.b.Unfreeze()
+	if err := tEnv.b.Create(tEnv.obj()); !errors.Is(err, ErrBucketFrozen) {
+		t.Fatalf("create should be rejected while frozen. Got: %v", err)
+	}
+	if err := tEnv.b.DeleteByID(o.ID()); !errors.Is(err, ErrBucketFrozen) {
+		t.Fatalf("delete should be rejected while frozen. Got: %v", err)
+	}
+	if _, err := tEnv.b.GetByID(o.ID()); err != nil {
+		t.Fatalf("reads should be possible while frozen: %v", err)
+	}
+	target, err := url.JoinPath(tEnv.ts.URL, route, o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	r, err := http.NewRequest(http.MethodDelete, target, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res, err := tEnv.ts.Client().Do(r)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusServiceUnavailable, res.StatusCode)
+	}
+	if res.Header.Get(headerRetryAfter) == "" {
+		t.Fatalf("Retry-After header should be set")
+	}
+	tEnv.b.Unfreeze()
+	if err := tEnv.b.DeleteByID(o.ID()); err != nil {
+		t.Fatalf("delete should be possible after unfreeze: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// defaultGCDiscardRatio is the discard ratio
+// used if BucketOptions.GCDiscardRatio is not set.
+const defaultGCDiscardRatio = 0.5
+
+// RunGC runs the garbage collection of the value logs of all
+// stores. Every value log file of which at least GCDiscardRatio
+// of the space can be discarded is rewritten until no file is
+// left. It returns the number of rewritten files. In-memory
+// stores have no value log and are skipped. Afterwards the blob
+// files which are not referenced anymore e.g. because of a failed
+// write are removed. See BucketOptions.BlobThreshold.
+func (b *Bucket) RunGC(ctx context.Context) (int, error) {
+	ratio := b.opts.GCDiscardRatio
+	if ratio <= 0 {
+		ratio = defaultGCDiscardRatio
+	}
+	rewritten := 0
+	for _, s := range b.databases() {
+		start := time.Now()
+		n, err := s.runValueLogGC(ctx, ratio)
+		rewritten += n
+		if err != nil {
+			return rewritten, err
+		}
+		if !s.opts.InMemory {
+			b.emitStoreEvent(StoreEvent{Store: s.handle.name, Kind: StoreEventGC, At: time.Now(), Duration: time.Since(start), Rewritten: n})
+		}
+	}
+	return rewritten, b.collectBlobs()
+}
+
+// collectBlobs prunes the blob files while
+// no write is in progress. See pruneBlobs.
+func (b *Bucket) collectBlobs() error {
+	if b.readOnly {
+		return nil
+	}
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if b.closing {
+		return ErrBucketClosed
+	}
+	return b.pruneBlobs()
+}
+
+// runGC runs the garbage collection every
+// interval until the context is canceled.
+func (b *Bucket) runGC(ctx context.Context, interval time.Duration) {
+	defer b.gcWg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := b.RunGC(ctx); err != nil && ctx.Err() == nil && b.opts.Logger != nil {
+				b.opts.Logger.Warningf("garbage collection of the value logs failed: %v", err)
+			}
+		}
+	}
+}
+
+// databases returns the stores of the bucket with their own
+// database. The stores of the single layout share one database.
+func (b *Bucket) databases() []*store {
+	stores := make([]*store, 0, 3)
+	seen := make(map[*handle]bool)
+	for _, s := range []*store{b.payload, b.name, b.meta} {
+		if !seen[s.handle] {
+			seen[s.handle] = true
+			stores = append(stores, s)
+		}
+	}
+	return stores
+}
+
+// runValueLogGC rewrites value log files until no file can be
+// rewritten and returns the number of rewritten files. A GC
+// which is already running is not an error.
+func (s *store) runValueLogGC(ctx context.Context, ratio float64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	db, ok := s.badger()
+	if !ok || s.opts.InMemory {
+		return 0, nil
+	}
+	n := 0
+	for ctx.Err() == nil {
+		err := db.RunValueLogGC(ratio)
+		if errors.Is(err, badger.ErrNoRewrite) || errors.Is(err, badger.ErrRejected) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, ctx.Err()
+}
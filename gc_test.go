@@ -0,0 +1,60 @@
+package objst
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRunGC(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.GCInterval = 10 * time.Millisecond
+	// small value logs so the payloads are spread over many files
+	opts.ValueLogFileSize = 1 << 20
+	opts.ValueThreshold = 1 << 10
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	for i := 0; i < 20; i++ {
+		o := tEnv.obj()
+		o.Write(tEnv.payload(128 << 10))
+		if err := b.Create(o); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.DeleteByID(o.ID()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := b.RunGC(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// let the background worker run before the
+	// shutdown which has to stop it cleanly
+	time.Sleep(50 * time.Millisecond)
+	if err := b.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunGCInMemory(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.Layout = LayoutSingle
+	opts.PayloadStore = StoreOptions{InMemory: true}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	if len(b.databases()) != 1 {
+		t.Fatalf("single layout should have one database. Got: %d", len(b.databases()))
+	}
+	if n, err := b.RunGC(context.Background()); err != nil || n != 0 {
+		t.Fatalf("in-memory stores should be skipped. Got: %d, %v", n, err)
+	}
+}
@@ -0,0 +1,61 @@
+package objst
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The generation identifies one incarnation of an object.
+// An object which is deleted and created again with the
+// same id or name gets a new generation. The read path is
+// comparing generations to detect data which belongs to
+// an already deleted incarnation of the object.
+
+// nameValueSep separates the id and generation
+// in the values of the name index.
+const nameValueSep = "@"
+
+func newGeneration() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+// nameValue returns the value of the name index
+// entry for the object described by meta.
+func nameValue(meta *Metadata) []byte {
+	return []byte(meta.Get(MetaKeyID) + nameValueSep + meta.Get(MetaKeyGeneration))
+}
+
+// parseNameValue returns the id and generation of a name
+// index value. The generation of entries created before
+// generations existed is empty.
+func parseNameValue(val []byte) (string, string) {
+	id, gen, _ := strings.Cut(string(val), nameValueSep)
+	return id, gen
+}
+
+// checkGeneration makes sure that meta is still describing
+// the current incarnation of the object. It is used after
+// reading the payload to prevent returning data of an
+// object which was deleted while it was read.
+func (b *Bucket) checkGeneration(meta *Metadata) error {
+	cur, err := b.GetMeta(meta.Get(MetaKeyID))
+	if err != nil {
+		return err
+	}
+	if cur.Get(MetaKeyGeneration) != meta.Get(MetaKeyGeneration) {
+		return fmt.Errorf("%w: %s", ErrObjectNotFound, meta.Get(MetaKeyID))
+	}
+	return nil
+}
+
+// notFound wraps ErrStoreKeyNotFound with ErrObjectNotFound
+// so callers can rely on ErrObjectNotFound for missing objects.
+func notFound(err error) error {
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return fmt.Errorf("%w: %w", ErrObjectNotFound, err)
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package objst
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReadAfterDelete(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	// meta data read by an in-flight reader
+	// before the object is deleted.
+	meta, err := tEnv.b.GetMeta(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.DeleteByID(o.ID()); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := tEnv.b.GetByID(o.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("deleted object should not be found. Got: %v", err)
+	}
+	if _, err := tEnv.b.GetByName(o.Name(), o.Owner()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("deleted object should not be found by name. Got: %v", err)
+	}
+	// create a new incarnation with the same id and name
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := tEnv.b.composeObject(meta); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("stale reader should not get data of the new incarnation. Got: %v", err)
+	}
+	if _, err := tEnv.b.GetByName(o.Name(), o.Owner()); err != nil {
+		t.Fatalf("new incarnation should be found by name: %v", err)
+	}
+	// deleting the stale incarnation must not remove the new name entry
+	if err := tEnv.b.deleteName(meta); err != nil {
+		t.Error(err)
+		return
+	}
+	if !tEnv.b.isNameExisting(o.Name(), o.Owner()) {
+		t.Fatalf("name of the new incarnation should not be deleted")
+	}
+}
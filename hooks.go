@@ -0,0 +1,114 @@
+package objst
+
+import "fmt"
+
+// Hook extends the operations of a bucket e.g. to scan payloads for
+// viruses, to tag objects or to enforce policies. All stages are
+// optional. An error returned by a Before stage or by AfterGet
+// vetoes the operation which fails with an error wrapping ErrVetoed
+// and the error of the hook. See Bucket.Use.
+type Hook struct {
+	// Name identifies the hook in the errors.
+	Name string
+
+	// BeforeCreate is called with the object before it is validated
+	// and stored by Create, CreateWithOptions and BatchCreate. It may
+	// modify the user defined meta data and the payload of the object.
+	// Uploads are passed on their commit with the staged payload which
+	// is loaded lazily and can't be modified.
+	BeforeCreate func(obj *Object) error
+
+	// AfterCreate is called with the stored object. The
+	// object is immutable and contains all system meta data.
+	AfterCreate func(obj *Object)
+
+	// BeforeDelete is called with the object before it is deleted
+	// including deletions by queries and lifecycle rules. The payload
+	// of the object is loaded lazily. Deletions of expired objects and
+	// replicas are not passed to the hooks.
+	BeforeDelete func(obj *Object) error
+
+	// AfterGet is called with every object returned by GetByID,
+	// GetByName, Get and Head and with the object read by
+	// ViewPayload and Read. Objects of queries, Head and the
+	// reads are passed without their payload which is loaded
+	// on the first access.
+	AfterGet func(obj *Object) error
+}
+
+// Use registers the hooks. The stages of the hooks are called in
+// the order of their registration and the first veto stops the
+// operation. Hooks can be registered while the bucket is in use.
+func (b *Bucket) Use(hooks ...Hook) {
+	b.hookMu.Lock()
+	defer b.hookMu.Unlock()
+	// the slice is replaced so running
+	// operations keep their snapshot.
+	b.hooks = append(b.hooks[:len(b.hooks):len(b.hooks)], hooks...)
+}
+
+func (b *Bucket) registeredHooks() []Hook {
+	b.hookMu.RLock()
+	defer b.hookMu.RUnlock()
+	return b.hooks
+}
+
+func vetoed(h Hook, err error) error {
+	return fmt.Errorf("%w by %s: %w", ErrVetoed, h.Name, err)
+}
+
+func (b *Bucket) beforeCreate(objs ...*Object) error {
+	for _, h := range b.registeredHooks() {
+		if h.BeforeCreate == nil {
+			continue
+		}
+		for _, obj := range objs {
+			if err := h.BeforeCreate(obj); err != nil {
+				return vetoed(h, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (b *Bucket) afterCreate(objs ...*Object) {
+	for _, h := range b.registeredHooks() {
+		if h.AfterCreate == nil {
+			continue
+		}
+		for _, obj := range objs {
+			h.AfterCreate(obj)
+		}
+	}
+}
+
+func (b *Bucket) beforeDelete(meta *Metadata) error {
+	var obj *Object
+	for _, h := range b.registeredHooks() {
+		if h.BeforeDelete == nil {
+			continue
+		}
+		if obj == nil {
+			obj = b.composeLazyObject(meta)
+			obj.markAsImmutable()
+		}
+		if err := h.BeforeDelete(obj); err != nil {
+			return vetoed(h, err)
+		}
+	}
+	return nil
+}
+
+func (b *Bucket) afterGet(objs ...*Object) error {
+	for _, h := range b.registeredHooks() {
+		if h.AfterGet == nil {
+			continue
+		}
+		for _, obj := range objs {
+			if err := h.AfterGet(obj); err != nil {
+				return vetoed(h, err)
+			}
+		}
+	}
+	return nil
+}
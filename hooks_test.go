@@ -0,0 +1,120 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	errInfected := errors.New("payload is infected")
+	audit := make([]string, 0)
+	b.Use(Hook{
+		Name: "scanner",
+		BeforeCreate: func(obj *Object) error {
+			if bytes.Contains(obj.Payload(), []byte("EICAR")) {
+				return errInfected
+			}
+			obj.SetMetaKey("scanned", "true")
+			return nil
+		},
+		AfterCreate: func(obj *Object) {
+			audit = append(audit, "create "+obj.ID())
+		},
+	}, Hook{
+		Name: "policy",
+		BeforeDelete: func(obj *Object) error {
+			if obj.GetMetaKey("keep") == "true" {
+				return errors.New("object must be kept")
+			}
+			return nil
+		},
+		AfterGet: func(obj *Object) error {
+			if obj.GetMetaKey("secret") == "true" {
+				return errors.New("object is secret")
+			}
+			return nil
+		},
+	})
+
+	infected := tEnv.emptyObj()
+	infected.Write([]byte("X5O EICAR test file"))
+	err = b.Create(infected)
+	if !errors.Is(err, ErrVetoed) || !errors.Is(err, errInfected) {
+		t.Fatalf("create should be vetoed by the scanner. Got: %v", err)
+	}
+	if _, err := b.GetMeta(infected.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("vetoed object should not be stored. Got: %v", err)
+	}
+	if err := b.BatchCreate([]*Object{tEnv.obj(), infected}); !errors.Is(err, ErrVetoed) {
+		t.Fatalf("batch with a vetoed object should fail. Got: %v", err)
+	}
+
+	kept, secret := tEnv.obj(), tEnv.obj()
+	kept.SetMetaKey("keep", "true")
+	secret.SetMetaKey("secret", "true")
+	if err := b.BatchCreate([]*Object{kept, secret}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.GetByID(kept.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetMetaKey("scanned") != "true" {
+		t.Fatal("meta data set by the hook should be stored")
+	}
+	if len(audit) != 2 || audit[0] != "create "+kept.ID() {
+		t.Fatalf("created objects should be audited. Got: %v", audit)
+	}
+	if _, err := b.GetByID(secret.ID()); !errors.Is(err, ErrVetoed) {
+		t.Fatalf("get should be vetoed by the policy. Got: %v", err)
+	}
+	if _, err := b.Get(NewQuery().ID(secret.ID())); !errors.Is(err, ErrVetoed) {
+		t.Fatalf("query should be vetoed by the policy. Got: %v", err)
+	}
+	if err := b.DeleteByID(kept.ID()); !errors.Is(err, ErrVetoed) {
+		t.Fatalf("delete should be vetoed by the policy. Got: %v", err)
+	}
+	if err := b.DeleteByID(secret.ID()); err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := b.NewUpload(tEnv.emptyObj())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Write([]byte("X5O EICAR test file")); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Commit(); !errors.Is(err, ErrVetoed) || !errors.Is(err, errInfected) {
+		t.Fatalf("upload should be vetoed by the scanner. Got: %v", err)
+	}
+	if _, err := b.GetMeta(u.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("vetoed upload should not be stored. Got: %v", err)
+	}
+	u, err = b.NewUpload(tEnv.emptyObj())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Write(tEnv.payload(100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := b.GetMeta(u.ID()); err != nil || got.Get("scanned") != "true" {
+		t.Fatalf("meta data set by the hook should be stored for uploads. Got: %v", err)
+	}
+	if len(audit) != 3 || audit[2] != "create "+u.ID() {
+		t.Fatalf("committed uploads should be audited. Got: %v", audit)
+	}
+}
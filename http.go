@@ -1,11 +1,17 @@
 package objst
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"mime"
 	"net/http"
+	"net/http/pprof"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -15,7 +21,16 @@ import (
 )
 
 const (
-	headerContentType = "Content-Type"
+	headerContentType   = "Content-Type"
+	headerContentLength = "Content-Length"
+	headerETag          = "ETag"
+	headerIfMatch       = "If-Match"
+	headerIfNoneMatch   = "If-None-Match"
+	headerRetryAfter    = "Retry-After"
+	headerAuthorization = "Authorization"
+	// headerEpoch is the epoch known by the client.
+	// See HTTPHandler.fence.
+	headerEpoch = "X-Objst-Epoch"
 )
 
 const (
@@ -29,17 +44,12 @@ const (
 type CtxKey string
 
 const (
-	CtxKeyOwner CtxKey = "owner"
-	CtxKeyReqID CtxKey = "reqid"
+	CtxKeyOwner  CtxKey = "owner"
+	CtxKeyReqID  CtxKey = "reqid"
+	CtxKeyPolicy CtxKey = "policy"
+	CtxKeyAPIKey CtxKey = "apikey"
 )
 
-type objectModel struct {
-	ID       string             `json:"id,omitempty"`
-	Name     string             `json:"name,omitempty"`
-	Owner    string             `json:"owner,omitempty"`
-	Metadata map[MetaKey]string `json:"metadata,omitempty"`
-}
-
 type HTTPHandler struct {
 	bucket *Bucket
 	opts   HTTPHandlerOptions
@@ -62,20 +72,79 @@ func (h *HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (h *HTTPHandler) routes() chi.Router {
 	r := chi.NewRouter()
-	r.Use(h.opts.IsAuthenticated)
 	r.Use(requestID)
+	r.Use(h.authenticate)
+	r.Use(h.fence)
 	r.Use(middleware.CleanPath)
 	r.Use(middleware.Timeout(defaultTimeout))
 
+	read := h.requireScope(ScopeRead)
+	write := h.requireScope(ScopeWrite)
 	r.Route("/objst", func(r chi.Router) {
 		r.Route("/", func(r chi.Router) {
-			r.Use(h.opts.IsAuthorized)
-			r.Get("/read/{id}", h.Read)
-			r.Get("/{id}", h.Get)
-			r.Delete("/{id}", h.Remove)
+			r.Use(withAPIKey(h.opts.IsAuthorized))
+			r.With(read).Get("/read/{id}", h.Read)
+			r.With(read).Head("/read/{id}", h.Head)
+			r.With(read).Get("/{id}", h.Get)
+			r.With(write, h.rejectWhenFrozen).Patch("/{id}", h.UpdateMeta)
+			r.With(write, h.rejectWhenFrozen).Delete("/{id}", h.Remove)
+		})
+		if h.opts.Debug {
+			r.Route("/debug", func(r chi.Router) {
+				r.Use(withAPIKey(h.opts.IsAuthorized))
+				r.Use(h.requireScope(ScopeAdmin))
+				r.Get("/latencies", h.Latencies)
+				r.Get("/slowops", h.SlowOps)
+				r.Get("/usage", h.Usages)
+				// CleanPath removes the trailing slash so the
+				// index is routed as /pprof but has to be
+				// requested as /pprof/ for the relative links.
+				r.Get("/pprof", pprof.Index)
+				r.Get("/pprof/cmdline", pprof.Cmdline)
+				r.Get("/pprof/profile", pprof.Profile)
+				r.HandleFunc("/pprof/symbol", pprof.Symbol)
+				r.Get("/pprof/trace", pprof.Trace)
+				r.Get("/pprof/{profile}", func(w http.ResponseWriter, r *http.Request) {
+					pprof.Handler(chi.URLParam(r, "profile")).ServeHTTP(w, r)
+				})
+			})
+		}
+		r.Route("/checksum", func(r chi.Router) {
+			r.Use(read)
+			r.Use(assureOwner)
+			r.Get("/{sum}", h.FindByChecksum)
+			r.Post("/missing", h.MissingChecksums)
+		})
+		r.Route("/moderation", func(r chi.Router) {
+			r.Use(h.opts.IsModerator)
+			r.Get("/", h.ModerationQueue)
+			r.With(h.rejectWhenFrozen).Post("/{id}/approve", h.Approve)
+			r.With(h.rejectWhenFrozen).Post("/{id}/reject", h.Reject)
+		})
+		r.Route("/admin", h.adminRoutes)
+		r.Route("/changes", func(r chi.Router) {
+			r.Use(read)
+			r.Use(assureOwner)
+			r.Get("/", h.Changes)
+		})
+		r.Route("/subscriptions", func(r chi.Router) {
+			r.Use(read)
+			r.Use(assureOwner)
+			r.Get("/", h.Subscriptions)
+			r.With(h.rejectWhenFrozen).Post("/", h.Subscribe)
+			r.With(h.rejectWhenFrozen).Delete("/{subID}", h.Unsubscribe)
+			r.With(h.rejectWhenFrozen).Post("/{subID}/replay", h.ReplaySubscription)
+		})
+		r.Route("/uploads", func(r chi.Router) {
+			r.Use(h.requireScope(ScopeUpload))
+			r.Use(assureOwner)
+			r.Get("/{id}/status", h.UploadStatus)
 		})
 		r.Route("/upload", func(r chi.Router) {
+			r.Use(h.requireScope(ScopeUpload))
+			r.Use(h.uploadPolicy)
 			r.Use(assureOwner)
+			r.Use(h.rejectWhenFrozen)
 			r.Post("/", h.Upload)
 		})
 	})
@@ -92,15 +161,16 @@ func (h *HTTPHandler) Get(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "id is an invalid uuid-v4", http.StatusBadRequest)
 		return
 	}
-	obj, err := h.bucket.GetByID(id)
+	obj, err := h.bucket.Head(id)
 	if err != nil {
 		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, err.Error(), errorStatus(err, http.StatusBadRequest))
 		return
 	}
 	w.Header().Set(headerContentType, contentTypeJSON)
+	w.Header().Set(headerETag, strconv.Quote(obj.ETag()))
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(obj.ToModel()); err != nil {
+	if err := json.NewEncoder(w).Encode(obj.Info()); err != nil {
 		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -127,10 +197,17 @@ func (h *HTTPHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	if _, err := io.Copy(obj, file); err != nil {
-		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
-		http.Error(w, "couldn't copy the payload of the file into the object", http.StatusInternalServerError)
-		return
+	if h.bucket.opts.DetectContentType && obj.GetMetaKey(MetaKeyContentType) == "" && r.Form.Get(MetaKeyContentType.String()) == "" {
+		// the content type is sniffed from the beginning of the file
+		// so the upload policy can be checked before the upload.
+		head := make([]byte, sniffLen)
+		n, _ := io.ReadFull(file, head)
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, "couldn't read the file from the multipart form", http.StatusInternalServerError)
+			return
+		}
+		h.bucket.detectContentType(obj, head[:n])
 	}
 	if obj.GetMetaKey(MetaKeyContentType) == "" {
 		contentType := r.Form.Get(MetaKeyContentType.String())
@@ -146,14 +223,72 @@ func (h *HTTPHandler) Upload(w http.ResponseWriter, r *http.Request) {
 		mime.AddExtensionType(filepath.Ext(header.Filename), contentType)
 		obj.SetMetaKey(MetaKeyContentType, contentType)
 	}
-	if err := h.bucket.Create(obj); err != nil {
+	if p, ok := r.Context().Value(CtxKeyPolicy).(UploadPolicy); ok {
+		if err := p.allows(obj.Name(), obj.GetMetaKey(MetaKeyContentType), header.Size); err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	if k, ok := r.Context().Value(CtxKeyAPIKey).(APIKey); ok {
+		if err := k.allows(ScopeUpload, obj.Name(), obj.Owner()); err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	ifNoneMatch := r.Header.Get(headerIfNoneMatch) == "*"
+	if ifNoneMatch && h.bucket.isNameExisting(obj.Name(), obj.Owner()) {
+		h.opts.Logger.ErrorCtx(r.Context(), ErrPreconditionFailed.Error(), slog.String("req_id", reqID))
+		http.Error(w, "an object with the same name already exists", http.StatusPreconditionFailed)
+		return
+	}
+	if h.opts.ModerateUploads {
+		obj.meta.set(MetaKeyModeration, string(ModerationPending))
+	}
+	obj.meta.actor = actorOf(r)
+	// the payload is streamed into the staging area of the
+	// bucket so an interrupted upload never results in a
+	// partially written object.
+	upload, err := h.bucket.NewUpload(obj)
+	if err != nil {
 		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
 		http.Error(w, "something went wrong while creating the object", http.StatusInternalServerError)
 		return
 	}
+	if _, err := io.Copy(upload, file); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		if err := upload.Abort(); err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		}
+		http.Error(w, "couldn't copy the payload of the file into the object", http.StatusInternalServerError)
+		return
+	}
+	if err := upload.Commit(); err != nil {
+		if ifNoneMatch && errors.Is(err, ErrDuplicateName) {
+			// the name was created concurrently after the check
+			err = fmt.Errorf("%w: %w", ErrPreconditionFailed, err)
+		}
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		if err := upload.Abort(); err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		}
+		if errors.Is(err, ErrQuotaExceeded) {
+			http.Error(w, err.Error(), quotaStatus(h.bucket, owner, obj.GetMetaKey(MetaKeyContentType), header.Size))
+			return
+		}
+		if status := errorStatus(err, 0); status != 0 {
+			setRetryAfter(w, err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		http.Error(w, "something went wrong while creating the object", http.StatusInternalServerError)
+		return
+	}
 	w.Header().Set(headerContentType, contentTypeJSON)
+	w.Header().Set(headerETag, strconv.Quote(obj.ETag()))
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(obj.ToModel()); err != nil {
+	if err := json.NewEncoder(w).Encode(obj.Info()); err != nil {
 		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
 		http.Error(w, "something went wrong while sending the object model", http.StatusInternalServerError)
 		return
@@ -163,20 +298,306 @@ func (h *HTTPHandler) Upload(w http.ResponseWriter, r *http.Request) {
 func (h *HTTPHandler) Read(w http.ResponseWriter, r *http.Request) {
 	reqID := r.Context().Value(CtxKeyReqID).(string)
 	id := chi.URLParam(r, "id")
-	if err := h.bucket.Read(id, w); err != nil {
+	obj, err := h.bucket.Head(id)
+	if err != nil {
 		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
-		http.Error(w, "something went wrong while streaming the object", http.StatusInternalServerError)
+		http.Error(w, "something went wrong while streaming the object", errorStatus(err, http.StatusInternalServerError))
 		return
 	}
+	if !obj.isDownloadable() {
+		http.Error(w, fmt.Sprintf("object is %s by the moderation", obj.ModerationState()), http.StatusForbidden)
+		return
+	}
+	// payloads stored as files are copied from the file
+	// so the server can send them using sendfile.
+	if f, err := h.bucket.OpenPayloadFile(id); err == nil {
+		defer f.Close()
+		setPayloadHeaders(w, obj)
+		if _, err := io.Copy(w, f); err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		}
+		return
+	}
+	// the headers are sent with the first chunk so errors
+	// occurring before can still be sent to the client.
+	written := false
+	err = h.bucket.viewObject(obj, func(chunk []byte) error {
+		if !written {
+			setPayloadHeaders(w, obj)
+			written = true
+		}
+		_, err := w.Write(chunk)
+		return err
+	})
+	if err != nil && !written {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "something went wrong while streaming the object", errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+	}
+}
+
+// Head responds with the headers of Read
+// without loading the payload of the object.
+func (h *HTTPHandler) Head(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	id := chi.URLParam(r, "id")
+	obj, err := h.bucket.Head(id)
+	if errors.Is(err, ErrObjectNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		w.WriteHeader(errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if !obj.isDownloadable() {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	setPayloadHeaders(w, obj)
+	w.WriteHeader(http.StatusOK)
+}
+
+// setPayloadHeaders sets the headers describing
+// the payload of the object using its meta data.
+func setPayloadHeaders(w http.ResponseWriter, obj *Object) {
+	w.Header().Set(headerContentType, obj.GetMetaKey(MetaKeyContentType))
+	w.Header().Set(headerContentLength, strconv.FormatInt(obj.Size(), 10))
+	w.Header().Set(headerETag, strconv.Quote(obj.ETag()))
+}
+
+// UpdateMeta merges the user defined meta data of the request
+// body into the meta data of the object. If the `If-Match` header
+// is set the update will only be applied iff the etag matches.
+func (h *HTTPHandler) UpdateMeta(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	id := chi.URLParam(r, "id")
+	patch := make(map[MetaKey]string)
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "request body is not a valid meta data object", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if etag := r.Header.Get(headerIfMatch); etag != "" {
+		err = h.bucket.updateMetaIfAs(actorOf(r), id, strings.Trim(etag, `"`), patch)
+	} else {
+		err = h.bucket.updateMetaAs(actorOf(r), id, patch)
+	}
+	if errors.Is(err, ErrPreconditionFailed) {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "etag of the object does not match", http.StatusPreconditionFailed)
+		return
+	}
+	if status := errorStatus(err, 0); status != 0 {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		setRetryAfter(w, err)
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "couldn't update the meta data of the object with the id: "+id, http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *HTTPHandler) Remove(w http.ResponseWriter, r *http.Request) {
 	reqID := r.Context().Value(CtxKeyReqID).(string)
 	id := chi.URLParam(r, "id")
-	if err := h.bucket.DeleteByID(id); err != nil {
+	if err := h.bucket.deleteByIDAs(actorOf(r), id); err != nil {
 		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		if status := errorStatus(err, 0); status != 0 {
+			setRetryAfter(w, err)
+			http.Error(w, err.Error(), status)
+			return
+		}
 		http.Error(w, "couldn't delete the object with the id: "+id, http.StatusBadRequest)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// FindByChecksum returns the ObjectInfo of all objects of the
+// owner whose payload has the checksum. If no object is found
+// StatusNotFound is returned.
+func (h *HTTPHandler) FindByChecksum(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	owner := r.Context().Value(CtxKeyOwner).(string)
+	ids, err := h.bucket.FindByChecksum(chi.URLParam(r, "sum"))
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "something went wrong while searching the checksum", http.StatusInternalServerError)
+		return
+	}
+	infos := make([]*ObjectInfo, 0, len(ids))
+	for _, id := range ids {
+		meta, err := h.bucket.GetMeta(id)
+		if err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			continue
+		}
+		if meta.Get(MetaKeyOwner) != owner {
+			continue
+		}
+		obj := &Object{meta: meta, pl: new(bytes.Buffer)}
+		infos = append(infos, obj.Info())
+	}
+	if len(infos) == 0 {
+		http.Error(w, "no object with the checksum exists", http.StatusNotFound)
+		return
+	}
+	w.Header().Set(headerContentType, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(infos); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// checksumsModel is the request and response body
+// of the deduplication handshake. See MissingChecksums.
+type checksumsModel struct {
+	Checksums []string `json:"checksums"`
+}
+
+// MissingChecksums is the deduplication handshake for uploads.
+// The client sends the checksums of the content it wants to
+// upload and the response contains the checksums of the content
+// which is missing for the owner and has to be uploaded.
+func (h *HTTPHandler) MissingChecksums(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	owner := r.Context().Value(CtxKeyOwner).(string)
+	req := checksumsModel{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "request body is not a valid list of checksums", http.StatusBadRequest)
+		return
+	}
+	missing, err := h.bucket.MissingChecksums(owner, req.Checksums)
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "something went wrong while searching the checksums", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(headerContentType, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(checksumsModel{Checksums: missing}); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// changesModel is the response body of Changes.
+type changesModel struct {
+	Changes []Change `json:"changes"`
+	// Seq is the sequence number to pass as the
+	// since parameter of the next request.
+	Seq uint64 `json:"seq"`
+}
+
+// Changes returns the changes of the objects of the owner since
+// the sequence number of the `since` query parameter. The number
+// of changes can be limited by the `limit` query parameter.
+func (h *HTTPHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	owner := r.Context().Value(CtxKeyOwner).(string)
+	since, limit, ok := h.parseFeedQuery(w, r)
+	if !ok {
+		return
+	}
+	changes, seq, err := h.bucket.Changes(owner, since, limit)
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "something went wrong while reading the changes", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(headerContentType, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(changesModel{Changes: changes, Seq: seq}); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Latencies returns the latencies of the
+// operations of all stores of the bucket.
+func (h *HTTPHandler) Latencies(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	w.Header().Set(headerContentType, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(h.bucket.Latencies()); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// SlowOps returns the most recent slow operations of the bucket.
+func (h *HTTPHandler) SlowOps(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	w.Header().Set(headerContentType, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(h.bucket.SlowOps()); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// Usages returns the usage of all owners of the bucket.
+func (h *HTTPHandler) Usages(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	w.Header().Set(headerContentType, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(h.bucket.Usages()); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// quotaStatus returns the status code for ErrQuotaExceeded.
+// StatusRequestEntityTooLarge is used if the object alone is
+// exceeding the quota or a content type quota of the owner and
+// StatusInsufficientStorage otherwise.
+func quotaStatus(b *Bucket, owner, contentType string, size int64) int {
+	if b.exceedsQuota(owner, contentType, size) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusInsufficientStorage
+}
+
+// errorStatus returns the status code of the error of the bucket
+// or fallback if the error isn't wrapping any known error.
+func errorStatus(err error, fallback int) int {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrPreconditionFailed):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrDuplicateName), errors.Is(err, ErrImmutable), errors.Is(err, ErrColdObject):
+		return http.StatusConflict
+	case errors.Is(err, ErrStaleEpoch), errors.Is(err, ErrFenced):
+		return http.StatusConflict
+	case errors.Is(err, ErrVetoed):
+		return http.StatusForbidden
+	case errors.Is(err, ErrObjectLocked):
+		return http.StatusLocked
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusInsufficientStorage
+	case errors.Is(err, ErrBucketFrozen), errors.Is(err, ErrBackpressure), errors.Is(err, ErrBucketClosed), errors.Is(err, ErrStandby):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrInvalidOwner), errors.Is(err, ErrInvalidMeta):
+		return http.StatusBadRequest
+	}
+	return fallback
+}
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
+	"strconv"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -43,6 +46,37 @@ func TestHTTPRead(t *testing.T) {
 	}
 }
 
+func TestHTTPHead(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	target, err := url.JoinPath(tEnv.ts.URL, route, "read", o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res, err := tEnv.ts.Client().Head(target)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusOK, res.StatusCode)
+	}
+	if res.ContentLength != int64(len(o.Payload())) {
+		t.Fatalf("content length is not %d. Got: %d", len(o.Payload()), res.ContentLength)
+	}
+	if res.Header.Get(headerETag) != strconv.Quote(o.ETag()) {
+		t.Fatalf("etag is not %s. Got: %s", o.ETag(), res.Header.Get(headerETag))
+	}
+	if res.Header.Get(headerContentType) != o.GetMetaKey(MetaKeyContentType) {
+		t.Fatalf("content type is not %s. Got: %s", o.GetMetaKey(MetaKeyContentType), res.Header.Get(headerContentType))
+	}
+}
+
 func TestHTTPCreate(t *testing.T) {
 	data := `
 		{
@@ -122,13 +156,13 @@ func TestHTTPGet(t *testing.T) {
 		return
 	}
 	defer res.Body.Close()
-	m := objectModel{}
+	m := ObjectInfo{}
 	if err := json.NewDecoder(res.Body).Decode(&m); err != nil {
 		t.Error(err)
 		return
 	}
-	if ok := cmp.Equal(&m, o.ToModel()); !ok {
-		s := cmp.Diff(&m, o.ToModel())
+	if ok := cmp.Equal(&m, o.Info()); !ok {
+		s := cmp.Diff(&m, o.Info())
 		t.Fatalf("models are not equal. Got: %s", s)
 	}
 	if res.StatusCode != http.StatusOK {
@@ -136,6 +170,36 @@ func TestHTTPGet(t *testing.T) {
 	}
 }
 
+func TestHTTPErrorStatus(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{method: http.MethodGet, path: uuid.NewString(), want: http.StatusNotFound},
+		{method: http.MethodGet, path: "read/" + uuid.NewString(), want: http.StatusNotFound},
+		{method: http.MethodDelete, path: uuid.NewString(), want: http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		target, err := url.JoinPath(tEnv.ts.URL, route, tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(tc.method, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := tEnv.ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != tc.want {
+			t.Errorf("%s %s: statuscode is not %d. Got: %d", tc.method, tc.path, tc.want, res.StatusCode)
+		}
+	}
+}
+
 func TestHTTPUpload(t *testing.T) {
 	target, err := url.JoinPath(tEnv.ts.URL, route, "upload")
 	if err != nil {
@@ -221,3 +285,153 @@ func TestHTTPUploadUknownMimeTypeAndEmptyCt(t *testing.T) {
 		t.Fatalf("statuscode is not as expected. Got: %d. Expected: %d", w.Code, http.StatusOK)
 	}
 }
+
+func TestHTTPUpdateMetaIfMatch(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	target, err := url.JoinPath(tEnv.ts.URL, route, o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	tests := []struct {
+		name string
+		etag string
+		code int
+	}{
+		{
+			name: "matching etag",
+			etag: o.ETag(),
+			code: http.StatusNoContent,
+		},
+		{
+			name: "stale etag",
+			etag: o.ETag(),
+			code: http.StatusPreconditionFailed,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := bytes.NewReader([]byte(`{"foo": "bar"}`))
+			r, err := http.NewRequest(http.MethodPatch, target, body)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			r.Header.Set(headerIfMatch, `"`+test.etag+`"`)
+			res, err := tEnv.ts.Client().Do(r)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer res.Body.Close()
+			if res.StatusCode != test.code {
+				t.Fatalf("statuscode is not %d. Got: %d", test.code, res.StatusCode)
+			}
+		})
+	}
+}
+
+func TestHTTPMissingChecksums(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	target, err := url.JoinPath(tEnv.ts.URL, route, "checksum", "missing")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	unknown := checksum([]byte("unknown"))
+	body, err := json.Marshal(checksumsModel{Checksums: []string{o.Checksum(), unknown}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	r, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	injectOwner := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), CtxKeyOwner, o.Owner())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+	hl := injectOwner(tEnv.h)
+	w := httptest.NewRecorder()
+	hl.ServeHTTP(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Res: %v", http.StatusOK, w.Code, w.Body)
+	}
+	res := checksumsModel{}
+	if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(res.Checksums) != 1 || res.Checksums[0] != unknown {
+		t.Fatalf("only the unknown checksum should be missing. Got: %v", res.Checksums)
+	}
+}
+
+func TestHTTPAfterGetVeto(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	ts := httptest.NewServer(NewHTTPHandler(b, DefaultHTTPHandlerOptions()))
+	defer ts.Close()
+	b.Use(Hook{
+		Name: "policy",
+		AfterGet: func(obj *Object) error {
+			if obj.GetMetaKey("secret") == "true" {
+				return errors.New("object is secret")
+			}
+			return nil
+		},
+	})
+	o := tEnv.obj()
+	o.SetMetaKey("secret", "true")
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodGet, path: o.ID()},
+		{method: http.MethodGet, path: "read/" + o.ID()},
+		{method: http.MethodHead, path: "read/" + o.ID()},
+	}
+	for _, tc := range tests {
+		target, err := url.JoinPath(ts.URL, route, tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(tc.method, target, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s: statuscode is not %d. Got: %d", tc.method, tc.path, http.StatusForbidden, res.StatusCode)
+		}
+	}
+	if err := b.Read(o.ID(), io.Discard); !errors.Is(err, ErrVetoed) {
+		t.Fatalf("read should be vetoed by the policy. Got: %v", err)
+	}
+}
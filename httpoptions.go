@@ -3,6 +3,7 @@ package objst
 import (
 	"net/http"
 	"os"
+	"time"
 
 	"golang.org/x/exp/slog"
 )
@@ -37,6 +38,57 @@ type HTTPHandlerOptions struct {
 	// Logger is the default logger. By default slog.Logger
 	// with the text handler will be used.
 	Logger *slog.Logger
+
+	// RetryAfter is the duration sent in the Retry-After
+	// header if a write is rejected because the bucket is
+	// frozen. Default: 30 seconds.
+	RetryAfter time.Duration
+
+	// PolicySecret is the secret used to verify the
+	// signatures of upload policies. Uploads with a
+	// policy are rejected if no secret is set or if
+	// it is shorter than 14 bytes in the FIPS mode.
+	// See SignUploadPolicy. Default: nil.
+	PolicySecret []byte
+
+	// Debug enables the pprof endpoints under
+	// /objst/debug/pprof/, the latencies of the
+	// stores of the bucket under /objst/debug/latencies,
+	// the slow operations under /objst/debug/slowops
+	// and the usage of the owners under /objst/debug/usage.
+	// All require authentication and authorization.
+	// Default: false.
+	Debug bool
+
+	// ModerateUploads quarantines all objects uploaded using
+	// the /objst/upload endpoint until they are approved by
+	// a moderator. Pending and rejected objects cannot be
+	// downloaded. Default: false.
+	ModerateUploads bool
+
+	// IsModerator is the middleware used to validate if the
+	// incoming request is sent by a moderator which is allowed
+	// to use the /objst/moderation endpoints. By default all
+	// requests are rejected.
+	IsModerator func(http.Handler) http.Handler
+
+	// IsAdmin is the middleware used to validate if the
+	// incoming request is sent by an operator which is
+	// allowed to use the /objst/admin endpoints exposing
+	// the stats, usage, change feed, slow operations and
+	// jobs of the bucket and configuring its lifecycle
+	// rules and retention classes. By default all
+	// requests are rejected.
+	IsAdmin func(http.Handler) http.Handler
+
+	// APIKeys enables the authentication using the API keys
+	// of the bucket sent as `Authorization: Bearer <token>`.
+	// Requests with an API key are not passed to IsAuthenticated,
+	// IsAuthorized and IsAdmin but are restricted to the owner,
+	// the scopes and the prefixes of the key instead. All other
+	// requests are handled as before. See Bucket.CreateAPIKey.
+	// Default: false.
+	APIKeys bool
 }
 
 func DefaultHTTPHandlerOptions() HTTPHandlerOptions {
@@ -50,8 +102,11 @@ func DefaultHTTPHandlerOptions() HTTPHandlerOptions {
 	opts.FormKey = formKey
 	opts.IsAuthorized = isAuthorized
 	opts.IsAuthenticated = isAuthenticated
+	opts.IsModerator = isModerator
+	opts.IsAdmin = isAdmin
 	opts.Handler = nil
 	opts.Logger = slog.New(slog.NewTextHandler(os.Stdout, nil))
+	opts.RetryAfter = 30 * time.Second
 	return opts
 }
 
@@ -68,3 +123,17 @@ func isAuthenticated(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// isModerator is the default moderator middleware which rejects all requests.
+func isModerator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "moderation is not enabled", http.StatusForbidden)
+	})
+}
+
+// isAdmin is the default admin middleware which rejects all requests.
+func isAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "admin endpoints are not enabled", http.StatusForbidden)
+	})
+}
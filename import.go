@@ -0,0 +1,466 @@
+package objst
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Collision is the strategy of an import if an
+// object with the name of a file already exists.
+type Collision int
+
+const (
+	// CollisionFail stops the import with an
+	// error wrapping ErrDuplicateName.
+	CollisionFail Collision = iota
+	// CollisionSkip keeps the existing object.
+	CollisionSkip
+	// CollisionOverwrite deletes the existing object
+	// and creates a new one with the same name.
+	CollisionOverwrite
+	// CollisionRename creates the object with the first free
+	// name using a numeric suffix e.g. report-1.txt.
+	CollisionRename
+)
+
+// ImportAction is what happened to an imported file.
+type ImportAction string
+
+const (
+	ImportCreated     ImportAction = "created"
+	ImportSkipped     ImportAction = "skipped"
+	ImportOverwritten ImportAction = "overwritten"
+	ImportRenamed     ImportAction = "renamed"
+	ImportFailed      ImportAction = "failed"
+)
+
+type ImportOptions struct {
+	// Collision is the strategy if an object with
+	// the name of a file exists. Default: CollisionFail.
+	Collision Collision
+
+	// Concurrency is the number of files which are read
+	// and imported in parallel by ImportDir. Files of
+	// archives are imported sequentially. Default: 1.
+	Concurrency int
+
+	// OnProgress is called after every processed file.
+	// It is never called concurrently.
+	OnProgress func(ImportProgress)
+}
+
+// ImportProgress is the progress of an import.
+type ImportProgress struct {
+	// Done is the number of processed files.
+	Done int
+	// Total is the number of files to import. It is
+	// 0 for archives because it is unknown upfront.
+	Total int
+	// Result is the result of the processed file.
+	Result ImportResult
+}
+
+// ImportResult is the result of importing one file.
+type ImportResult struct {
+	// Path is the slash separated path of the file relative
+	// to the imported directory or inside of the archive.
+	Path string
+	// Name is the name of the object which
+	// only differs from Path if it is renamed.
+	Name   string
+	ID     string
+	Action ImportAction
+	Err    error
+}
+
+// ImportReport lists the results of all
+// files of an import in the order of import.
+type ImportReport struct {
+	Results []ImportResult
+}
+
+// Count returns the number of files with the action a.
+func (r *ImportReport) Count(a ImportAction) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Action == a {
+			n++
+		}
+	}
+	return n
+}
+
+// ImportDir imports all regular files of the directory dir
+// and its subdirectories as objects of the owner named by their
+// path relative to dir. Files which can't be imported e.g. because
+// of an invalid name are reported as failed without stopping the
+// import. The report is returned even if the import is stopped.
+// If the files are imported concurrently the report lists them
+// in the order of the walk and a renamed file may fail if its new
+// name is the name of another file of the directory.
+func (b *Bucket) ImportDir(ctx context.Context, dir, owner string, opts ImportOptions) (*ImportReport, error) {
+	report := &ImportReport{Results: make([]ImportResult, 0)}
+	paths := make([]string, 0)
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+	workers := opts.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	stopCtx, stop := context.WithCancel(ctx)
+	defer stop()
+	results := make([]*ImportResult, len(paths))
+	progress := importProgress(len(paths), opts)
+	// the first error is returned because the
+	// others can be caused by stopping the import.
+	var first error
+	var once sync.Once
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if stopCtx.Err() != nil {
+					continue
+				}
+				res, err := b.importPath(dir, paths[i], owner, opts)
+				if res != nil {
+					results[i] = res
+					progress(*res)
+				}
+				if err != nil {
+					once.Do(func() {
+						first = err
+						stop()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for i := range paths {
+		select {
+		case jobs <- i:
+		case <-stopCtx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	for _, res := range results {
+		if res != nil {
+			report.Results = append(report.Results, *res)
+		}
+	}
+	if first != nil {
+		return report, first
+	}
+	return report, ctx.Err()
+}
+
+// importPath reads and imports the file p of the directory dir.
+// The result is nil if the file can't be read.
+func (b *Bucket) importPath(dir, p, owner string, opts ImportOptions) (*ImportResult, error) {
+	rel, err := filepath.Rel(dir, p)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	res, err := b.importFile(importEntry{path: filepath.ToSlash(rel), owner: owner, data: data}, opts)
+	return &res, err
+}
+
+// importProgress returns the function reporting the
+// result of a processed file to opts.OnProgress.
+func importProgress(total int, opts ImportOptions) func(ImportResult) {
+	var mu sync.Mutex
+	done := 0
+	return func(res ImportResult) {
+		if opts.OnProgress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		opts.OnProgress(ImportProgress{Done: done, Total: total, Result: res})
+	}
+}
+
+// ImportTar is like ImportDir but imports the regular
+// files of the tar archive read from r. The content type
+// and the user defined meta data of the objects of archives
+// created by Export are restored.
+func (b *Bucket) ImportTar(ctx context.Context, r io.Reader, owner string, opts ImportOptions) (*ImportReport, error) {
+	return b.importTar(ctx, r, opts, func(info *ObjectInfo) importEntry {
+		return importEntry{path: info.Name, owner: owner, meta: info.importMeta()}
+	})
+}
+
+// Import restores the objects of a tar or zip archive created by
+// Export including their ids, owners, content types and user defined
+// meta data. The format is detected from the content of r. Files
+// colliding with an object with the same name or id are handled by
+// the collision strategy of opts. Zip archives are buffered in a
+// temporary file in BucketOptions.SpillDir.
+func (b *Bucket) Import(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(len(zipMagic)); err == nil && string(magic) == zipMagic {
+		return b.importZip(ctx, br, opts)
+	}
+	return b.importTar(ctx, br, opts, restoreEntry)
+}
+
+// zipMagic is the signature of the first local file header of a zip archive.
+const zipMagic = "PK\x03\x04"
+
+// restoreEntry returns the entry restoring the object.
+func restoreEntry(info *ObjectInfo) importEntry {
+	return importEntry{path: info.Name, owner: info.Owner, id: info.ID, meta: info.importMeta()}
+}
+
+// importTar imports the regular files of the tar archive
+// as the entries returned by entry for their information.
+func (b *Bucket) importTar(ctx context.Context, r io.Reader, opts ImportOptions, entry func(info *ObjectInfo) importEntry) (*ImportReport, error) {
+	report := &ImportReport{Results: make([]ImportResult, 0)}
+	progress := importProgress(0, opts)
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+		if hdr.Typeflag != tar.TypeReg || hdr.Name == ManifestName {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return report, err
+		}
+		name := path.Clean(strings.TrimPrefix(hdr.Name, "/"))
+		e := entry(paxInfo(name, hdr.PAXRecords))
+		e.data = data
+		res, err := b.importFile(e, opts)
+		report.Results = append(report.Results, res)
+		progress(res)
+		if err != nil {
+			return report, err
+		}
+	}
+}
+
+// importZip restores the files of the zip archive using the
+// manifest. Files which are not listed in the manifest are
+// failing because their owner is unknown.
+func (b *Bucket) importZip(ctx context.Context, r io.Reader, opts ImportOptions) (*ImportReport, error) {
+	report := &ImportReport{Results: make([]ImportResult, 0)}
+	f, err := os.CreateTemp(b.opts.SpillDir, "objst-import-*")
+	if err != nil {
+		return report, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return report, err
+	}
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return report, err
+	}
+	files := make([]*zip.File, 0, len(zr.File))
+	var manifest Manifest
+	for _, zf := range zr.File {
+		if zf.Name != ManifestName {
+			files = append(files, zf)
+			continue
+		}
+		if err := readZipJSON(zf, &manifest); err != nil {
+			return report, err
+		}
+	}
+	progress := importProgress(len(files), opts)
+	for i, zf := range files {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		// the manifest lists the objects in the order of the archive
+		info := &ObjectInfo{Name: zf.Name}
+		if i < len(manifest.Entries) && manifest.Entries[i].Path == zf.Name && manifest.Entries[i].Object != nil {
+			info = manifest.Entries[i].Object
+		}
+		data, err := readZipFile(zf)
+		if err != nil {
+			return report, err
+		}
+		e := restoreEntry(info)
+		e.path = path.Clean(strings.TrimPrefix(zf.Name, "/"))
+		e.data = data
+		res, err := b.importFile(e, opts)
+		report.Results = append(report.Results, res)
+		progress(res)
+		if err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+func readZipFile(zf *zip.File) ([]byte, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func readZipJSON(zf *zip.File, v any) error {
+	data, err := readZipFile(zf)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// importEntry is a file which is imported as an object.
+type importEntry struct {
+	path  string
+	owner string
+	// id is the id of a restored object.
+	// If it is empty a new id is used.
+	id   string
+	data []byte
+	meta map[MetaKey]string
+}
+
+// importFile imports the file with the meta data and returns its
+// result. The file collides with an object with the same name or
+// id. An error is only returned if the import has to be stopped
+// because of the collision strategy.
+func (b *Bucket) importFile(e importEntry, opts ImportOptions) (ImportResult, error) {
+	res := ImportResult{Path: e.path, Name: e.path, Action: ImportCreated}
+	nameTaken := b.isNameExisting(e.path, e.owner)
+	idTaken := e.id != "" && b.isIDExisting(e.id)
+	if nameTaken || idTaken {
+		switch opts.Collision {
+		case CollisionSkip:
+			res.Action = ImportSkipped
+			return res, nil
+		case CollisionOverwrite:
+			res.Action = ImportOverwritten
+		case CollisionRename:
+			if nameTaken {
+				res.Name = b.freeName(e.path, e.owner)
+			}
+			if idTaken {
+				e.id = ""
+			}
+			res.Action = ImportRenamed
+		default:
+			res.Action = ImportFailed
+			res.Err = fmt.Errorf("%w: %s", ErrDuplicateName, e.path)
+			if !nameTaken {
+				res.Err = fmt.Errorf("%w: %s", ErrDuplicateID, e.id)
+			}
+			return res, res.Err
+		}
+	}
+	obj, err := b.importObject(res.Name, e.owner, e.id, e.data, e.meta)
+	if err == nil && res.Action == ImportOverwritten && nameTaken {
+		err = b.DeleteByName(res.Name, e.owner)
+	}
+	// the object with the id may have been deleted by its name
+	if err == nil && res.Action == ImportOverwritten && idTaken {
+		if err = b.DeleteByID(e.id); errors.Is(err, ErrObjectNotFound) {
+			err = nil
+		}
+	}
+	if err == nil {
+		err = b.Create(obj)
+	}
+	if err != nil {
+		res.Action = ImportFailed
+		res.Err = err
+	} else {
+		res.ID = obj.ID()
+	}
+	return res, nil
+}
+
+// importObject returns the object of the file with the meta data
+// and the id which is random if it is empty. If the meta data
+// doesn't contain the content type it is sniffed from the payload
+// if the extension is unknown.
+func (b *Bucket) importObject(name, owner, id string, data []byte, meta map[MetaKey]string) (*Object, error) {
+	var obj *Object
+	var err error
+	if id == "" {
+		obj, err = NewObject(name, owner)
+	} else {
+		obj, err = NewObjectWithID(id, name, owner)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range meta {
+		obj.SetMetaKey(k, v)
+	}
+	if !obj.HasMetaKey(MetaKeyContentType) {
+		obj.SetMetaKey(MetaKeyContentType, http.DetectContentType(data))
+	}
+	_, err = obj.Write(data)
+	return obj, err
+}
+
+// freeName returns the first name of the owner which
+// doesn't exist by adding a numeric suffix to the name.
+func (b *Bucket) freeName(name, owner string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s-%d%s", base, i, ext)
+		if !b.isNameExisting(candidate, owner) {
+			return candidate
+		}
+	}
+}
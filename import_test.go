@@ -0,0 +1,155 @@
+package objst
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestImportDir(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{"a.txt": "a", "docs/b.txt": "b", "invalid": "c"}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	owner := tEnv.owner()
+	ctx := context.Background()
+	report, err := b.ImportDir(ctx, dir, owner, ImportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(ImportCreated) != 2 || report.Count(ImportFailed) != 1 {
+		t.Fatalf("expected two created and one failed file. Got: %+v", report.Results)
+	}
+	obj, err := b.GetByName("docs/b.txt", owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(obj.GetMetaKey(MetaKeyContentType), "text/plain") {
+		t.Fatalf("content type should be detected. Got: %s", obj.GetMetaKey(MetaKeyContentType))
+	}
+
+	report, err = b.ImportDir(ctx, dir, owner, ImportOptions{})
+	if !errors.Is(err, ErrDuplicateName) {
+		t.Fatalf("collision should stop the import. Got: %v", err)
+	}
+	if report.Count(ImportFailed) != 1 || report.Count(ImportCreated) != 0 {
+		t.Fatalf("report should end with the collision. Got: %+v", report.Results)
+	}
+
+	tests := []struct {
+		name      string
+		collision Collision
+		action    ImportAction
+	}{
+		{name: "skip", collision: CollisionSkip, action: ImportSkipped},
+		{name: "overwrite", collision: CollisionOverwrite, action: ImportOverwritten},
+		{name: "rename", collision: CollisionRename, action: ImportRenamed},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			report, err := b.ImportDir(ctx, dir, owner, ImportOptions{Collision: tc.collision})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Count(tc.action) != 2 {
+				t.Fatalf("expected two files to be %s. Got: %+v", tc.action, report.Results)
+			}
+		})
+	}
+	if !b.isNameExisting("a-1.txt", owner) || !b.isNameExisting("docs/b-1.txt", owner) {
+		t.Fatalf("renamed objects should exist")
+	}
+}
+
+func TestImportTar(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range []string{"./a.txt", "a.txt"} {
+		data := tEnv.payload(10)
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	report, err := b.ImportTar(context.Background(), &buf, tEnv.owner(), ImportOptions{Collision: CollisionRename})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 2 || report.Results[1].Name != "a-1.txt" {
+		t.Fatalf("second file should be renamed. Got: %+v", report.Results)
+	}
+}
+
+func TestImportDirConcurrent(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	dir := t.TempDir()
+	const n = 20
+	for i := 0; i < n; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file-%02d.txt", i))
+		if err := os.WriteFile(name, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	progress := make([]ImportProgress, 0, n)
+	owner := tEnv.owner()
+	report, err := b.ImportDir(context.Background(), dir, owner, ImportOptions{
+		Concurrency: 4,
+		OnProgress: func(p ImportProgress) {
+			progress = append(progress, p)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Count(ImportCreated) != n {
+		t.Fatalf("expected %d created files. Got: %+v", n, report.Results)
+	}
+	for i, res := range report.Results {
+		if want := fmt.Sprintf("file-%02d.txt", i); res.Path != want {
+			t.Fatalf("report should be in the order of the walk. Got: %s. Want: %s", res.Path, want)
+		}
+	}
+	if len(progress) != n || progress[n-1].Done != n || progress[n-1].Total != n {
+		t.Fatalf("progress should be reported for every file. Got: %+v", progress)
+	}
+}
@@ -0,0 +1,258 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// indexPrefix is the prefix of the secondary index entries
+// in the name store. Object names cannot start with "#"
+// so the entries never collide with a name entry.
+const indexPrefix = "#index/"
+
+// indexKeyPrefix returns the prefix of all index entries
+// of the key k. The key and the value are escaped so both
+// can contain a "/".
+func indexKeyPrefix(k MetaKey) string {
+	return indexPrefix + url.PathEscape(k.String()) + "/"
+}
+
+// indexKey returns the key of the index entry
+// in the format #index/<key>/<value>/<id>.
+func indexKey(k MetaKey, v, id string) []byte {
+	return []byte(indexKeyPrefix(k) + url.PathEscape(v) + "/" + id)
+}
+
+// CreateIndex creates a secondary index on the meta data key k.
+// The index is built for all existing objects and maintained on
+// every write afterwards. Queries with an equality, prefix or
+// regular expression condition with a literal prefix on an indexed
+// key are only comparing the objects found in the index instead of
+// scanning the meta data of all objects. Indexes are persisted and
+// creating an existing index is a no-op.
+func (b *Bucket) CreateIndex(k MetaKey) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	if k == "" {
+		return ErrEmptyIndexKey
+	}
+	b.indexMu.Lock()
+	if _, ok := b.indexes[k]; ok {
+		b.indexMu.Unlock()
+		return nil
+	}
+	// the index is maintained by all writes from now on
+	// but not used by queries until it is complete.
+	b.indexes[k] = false
+	b.indexMu.Unlock()
+	if err := b.buildIndex(context.Background(), k); err != nil {
+		b.indexMu.Lock()
+		delete(b.indexes, k)
+		b.indexMu.Unlock()
+		return err
+	}
+	if err := b.persistIndex(k); err != nil {
+		return err
+	}
+	b.indexMu.Lock()
+	b.indexes[k] = true
+	b.indexMu.Unlock()
+	return nil
+}
+
+// Indexes returns the keys of all
+// complete secondary indexes.
+func (b *Bucket) Indexes() []MetaKey {
+	b.indexMu.RLock()
+	defer b.indexMu.RUnlock()
+	keys := make([]MetaKey, 0, len(b.indexes))
+	for k, ready := range b.indexes {
+		if ready {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// buildIndex inserts the index entries of
+// the key k for all existing objects.
+func (b *Bucket) buildIndex(ctx context.Context, k MetaKey) error {
+	wb := b.name.NewWriteBatch()
+	defer wb.Cancel()
+	err := b.forEachID(ctx, func(id string) error {
+		meta, err := b.GetMeta(id)
+		if err != nil {
+			return err
+		}
+		if !meta.Has(k) {
+			return nil
+		}
+		return wb.Set(indexKey(k, meta.Get(k), id), nil)
+	})
+	if err != nil {
+		return err
+	}
+	return wb.Flush()
+}
+
+// persistIndex adds the key k to the indexes of the manifest.
+func (b *Bucket) persistIndex(k MetaKey) error {
+	b.dictMu.Lock()
+	defer b.dictMu.Unlock()
+	m, err := b.manifest()
+	if err != nil {
+		return err
+	}
+	m.Indexes = append(m.Indexes, k)
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Set([]byte(manifestKey), data)
+	})
+}
+
+// loadIndexes loads the indexes of the manifest.
+func (b *Bucket) loadIndexes() error {
+	b.dictMu.RLock()
+	m, err := b.manifest()
+	b.dictMu.RUnlock()
+	if err != nil {
+		return err
+	}
+	b.indexMu.Lock()
+	defer b.indexMu.Unlock()
+	b.indexes = make(map[MetaKey]bool, len(m.Indexes))
+	for _, k := range m.Indexes {
+		b.indexes[k] = true
+	}
+	return nil
+}
+
+// indexEntries returns the keys of the index
+// entries of the object with the meta data.
+func (b *Bucket) indexEntries(meta *Metadata) [][]byte {
+	b.indexMu.RLock()
+	defer b.indexMu.RUnlock()
+	keys := make([][]byte, 0, len(b.indexes))
+	for k := range b.indexes {
+		if meta.Has(k) {
+			keys = append(keys, indexKey(k, meta.Get(k), meta.Get(MetaKeyID)))
+		}
+	}
+	return keys
+}
+
+// reindexMeta replaces the index entries of the
+// old meta data with the entries of the new one.
+func (b *Bucket) reindexMeta(old, meta *Metadata) error {
+	oldKeys := b.indexEntries(old)
+	newKeys := b.indexEntries(meta)
+	return b.name.Update(func(txn *storeTxn) error {
+		for _, k := range oldKeys {
+			if slices.ContainsFunc(newKeys, func(nk []byte) bool { return bytes.Equal(nk, k) }) {
+				continue
+			}
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		for _, k := range newKeys {
+			if err := txn.Set(k, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// conjuncts returns the expressions which all have to match
+// for e to match by flattening nested `And` expressions.
+func conjuncts(e Expr) []Expr {
+	l, ok := e.(logicalExpr)
+	if !ok || !l.and {
+		return []Expr{e}
+	}
+	exprs := make([]Expr, 0, len(l.exprs))
+	for _, e := range l.exprs {
+		exprs = append(exprs, conjuncts(e)...)
+	}
+	return exprs
+}
+
+// indexLookup returns the index prefix of the first condition of
+// the conjuncts of the expression which can be answered by a
+// complete index. The second return value is false if no
+// condition is usable.
+func (b *Bucket) indexLookup(e Expr) (string, bool) {
+	b.indexMu.RLock()
+	defer b.indexMu.RUnlock()
+	for _, e := range conjuncts(e) {
+		c, ok := e.(Condition)
+		if !ok || !b.indexes[c.Key] {
+			continue
+		}
+		switch c.Op {
+		case OpEq:
+			// equal times may have different representations
+			if slices.Contains(timeKeys, c.Key) {
+				continue
+			}
+			return indexKeyPrefix(c.Key) + url.PathEscape(c.Value) + "/", true
+		case OpPrefix:
+			return indexKeyPrefix(c.Key) + url.PathEscape(c.Value), true
+		case OpMatch:
+			re, err := regexp.Compile(c.Value)
+			if err != nil {
+				continue
+			}
+			prefix, complete := re.LiteralPrefix()
+			if complete {
+				return indexKeyPrefix(c.Key) + url.PathEscape(prefix) + "/", true
+			}
+			if prefix != "" {
+				return indexKeyPrefix(c.Key) + url.PathEscape(prefix), true
+			}
+		case OpGlob:
+			i := strings.IndexAny(c.Value, `*?[\`)
+			if i < 0 {
+				return indexKeyPrefix(c.Key) + url.PathEscape(c.Value) + "/", true
+			}
+			if i > 0 {
+				return indexKeyPrefix(c.Key) + url.PathEscape(c.Value[:i]), true
+			}
+		}
+	}
+	return "", false
+}
+
+// indexedIDs returns the sorted ids of all
+// index entries starting with the prefix.
+func (b *Bucket) indexedIDs(prefix string) ([]string, error) {
+	ids := make([]string, 0)
+	err := b.name.View(func(txn *storeTxn) error {
+		opts := defaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		p := []byte(prefix)
+		for it.Seek(p); it.ValidForPrefix(p); it.Next() {
+			key := string(it.Item().Key())
+			ids = append(ids, key[strings.LastIndex(key, "/")+1:])
+		}
+		return nil
+	})
+	slices.Sort(ids)
+	return slices.Compact(ids), err
+}
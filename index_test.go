@@ -0,0 +1,125 @@
+package objst
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestCreateIndex(t *testing.T) {
+	const tag MetaKey = "tag"
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	if err := b.CreateIndex(""); !errors.Is(err, ErrEmptyIndexKey) {
+		t.Fatalf("empty index key should be rejected. Got: %v", err)
+	}
+	owner := tEnv.owner()
+	tags := []string{"finance/2023", "finance/2024", "hr", "legal"}
+	ids := make([]string, 0, len(tags))
+	for i, v := range tags {
+		o, _ := NewObject(fmt.Sprintf("doc-%d.txt", i), owner)
+		o.Write(tEnv.payload(10))
+		o.SetMetaKey(tag, v)
+		if err := b.Create(o); err != nil {
+			t.Error(err)
+			return
+		}
+		ids = append(ids, o.ID())
+	}
+	// objects created before the index are indexed
+	if err := b.CreateIndex(tag); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.CreateIndex(tag); err != nil {
+		t.Fatalf("creating an existing index should be a no-op. Got: %v", err)
+	}
+	if !slices.Equal(b.Indexes(), []MetaKey{tag}) {
+		t.Fatalf("index should be listed. Got: %v", b.Indexes())
+	}
+	o, _ := NewObject("doc-new.txt", owner)
+	o.Write(tEnv.payload(10))
+	o.SetMetaKey(tag, "hr")
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.UpdateMeta(ids[3], map[MetaKey]string{tag: "finance/2025"}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.DeleteByID(ids[2]); err != nil {
+		t.Error(err)
+		return
+	}
+	tests := []struct {
+		name string
+		q    *Query
+		c    int
+	}{
+		{
+			name: "equality",
+			q:    NewQuery().Filter(Eq(tag, "hr")),
+			c:    1,
+		},
+		{
+			name: "prefix",
+			q:    NewQuery().Filter(Prefix(tag, "finance/")),
+			c:    3,
+		},
+		{
+			name: "updated value",
+			q:    NewQuery().Filter(Eq(tag, "legal")),
+			c:    0,
+		},
+		{
+			name: "param with literal prefix",
+			q:    NewQuery().Owner(owner).Param(tag, "finance/202[34]"),
+			c:    2,
+		},
+		{
+			name: "glob with literal prefix",
+			q:    NewQuery().Filter(Glob(tag, "finance/202?")),
+			c:    3,
+		},
+		{
+			name: "index narrowing further conditions",
+			q:    NewQuery().Filter(And(Prefix(tag, "finance/"), Not(Eq(tag, "finance/2023")))),
+			c:    2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, ok := b.indexLookup(test.q.expr()); !ok {
+				t.Fatalf("query should use the index")
+			}
+			objs, err := b.Execute(test.q)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(objs) != test.c {
+				t.Fatalf("not the right number fetched. Got: %d. Expected: %d", len(objs), test.c)
+			}
+		})
+	}
+	entries, err := b.indexedIDs(indexKeyPrefix(tag))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(entries) != 4 {
+		t.Fatalf("index should contain an entry per object. Got: %d", len(entries))
+	}
+}
@@ -0,0 +1,123 @@
+package objst
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	// kvPrefix is the prefix of the key-value entries in the
+	// name store. The entries are kept out of the meta store
+	// so scans of the meta data only have to visit objects.
+	kvPrefix = "#kv/"
+	// MaxKVValueSize is the maximum size of a value of a KV.
+	MaxKVValueSize = 64 << 10
+)
+
+// KV is a small key-value store scoped to an owner. It allows
+// applications to store small settings next to their objects
+// without a second database. Use objects for larger data.
+type KV struct {
+	b     *Bucket
+	owner string
+}
+
+// KV returns the key-value store of the owner.
+func (b *Bucket) KV(owner string) *KV {
+	return &KV{b: b, owner: owner}
+}
+
+// kvKeyPrefix returns the prefix of all entries of the owner.
+// The owner is escaped so it can contain a "/".
+func kvKeyPrefix(owner string) string {
+	return kvPrefix + url.PathEscape(owner) + "/"
+}
+
+func (kv *KV) key(k string) []byte {
+	return []byte(kvKeyPrefix(kv.owner) + k)
+}
+
+func (kv *KV) isValid(k string) error {
+	if k == "" {
+		return ErrEmptyKVKey
+	}
+	return kv.b.opts.OwnerValidator(kv.owner)
+}
+
+// Set sets the value of the key k. ErrKVValueTooLarge
+// is returned if the value exceeds MaxKVValueSize.
+func (kv *KV) Set(k string, v []byte) error {
+	done, err := kv.b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	if err := kv.isValid(k); err != nil {
+		return err
+	}
+	if len(v) > MaxKVValueSize {
+		return fmt.Errorf("%w: %d bytes", ErrKVValueTooLarge, len(v))
+	}
+	return kv.b.updateAudited(func(txn *storeTxn) error {
+		return txn.Set(kv.key(k), v)
+	}, AuditEntry{Op: AuditKVSet, Owner: kv.owner, ID: k})
+}
+
+// Get returns the value of the key k or
+// ErrKVKeyNotFound if the key isn't set.
+func (kv *KV) Get(k string) ([]byte, error) {
+	if err := kv.isValid(k); err != nil {
+		return nil, err
+	}
+	var v []byte
+	err := kv.b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get(kv.key(k))
+		if err != nil {
+			return err
+		}
+		v, err = item.ValueCopy(nil)
+		return err
+	})
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrKVKeyNotFound, k)
+	}
+	return v, err
+}
+
+// Delete deletes the key k. Deleting
+// a key which isn't set is a no-op.
+func (kv *KV) Delete(k string) error {
+	done, err := kv.b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	if err := kv.isValid(k); err != nil {
+		return err
+	}
+	return kv.b.updateAudited(func(txn *storeTxn) error {
+		return txn.Delete(kv.key(k))
+	}, AuditEntry{Op: AuditKVDelete, Owner: kv.owner, ID: k})
+}
+
+// Keys returns all keys of the owner in lexical order.
+func (kv *KV) Keys() ([]string, error) {
+	if err := kv.b.opts.OwnerValidator(kv.owner); err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0)
+	err := kv.b.name.View(func(txn *storeTxn) error {
+		opts := defaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := []byte(kvKeyPrefix(kv.owner))
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			keys = append(keys, strings.TrimPrefix(string(it.Item().Key()), string(prefix)))
+		}
+		return nil
+	})
+	return keys, err
+}
@@ -0,0 +1,56 @@
+package objst
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestKV(t *testing.T) {
+	owner := tEnv.owner()
+	kv := tEnv.b.KV(owner)
+	if err := kv.Set("theme", []byte("dark")); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := kv.Set("limits/daily", []byte("10")); err != nil {
+		t.Error(err)
+		return
+	}
+	v, err := kv.Get("theme")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(v) != "dark" {
+		t.Fatalf("value is not correct. Got: %s", v)
+	}
+	if _, err := tEnv.b.KV(tEnv.owner()).Get("theme"); !errors.Is(err, ErrKVKeyNotFound) {
+		t.Fatalf("keys should be scoped to the owner. Got: %v", err)
+	}
+	keys, err := kv.Keys()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !slices.Equal(keys, []string{"limits/daily", "theme"}) {
+		t.Fatalf("keys are not correct. Got: %v", keys)
+	}
+	if err := kv.Delete("theme"); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := kv.Get("theme"); !errors.Is(err, ErrKVKeyNotFound) {
+		t.Fatalf("deleted key should not be found. Got: %v", err)
+	}
+	if err := kv.Set("", nil); !errors.Is(err, ErrEmptyKVKey) {
+		t.Fatalf("empty key should be invalid. Got: %v", err)
+	}
+	if err := kv.Set("big", make([]byte, MaxKVValueSize+1)); !errors.Is(err, ErrKVValueTooLarge) {
+		t.Fatalf("too large value should be rejected. Got: %v", err)
+	}
+	if err := tEnv.b.KV("invalid").Set("theme", nil); !errors.Is(err, ErrInvalidOwner) {
+		t.Fatalf("invalid owner should be rejected. Got: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package objst
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// latencySamples is the number of the most recent
+// latencies kept per operation to calculate quantiles.
+const latencySamples = 1024
+
+const (
+	opView   = "view"
+	opUpdate = "update"
+	opBatch  = "batch"
+)
+
+// LatencyStats are the latencies of one
+// operation of one store of the bucket.
+type LatencyStats struct {
+	Store string        `json:"store"`
+	Op    string        `json:"op"`
+	Count int64         `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// latencySampler records the latencies of the operations
+// of a store. Count, mean and max are covering all operations
+// while the quantiles are calculated of the recent samples.
+type latencySampler struct {
+	mu  sync.Mutex
+	ops map[string]*opLatency
+}
+
+type opLatency struct {
+	count   int64
+	total   time.Duration
+	max     time.Duration
+	samples []time.Duration
+	next    int
+}
+
+func newLatencySampler() *latencySampler {
+	return &latencySampler{
+		ops: make(map[string]*opLatency),
+	}
+}
+
+// record records the latency of op since start.
+func (l *latencySampler) record(op string, start time.Time) {
+	d := time.Since(start)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	o, ok := l.ops[op]
+	if !ok {
+		o = &opLatency{samples: make([]time.Duration, 0, latencySamples)}
+		l.ops[op] = o
+	}
+	o.count++
+	o.total += d
+	if d > o.max {
+		o.max = d
+	}
+	if len(o.samples) < latencySamples {
+		o.samples = append(o.samples, d)
+		return
+	}
+	o.samples[o.next] = d
+	o.next = (o.next + 1) % latencySamples
+}
+
+func (l *latencySampler) stats(store string) []LatencyStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make([]LatencyStats, 0, len(l.ops))
+	for op, o := range l.ops {
+		samples := slices.Clone(o.samples)
+		slices.Sort(samples)
+		stats = append(stats, LatencyStats{
+			Store: store,
+			Op:    op,
+			Count: o.count,
+			Mean:  o.total / time.Duration(o.count),
+			P50:   quantile(samples, 0.5),
+			P99:   quantile(samples, 0.99),
+			Max:   o.max,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Op < stats[j].Op
+	})
+	return stats
+}
+
+// quantile returns the q-quantile of the sorted samples.
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[int(float64(len(sorted)-1)*q)]
+}
+
+// Latencies returns the latencies of the
+// operations of all stores of the bucket.
+func (b *Bucket) Latencies() []LatencyStats {
+	stats := make([]LatencyStats, 0)
+	for _, s := range []*store{b.payload, b.name, b.meta} {
+		stats = append(stats, s.latency.stats(s.name)...)
+	}
+	return stats
+}
@@ -0,0 +1,74 @@
+package objst
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLatencies(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := tEnv.b.GetByID(o.ID()); err != nil {
+		t.Error(err)
+		return
+	}
+	found := make(map[string]bool)
+	for _, s := range tEnv.b.Latencies() {
+		if s.Count <= 0 || s.P50 > s.Max || s.P99 > s.Max {
+			t.Fatalf("invalid latency stats: %+v", s)
+		}
+		found[s.Store+"/"+s.Op] = true
+	}
+	for _, k := range []string{"payload/batch", "meta/update", "meta/view", "payload/view"} {
+		if !found[k] {
+			t.Fatalf("missing latencies of %s. Got: %v", k, found)
+		}
+	}
+}
+
+func TestHTTPDebug(t *testing.T) {
+	opts := DefaultHTTPHandlerOptions()
+	ts := httptest.NewServer(NewHTTPHandler(tEnv.b, opts))
+	defer ts.Close()
+	res, err := ts.Client().Get(ts.URL + "/objst/debug/latencies")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		t.Fatalf("debug endpoints should be disabled by default")
+	}
+
+	opts.Debug = true
+	tsDebug := httptest.NewServer(NewHTTPHandler(tEnv.b, opts))
+	defer tsDebug.Close()
+	res, err = tsDebug.Client().Get(tsDebug.URL + "/objst/debug/latencies")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusOK, res.StatusCode)
+	}
+	stats := make([]LatencyStats, 0)
+	if err := json.NewDecoder(res.Body).Decode(&stats); err != nil {
+		t.Error(err)
+		return
+	}
+	res, err = tsDebug.Client().Get(tsDebug.URL + "/objst/debug/pprof/heap")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("pprof statuscode is not %d. Got: %d", http.StatusOK, res.StatusCode)
+	}
+}
@@ -0,0 +1,382 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	archiveDir = "archive"
+	// lifecyclePrefix is the prefix of the persisted
+	// lifecycle rules in the name store.
+	lifecyclePrefix = "#lifecycle/"
+)
+
+// LifecycleAction is the action applied to
+// objects matching a lifecycle rule.
+type LifecycleAction int
+
+const (
+	// LifecycleDelete deletes the object.
+	LifecycleDelete LifecycleAction = iota + 1
+	// LifecycleArchive exports the payload and the
+	// ObjectInfo of the object into the ArchiveDir
+	// of the bucket and deletes the object afterwards.
+	LifecycleArchive
+)
+
+var lifecycleActionNames = map[LifecycleAction]string{
+	LifecycleDelete:  "delete",
+	LifecycleArchive: "archive",
+}
+
+func (a LifecycleAction) MarshalText() ([]byte, error) {
+	name, ok := lifecycleActionNames[a]
+	if !ok {
+		return nil, ErrUnknownLifecycleAction
+	}
+	return []byte(name), nil
+}
+
+func (a *LifecycleAction) UnmarshalText(text []byte) error {
+	for action, name := range lifecycleActionNames {
+		if name == string(text) {
+			*a = action
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownLifecycleAction, text)
+}
+
+// Rule is a lifecycle rule similar to the lifecycle rules
+// of S3. An object matches the rule if its name starts with
+// Prefix, it belongs to Owner and it was created more than
+// OlderThan ago. Empty fields are matching all objects.
+type Rule struct {
+	// ID identifies a persisted rule. See PutLifecycleRule.
+	ID        string
+	Prefix    string
+	Owner     string
+	OlderThan time.Duration
+	Action    LifecycleAction
+	CreatedAt time.Time
+}
+
+// ruleJSON is the JSON representation of a Rule
+// with OlderThan formatted like time.Duration.
+type ruleJSON struct {
+	ID        string          `json:"id,omitempty"`
+	Prefix    string          `json:"prefix,omitempty"`
+	Owner     string          `json:"owner,omitempty"`
+	OlderThan string          `json:"olderThan,omitempty"`
+	Action    LifecycleAction `json:"action"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func (r Rule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ruleJSON{
+		ID:        r.ID,
+		Prefix:    r.Prefix,
+		Owner:     r.Owner,
+		OlderThan: r.OlderThan.String(),
+		Action:    r.Action,
+		CreatedAt: r.CreatedAt,
+	})
+}
+
+func (r *Rule) UnmarshalJSON(data []byte) error {
+	var v ruleJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	var olderThan time.Duration
+	if v.OlderThan != "" {
+		d, err := time.ParseDuration(v.OlderThan)
+		if err != nil {
+			return err
+		}
+		olderThan = d
+	}
+	*r = Rule{ID: v.ID, Prefix: v.Prefix, Owner: v.Owner, OlderThan: olderThan, Action: v.Action, CreatedAt: v.CreatedAt}
+	return nil
+}
+
+func lifecycleKey(id string) []byte {
+	return []byte(lifecyclePrefix + id)
+}
+
+func (r Rule) isValid() error {
+	if r.Action != LifecycleDelete && r.Action != LifecycleArchive {
+		return ErrUnknownLifecycleAction
+	}
+	if r.OlderThan < 0 {
+		return ErrNegativeDuration
+	}
+	return nil
+}
+
+func (r Rule) matches(meta *Metadata, now time.Time) bool {
+	if !strings.HasPrefix(meta.Get(MetaKeyName), r.Prefix) {
+		return false
+	}
+	if r.Owner != "" && meta.Get(MetaKeyOwner) != r.Owner {
+		return false
+	}
+	createdAt, err := parseTime(meta.Get(MetaKeyCreatedAt))
+	if err != nil {
+		// the age of objects without a creation
+		// time is unknown so they never expire.
+		return false
+	}
+	return now.Sub(createdAt) >= r.OlderThan
+}
+
+// AddLifecycleRule adds the rule to the lifecycle rules of the
+// bucket which are applied every LifecycleInterval. If multiple
+// rules are matching an object the rule added first is applied.
+// The rule is not persisted and has to be added again after a
+// restart. See PutLifecycleRule.
+func (b *Bucket) AddLifecycleRule(r Rule) error {
+	if err := r.isValid(); err != nil {
+		return err
+	}
+	b.lifecycleMu.Lock()
+	defer b.lifecycleMu.Unlock()
+	b.rules = append(b.rules, r)
+	return nil
+}
+
+// PutLifecycleRule persists the rule in the bucket. A rule
+// without an id is created with a new id and a rule with the
+// id of an existing rule replaces it. Persisted rules are
+// applied after the rules added by AddLifecycleRule in the
+// order they were created.
+func (b *Bucket) PutLifecycleRule(r Rule) (Rule, error) {
+	if err := r.isValid(); err != nil {
+		return r, err
+	}
+	done, err := b.beginWrite()
+	if err != nil {
+		return r, err
+	}
+	defer done()
+	if r.ID == "" {
+		r.ID = uuid.NewString()
+		r.CreatedAt = time.Now().UTC()
+	} else {
+		old, err := b.lifecycleRule(r.ID)
+		if err != nil {
+			return r, err
+		}
+		r.CreatedAt = old.CreatedAt
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return r, err
+	}
+	err = b.name.Update(func(txn *storeTxn) error {
+		return txn.Set(lifecycleKey(r.ID), data)
+	})
+	return r, err
+}
+
+// DeleteLifecycleRule deletes the persisted rule. ErrLifecycleRuleNotFound
+// is returned if no rule with the id exists.
+func (b *Bucket) DeleteLifecycleRule(id string) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	if _, err := b.lifecycleRule(id); err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Delete(lifecycleKey(id))
+	})
+}
+
+// LifecycleRules returns the persisted rules in the order they
+// were created. Rules added by AddLifecycleRule are not included.
+func (b *Bucket) LifecycleRules() ([]Rule, error) {
+	rules := make([]Rule, 0)
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(lifecyclePrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var r Rule
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &r)
+			}); err != nil {
+				return err
+			}
+			rules = append(rules, r)
+		}
+		return nil
+	})
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+	})
+	return rules, err
+}
+
+func (b *Bucket) lifecycleRule(id string) (Rule, error) {
+	var r Rule
+	err := b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get(lifecycleKey(id))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &r)
+		})
+	})
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return r, fmt.Errorf("%w: %s", ErrLifecycleRuleNotFound, id)
+	}
+	return r, err
+}
+
+// ApplyLifecycle deletes the expired objects and applies the
+// lifecycle rules to all objects of the bucket once. It is called
+// periodically by the bucket but can be used to apply the rules
+// on demand.
+func (b *Bucket) ApplyLifecycle(ctx context.Context) error {
+	if _, err := b.ReapExpired(ctx); err != nil {
+		return err
+	}
+	b.lifecycleMu.Lock()
+	rules := make([]Rule, len(b.rules))
+	copy(rules, b.rules)
+	b.lifecycleMu.Unlock()
+	persisted, err := b.LifecycleRules()
+	if err != nil {
+		return err
+	}
+	rules = append(rules, persisted...)
+	if len(rules) == 0 {
+		return nil
+	}
+	type match struct {
+		meta   *Metadata
+		action LifecycleAction
+	}
+	matches := make([]match, 0)
+	now := time.Now()
+	err = b.meta.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			meta := NewMetadata()
+			err := it.Item().Value(func(val []byte) error {
+				return b.unmarshalMeta(meta, val)
+			})
+			if err != nil {
+				return err
+			}
+			for _, r := range rules {
+				if r.matches(meta, now) {
+					matches = append(matches, match{meta: meta, action: r.Action})
+					break
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, m := range matches {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := b.applyLifecycleAction(m.meta, m.action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bucket) applyLifecycleAction(meta *Metadata, action LifecycleAction) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	// locked objects are expired after
+	// their retention period passed.
+	if isLocked(meta) {
+		return nil
+	}
+	if action == LifecycleArchive {
+		if err := b.archive(meta); err != nil {
+			return err
+		}
+	}
+	// the object was deleted concurrently
+	if err := b.deleteObject(meta); !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	return nil
+}
+
+// archive writes the payload of the object to <ArchiveDir>/<id>
+// and the ObjectInfo of the object to <ArchiveDir>/<id>.json.
+func (b *Bucket) archive(meta *Metadata) error {
+	pl, err := b.readPayload(meta)
+	if err != nil {
+		return err
+	}
+	obj := &Object{meta: meta, pl: bytes.NewBuffer(pl)}
+	info, err := json.Marshal(obj.Info())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(b.opts.ArchiveDir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(b.opts.ArchiveDir, obj.ID())
+	if err := os.WriteFile(path, pl, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(path+".json", info, 0o644)
+}
+
+// runLifecycle applies the lifecycle rules and purges
+// the expired uploads every interval until the context
+// is canceled.
+func (b *Bucket) runLifecycle(ctx context.Context, interval time.Duration) {
+	defer b.lifecycleWg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.ApplyLifecycle(ctx); err != nil && ctx.Err() == nil && b.opts.Logger != nil {
+				b.opts.Logger.Warningf("applying the lifecycle rules failed: %v", err)
+			}
+			if b.opts.UploadRetention <= 0 {
+				continue
+			}
+			if err := b.PurgeStaging(ctx, b.opts.UploadRetention); err != nil && ctx.Err() == nil && b.opts.Logger != nil {
+				b.opts.Logger.Warningf("purging the staged uploads failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLifecycle(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.ArchiveDir = t.TempDir()
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	owner := tEnv.owner()
+	newObj := func(name string) *Object {
+		o, _ := NewObject(name, owner)
+		o.Write(tEnv.payload(10))
+		if err := b.Create(o); err != nil {
+			t.Fatal(err)
+		}
+		return o
+	}
+	tmp := newObj("tmp/a.test")
+	logs := newObj("logs/a.test")
+	keep := newObj("keep/a.test")
+
+	rules := []Rule{
+		{Prefix: "tmp/", Action: LifecycleDelete},
+		{Prefix: "logs/", Owner: owner, Action: LifecycleArchive},
+		{Prefix: "keep/", OlderThan: time.Hour, Action: LifecycleDelete},
+	}
+	for _, r := range rules {
+		if err := b.AddLifecycleRule(r); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err := b.ApplyLifecycle(context.Background()); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, o := range []*Object{tmp, logs} {
+		if _, err := b.GetByID(o.ID()); !errors.Is(err, ErrObjectNotFound) {
+			t.Fatalf("object %s should be removed. Got: %v", o.Name(), err)
+		}
+	}
+	if _, err := b.GetByID(keep.ID()); err != nil {
+		t.Fatalf("object not older than the rule should be kept: %v", err)
+	}
+	pl, err := os.ReadFile(filepath.Join(opts.ArchiveDir, logs.ID()))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if string(pl) != string(logs.Payload()) {
+		t.Fatalf("archived payload is not equal. Got: %s. Expected: %s", pl, logs.Payload())
+	}
+	if _, err := os.Stat(filepath.Join(opts.ArchiveDir, logs.ID()+".json")); err != nil {
+		t.Fatalf("object info should be archived: %v", err)
+	}
+}
+
+func TestAddLifecycleRuleInvalid(t *testing.T) {
+	if err := tEnv.b.AddLifecycleRule(Rule{}); !errors.Is(err, ErrUnknownLifecycleAction) {
+		t.Fatalf("rule without action should be invalid. Got: %v", err)
+	}
+	err := tEnv.b.AddLifecycleRule(Rule{OlderThan: -time.Second, Action: LifecycleDelete})
+	if !errors.Is(err, ErrNegativeDuration) {
+		t.Fatalf("rule with negative duration should be invalid. Got: %v", err)
+	}
+}
+
+func TestPersistedLifecycleRule(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	rule, err := b.PutLifecycleRule(Rule{Prefix: "tmp/", Action: LifecycleDelete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.ID == "" || rule.CreatedAt.IsZero() {
+		t.Fatalf("rule should have an id and a creation time. Got: %+v", rule)
+	}
+	if _, err := b.PutLifecycleRule(Rule{ID: "missing", Action: LifecycleDelete}); !errors.Is(err, ErrLifecycleRuleNotFound) {
+		t.Fatalf("replacing a missing rule should fail. Got: %v", err)
+	}
+	b.Shutdown()
+
+	b, err = OpenBucket(b.BasePath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Shutdown()
+	rules, err := b.LifecycleRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].ID != rule.ID || rules[0].Prefix != "tmp/" || rules[0].Action != LifecycleDelete {
+		t.Fatalf("rule should survive a restart. Got: %+v", rules)
+	}
+	o, _ := NewObject("tmp/a.test", tEnv.owner())
+	o.Write(tEnv.payload(10))
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.ApplyLifecycle(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetByID(o.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("object should be removed by the persisted rule. Got: %v", err)
+	}
+	if err := b.DeleteLifecycleRule(rule.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.DeleteLifecycleRule(rule.ID); !errors.Is(err, ErrLifecycleRuleNotFound) {
+		t.Fatalf("rule should be deleted. Got: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package objst
+
+import (
+	"fmt"
+	"time"
+)
+
+// Lock retains the object with the given id until the given time.
+// Until then the object cannot be deleted and neither its payload
+// nor its meta data can be updated, returning ErrObjectLocked.
+// The retention period can only be extended but never shortened
+// allowing write once read many (WORM) storage.
+func (b *Bucket) Lock(id string, until time.Time) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	return b.updateMeta(id, func(meta *Metadata) error {
+		if retainUntil, ok := retentionOf(meta); ok && until.Before(retainUntil) {
+			return fmt.Errorf("%w: retention can only be extended until after %s", ErrObjectLocked, retainUntil.Format(time.RFC3339))
+		}
+		if expiresAt, ok := expiryOf(meta); ok && expiresAt.Before(until) {
+			return fmt.Errorf("%w: %s", ErrExpiresBeforeRetention, id)
+		}
+		meta.set(MetaKeyRetainUntil, formatTime(until))
+		return nil
+	})
+}
+
+// retentionOf returns the time until the object is
+// retained and false if the object was never locked.
+func retentionOf(meta *Metadata) (time.Time, bool) {
+	retainUntil, err := parseTime(meta.Get(MetaKeyRetainUntil))
+	return retainUntil, err == nil
+}
+
+// isLocked reports if the object
+// described by meta is retained.
+func isLocked(meta *Metadata) bool {
+	retainUntil, ok := retentionOf(meta)
+	return ok && time.Now().Before(retainUntil)
+}
+
+// checkRetention returns ErrObjectLocked if
+// the object described by meta is retained.
+func checkRetention(meta *Metadata) error {
+	if isLocked(meta) {
+		return fmt.Errorf("%w: %s", ErrObjectLocked, meta.Get(MetaKeyID))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package objst
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLock(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	until := time.Now().Add(time.Hour)
+	if err := tEnv.b.Lock(o.ID(), until); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.DeleteByID(o.ID()); !errors.Is(err, ErrObjectLocked) {
+		t.Fatalf("locked object should not be deletable. Got: %v", err)
+	}
+	if err := tEnv.b.DeleteByName(o.Name(), o.Owner()); !errors.Is(err, ErrObjectLocked) {
+		t.Fatalf("locked object should not be deletable by name. Got: %v", err)
+	}
+	if err := tEnv.b.UpdateMeta(o.ID(), map[MetaKey]string{"foo": "bar"}); !errors.Is(err, ErrObjectLocked) {
+		t.Fatalf("meta data of locked object should not be updatable. Got: %v", err)
+	}
+	if err := tEnv.b.PatchPayload(o.ID(), 0, []byte("patched")); !errors.Is(err, ErrObjectLocked) {
+		t.Fatalf("payload of locked object should not be patchable. Got: %v", err)
+	}
+	if err := tEnv.b.Lock(o.ID(), until.Add(-time.Minute)); !errors.Is(err, ErrObjectLocked) {
+		t.Fatalf("retention should not be shortened. Got: %v", err)
+	}
+	if _, err := tEnv.b.GetByID(o.ID()); err != nil {
+		t.Fatalf("locked object should be readable: %v", err)
+	}
+}
+
+func TestLockExpired(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.Lock(o.ID(), time.Now().Add(-time.Second)); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.DeleteByID(o.ID()); err != nil {
+		t.Fatalf("object should be deletable after the retention period: %v", err)
+	}
+}
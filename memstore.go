@@ -0,0 +1,293 @@
+package objst
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore is a Store keeping the entries in memory. Every
+// transaction reads an immutable snapshot of the entries and the
+// read-write transactions are serialized so they never conflict.
+// It is meant for tests and small buckets because every commit
+// copies all entries.
+type memoryStore struct {
+	// writeMu serializes the read-write transactions
+	// and batches. mu guards snap and closed.
+	writeMu  sync.Mutex
+	mu       sync.Mutex
+	snap     *memorySnapshot
+	closed   bool
+	readOnly bool
+}
+
+// memorySnapshot is an immutable state of the entries.
+type memorySnapshot struct {
+	entries map[string]memoryEntry
+	// keys are the sorted keys of the entries.
+	keys []string
+}
+
+type memoryEntry struct {
+	value     []byte
+	expiresAt uint64
+}
+
+func (e memoryEntry) expired(now uint64) bool {
+	return e.expiresAt != 0 && e.expiresAt <= now
+}
+
+// NewMemoryStore returns a Store keeping the entries in memory
+// which are lost when it is closed. See OpenMemoryStore.
+func NewMemoryStore() Store {
+	return &memoryStore{snap: &memorySnapshot{entries: make(map[string]memoryEntry)}}
+}
+
+// OpenMemoryStore can be used as BucketOptions.OpenStore to
+// keep all stores of a bucket in memory using NewMemoryStore.
+func OpenMemoryStore(c StoreConfig) (Store, error) {
+	s := NewMemoryStore().(*memoryStore)
+	s.readOnly = c.ReadOnly
+	return s, nil
+}
+
+func (s *memoryStore) snapshot() (*memorySnapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrBucketClosed
+	}
+	return s.snap, nil
+}
+
+func (s *memoryStore) View(fn func(txn Txn) error) error {
+	snap, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	return fn(&memoryTxn{snap: snap, now: uint64(time.Now().Unix())})
+}
+
+func (s *memoryStore) Update(fn func(txn Txn) error) error {
+	if s.readOnly {
+		return ErrStoreReadOnly
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	snap, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	txn := &memoryTxn{snap: snap, now: uint64(time.Now().Unix()), writes: make(map[string]*memoryEntry)}
+	if err := fn(txn); err != nil {
+		return err
+	}
+	return s.commit(txn.writes)
+}
+
+// commit applies the writes to a copy of the current snapshot.
+// A nil entry deletes the key. writeMu has to be held.
+func (s *memoryStore) commit(writes map[string]*memoryEntry) error {
+	if len(writes) == 0 {
+		return nil
+	}
+	snap, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	next := &memorySnapshot{entries: make(map[string]memoryEntry, len(snap.entries)+len(writes))}
+	for k, e := range snap.entries {
+		next.entries[k] = e
+	}
+	for k, e := range writes {
+		if e == nil {
+			delete(next.entries, k)
+		} else {
+			next.entries[k] = *e
+		}
+	}
+	next.keys = sortedEntryKeys(next.entries)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snap = next
+	return nil
+}
+
+func (s *memoryStore) NewBatch() Batch {
+	return &memoryBatch{s: s, writes: make(map[string]*memoryEntry)}
+}
+
+func (s *memoryStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.snap = nil
+	return nil
+}
+
+type memoryTxn struct {
+	snap *memorySnapshot
+	now  uint64
+	// writes are the writes of a read-write transaction
+	// which is nil for read-only transactions.
+	writes map[string]*memoryEntry
+}
+
+func (t *memoryTxn) lookup(key string) (memoryEntry, bool) {
+	if e, ok := t.writes[key]; ok {
+		if e == nil || e.expired(t.now) {
+			return memoryEntry{}, false
+		}
+		return *e, true
+	}
+	e, ok := t.snap.entries[key]
+	if !ok || e.expired(t.now) {
+		return memoryEntry{}, false
+	}
+	return e, true
+}
+
+func (t *memoryTxn) Get(key []byte) (Item, error) {
+	e, ok := t.lookup(string(key))
+	if !ok {
+		return nil, ErrStoreKeyNotFound
+	}
+	return &memoryItem{key: key, entry: e}, nil
+}
+
+func (t *memoryTxn) Set(e *Entry) error {
+	if t.writes == nil {
+		return ErrStoreReadOnly
+	}
+	t.writes[string(e.Key)] = &memoryEntry{value: append([]byte(nil), e.Value...), expiresAt: e.ExpiresAt}
+	return nil
+}
+
+func (t *memoryTxn) Delete(key []byte) error {
+	if t.writes == nil {
+		return ErrStoreReadOnly
+	}
+	t.writes[string(key)] = nil
+	return nil
+}
+
+func (t *memoryTxn) Iterate(prefix []byte, _ IteratorOptions) Iterator {
+	keys := t.snap.keys
+	if len(t.writes) > 0 {
+		merged := make(map[string]memoryEntry, len(t.snap.entries))
+		for _, k := range keys {
+			merged[k] = memoryEntry{}
+		}
+		for k := range t.writes {
+			merged[k] = memoryEntry{}
+		}
+		keys = sortedEntryKeys(merged)
+	}
+	p := string(prefix)
+	start := sort.SearchStrings(keys, p)
+	end := start
+	for end < len(keys) && strings.HasPrefix(keys[end], p) {
+		end++
+	}
+	return &memoryIterator{txn: t, keys: keys[start:end]}
+}
+
+type memoryIterator struct {
+	txn  *memoryTxn
+	keys []string
+	pos  int
+	item *memoryItem
+}
+
+// skip moves to the next visible key starting at the position.
+func (it *memoryIterator) skip() {
+	it.item = nil
+	for ; it.pos < len(it.keys); it.pos++ {
+		if e, ok := it.txn.lookup(it.keys[it.pos]); ok {
+			it.item = &memoryItem{key: []byte(it.keys[it.pos]), entry: e}
+			return
+		}
+	}
+}
+
+func (it *memoryIterator) Seek(key []byte) {
+	it.pos = sort.SearchStrings(it.keys, string(key))
+	it.skip()
+}
+
+func (it *memoryIterator) Rewind() {
+	it.pos = 0
+	it.skip()
+}
+
+func (it *memoryIterator) Valid() bool {
+	return it.item != nil
+}
+
+func (it *memoryIterator) Next() {
+	it.pos++
+	it.skip()
+}
+
+func (it *memoryIterator) Item() Item {
+	return it.item
+}
+
+func (it *memoryIterator) Close() {}
+
+type memoryItem struct {
+	key   []byte
+	entry memoryEntry
+}
+
+func (i *memoryItem) Key() []byte {
+	return i.key
+}
+
+func (i *memoryItem) Value(fn func(val []byte) error) error {
+	return fn(i.entry.value)
+}
+
+func (i *memoryItem) ValueSize() int64 {
+	return int64(len(i.entry.value))
+}
+
+func (i *memoryItem) ExpiresAt() uint64 {
+	return i.entry.expiresAt
+}
+
+type memoryBatch struct {
+	s      *memoryStore
+	writes map[string]*memoryEntry
+}
+
+func (b *memoryBatch) Set(e *Entry) error {
+	b.writes[string(e.Key)] = &memoryEntry{value: append([]byte(nil), e.Value...), expiresAt: e.ExpiresAt}
+	return nil
+}
+
+func (b *memoryBatch) Delete(key []byte) error {
+	b.writes[string(key)] = nil
+	return nil
+}
+
+func (b *memoryBatch) Flush() error {
+	if b.s.readOnly {
+		return ErrStoreReadOnly
+	}
+	b.s.writeMu.Lock()
+	defer b.s.writeMu.Unlock()
+	return b.s.commit(b.writes)
+}
+
+func (b *memoryBatch) Cancel() {}
+
+func sortedEntryKeys(entries map[string]memoryEntry) []string {
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,91 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+	err := s.Update(func(txn Txn) error {
+		for _, k := range []string{"b/2", "a/1", "b/1", "c/1"} {
+			if err := txn.Set(&Entry{Key: []byte(k), Value: []byte(k)}); err != nil {
+				return err
+			}
+		}
+		if err := txn.Delete([]byte("c/1")); err != nil {
+			return err
+		}
+		// writes are visible to the transaction itself
+		if _, err := txn.Get([]byte("a/1")); err != nil {
+			return err
+		}
+		return txn.Set(&Entry{Key: []byte("b/0"), Value: []byte("expired"), ExpiresAt: 1})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.View(func(txn Txn) error {
+		if _, err := txn.Get([]byte("c/1")); !errors.Is(err, ErrStoreKeyNotFound) {
+			t.Fatalf("deleted key should not be found. Got: %v", err)
+		}
+		if err := txn.Set(&Entry{Key: []byte("d")}); !errors.Is(err, ErrStoreReadOnly) {
+			t.Fatalf("read-only transaction should reject writes. Got: %v", err)
+		}
+		it := txn.Iterate([]byte("b/"), defaultIteratorOptions)
+		defer it.Close()
+		var keys [][]byte
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, it.Item().Key())
+		}
+		if len(keys) != 2 || !bytes.Equal(keys[0], []byte("b/1")) || !bytes.Equal(keys[1], []byte("b/2")) {
+			t.Fatalf("iteration should visit the unexpired keys with the prefix in order. Got: %q", keys)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBucketWithMemoryStore(t *testing.T) {
+	for _, layout := range []Layout{LayoutSeparate, LayoutSingle} {
+		opts := NewDefaultBucketOptions()
+		opts.Logger = nil
+		opts.Layout = layout
+		opts.OpenStore = OpenMemoryStore
+		b, err := NewBucket(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(b.BasePath)
+		defer b.Shutdown()
+		o := tEnv.obj()
+		if err := b.Create(o); err != nil {
+			t.Fatal(err)
+		}
+		got, err := b.GetByName(o.Name(), o.Owner())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Payload(), o.Payload()) {
+			t.Fatal("payload of the object differs")
+		}
+		objs, err := b.Execute(NewQuery().Owner(o.Owner()))
+		if err != nil || len(objs) != 1 {
+			t.Fatalf("query should find the object. Got: %d %v", len(objs), err)
+		}
+		if err := b.DeleteByID(o.ID()); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := b.GetByID(o.ID()); !errors.Is(err, ErrObjectNotFound) {
+			t.Fatalf("deleted object should not be found. Got: %v", err)
+		}
+		if _, err := b.Backup(&bytes.Buffer{}, 0); !errors.Is(err, ErrStoreUnsupported) {
+			t.Fatalf("backups should require badger. Got: %v", err)
+		}
+	}
+}
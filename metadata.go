@@ -1,12 +1,16 @@
 package objst
 
 import (
-	"bytes"
-	"encoding/gob"
+	"encoding/json"
 	"fmt"
 	"net/url"
+	"path"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
@@ -18,8 +22,61 @@ const (
 	MetaKeyName        MetaKey = "name"
 	MetaKeyID          MetaKey = "id"
 	MetaKeyOwner       MetaKey = "owner"
+	MetaKeyETag        MetaKey = "etag"
+	MetaKeyChecksum    MetaKey = "checksum"
+	MetaKeyChunkSize   MetaKey = "chunkSize"
+	MetaKeyDataKey     MetaKey = "dataKey"
+	MetaKeyNonce       MetaKey = "nonce"
+	MetaKeyGeneration  MetaKey = "generation"
+	MetaKeyCompression MetaKey = "compression"
+	MetaKeySize        MetaKey = "size"
+	MetaKeyRetainUntil MetaKey = "retainUntil"
+	MetaKeyDictionary  MetaKey = "dictionary"
+	MetaKeyUpdatedAt   MetaKey = "updatedAt"
+	MetaKeyModeration  MetaKey = "moderation"
+	// MetaKeyModerationReason is the reason
+	// of the rejection of an object.
+	MetaKeyModerationReason MetaKey = "moderationReason"
+	// MetaKeySignature is the HMAC of the system meta
+	// data. See BucketOptions.MetaSigningKey.
+	MetaKeySignature MetaKey = "signature"
+	// MetaKeyExpiresAt is the time the object
+	// expires. See Bucket.Expire.
+	MetaKeyExpiresAt MetaKey = "expiresAt"
+	// MetaKeyTags is the list-valued key of the user
+	// defined labels of an object. See Metadata.Add.
+	MetaKeyTags MetaKey = "tags"
+	// MetaKeyPlaintext is "true" if the payload is never
+	// encrypted. See CreateOptions.Plaintext.
+	MetaKeyPlaintext MetaKey = "plaintext"
+	// MetaKeyRetentionClass is the user defined name of the
+	// retention class the object is locked by on creation.
+	// See RetentionClass.
+	MetaKeyRetentionClass MetaKey = "retentionClass"
+	// MetaKeyTier is "cold" if the payload of the object
+	// is stored in the Remote. See Bucket.Tier.
+	MetaKeyTier MetaKey = "tier"
 )
 
+// valueSeparator separates the escaped values
+// of list-valued meta data. See Metadata.Add.
+const valueSeparator = ","
+
+// TimeFormat is the format of all times stored
+// in the meta data e.g. MetaKeyCreatedAt.
+const TimeFormat = time.RFC3339Nano
+
+// timeKeys are the system keys storing a time.
+var timeKeys = []MetaKey{MetaKeyCreatedAt, MetaKeyUpdatedAt, MetaKeyRetainUntil, MetaKeyExpiresAt}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+func parseTime(s string) (time.Time, error) {
+	return time.Parse(TimeFormat, s)
+}
+
 func (m MetaKey) String() string {
 	return string(m)
 }
@@ -30,12 +87,15 @@ type Metadata struct {
 	// systemKeys contains all the keys
 	// which will be managed by objst.
 	systemKeys []MetaKey
+	// actor made the change of the object which is
+	// recorded in the audit log. It is not persisted.
+	actor string
 }
 
 func NewMetadata() *Metadata {
 	return &Metadata{
 		data:       make(map[MetaKey]string),
-		systemKeys: []MetaKey{MetaKeyID, MetaKeyCreatedAt, MetaKeyName, MetaKeyOwner},
+		systemKeys: []MetaKey{MetaKeyID, MetaKeyCreatedAt, MetaKeyName, MetaKeyOwner, MetaKeyETag, MetaKeyChecksum, MetaKeyChunkSize, MetaKeyDataKey, MetaKeyNonce, MetaKeyGeneration, MetaKeyCompression, MetaKeySize, MetaKeyRetainUntil, MetaKeyDictionary, MetaKeyUpdatedAt, MetaKeyModeration, MetaKeyModerationReason, MetaKeySignature, MetaKeyExpiresAt, MetaKeyPlaintext, MetaKeyTier},
 	}
 }
 
@@ -61,6 +121,57 @@ func (m Metadata) Get(k MetaKey) string {
 	return m.data[k]
 }
 
+// Add adds v to the values of the list-valued key k iff it
+// isn't a systemKey and v is not already a value of k. The
+// values are stored escaped and separated by a comma in one
+// value so Get returns e.g. "invoice,paid". List-valued keys
+// should only be modified using Add and Remove.
+func (m Metadata) Add(k MetaKey, v string) {
+	values := m.Values(k)
+	if v == "" || slices.Contains(values, v) {
+		return
+	}
+	m.Set(k, joinValues(append(values, v)))
+}
+
+// Remove removes v from the values of the list-valued key
+// k. The key is deleted if it has no values left.
+func (m Metadata) Remove(k MetaKey, v string) {
+	values := m.Values(k)
+	i := slices.Index(values, v)
+	if i < 0 {
+		return
+	}
+	values = slices.Delete(values, i, i+1)
+	if len(values) == 0 {
+		m.Del(k)
+		return
+	}
+	m.Set(k, joinValues(values))
+}
+
+// Values returns the values of the list-valued key k.
+func (m Metadata) Values(k MetaKey) []string {
+	if !m.Has(k) {
+		return nil
+	}
+	values := strings.Split(m.Get(k), valueSeparator)
+	for i, v := range values {
+		if unescaped, err := url.QueryUnescape(v); err == nil {
+			values[i] = unescaped
+		}
+	}
+	return values
+}
+
+func joinValues(values []string) string {
+	escaped := make([]string, 0, len(values))
+	for _, v := range values {
+		escaped = append(escaped, url.QueryEscape(v))
+	}
+	return strings.Join(escaped, valueSeparator)
+}
+
 func (m Metadata) Del(k MetaKey) {
 	if m.isSystemMetaKey(k) {
 		return
@@ -92,6 +203,12 @@ func (m Metadata) set(k MetaKey, v string) {
 	m.data[k] = v
 }
 
+// del is intended for internal usage where
+// system MetaKeys can be deleted.
+func (m Metadata) del(k MetaKey) {
+	delete(m.data, k)
+}
+
 func (m Metadata) UserDefinedPairs() map[MetaKey]string {
 	res := make(map[MetaKey]string)
 	for k, v := range m.data {
@@ -102,45 +219,166 @@ func (m Metadata) UserDefinedPairs() map[MetaKey]string {
 	return res
 }
 
+// Marshal encodes the meta data as the protobuf
+// message Metadata. See schema/metadata.proto.
 func (m Metadata) Marshal() ([]byte, error) {
-	var buf bytes.Buffer
-	err := gob.NewEncoder(&buf).Encode(m.data)
-	return buf.Bytes(), err
+	return CodecProtobuf.Marshal(m.data)
 }
 
+// Unmarshal decodes the meta data encoded by Marshal.
+// Meta data encoded using gob is still readable.
 func (m *Metadata) Unmarshal(data []byte) error {
-	r := bytes.NewReader(data)
-	return gob.NewDecoder(r).Decode(&m.data)
+	pairs, err := decodePairs(CodecProtobuf, data)
+	if err != nil {
+		return err
+	}
+	m.data = pairs
+	return nil
+}
+
+// ToMap returns a copy of all meta data
+// including the system meta data.
+func (m Metadata) ToMap() map[MetaKey]string {
+	return maps.Clone(m.data)
 }
 
-func (m *Metadata) Compare(md *Metadata, act action) bool {
-	if act == Or {
-		return m.or(md)
+// FromMap replaces the meta data with a copy of mp
+// including the system meta data. It is the inverse
+// of ToMap.
+func (m *Metadata) FromMap(mp map[MetaKey]string) {
+	if m.systemKeys == nil {
+		*m = *NewMetadata()
+	}
+	m.data = maps.Clone(mp)
+	if m.data == nil {
+		m.data = make(map[MetaKey]string)
 	}
-	return m.and(md)
 }
 
-func (m Metadata) or(md *Metadata) bool {
-	for k, v := range m.data {
-		ok, _ := regexp.MatchString(metaPattern(v), md.Get(k))
-		if md.Has(k) && ok {
-			return true
-		}
+// MarshalJSON encodes the meta data as a JSON object
+// of strings. Unlike Encode the values are not escaped.
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.data)
+}
 
+// UnmarshalJSON decodes the JSON object
+// created by MarshalJSON into the meta data.
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	mp := make(map[MetaKey]string)
+	if err := json.Unmarshal(data, &mp); err != nil {
+		return err
 	}
-	return false
+	m.FromMap(mp)
+	return nil
 }
 
-func (m Metadata) and(md *Metadata) bool {
-	for k, v := range m.data {
-		if !md.Has(k) {
-			return false
-		}
-		if ok, _ := regexp.MatchString(metaPattern(v), md.Get(k)); !ok {
-			return false
+// Compare reports if the meta data is matching the expression e.
+func (m *Metadata) Compare(e Expr) bool {
+	return e.eval(m)
+}
+
+// matches reports if the meta data is matching the condition.
+func (m Metadata) matches(c Condition) bool {
+	if !m.Has(c.Key) {
+		return false
+	}
+	v := m.Get(c.Key)
+	if slices.Contains(timeKeys, c.Key) && c.Op != OpMatch {
+		return matchesTime(v, c)
+	}
+	switch c.Op {
+	case OpEq:
+		return v == c.Value
+	case OpNe:
+		return v != c.Value
+	case OpGt:
+		return compareValues(v, c.Value) > 0
+	case OpLt:
+		return compareValues(v, c.Value) < 0
+	case OpContains:
+		return strings.Contains(v, c.Value)
+	case OpPrefix:
+		return strings.HasPrefix(v, c.Value)
+	case OpMatch:
+		ok, _ := regexp.MatchString(metaPattern(c.Value), v)
+		return ok
+	case OpGlob:
+		ok, _ := path.Match(c.Value, v)
+		return ok
+	case OpHas:
+		return slices.Contains(m.Values(c.Key), c.Value)
+	default:
+		return false
+	}
+}
+
+// matchesTime is like matches but compares the values
+// of time keys always as times. If one of the values is
+// not a valid time the condition is not matching.
+func matchesTime(v string, c Condition) bool {
+	t, err := parseTime(v)
+	if err != nil {
+		return false
+	}
+	ct, err := parseTime(c.Value)
+	if err != nil {
+		return false
+	}
+	switch c.Op {
+	case OpEq:
+		return t.Equal(ct)
+	case OpNe:
+		return !t.Equal(ct)
+	case OpGt:
+		return t.After(ct)
+	case OpLt:
+		return t.Before(ct)
+	default:
+		return false
+	}
+}
+
+// compareMetaValues compares the values of the key k like
+// compareValues but values of time keys are always compared
+// as times. Invalid times are ordered before valid ones.
+func compareMetaValues(k MetaKey, a, b string) int {
+	if !slices.Contains(timeKeys, k) {
+		return compareValues(a, b)
+	}
+	at, errA := parseTime(a)
+	bt, errB := parseTime(b)
+	switch {
+	case errA != nil && errB != nil:
+		return 0
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return at.Compare(bt)
+}
+
+// compareValues compares a and b as numbers if both are
+// numbers, as times if both are RFC3339 timestamps and as
+// strings otherwise. The result is like strings.Compare.
+func compareValues(a, b string) int {
+	af, errA := strconv.ParseFloat(a, 64)
+	bf, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case af < bf:
+			return -1
+		case af > bf:
+			return 1
 		}
+		return 0
+	}
+	at, errA := parseTime(a)
+	bt, errB := parseTime(b)
+	if errA == nil && errB == nil {
+		return at.Compare(bt)
 	}
-	return true
+	return strings.Compare(a, b)
 }
 
 func (m Metadata) isEmpty() bool {
@@ -148,5 +386,5 @@ func (m Metadata) isEmpty() bool {
 }
 
 func metaPattern(pattern string) string {
-	return fmt.Sprintf("^%s$", pattern)
+	return fmt.Sprintf("^(?:%s)$", pattern)
 }
@@ -0,0 +1,58 @@
+package objst
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestMetadataJSON(t *testing.T) {
+	meta := NewMetadata()
+	meta.set(MetaKeyID, tEnv.owner())
+	meta.Set("query", "a=b&c d")
+	meta.Add(MetaKeyTags, "x,y")
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	var got Metadata
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Error(err)
+		return
+	}
+	if diff := cmp.Diff(meta.ToMap(), got.ToMap()); diff != "" {
+		t.Fatalf("meta data changed by the JSON round trip: %s", diff)
+	}
+	if got.Get("query") != "a=b&c d" {
+		t.Fatalf("value should not be escaped. Got: %s", got.Get("query"))
+	}
+	if !got.isSystemMetaKey(MetaKeyID) {
+		t.Fatalf("unmarshaled meta data should know the system keys")
+	}
+	if err := json.Unmarshal([]byte(`{"foo": 1}`), &got); err == nil {
+		t.Fatalf("values which aren't strings should be rejected")
+	}
+}
+
+func TestMetadataMap(t *testing.T) {
+	meta := NewMetadata()
+	meta.set(MetaKeyOwner, tEnv.owner())
+	meta.Set("foo", "bar")
+	mp := meta.ToMap()
+	mp["foo"] = "baz"
+	if meta.Get("foo") != "bar" {
+		t.Fatalf("ToMap should return a copy")
+	}
+	other := NewMetadata()
+	other.FromMap(mp)
+	if other.Get(MetaKeyOwner) != meta.Get(MetaKeyOwner) || other.Get("foo") != "baz" {
+		t.Fatalf("FromMap should set all pairs. Got: %v", other.ToMap())
+	}
+	other.FromMap(nil)
+	other.Set("foo", "bar")
+	if other.Get("foo") != "bar" || other.Has(MetaKeyOwner) {
+		t.Fatalf("FromMap should replace the meta data. Got: %v", other.ToMap())
+	}
+}
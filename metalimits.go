@@ -0,0 +1,60 @@
+package objst
+
+import (
+	"fmt"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// MetaLimits are the limits of the user defined meta data of
+// an object which prevent clients from using the meta data as
+// a secondary payload store. Limits which are not positive
+// are not enforced.
+type MetaLimits struct {
+	// MaxKeys is the maximum number of keys.
+	MaxKeys int
+	// MaxKeyLen is the maximum length of a key in bytes.
+	MaxKeyLen int
+	// MaxValueLen is the maximum length of a value in bytes.
+	MaxValueLen int
+	// MaxBytes is the maximum sum of the
+	// lengths of all keys and values.
+	MaxBytes int
+}
+
+func DefaultMetaLimits() MetaLimits {
+	return MetaLimits{
+		MaxKeys:     128,
+		MaxKeyLen:   256,
+		MaxValueLen: 4 << 10,
+		MaxBytes:    16 << 10,
+	}
+}
+
+// check returns an error wrapping ErrTooManyMetaKeys,
+// ErrMetaKeyTooLong, ErrMetaValueTooLong or ErrMetaTooLarge
+// if the user defined meta data exceeds one of the limits.
+func (l MetaLimits) check(meta *Metadata) error {
+	pairs := meta.UserDefinedPairs()
+	if l.MaxKeys > 0 && len(pairs) > l.MaxKeys {
+		return fmt.Errorf("%w: %d keys exceed the limit of %d", ErrTooManyMetaKeys, len(pairs), l.MaxKeys)
+	}
+	keys := maps.Keys(pairs)
+	slices.Sort(keys)
+	size := 0
+	for _, k := range keys {
+		v := pairs[k]
+		if l.MaxKeyLen > 0 && len(k) > l.MaxKeyLen {
+			return fmt.Errorf("%w: %d bytes of %.32s... exceed the limit of %d", ErrMetaKeyTooLong, len(k), k, l.MaxKeyLen)
+		}
+		if l.MaxValueLen > 0 && len(v) > l.MaxValueLen {
+			return fmt.Errorf("%w: %d bytes of the value of %s exceed the limit of %d", ErrMetaValueTooLong, len(v), k, l.MaxValueLen)
+		}
+		size += len(k) + len(v)
+	}
+	if l.MaxBytes > 0 && size > l.MaxBytes {
+		return fmt.Errorf("%w: %d bytes exceed the limit of %d", ErrMetaTooLarge, size, l.MaxBytes)
+	}
+	return nil
+}
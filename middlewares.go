@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/naivary/objst/random"
 )
@@ -29,3 +30,16 @@ func assureOwner(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// rejectWhenFrozen responds with 503 and the Retry-After
+// header if the bucket is frozen for maintenance.
+func (h *HTTPHandler) rejectWhenFrozen(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.bucket.IsFrozen() {
+			w.Header().Set(headerRetryAfter, strconv.Itoa(int(h.opts.RetryAfter.Seconds())))
+			http.Error(w, ErrBucketFrozen.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,130 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// MigrateOptions are the options of Migrate.
+type MigrateOptions struct {
+	// Source are the options the old bucket is opened with
+	// e.g. its encryption key or the cipher of its payloads.
+	Source BucketOptions
+
+	// Target are the options of the new bucket e.g. its
+	// Layout, Codec, PayloadCompression or Cipher.
+	Target BucketOptions
+
+	// OnObject is called after every migrated object.
+	OnObject func(id string)
+}
+
+// Migrate rewrites the bucket at oldPath into a new bucket at newPath
+// using the current formats and the options of the target. The old
+// bucket may use the separate or the single layout and any format
+// written by older versions e.g. gob encoded meta data or objects
+// without a recorded size. It is opened read-only and not modified.
+//
+// Every object is copied with its id, name, owner and user defined
+// meta data like MoveObject. The payloads are verified against their
+// checksum before and after they are written. The key-value entries
+// of the owners are copied as well. The new bucket is removed if the
+// migration fails. It returns the number of migrated objects.
+func Migrate(oldPath, newPath string, opts MigrateOptions) (int, error) {
+	if _, err := os.Stat(newPath); err == nil {
+		return 0, fmt.Errorf("%w: %s", ErrMigrationTarget, newPath)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	src, err := OpenBucketWithRecovery(oldPath, opts.Source, RecoveryReadOnly)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Shutdown()
+	dst, err := openBucket(newPath, opts.Target, false)
+	if err != nil {
+		return 0, err
+	}
+	n, err := migrate(src, dst, opts.OnObject)
+	if serr := dst.Shutdown(); err == nil {
+		err = serr
+	}
+	if err != nil {
+		return n, errors.Join(err, os.RemoveAll(newPath))
+	}
+	return n, nil
+}
+
+func migrate(src, dst *Bucket, onObject func(id string)) (int, error) {
+	n := 0
+	err := src.forEachID(context.Background(), func(id string) error {
+		if err := migrateObject(src, dst, id); err != nil {
+			return fmt.Errorf("migrating %s: %w", id, err)
+		}
+		n++
+		if onObject != nil {
+			onObject(id)
+		}
+		return nil
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, migrateKV(src, dst)
+}
+
+func migrateObject(src, dst *Bucket, id string) error {
+	meta, err := src.GetMeta(id)
+	if err != nil {
+		return err
+	}
+	pl, err := src.readPayload(meta)
+	if err != nil {
+		return err
+	}
+	// objects of older versions don't record their checksum
+	if meta.Has(MetaKeyChecksum) && checksum(pl) != meta.Get(MetaKeyChecksum) {
+		return ErrChecksumMismatch
+	}
+	obj := &Object{
+		meta:      NewMetadata(),
+		pl:        bytes.NewBuffer(pl),
+		isMutable: true,
+	}
+	obj.meta.set(MetaKeyID, id)
+	obj.meta.set(MetaKeyName, meta.Get(MetaKeyName))
+	obj.meta.set(MetaKeyOwner, meta.Get(MetaKeyOwner))
+	obj.meta.Merge(meta.UserDefinedPairs())
+	if err := dst.CreateWithOptions(obj, CreateOptions{ChunkSize: chunkSizeOf(meta)}); err != nil {
+		return err
+	}
+	return dst.Verify(id)
+}
+
+// migrateKV copies the key-value entries of all owners.
+func migrateKV(src, dst *Bucket) error {
+	wb := dst.name.NewWriteBatch()
+	defer wb.Cancel()
+	prefix := []byte(kvPrefix)
+	err := src.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			v, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := wb.Set(it.Item().KeyCopy(nil), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return wb.Flush()
+}
@@ -0,0 +1,98 @@
+package objst
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrate(t *testing.T) {
+	src := NewDefaultBucketOptions()
+	src.Logger = nil
+	src.Layout = LayoutSeparate
+	src.Codec = CodecGob
+	old, err := NewBucket(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(old.BasePath)
+	objs := tEnv.nObj(3)
+	for _, o := range objs {
+		if err := old.Create(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kvOwner := objs[0].Owner()
+	if err := old.KV(kvOwner).Set("theme", []byte("dark")); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	target := src
+	target.Layout = LayoutSingle
+	target.Codec = CodecProtobuf
+	newPath := filepath.Join(t.TempDir(), "migrated")
+	n, err := Migrate(old.BasePath, newPath, MigrateOptions{Source: src, Target: target})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(objs) {
+		t.Fatalf("all objects should be migrated. Got: %d", n)
+	}
+	if _, err := Migrate(old.BasePath, newPath, MigrateOptions{Source: src, Target: target}); !errors.Is(err, ErrMigrationTarget) {
+		t.Fatalf("existing target should be rejected. Got: %v", err)
+	}
+	b, err := OpenBucket(newPath, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Shutdown()
+	if !isDir(filepath.Join(newPath, storeDir)) {
+		t.Fatal("migrated bucket should use the single layout")
+	}
+	for _, o := range objs {
+		got, err := b.GetByName(o.Name(), o.Owner())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.ID() != o.ID() || !bytes.Equal(got.Payload(), o.Payload()) {
+			t.Fatalf("migrated object %s differs from the original", o.ID())
+		}
+	}
+	v, err := b.KV(kvOwner).Get("theme")
+	if err != nil || string(v) != "dark" {
+		t.Fatalf("key-value entries should be migrated. Got: %q %v", v, err)
+	}
+}
+
+func TestMigrateCorrupted(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	old, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(old.BasePath)
+	o := tEnv.obj()
+	if err := old.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	tampered := payloadEntries(o.ID(), chunkSizeOf(o.meta), tEnv.payload(10))
+	if err := old.insertPayload(tampered); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	newPath := filepath.Join(t.TempDir(), "migrated")
+	if _, err := Migrate(old.BasePath, newPath, MigrateOptions{Source: opts, Target: opts}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("corrupted object should fail the migration. Got: %v", err)
+	}
+	if _, err := os.Stat(newPath); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("target of a failed migration should be removed. Got: %v", err)
+	}
+}
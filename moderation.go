@@ -0,0 +1,226 @@
+package objst
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/exp/slog"
+)
+
+// ModerationState is the review state of an object. Objects
+// which are not moderated have an empty state. Objects which
+// are pending or rejected cannot be downloaded using the
+// /objst/read endpoint.
+type ModerationState string
+
+const (
+	// ModerationPending means that the object
+	// is quarantined until it is reviewed.
+	ModerationPending ModerationState = "pending"
+	// ModerationApproved means that the object was
+	// reviewed and can be downloaded.
+	ModerationApproved ModerationState = "approved"
+	// ModerationRejected means that the object was
+	// reviewed and must not be downloaded.
+	ModerationRejected ModerationState = "rejected"
+)
+
+// moderationTransitions are the allowed transitions of the
+// moderation state. Approved objects can be quarantined
+// again e.g. if they were reported.
+var moderationTransitions = map[ModerationState][]ModerationState{
+	"":                 {ModerationPending},
+	ModerationPending:  {ModerationApproved, ModerationRejected},
+	ModerationApproved: {ModerationPending},
+}
+
+func (s ModerationState) canTransitionTo(to ModerationState) bool {
+	for _, allowed := range moderationTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ModerationEvent is a transition of the
+// moderation state of an object.
+type ModerationEvent struct {
+	ID     string          `json:"id"`
+	Owner  string          `json:"owner"`
+	From   ModerationState `json:"from"`
+	To     ModerationState `json:"to"`
+	Reason string          `json:"reason,omitempty"`
+	At     time.Time       `json:"at"`
+}
+
+// ModerationState returns the review state of the object.
+func (o Object) ModerationState() ModerationState {
+	return ModerationState(o.meta.Get(MetaKeyModeration))
+}
+
+// isDownloadable reports whether the payload of
+// the object can be served to the public.
+func (o Object) isDownloadable() bool {
+	s := o.ModerationState()
+	return s == "" || s == ModerationApproved
+}
+
+// Quarantine sets the moderation state of the object
+// to ModerationPending until it is approved or rejected.
+func (b *Bucket) Quarantine(id string) error {
+	return b.moderate("", id, ModerationPending, "")
+}
+
+// Approve approves the pending object.
+func (b *Bucket) Approve(id string) error {
+	return b.moderate("", id, ModerationApproved, "")
+}
+
+// Reject rejects the pending object. The reason is
+// stored in the meta data of the object.
+func (b *Bucket) Reject(id, reason string) error {
+	return b.moderate("", id, ModerationRejected, reason)
+}
+
+// moderate changes the moderation state of the object to the given
+// state and notifies OnModeration. ErrInvalidModeration is returned
+// if the transition is not allowed. The actor is recorded in the
+// audit log.
+func (b *Bucket) moderate(actor, id string, to ModerationState, reason string) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	defer b.finishOp(b.startOp("Moderate", id))
+	var event ModerationEvent
+	err = b.updateMeta(id, func(meta *Metadata) error {
+		from := ModerationState(meta.Get(MetaKeyModeration))
+		if !from.canTransitionTo(to) {
+			return fmt.Errorf("%w: %q to %q", ErrInvalidModeration, from, to)
+		}
+		meta.set(MetaKeyModeration, string(to))
+		meta.del(MetaKeyModerationReason)
+		meta.actor = actor
+		if reason != "" {
+			meta.set(MetaKeyModerationReason, reason)
+		}
+		event = ModerationEvent{
+			ID:     id,
+			Owner:  meta.Get(MetaKeyOwner),
+			From:   from,
+			To:     to,
+			Reason: reason,
+			At:     time.Now(),
+		}
+		return nil
+	})
+	if err != nil {
+		return notFound(err)
+	}
+	b.notifyModeration(event)
+	return nil
+}
+
+// notifyModeration calls OnModeration with the event if set.
+func (b *Bucket) notifyModeration(e ModerationEvent) {
+	if b.opts.OnModeration != nil {
+		b.opts.OnModeration(e)
+	}
+}
+
+// ModerationQueue returns the objects in the moderation state
+// ordered by their creation time, oldest first. An empty owner
+// returns the objects of all owners.
+func (h *HTTPHandler) ModerationQueue(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	state := ModerationState(r.URL.Query().Get("state"))
+	if state == "" {
+		state = ModerationPending
+	}
+	if state != ModerationPending && state != ModerationApproved && state != ModerationRejected {
+		http.Error(w, "unknown moderation state: "+string(state), http.StatusBadRequest)
+		return
+	}
+	q := NewQuery().Filter(Eq(MetaKeyModeration, string(state))).OrderBy(MetaKeyCreatedAt, Asc)
+	if owner := r.URL.Query().Get("owner"); owner != "" {
+		q = q.Owner(owner)
+	}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			http.Error(w, "limit is not a number", http.StatusBadRequest)
+			return
+		}
+		q = q.Limit(n)
+	}
+	objs, err := h.bucket.Execute(q)
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	infos := make([]*ObjectInfo, 0, len(objs))
+	for _, obj := range objs {
+		infos = append(infos, obj.Info())
+	}
+	w.Header().Set(headerContentType, contentTypeJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(infos); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// rejectModel is the optional body of the reject endpoint.
+type rejectModel struct {
+	Reason string `json:"reason"`
+}
+
+// Approve approves the pending object with the id.
+func (h *HTTPHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, func(id string) error {
+		return h.bucket.moderate(actorOf(r), id, ModerationApproved, "")
+	})
+}
+
+// Reject rejects the pending object with the id. The
+// reason is read from the optional JSON body.
+func (h *HTTPHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	var body rejectModel
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "request body is not a valid reject object", http.StatusBadRequest)
+			return
+		}
+	}
+	h.moderate(w, r, func(id string) error {
+		return h.bucket.moderate(actorOf(r), id, ModerationRejected, body.Reason)
+	})
+}
+
+func (h *HTTPHandler) moderate(w http.ResponseWriter, r *http.Request, fn func(id string) error) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	id := chi.URLParam(r, "id")
+	err := fn(id)
+	if err == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+	switch {
+	case errors.Is(err, ErrObjectNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, ErrInvalidModeration):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "couldn't moderate the object with the id: "+id, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,146 @@
+package objst
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestModeration(t *testing.T) {
+	events := make([]ModerationEvent, 0)
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.OnModeration = func(e ModerationEvent) {
+		events = append(events, e)
+	}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	o := tEnv.obj()
+	if err := b.CreateWithOptions(o, CreateOptions{Pending: true}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.Approve(tEnv.owner()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("approving a missing object should fail. Got: %v", err)
+	}
+	if err := b.Reject(o.ID(), "spam"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.Approve(o.ID()); !errors.Is(err, ErrInvalidModeration) {
+		t.Fatalf("approving a rejected object should fail. Got: %v", err)
+	}
+	got, err := b.GetByID(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got.ModerationState() != ModerationRejected || got.GetMetaKey(MetaKeyModerationReason) != "spam" {
+		t.Fatalf("object should be rejected for spam. Got: %s %s", got.ModerationState(), got.GetMetaKey(MetaKeyModerationReason))
+	}
+	want := []ModerationEvent{
+		{ID: o.ID(), Owner: o.Owner(), To: ModerationPending},
+		{ID: o.ID(), Owner: o.Owner(), From: ModerationPending, To: ModerationRejected, Reason: "spam"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("number of events is not %d. Got: %+v", len(want), events)
+	}
+	for i, e := range events {
+		e.At = want[i].At
+		if e != want[i] {
+			t.Fatalf("event is not the same. Got: %+v. Expected: %+v", e, want[i])
+		}
+	}
+}
+
+func TestHTTPModeration(t *testing.T) {
+	owner := tEnv.owner()
+	opts := DefaultHTTPHandlerOptions()
+	opts.ModerateUploads = true
+	opts.IsModerator = func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("X-Moderator") == "" {
+				http.Error(w, "not a moderator", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	h := NewHTTPHandler(tEnv.b, opts)
+	serve := func(r *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		ctx := context.WithValue(r.Context(), CtxKeyOwner, owner)
+		h.ServeHTTP(w, r.WithContext(ctx))
+		return w
+	}
+	target, err := url.JoinPath(tEnv.ts.URL, route, "upload")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	r, err := tEnv.newUploadRequest(target, nil, opts.FormKey, "testdata/images/2500KB.jpg")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	w := serve(r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	info := ObjectInfo{}
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Error(err)
+		return
+	}
+	if info.Moderation != ModerationPending {
+		t.Fatalf("uploaded object should be pending. Got: %q", info.Moderation)
+	}
+	read := httptest.NewRequest(http.MethodGet, "/objst/read/"+info.ID, nil)
+	if w := serve(read); w.Code != http.StatusForbidden {
+		t.Fatalf("pending object should not be downloadable. Got: %d", w.Code)
+	}
+
+	queue := httptest.NewRequest(http.MethodGet, "/objst/moderation/?owner="+owner, nil)
+	if w := serve(queue); w.Code != http.StatusForbidden {
+		t.Fatalf("moderation should require a moderator. Got: %d", w.Code)
+	}
+	queue.Header.Set("X-Moderator", "true")
+	w = serve(queue)
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	infos := make([]ObjectInfo, 0)
+	if err := json.NewDecoder(w.Body).Decode(&infos); err != nil {
+		t.Error(err)
+		return
+	}
+	if len(infos) != 1 || infos[0].ID != info.ID {
+		t.Fatalf("queue should contain the uploaded object. Got: %+v", infos)
+	}
+
+	approve := httptest.NewRequest(http.MethodPost, "/objst/moderation/"+info.ID+"/approve", nil)
+	approve.Header.Set("X-Moderator", "true")
+	if w := serve(approve); w.Code != http.StatusNoContent {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	reject := httptest.NewRequest(http.MethodPost, "/objst/moderation/"+info.ID+"/reject", strings.NewReader(`{"reason":"spam"}`))
+	reject.Header.Set("X-Moderator", "true")
+	if w := serve(reject); w.Code != http.StatusConflict {
+		t.Fatalf("rejecting an approved object should conflict. Got: %d", w.Code)
+	}
+	if w := serve(httptest.NewRequest(http.MethodGet, "/objst/read/"+info.ID, nil)); w.Code != http.StatusOK {
+		t.Fatalf("approved object should be downloadable. Got: %d", w.Code)
+	}
+}
@@ -0,0 +1,169 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// journalPrefix is the prefix of the move journal entries in
+// the name store of the source bucket. Object names cannot
+// start with "#" so the entries never collide with a name.
+const journalPrefix = "#journal/move/"
+
+const (
+	// moveCopying means the object may be partially
+	// copied to the destination bucket.
+	moveCopying = "copying"
+	// moveVerified means the copy in the destination
+	// bucket is verified and the source can be deleted.
+	moveVerified = "verified"
+)
+
+// moveEntry is an entry of the move journal.
+type moveEntry struct {
+	// Dst is the BasePath of the destination bucket
+	Dst   string `json:"dst"`
+	State string `json:"state"`
+}
+
+func journalKey(id string) []byte {
+	return []byte(journalPrefix + id)
+}
+
+// MoveObject moves the object with the given id from src to dst
+// keeping its id, name, owner and user defined meta data. The
+// source is only deleted after the copy in dst is verified. Every
+// step is recorded in a journal of src so an interrupted move can
+// be completed or rolled back by RecoverMoves without losing or
+// duplicating the object.
+func MoveObject(src, dst *Bucket, id string) error {
+	if src == dst {
+		return ErrSameBucket
+	}
+	done, err := src.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	meta, err := src.GetMeta(id)
+	if err != nil {
+		return err
+	}
+	if err := checkRetention(meta); err != nil {
+		return err
+	}
+	pl, err := src.readPayload(meta)
+	if err != nil {
+		return err
+	}
+	if err := src.writeJournal(id, moveEntry{Dst: dst.BasePath, State: moveCopying}); err != nil {
+		return err
+	}
+	obj := &Object{
+		meta:      NewMetadata(),
+		pl:        bytes.NewBuffer(pl),
+		isMutable: true,
+	}
+	obj.meta.set(MetaKeyID, id)
+	obj.meta.set(MetaKeyName, meta.Get(MetaKeyName))
+	obj.meta.set(MetaKeyOwner, meta.Get(MetaKeyOwner))
+	obj.meta.Merge(meta.UserDefinedPairs())
+	if err := dst.CreateWithOptions(obj, CreateOptions{ChunkSize: chunkSizeOf(meta)}); err != nil {
+		// nothing was copied so the move can be forgotten
+		return errors.Join(err, src.deleteJournal(id))
+	}
+	if err := dst.Verify(id); err != nil {
+		return err
+	}
+	if obj.Checksum() != meta.Get(MetaKeyChecksum) {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, id)
+	}
+	if err := src.writeJournal(id, moveEntry{Dst: dst.BasePath, State: moveVerified}); err != nil {
+		return err
+	}
+	// the object may have been deleted concurrently
+	if err := src.deleteObject(meta); err != nil && !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	return src.deleteJournal(id)
+}
+
+// RecoverMoves completes or rolls back all interrupted moves
+// from src to dst. Verified moves are completed by deleting the
+// object in src. All other moves are rolled back by deleting the
+// possibly partial copy in dst. The ids of the objects whose move
+// was completed are returned.
+func RecoverMoves(ctx context.Context, src, dst *Bucket) ([]string, error) {
+	entries, err := src.journal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	moved := make([]string, 0)
+	for id, e := range entries {
+		if e.Dst != dst.BasePath {
+			continue
+		}
+		if e.State == moveVerified {
+			if err := src.DeleteByID(id); err != nil && !errors.Is(err, ErrObjectNotFound) {
+				return moved, err
+			}
+			moved = append(moved, id)
+		} else if err := dst.DeleteByID(id); errors.Is(err, ErrObjectNotFound) {
+			// the meta data is written last so
+			// a partial copy has no meta data.
+			if err := dst.deletePayload(id); err != nil {
+				return moved, err
+			}
+		} else if err != nil {
+			return moved, err
+		}
+		if err := src.deleteJournal(id); err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+func (b *Bucket) writeJournal(id string, e moveEntry) error {
+	data, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Set(journalKey(id), data)
+	})
+}
+
+func (b *Bucket) deleteJournal(id string) error {
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Delete(journalKey(id))
+	})
+}
+
+// journal returns all entries of the move journal by id.
+func (b *Bucket) journal(ctx context.Context) (map[string]moveEntry, error) {
+	entries := make(map[string]moveEntry)
+	prefix := []byte(journalPrefix)
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var e moveEntry
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &e)
+			}); err != nil {
+				return err
+			}
+			entries[strings.TrimPrefix(string(it.Item().Key()), journalPrefix)] = e
+		}
+		return nil
+	})
+	return entries, err
+}
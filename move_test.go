@@ -0,0 +1,108 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func newMoveBuckets(t *testing.T) (*Bucket, *Bucket) {
+	buckets := make([]*Bucket, 0, 2)
+	for i := 0; i < 2; i++ {
+		opts := NewDefaultBucketOptions()
+		opts.Logger = nil
+		b, err := NewBucket(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			b.Shutdown()
+			os.RemoveAll(b.BasePath)
+		})
+		buckets = append(buckets, b)
+	}
+	return buckets[0], buckets[1]
+}
+
+func TestMoveObject(t *testing.T) {
+	src, dst := newMoveBuckets(t)
+	o := tEnv.obj()
+	o.SetMetaKey("foo", "bar")
+	if err := src.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := MoveObject(src, dst, o.ID()); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := src.GetByID(o.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("object should be removed from the source. Got: %v", err)
+	}
+	oG, err := dst.GetByName(o.Name(), o.Owner())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if oG.ID() != o.ID() || string(oG.Payload()) != string(o.Payload()) || oG.GetMetaKey("foo") != "bar" {
+		t.Fatalf("moved object is not equal to the source object")
+	}
+	if err := MoveObject(src, src, o.ID()); !errors.Is(err, ErrSameBucket) {
+		t.Fatalf("moving into the same bucket should fail. Got: %v", err)
+	}
+	entries, err := src.journal(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(entries) != 0 {
+		t.Fatalf("journal should be empty after the move. Got: %v", entries)
+	}
+}
+
+func TestRecoverMoves(t *testing.T) {
+	src, dst := newMoveBuckets(t)
+	verified, copying := tEnv.obj(), tEnv.obj()
+	for _, o := range []*Object{verified, copying} {
+		if err := src.Create(o); err != nil {
+			t.Error(err)
+			return
+		}
+		// simulate a crash after the object was copied
+		cp := &Object{meta: NewMetadata(), pl: o.pl, isMutable: true}
+		cp.meta.set(MetaKeyID, o.ID())
+		cp.meta.set(MetaKeyName, o.Name())
+		cp.meta.set(MetaKeyOwner, o.Owner())
+		cp.meta.Merge(o.meta.UserDefinedPairs())
+		if err := dst.Create(cp); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err := src.writeJournal(verified.ID(), moveEntry{Dst: dst.BasePath, State: moveVerified}); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := src.writeJournal(copying.ID(), moveEntry{Dst: dst.BasePath, State: moveCopying}); err != nil {
+		t.Error(err)
+		return
+	}
+	moved, err := RecoverMoves(context.Background(), src, dst)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(moved) != 1 || moved[0] != verified.ID() {
+		t.Fatalf("only the verified move should be completed. Got: %v", moved)
+	}
+	if _, err := src.GetByID(verified.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("verified move should delete the source. Got: %v", err)
+	}
+	if _, err := dst.GetByID(copying.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("unverified move should delete the copy. Got: %v", err)
+	}
+	if _, err := src.GetByID(copying.ID()); err != nil {
+		t.Fatalf("unverified move should keep the source: %v", err)
+	}
+}
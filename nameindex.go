@@ -0,0 +1,202 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// nameRepairQueueSize is the number of name entries which can
+// be queued for repair. Repairs are dropped if the queue is
+// full and have to be done using RebuildNameIndex.
+const nameRepairQueueSize = 64
+
+// nameReservationRetries is the number of attempts to reserve
+// the names of objects if the reservation conflicts with a
+// concurrent write of the same name. See reserveNames.
+const nameReservationRetries = 3
+
+// deleteRetries is the number of attempts to delete an entry
+// if the deletion conflicts with a concurrent write of it.
+// See deleteExisting.
+const deleteRetries = 3
+
+// scanName scans the meta data of all objects for the object
+// with the name and owner. It is the fallback of resolveName
+// if the name entry of the object is missing.
+func (b *Bucket) scanName(name, owner string) (*Metadata, error) {
+	var found *Metadata
+	err := b.meta.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			meta := NewMetadata()
+			err := it.Item().Value(func(val []byte) error {
+				return b.unmarshalMeta(meta, val)
+			})
+			if err != nil {
+				return err
+			}
+			if meta.Get(MetaKeyName) == name && meta.Get(MetaKeyOwner) == owner {
+				found = meta
+				return nil
+			}
+		}
+		return ErrStoreKeyNotFound
+	})
+	return found, notFound(err)
+}
+
+// isNameEntryOf reports whether the name entry with the id and
+// generation is referencing the object described by meta. Entries
+// created before generations existed are matching every generation.
+func isNameEntryOf(id, gen string, meta *Metadata) bool {
+	return id == meta.Get(MetaKeyID) && (gen == "" || gen == meta.Get(MetaKeyGeneration))
+}
+
+// queueNameRepair queues the repair of the name entry of the
+// object without blocking the read which detected the damage.
+func (b *Bucket) queueNameRepair(meta *Metadata) {
+	select {
+	case b.nameRepairs <- meta:
+	default:
+		if b.opts.Logger != nil {
+			b.opts.Logger.Warningf("name repair queue is full. Dropping the repair of %s", meta.Get(MetaKeyID))
+		}
+	}
+}
+
+// runNameRepair repairs the queued name
+// entries until the context is canceled.
+func (b *Bucket) runNameRepair(ctx context.Context) {
+	defer b.nameRepairWg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case meta := <-b.nameRepairs:
+			if err := b.repairName(meta); err != nil && b.opts.Logger != nil {
+				b.opts.Logger.Warningf("repairing the name of %s failed: %v", meta.Get(MetaKeyID), err)
+			}
+		}
+	}
+}
+
+// repairName inserts the name entry of the object described by
+// meta iff meta is still describing the current incarnation.
+func (b *Bucket) repairName(meta *Metadata) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	defer b.finishOp(b.startOp("RepairName", meta.Get(MetaKeyID)))
+	cur, err := b.GetMeta(meta.Get(MetaKeyID))
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cur.Get(MetaKeyGeneration) != meta.Get(MetaKeyGeneration) {
+		return nil
+	}
+	return b.insertName(cur)
+}
+
+// RebuildNameIndex inserts the missing name entries of all
+// objects and removes the name entries of objects which don't
+// exist anymore. It returns the number of repaired entries.
+func (b *Bucket) RebuildNameIndex(ctx context.Context) (int, error) {
+	done, err := b.beginWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+	defer b.finishOp(b.startOp("RebuildNameIndex"))
+	missing := make([]*Metadata, 0)
+	err = b.meta.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			meta := NewMetadata()
+			err := it.Item().Value(func(val []byte) error {
+				return b.unmarshalMeta(meta, val)
+			})
+			if err != nil {
+				return err
+			}
+			id, gen, err := b.resolveEntry(meta.Get(MetaKeyName), meta.Get(MetaKeyOwner))
+			if err != nil && !errors.Is(err, ErrObjectNotFound) {
+				return err
+			}
+			if err != nil || !isNameEntryOf(id, gen, meta) {
+				missing = append(missing, meta)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, meta := range missing {
+		if err := b.insertName(meta); err != nil {
+			return 0, err
+		}
+	}
+	dangling, err := b.danglingNames(ctx)
+	if err != nil {
+		return len(missing), err
+	}
+	err = b.name.Update(func(txn *storeTxn) error {
+		for _, k := range dangling {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return len(missing), err
+	}
+	return len(missing) + len(dangling), nil
+}
+
+// danglingNames returns the keys of the name
+// entries referencing objects which don't exist.
+func (b *Bucket) danglingNames(ctx context.Context) ([][]byte, error) {
+	dangling := make([][]byte, 0)
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			// all other entries of the name store are prefixed with #
+			if strings.HasPrefix(string(item.Key()), "#") {
+				continue
+			}
+			var id, gen string
+			if err := item.Value(func(val []byte) error {
+				id, gen = parseNameValue(val)
+				return nil
+			}); err != nil {
+				return err
+			}
+			meta, err := b.GetMeta(id)
+			if err != nil && !errors.Is(err, ErrObjectNotFound) {
+				return err
+			}
+			if err != nil || !isNameEntryOf(id, gen, meta) || string(item.Key()) != b.nameFormat(meta.Get(MetaKeyName), meta.Get(MetaKeyOwner)) {
+				dangling = append(dangling, item.KeyCopy(nil))
+			}
+		}
+		return nil
+	})
+	return dangling, err
+}
@@ -0,0 +1,83 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNameFallback(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	deleteName := func(name, owner string) {
+		t.Helper()
+		err := b.name.Update(func(txn *storeTxn) error {
+			return txn.Delete([]byte(b.nameFormat(name, owner)))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	deleteName(o.Name(), o.Owner())
+	got, err := b.GetByName(o.Name(), o.Owner())
+	if err != nil {
+		t.Fatalf("object should be found by scanning the meta data. Got: %v", err)
+	}
+	if got.ID() != o.ID() {
+		t.Fatalf("wrong object found. Got: %s. Expected: %s", got.ID(), o.ID())
+	}
+	// the name entry is repaired in the background
+	_, _, err = b.resolveEntry(o.Name(), o.Owner())
+	for deadline := time.Now().Add(time.Second); err != nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		_, _, err = b.resolveEntry(o.Name(), o.Owner())
+	}
+	if err != nil {
+		t.Fatalf("name entry should be repaired. Got: %v", err)
+	}
+	if _, err := b.GetByName(tEnv.name(), o.Owner()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("unknown name should not be found. Got: %v", err)
+	}
+
+	deleteName(o.Name(), o.Owner())
+	dangling := tEnv.name()
+	err = b.name.Update(func(txn *storeTxn) error {
+		return txn.Set([]byte(b.nameFormat(dangling, o.Owner())), []byte(tEnv.owner()))
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	n, err := b.RebuildNameIndex(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n != 2 {
+		t.Fatalf("one missing and one dangling entry should be repaired. Got: %d", n)
+	}
+	if id, _, err := b.resolveEntry(o.Name(), o.Owner()); err != nil || id != o.ID() {
+		t.Fatalf("missing name entry should be inserted. Got: %s, %v", id, err)
+	}
+	if _, _, err := b.resolveEntry(dangling, o.Owner()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("dangling name entry should be removed. Got: %v", err)
+	}
+	if n, err := b.RebuildNameIndex(context.Background()); err != nil || n != 0 {
+		t.Fatalf("intact name index should not be changed. Got: %d, %v", n, err)
+	}
+}
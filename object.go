@@ -2,11 +2,15 @@ package objst
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"io"
 	"mime"
 	"path/filepath"
+	"strconv"
 
 	"github.com/google/uuid"
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 const (
@@ -23,6 +27,16 @@ type Object struct {
 	pl *bytes.Buffer
 	// current reading psotion
 	pos int64
+	// bucket the payload is loaded from
+	// if the object is lazily loaded.
+	bucket *Bucket
+	// isLoaded reports whether the payload of
+	// a lazily loaded object has been loaded.
+	isLoaded bool
+	// staged is true while the payload is loaded from
+	// the staging area of an upload which isn't
+	// committed yet and has no generation to check.
+	staged bool
 	// An object is only mutable if it
 	// isn't already inserted into the store
 	// or wasn't retrieved from the store.
@@ -48,6 +62,22 @@ func NewObject(name, owner string) (*Object, error) {
 	return o, nil
 }
 
+// NewObjectWithID is like NewObject but uses the given id
+// instead of a random one which allows to create reproducible
+// objects e.g. for fixtures. The id has to be a UUID and the
+// caller is responsible for its uniqueness.
+func NewObjectWithID(id, name, owner string) (*Object, error) {
+	if id == "" || !isValidUUID(id) {
+		return nil, ErrInvalidObjectID
+	}
+	o, err := NewObject(name, owner)
+	if err != nil {
+		return nil, err
+	}
+	o.meta.set(MetaKeyID, id)
+	return o, nil
+}
+
 func (o Object) ID() string {
 	return o.meta.Get(MetaKeyID)
 }
@@ -60,10 +90,70 @@ func (o Object) Owner() string {
 	return o.meta.Get(MetaKeyOwner)
 }
 
-func (o Object) Payload() []byte {
+// Payload returns the payload of the object and loads it
+// if the object is lazily loaded. Use Load to handle errors
+// while loading the payload.
+func (o *Object) Payload() []byte {
+	o.Load()
 	return o.pl.Bytes()
 }
 
+// Load loads the payload of an object returned by a query
+// from the bucket. Objects returned by a query are loaded
+// lazily on the first access of the payload e.g. by Read.
+// Load is a no-op for objects which are already loaded.
+func (o *Object) Load() error {
+	if o.bucket == nil || o.isLoaded {
+		return nil
+	}
+	if !o.staged {
+		if err := o.bucket.checkGeneration(o.meta); err != nil {
+			return err
+		}
+	}
+	pl, err := o.bucket.readPayload(o.meta)
+	if err != nil {
+		return err
+	}
+	o.pl = bytes.NewBuffer(pl)
+	o.isLoaded = true
+	return nil
+}
+
+// Release frees the payload of a lazily loaded object
+// which is loaded again on the next access. It is a
+// no-op for objects which weren't returned by a query.
+func (o *Object) Release() {
+	if o.bucket == nil {
+		return
+	}
+	o.pl = new(bytes.Buffer)
+	o.isLoaded = false
+}
+
+// Size returns the size of the payload in bytes. The size
+// of inserted objects is recorded in the meta data so it is
+// also known for objects returned by Bucket.Head.
+func (o Object) Size() int64 {
+	if size, err := strconv.ParseInt(o.meta.Get(MetaKeySize), 10, 64); err == nil {
+		return size
+	}
+	return int64(o.pl.Len())
+}
+
+// ETag returns the entity tag of the object which
+// is only set after the object has been inserted
+// into the store.
+func (o Object) ETag() string {
+	return o.meta.Get(MetaKeyETag)
+}
+
+// Checksum returns the hex encoded SHA-256 of the
+// payload which is calculated on insertion.
+func (o Object) Checksum() string {
+	return o.meta.Get(MetaKeyChecksum)
+}
+
 // SetMeta will set the given key and
 // value as a meta data key-pair, over-
 // writing any key-pair which has been
@@ -79,6 +169,18 @@ func (o *Object) GetMetaKey(k MetaKey) string {
 	return o.meta.Get(k)
 }
 
+// AddMetaValue adds v to the values of
+// the list-valued key k. See Metadata.Add.
+func (o *Object) AddMetaValue(k MetaKey, v string) {
+	o.meta.Add(k, v)
+}
+
+// MetaValues returns the values of the list-valued
+// key k e.g. the tags of the object.
+func (o *Object) MetaValues(k MetaKey) []string {
+	return o.meta.Values(k)
+}
+
 // HasMetaKey check if the meta data of the
 // object contains the given key.
 func (o *Object) HasMetaKey(k MetaKey) bool {
@@ -93,8 +195,69 @@ func (o *Object) BinaryUnmarshaler(data []byte) error {
 	return o.Unmarshal(data)
 }
 
+// MarshalBinary encodes the meta data and the payload of the object
+// as the protobuf message Object. See schema/metadata.proto.
+func (o *Object) MarshalBinary() ([]byte, error) {
+	if err := o.Load(); err != nil {
+		return nil, err
+	}
+	meta, err := o.meta.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendBytes(data, meta)
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendBytes(data, o.pl.Bytes())
+	return data, nil
+}
+
+// UnmarshalBinary decodes an object encoded by MarshalBinary. The
+// object is mutable so it can be inserted into another bucket.
+func (o *Object) UnmarshalBinary(data []byte) error {
+	meta := NewMetadata()
+	var pl []byte
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if typ != protowire.BytesType || (num != 1 && num != 2) {
+			n = protowire.ConsumeFieldValue(num, typ, data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			data = data[n:]
+			continue
+		}
+		v, n := protowire.ConsumeBytes(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if num == 2 {
+			pl = v
+			continue
+		}
+		if err := meta.Unmarshal(v); err != nil {
+			return err
+		}
+	}
+	*o = Object{
+		meta:      meta,
+		pl:        bytes.NewBuffer(bytes.Clone(pl)),
+		isMutable: true,
+	}
+	return nil
+}
+
 func (o *Object) Marshal() ([]byte, error) {
-	return o.Payload(), nil
+	if err := o.Load(); err != nil {
+		return nil, err
+	}
+	return o.pl.Bytes(), nil
 }
 
 func (o *Object) Unmarshal(data []byte) error {
@@ -102,17 +265,23 @@ func (o *Object) Unmarshal(data []byte) error {
 	return nil
 }
 
-func (o Object) isValid() error {
-	if !o.HasMetaKey(MetaKeyContentType) {
-		return ErrContentTypeNotExist
-	}
+func (o Object) isValid(validateOwner OwnerValidator) error {
 	if len(o.pl.Bytes()) == 0 {
 		return ErrEmptyPayload
 	}
+	return o.isValidMeta(validateOwner)
+}
+
+// isValidMeta validates the meta data of the
+// object without taking the payload into account.
+func (o Object) isValidMeta(validateOwner OwnerValidator) error {
+	if !o.HasMetaKey(MetaKeyContentType) {
+		return ErrContentTypeNotExist
+	}
 	if !isValidObjectName(o.Name()) {
 		return ErrInvalidNamePattern
 	}
-	return nil
+	return validateOwner(o.Owner())
 }
 
 // Write will write the data iff the object is mutable.
@@ -126,10 +295,18 @@ func (o *Object) Write(p []byte) (int, error) {
 	return o.pl.Write(p)
 }
 
+// WriteTo writes the payload starting at the current
+// reading position to w and advances the position.
 func (o *Object) WriteTo(w io.Writer) (int64, error) {
-	buf := bytes.NewBuffer(o.Payload())
-	defer buf.Reset()
-	return buf.WriteTo(w)
+	if err := o.Load(); err != nil {
+		return 0, err
+	}
+	if o.pos >= int64(o.pl.Len()) {
+		return 0, nil
+	}
+	n, err := w.Write(o.pl.Bytes()[o.pos:])
+	o.pos += int64(n)
+	return int64(n), err
 }
 
 func (o *Object) ReadFrom(r io.Reader) (int64, error) {
@@ -137,28 +314,119 @@ func (o *Object) ReadFrom(r io.Reader) (int64, error) {
 }
 
 func (o *Object) Read(b []byte) (int, error) {
-	n, err := bytes.NewReader(o.pl.Bytes()[o.pos:]).Read(b)
-	if err != nil {
+	if err := o.Load(); err != nil {
 		return 0, err
 	}
+	if o.pos >= int64(o.pl.Len()) {
+		return 0, io.EOF
+	}
+	n := copy(b, o.pl.Bytes()[o.pos:])
 	o.pos += int64(n)
 	return n, nil
 }
 
-// Reset resets the payload
+// ReadAt reads len(b) bytes of the payload starting at
+// the offset off. It doesn't change the reading position
+// so it can be used concurrently to Read.
+func (o *Object) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, ErrNegativeOffset
+	}
+	if err := o.Load(); err != nil {
+		return 0, err
+	}
+	if off >= int64(o.pl.Len()) {
+		return 0, io.EOF
+	}
+	n := copy(b, o.pl.Bytes()[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Seek sets the reading position of Read and WriteTo
+// relative to whence. Seeking beyond the end of the
+// payload is allowed and results in io.EOF on Read.
+func (o *Object) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = o.pos + offset
+	case io.SeekEnd:
+		if err := o.Load(); err != nil {
+			return 0, err
+		}
+		pos = int64(o.pl.Len()) + offset
+	default:
+		return 0, ErrInvalidWhence
+	}
+	if pos < 0 {
+		return 0, ErrNegativeOffset
+	}
+	o.pos = pos
+	return pos, nil
+}
+
+// Reset truncates the payload and makes the object mutable
+// again while keeping its id, name, owner and user defined meta
+// data. The system meta data describing the stored payload like
+// the checksum or etag is removed so the object can be reused
+// e.g. to write a new payload.
 func (o *Object) Reset() {
-	o.pl.Reset()
+	o.pl = new(bytes.Buffer)
+	o.pos = 0
+	o.bucket = nil
+	o.isLoaded = false
+	o.isMutable = true
+	for _, k := range o.meta.systemKeys {
+		if k != MetaKeyID && k != MetaKeyName && k != MetaKeyOwner {
+			o.meta.del(k)
+		}
+	}
 }
 
-func (o *Object) ToModel() *objectModel {
-	return &objectModel{
-		ID:       o.ID(),
-		Name:     o.Name(),
-		Owner:    o.Owner(),
-		Metadata: o.meta.UserDefinedPairs(),
+// Clone returns a mutable deep copy of the object with a new
+// id. The name, owner, user defined meta data and payload are
+// copied but no system meta data of the stored object. The
+// payload of lazily loaded objects is loaded. Use Load before
+// to handle errors while loading the payload.
+func (o *Object) Clone() *Object {
+	meta := NewMetadata()
+	for k, v := range o.meta.UserDefinedPairs() {
+		meta.set(k, v)
+	}
+	meta.set(MetaKeyID, uuid.NewString())
+	meta.set(MetaKeyName, o.Name())
+	meta.set(MetaKeyOwner, o.Owner())
+	return &Object{
+		meta:      meta,
+		pl:        bytes.NewBuffer(bytes.Clone(o.Payload())),
+		isMutable: true,
 	}
 }
 
+// etag calculates the entity tag of the object based
+// on the checksum of the payload and the user defined
+// meta data. Any change to one of them results in a
+// different etag.
+func (o Object) etag() string {
+	userMeta := NewMetadata()
+	userMeta.Merge(o.meta.UserDefinedPairs())
+	h := sha256.New()
+	h.Write([]byte(o.meta.Get(MetaKeyChecksum)))
+	h.Write([]byte(userMeta.Encode()))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checksum returns the hex encoded SHA-256 of the data.
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 func (o *Object) markAsImmutable() {
 	o.isMutable = false
 }
@@ -3,10 +3,15 @@ package objst
 import (
 	"bytes"
 	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/naivary/objst/random"
+	"golang.org/x/exp/slices"
 )
 
 func TestWrite(t *testing.T) {
@@ -54,15 +59,50 @@ func TestWriteTo(t *testing.T) {
 func TestNamePattern(t *testing.T) {
 	o1 := tEnv.obj()
 	o1.meta.set(MetaKeyName, "invalidname")
-	if err := o1.isValid(); !errors.Is(err, ErrInvalidNamePattern) {
+	if err := o1.isValid(ValidateUUIDOwner); !errors.Is(err, ErrInvalidNamePattern) {
 		t.Fatalf("the name '%s' should not be valid.", o1.Name())
 	}
 	o1.meta.set(MetaKeyName, "valid/name/musti.jpg")
-	if err := o1.isValid(); err != nil {
+	if err := o1.isValid(ValidateUUIDOwner); err != nil {
 		t.Fatalf("the name %s should be valid.", o1.Name())
 	}
 }
 
+func TestNewObjectWithID(t *testing.T) {
+	id := tEnv.owner()
+	o, err := NewObjectWithID(id, tEnv.name(), tEnv.owner())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if o.ID() != id {
+		t.Fatalf("id is not the given one. Got: %s. Expected: %s", o.ID(), id)
+	}
+	if _, err := NewObjectWithID("invalid", tEnv.name(), tEnv.owner()); !errors.Is(err, ErrInvalidObjectID) {
+		t.Fatalf("id which is not a uuid should be invalid. Got: %v", err)
+	}
+}
+
+func TestMetaValues(t *testing.T) {
+	o := tEnv.obj()
+	for _, tag := range []string{"invoice", "paid, late", "invoice"} {
+		o.AddMetaValue(MetaKeyTags, tag)
+	}
+	want := []string{"invoice", "paid, late"}
+	if got := o.MetaValues(MetaKeyTags); !slices.Equal(got, want) {
+		t.Fatalf("tags are not the same. Got: %q. Expected: %q", got, want)
+	}
+	o.meta.Remove(MetaKeyTags, "invoice")
+	o.meta.Remove(MetaKeyTags, "paid, late")
+	if o.HasMetaKey(MetaKeyTags) {
+		t.Fatalf("key without values should be removed. Got: %q", o.GetMetaKey(MetaKeyTags))
+	}
+	o.AddMetaValue(MetaKeyID, "tag")
+	if slices.Contains(o.MetaValues(MetaKeyID), "tag") {
+		t.Fatalf("values should not be added to system keys")
+	}
+}
+
 func TestWrite_largeFile(t *testing.T) {
 	o1 := tEnv.emptyObj()
 	image, err := os.ReadFile("./testdata/images/2500KB.jpg")
@@ -99,3 +139,104 @@ func BenchmarkWriteLargeFile(b *testing.B) {
 	}
 	b.ReportAllocs()
 }
+
+func TestReadAtSeek(t *testing.T) {
+	o := tEnv.emptyObj()
+	pl := []byte("0123456789")
+	o.Write(pl)
+
+	d := make([]byte, 4)
+	if n, err := o.ReadAt(d, 8); n != 2 || !errors.Is(err, io.EOF) {
+		t.Fatalf("short read should return io.EOF. Got: %d, %v", n, err)
+	}
+	if _, err := o.ReadAt(d, 3); err != nil || string(d) != "3456" {
+		t.Fatalf("read at offset is not correct. Got: %s, %v", d, err)
+	}
+	if _, err := o.ReadAt(d, -1); !errors.Is(err, ErrNegativeOffset) {
+		t.Fatalf("negative offset should be invalid. Got: %v", err)
+	}
+
+	if pos, err := o.Seek(-3, io.SeekEnd); err != nil || pos != 7 {
+		t.Fatalf("seek from the end is not correct. Got: %d, %v", pos, err)
+	}
+	rest, err := io.ReadAll(o)
+	if err != nil || string(rest) != "789" {
+		t.Fatalf("read after seek is not correct. Got: %s, %v", rest, err)
+	}
+	if _, err := o.Seek(2, io.SeekStart); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := o.Seek(1, io.SeekCurrent); err != nil {
+		t.Error(err)
+		return
+	}
+	var buf bytes.Buffer
+	if _, err := o.WriteTo(&buf); err != nil || buf.String() != "3456789" {
+		t.Fatalf("write to should start at the position. Got: %s, %v", buf.String(), err)
+	}
+	if _, err := o.Seek(-1, io.SeekStart); !errors.Is(err, ErrNegativeOffset) {
+		t.Fatalf("negative position should be invalid. Got: %v", err)
+	}
+	if _, err := o.Seek(0, 3); !errors.Is(err, ErrInvalidWhence) {
+		t.Fatalf("whence should be invalid. Got: %v", err)
+	}
+
+	// ranges are served by the stdlib
+	o.Seek(0, io.SeekStart)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Range", "bytes=2-4")
+	rec := httptest.NewRecorder()
+	http.ServeContent(rec, req, o.Name(), time.Time{}, o)
+	if rec.Code != http.StatusPartialContent || rec.Body.String() != "234" {
+		t.Fatalf("range should be served. Got: %d, %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCloneReset(t *testing.T) {
+	o := tEnv.obj()
+	o.SetMetaKey("foo", "bar")
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	got, err := tEnv.b.GetByID(o.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	clone := got.Clone()
+	if clone.ID() == o.ID() || clone.Name() != o.Name() || clone.Owner() != o.Owner() {
+		t.Fatalf("clone should have a new id and the same name and owner")
+	}
+	if clone.GetMetaKey("foo") != "bar" || clone.Checksum() != "" || clone.ETag() != "" {
+		t.Fatalf("clone should only have the user defined meta data. Got: %s", clone.meta.Encode())
+	}
+	if !bytes.Equal(clone.Payload(), o.Payload()) {
+		t.Fatalf("payload should be copied")
+	}
+	if _, err := clone.Write([]byte("more")); err != nil {
+		t.Fatalf("clone should be mutable. Got: %v", err)
+	}
+	if bytes.Equal(clone.Payload(), got.Payload()) {
+		t.Fatalf("payload of the clone should not be shared")
+	}
+	if err := tEnv.b.DeleteByID(o.ID()); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.Create(clone); err != nil {
+		t.Fatalf("clone should be insertable. Got: %v", err)
+	}
+
+	got.Reset()
+	if got.ID() != o.ID() || got.GetMetaKey("foo") != "bar" {
+		t.Fatalf("reset should keep the identity and user defined meta data")
+	}
+	if got.Size() != 0 || got.Checksum() != "" || got.meta.Has(MetaKeyCreatedAt) {
+		t.Fatalf("reset should remove the payload and its system meta data. Got: %s", got.meta.Encode())
+	}
+	if _, err := got.Write([]byte("new")); err != nil {
+		t.Fatalf("reset object should be mutable. Got: %v", err)
+	}
+}
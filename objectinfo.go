@@ -0,0 +1,50 @@
+package objst
+
+import (
+	"time"
+)
+
+// ObjectInfoVersion is the version of the ObjectInfo schema.
+// It is increased on every incompatible change of the schema.
+const ObjectInfoVersion = 1
+
+// ObjectInfo is the JSON representation of an object
+// without its payload. It is used by all HTTP endpoints
+// returning information about objects so clients can rely
+// on stable field names.
+type ObjectInfo struct {
+	Version     int                `json:"version"`
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Owner       string             `json:"owner"`
+	Size        int64              `json:"size"`
+	ContentType string             `json:"contentType"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+	Checksum    string             `json:"checksum"`
+	UserMeta    map[MetaKey]string `json:"userMeta"`
+	// Moderation is the review state of the object
+	// and empty if the object is not moderated.
+	Moderation ModerationState `json:"moderation,omitempty"`
+}
+
+// Info returns the ObjectInfo of the object.
+func (o *Object) Info() *ObjectInfo {
+	// objects created before the times were recorded
+	// are having a zero CreatedAt and UpdatedAt.
+	createdAt, _ := parseTime(o.meta.Get(MetaKeyCreatedAt))
+	updatedAt, _ := parseTime(o.meta.Get(MetaKeyUpdatedAt))
+	return &ObjectInfo{
+		Version:     ObjectInfoVersion,
+		ID:          o.ID(),
+		Name:        o.Name(),
+		Owner:       o.Owner(),
+		Size:        o.Size(),
+		ContentType: o.meta.Get(MetaKeyContentType),
+		CreatedAt:   createdAt,
+		UpdatedAt:   updatedAt,
+		Checksum:    o.Checksum(),
+		UserMeta:    o.meta.UserDefinedPairs(),
+		Moderation:  o.ModerationState(),
+	}
+}
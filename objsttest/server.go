@@ -0,0 +1,274 @@
+package objsttest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/naivary/objst"
+)
+
+// OwnerHeader is the header used by the Client to set the owner
+// of a request. The server started by StartServer injects its
+// value as objst.CtxKeyOwner into the request context. It must
+// only be used for testing.
+const OwnerHeader = "X-Objst-Owner"
+
+// ServerOptions are the options of the server started by StartServer.
+type ServerOptions struct {
+	// Bucket are the options of the bucket. By default
+	// objst.NewDefaultBucketOptions without a logger
+	// are used.
+	Bucket *objst.BucketOptions
+
+	// Handler are the options of the HTTP handler. By
+	// default objst.DefaultHTTPHandlerOptions are used.
+	Handler *objst.HTTPHandlerOptions
+}
+
+// Server is a bucket served by the objst HTTP
+// handler on a random port of the loopback interface.
+type Server struct {
+	// URL is the base url of the server
+	// e.g. http://127.0.0.1:41231.
+	URL     string
+	Bucket  *objst.Bucket
+	Handler *objst.HTTPHandler
+
+	opts objst.HTTPHandlerOptions
+	ts   *httptest.Server
+}
+
+// StartServer starts a fully configured bucket and HTTP handler
+// for integration tests. The server is shut down and all data of
+// the bucket is removed when the test and all its subtests are
+// completed.
+func StartServer(t testing.TB, opts ServerOptions) *Server {
+	t.Helper()
+	bucketOpts := objst.NewDefaultBucketOptions()
+	bucketOpts.Logger = nil
+	if opts.Bucket != nil {
+		bucketOpts = *opts.Bucket
+	}
+	handlerOpts := objst.DefaultHTTPHandlerOptions()
+	if opts.Handler != nil {
+		handlerOpts = *opts.Handler
+	}
+	b, err := objst.NewBucket(bucketOpts)
+	if err != nil {
+		t.Fatalf("objsttest: couldn't create the bucket: %v", err)
+	}
+	h := objst.NewHTTPHandler(b, handlerOpts)
+	s := &Server{
+		Bucket:  b,
+		Handler: h,
+		opts:    handlerOpts,
+		ts:      httptest.NewServer(injectOwner(h)),
+	}
+	s.URL = s.ts.URL
+	t.Cleanup(func() {
+		s.ts.Close()
+		if err := b.Shutdown(); err != nil {
+			t.Errorf("objsttest: couldn't shutdown the bucket: %v", err)
+		}
+		if err := os.RemoveAll(b.BasePath); err != nil {
+			t.Errorf("objsttest: couldn't remove the bucket: %v", err)
+		}
+	})
+	return s
+}
+
+// Client returns a client sending all
+// requests on behalf of the owner.
+func (s *Server) Client(owner string) *Client {
+	return &Client{
+		URL:     s.URL,
+		Owner:   owner,
+		FormKey: s.opts.FormKey,
+		HTTP:    s.ts.Client(),
+	}
+}
+
+// injectOwner injects the value of the OwnerHeader
+// as objst.CtxKeyOwner into the request context.
+func injectOwner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		owner := r.Header.Get(OwnerHeader)
+		if owner == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := context.WithValue(r.Context(), objst.CtxKeyOwner, owner)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// StatusError is returned by the Client if the
+// server responds with an unexpected status code.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("objsttest: unexpected status code %d: %s", e.Code, e.Body)
+}
+
+// Client is a client of the objst HTTP endpoints.
+type Client struct {
+	// URL is the base url of the server.
+	URL string
+	// Owner is sent in the OwnerHeader
+	// of all requests if not empty.
+	Owner string
+	// FormKey is the key of the file
+	// in the multipart form of uploads.
+	FormKey string
+	HTTP    *http.Client
+}
+
+// Upload uploads the payload as an object with the given name
+// and content type. Like browsers the name is reduced to its
+// last element by the multipart form.
+func (c *Client) Upload(name, contentType string, payload []byte) (*objst.ObjectInfo, error) {
+	return c.UploadWithFields(name, contentType, payload, nil)
+}
+
+// UploadWithFields is like Upload but adds the fields to
+// the multipart form e.g. a signed upload policy.
+func (c *Client) UploadWithFields(name, contentType string, payload []byte, fields map[string]string) (*objst.ObjectInfo, error) {
+	body := new(bytes.Buffer)
+	w := multipart.NewWriter(body)
+	file, err := w.CreateFormFile(c.FormKey, name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(payload); err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		if err := w.WriteField(objst.MetaKeyContentType.String(), contentType); err != nil {
+			return nil, err
+		}
+	}
+	for k, v := range fields {
+		if err := w.WriteField(k, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	target, err := url.JoinPath(c.URL, "objst", "upload")
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	info := &objst.ObjectInfo{}
+	if err := c.do(req, http.StatusOK, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Get returns the ObjectInfo of the object with the given id.
+func (c *Client) Get(id string) (*objst.ObjectInfo, error) {
+	req, err := c.newRequest(http.MethodGet, nil, "objst", id)
+	if err != nil {
+		return nil, err
+	}
+	info := &objst.ObjectInfo{}
+	if err := c.do(req, http.StatusOK, info); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// Read returns the payload of the object with the given id.
+func (c *Client) Read(id string) ([]byte, error) {
+	req, err := c.newRequest(http.MethodGet, nil, "objst", "read", id)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := c.do(req, http.StatusOK, buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UpdateMeta merges the patch into the user defined meta data of
+// the object with the given id. If etag is not empty the update
+// is only applied iff the etag of the object matches.
+func (c *Client) UpdateMeta(id, etag string, patch map[objst.MetaKey]string) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	req, err := c.newRequest(http.MethodPatch, bytes.NewReader(data), "objst", id)
+	if err != nil {
+		return err
+	}
+	if etag != "" {
+		req.Header.Set("If-Match", strconv.Quote(etag))
+	}
+	return c.do(req, http.StatusNoContent, nil)
+}
+
+// Delete deletes the object with the given id.
+func (c *Client) Delete(id string) error {
+	req, err := c.newRequest(http.MethodDelete, nil, "objst", id)
+	if err != nil {
+		return err
+	}
+	return c.do(req, http.StatusNoContent, nil)
+}
+
+func (c *Client) newRequest(method string, body io.Reader, elem ...string) (*http.Request, error) {
+	target, err := url.JoinPath(c.URL, elem...)
+	if err != nil {
+		return nil, err
+	}
+	return http.NewRequest(method, target, body)
+}
+
+// do sends the request and decodes the response body into v which
+// is either a *bytes.Buffer receiving the raw body, a value the JSON
+// body is decoded into or nil. A *StatusError is returned if the
+// status code of the response is not the expected one.
+func (c *Client) do(req *http.Request, code int, v any) error {
+	if c.Owner != "" {
+		req.Header.Set(OwnerHeader, c.Owner)
+	}
+	res, err := c.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != code {
+		body, _ := io.ReadAll(res.Body)
+		return &StatusError{Code: res.StatusCode, Body: string(bytes.TrimSpace(body))}
+	}
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case *bytes.Buffer:
+		_, err := io.Copy(v, res.Body)
+		return err
+	default:
+		return json.NewDecoder(res.Body).Decode(v)
+	}
+}
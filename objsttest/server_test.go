@@ -0,0 +1,57 @@
+package objsttest
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/naivary/objst"
+)
+
+func TestStartServer(t *testing.T) {
+	s := StartServer(t, ServerOptions{})
+	owner := uuid.NewString()
+	c := s.Client(owner)
+	payload := []byte("integration")
+	info, err := c.Upload("report.txt", "text/plain", payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Owner != owner || info.Name != "report.txt" || info.Size != int64(len(payload)) {
+		t.Fatalf("info of the uploaded object is not correct. Got: %+v", info)
+	}
+	got, err := c.Read(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("payload is not the same. Got: %s. Expected: %s", got, payload)
+	}
+	obj, err := s.Bucket.GetByID(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateMeta(info.ID, obj.ETag(), map[objst.MetaKey]string{"foo": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	err = c.UpdateMeta(info.ID, obj.ETag(), map[objst.MetaKey]string{"foo": "baz"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusPreconditionFailed {
+		t.Fatalf("update with a stale etag should fail. Got: %v", err)
+	}
+	info, err = c.Get(info.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.UserMeta["foo"] != "bar" {
+		t.Fatalf("meta data should be updated. Got: %v", info.UserMeta)
+	}
+	if err := c.Delete(info.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Bucket.GetByID(info.ID); !errors.Is(err, objst.ErrObjectNotFound) {
+		t.Fatalf("object should be deleted. Got: %v", err)
+	}
+}
@@ -0,0 +1,425 @@
+// Package oci distributes objects through OCI registries. A set of
+// objects e.g. the namespace of an owner or the result of a query is
+// packaged as an OCI artifact with one layer per object and can be
+// pushed to and pulled from any registry implementing the OCI
+// distribution specification.
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/naivary/objst"
+)
+
+const (
+	// ArtifactType is the artifact type of the manifest
+	// of a bundle of objects.
+	ArtifactType = "application/vnd.objst.bundle.v1"
+	// MediaTypeManifest is the media type of the manifest.
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	// MediaTypeEmpty is the media type of the empty config
+	// which is used because a bundle has no configuration.
+	MediaTypeEmpty = "application/vnd.oci.empty.v1+json"
+	// MediaTypeDefault is the media type of the layers of
+	// objects without a content type.
+	MediaTypeDefault = "application/octet-stream"
+
+	// AnnotationTitle is the annotation of a layer
+	// containing the name of the object.
+	AnnotationTitle = "org.opencontainers.image.title"
+	// AnnotationOwner is the annotation of a layer
+	// containing the owner of the object.
+	AnnotationOwner = "dev.objst.owner"
+	// AnnotationID is the annotation of a layer containing
+	// the id of the object in the bucket it was pushed from.
+	AnnotationID = "dev.objst.id"
+	// AnnotationMeta is the annotation of a layer containing the
+	// user defined meta data of the object encoded as JSON.
+	AnnotationMeta = "dev.objst.meta"
+)
+
+// emptyConfig is the content of the empty config.
+var emptyConfig = []byte("{}")
+
+var (
+	ErrInvalidReference = errors.New("reference must be in the format <registry>/<repository>[:<tag>|@<digest>]")
+	ErrUnexpectedStatus = errors.New("unexpected status code of the registry")
+	ErrDigestMismatch   = errors.New("digest of the content does not match")
+	ErrNotBundle        = errors.New("artifact is not a bundle of objects")
+	ErrEmptyBundle      = errors.New("bundle must contain at least one object")
+)
+
+// Descriptor describes a content addressable blob.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Manifest is the OCI image manifest of a bundle.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	ArtifactType  string            `json:"artifactType"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Reference identifies a manifest in a repository
+// of a registry either by a tag or a digest.
+type Reference struct {
+	Registry   string
+	Repository string
+	// Tag is the tag or digest of the manifest.
+	Tag string
+}
+
+// ParseReference parses a reference in the format
+// <registry>/<repository>[:<tag>|@<digest>]. The tag
+// defaults to latest.
+func ParseReference(s string) (Reference, error) {
+	registry, rest, ok := strings.Cut(s, "/")
+	if !ok || registry == "" || rest == "" {
+		return Reference{}, fmt.Errorf("%w: %s", ErrInvalidReference, s)
+	}
+	ref := Reference{Registry: registry, Repository: rest, Tag: "latest"}
+	if repo, digest, ok := strings.Cut(rest, "@"); ok {
+		ref.Repository, ref.Tag = repo, digest
+	} else if i := strings.LastIndex(rest, ":"); i > 0 {
+		ref.Repository, ref.Tag = rest[:i], rest[i+1:]
+	}
+	if ref.Repository == "" || ref.Tag == "" {
+		return Reference{}, fmt.Errorf("%w: %s", ErrInvalidReference, s)
+	}
+	return ref, nil
+}
+
+func (r Reference) String() string {
+	if strings.HasPrefix(r.Tag, "sha256:") {
+		return fmt.Sprintf("%s/%s@%s", r.Registry, r.Repository, r.Tag)
+	}
+	return fmt.Sprintf("%s/%s:%s", r.Registry, r.Repository, r.Tag)
+}
+
+type Options struct {
+	// Client is the http client used for all requests
+	// to the registry. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Username and Password are used for basic
+	// authentication if the username is set.
+	Username string
+	Password string
+	// Token is used as a bearer token if set.
+	Token string
+	// PlainHTTP connects to the registry
+	// without TLS e.g. for local registries.
+	PlainHTTP bool
+}
+
+// Client pushes and pulls bundles of objects.
+type Client struct {
+	opts Options
+}
+
+func NewClient(opts Options) *Client {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &Client{opts: opts}
+}
+
+// Push pushes the objects as a bundle to the reference and returns
+// the digest of the manifest. Blobs which are already present in
+// the repository are not uploaded again.
+func (c *Client) Push(ctx context.Context, ref string, objs []*objst.Object) (string, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return "", err
+	}
+	if len(objs) == 0 {
+		return "", ErrEmptyBundle
+	}
+	if err := c.pushBlob(ctx, r, emptyConfig); err != nil {
+		return "", err
+	}
+	m := Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeManifest,
+		ArtifactType:  ArtifactType,
+		Config:        Descriptor{MediaType: MediaTypeEmpty, Digest: digest(emptyConfig), Size: int64(len(emptyConfig))},
+		Layers:        make([]Descriptor, 0, len(objs)),
+	}
+	for _, obj := range objs {
+		layer, err := layerOf(obj)
+		if err != nil {
+			return "", err
+		}
+		if err := c.pushBlob(ctx, r, obj.Payload()); err != nil {
+			return "", err
+		}
+		m.Layers = append(m.Layers, layer)
+	}
+	data, err := json.Marshal(&m)
+	if err != nil {
+		return "", err
+	}
+	res, err := c.do(ctx, http.MethodPut, c.url(r, "manifests", r.Tag), MediaTypeManifest, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		return "", unexpectedStatus(res)
+	}
+	return digest(data), nil
+}
+
+// PushQuery pushes the objects matching the query as a bundle.
+func (c *Client) PushQuery(ctx context.Context, b *objst.Bucket, q *objst.Query, ref string) (string, error) {
+	objs, err := b.Execute(q)
+	if err != nil {
+		return "", err
+	}
+	return c.Push(ctx, ref, objs)
+}
+
+// PushNamespace pushes all objects of the owner as a bundle.
+func (c *Client) PushNamespace(ctx context.Context, b *objst.Bucket, owner, ref string) (string, error) {
+	return c.PushQuery(ctx, b, objst.NewQuery().Owner(owner), ref)
+}
+
+// Pull pulls the bundle of the reference and returns its objects.
+// The objects are new objects with random ids which can be inserted
+// into any bucket. The payload of every layer is verified against
+// its digest.
+func (c *Client) Pull(ctx context.Context, ref string) ([]*objst.Object, error) {
+	return c.pull(ctx, ref, "")
+}
+
+// PullInto pulls the bundle of the reference and inserts its objects
+// into the bucket. If owner is not empty the objects are owned by it
+// instead of their original owner.
+func (c *Client) PullInto(ctx context.Context, b *objst.Bucket, ref, owner string) ([]*objst.Object, error) {
+	objs, err := c.pull(ctx, ref, owner)
+	if err != nil {
+		return nil, err
+	}
+	return objs, b.BatchCreate(objs)
+}
+
+func (c *Client) pull(ctx context.Context, ref, owner string) ([]*objst.Object, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	m, err := c.manifest(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	objs := make([]*objst.Object, 0, len(m.Layers))
+	for _, layer := range m.Layers {
+		pl, err := c.blob(ctx, r, layer)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := objectOf(layer, pl, owner)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// manifest fetches the manifest of the reference
+// and verifies that it describes a bundle.
+func (c *Client) manifest(ctx context.Context, r Reference) (*Manifest, error) {
+	res, err := c.do(ctx, http.MethodGet, c.url(r, "manifests", r.Tag), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, unexpectedStatus(res)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(r.Tag, "sha256:") && digest(data) != r.Tag {
+		return nil, fmt.Errorf("%w: %s", ErrDigestMismatch, r)
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	if m.ArtifactType != ArtifactType {
+		return nil, fmt.Errorf("%w: %s", ErrNotBundle, r)
+	}
+	return m, nil
+}
+
+// blob fetches the blob of the descriptor
+// and verifies its size and digest.
+func (c *Client) blob(ctx context.Context, r Reference, desc Descriptor) ([]byte, error) {
+	res, err := c.do(ctx, http.MethodGet, c.url(r, "blobs", desc.Digest), "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, unexpectedStatus(res)
+	}
+	data, err := io.ReadAll(io.LimitReader(res.Body, desc.Size+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != desc.Size || digest(data) != desc.Digest {
+		return nil, fmt.Errorf("%w: %s", ErrDigestMismatch, desc.Digest)
+	}
+	return data, nil
+}
+
+// pushBlob uploads the data in a single request
+// if the blob doesn't exist in the repository.
+func (c *Client) pushBlob(ctx context.Context, r Reference, data []byte) error {
+	dgst := digest(data)
+	res, err := c.do(ctx, http.MethodHead, c.url(r, "blobs", dgst), "", nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode == http.StatusOK {
+		return nil
+	}
+	res, err = c.do(ctx, http.MethodPost, c.url(r, "blobs", "uploads/"), "", nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		return unexpectedStatus(res)
+	}
+	loc, err := res.Location()
+	if err != nil {
+		return err
+	}
+	q := loc.Query()
+	q.Set("digest", dgst)
+	loc.RawQuery = q.Encode()
+	res, err = c.do(ctx, http.MethodPut, loc.String(), MediaTypeDefault, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		return unexpectedStatus(res)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, method, u, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", MediaTypeManifest)
+	if c.opts.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.opts.Token)
+	} else if c.opts.Username != "" {
+		req.SetBasicAuth(c.opts.Username, c.opts.Password)
+	}
+	return c.opts.Client.Do(req)
+}
+
+// url returns the url of the endpoint of the
+// distribution API in the repository of r.
+func (c *Client) url(r Reference, endpoint, name string) string {
+	scheme := "https"
+	if c.opts.PlainHTTP {
+		scheme = "http"
+	}
+	u := url.URL{
+		Scheme: scheme,
+		Host:   r.Registry,
+		Path:   fmt.Sprintf("/v2/%s/%s/%s", r.Repository, endpoint, name),
+	}
+	return u.String()
+}
+
+// layerOf returns the descriptor of the layer containing the object.
+func layerOf(obj *objst.Object) (Descriptor, error) {
+	info := obj.Info()
+	meta, err := json.Marshal(info.UserMeta)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	mediaType := info.ContentType
+	if mediaType == "" {
+		mediaType = MediaTypeDefault
+	}
+	return Descriptor{
+		MediaType: mediaType,
+		Digest:    digest(obj.Payload()),
+		Size:      int64(len(obj.Payload())),
+		Annotations: map[string]string{
+			AnnotationTitle: info.Name,
+			AnnotationOwner: info.Owner,
+			AnnotationID:    info.ID,
+			AnnotationMeta:  string(meta),
+		},
+	}, nil
+}
+
+// objectOf returns the object contained in the layer. If owner is
+// not empty it is used instead of the owner of the layer.
+func objectOf(layer Descriptor, pl []byte, owner string) (*objst.Object, error) {
+	if owner == "" {
+		owner = layer.Annotations[AnnotationOwner]
+	}
+	obj, err := objst.NewObject(layer.Annotations[AnnotationTitle], owner)
+	if err != nil {
+		return nil, err
+	}
+	if meta := layer.Annotations[AnnotationMeta]; meta != "" {
+		pairs := make(map[objst.MetaKey]string)
+		if err := json.Unmarshal([]byte(meta), &pairs); err != nil {
+			return nil, err
+		}
+		for k, v := range pairs {
+			obj.SetMetaKey(k, v)
+		}
+	}
+	if layer.MediaType != MediaTypeDefault {
+		obj.SetMetaKey(objst.MetaKeyContentType, layer.MediaType)
+	}
+	if _, err := obj.Write(pl); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// digest returns the OCI digest of the data.
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func unexpectedStatus(res *http.Response) error {
+	return fmt.Errorf("%w: %s %s: %d", ErrUnexpectedStatus, res.Request.Method, res.Request.URL.Path, res.StatusCode)
+}
@@ -0,0 +1,191 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/naivary/objst"
+)
+
+// registry is an in-memory registry implementing the
+// parts of the distribution API used by the client.
+type registry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	uploads   int
+}
+
+func (reg *registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case strings.HasSuffix(path, "/blobs/uploads/") && r.Method == http.MethodPost:
+		w.Header().Set("Location", fmt.Sprintf("/v2/%supload-%d", path, reg.uploads))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(path, "/blobs/uploads/") && r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		reg.blobs[r.URL.Query().Get("digest")] = data
+		reg.uploads++
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		data, ok := reg.blobs[path[strings.LastIndex(path, "/")+1:]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case strings.Contains(path, "/manifests/") && r.Method == http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		reg.manifests[path] = data
+		repo, _, _ := strings.Cut(path, "/manifests/")
+		reg.manifests[repo+"/manifests/"+digest(data)] = data
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/manifests/"):
+		data, ok := reg.manifests[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", MediaTypeManifest)
+		w.Write(data)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newBucket(t *testing.T) *objst.Bucket {
+	t.Helper()
+	opts := objst.NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := objst.NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		b.Shutdown()
+		os.RemoveAll(b.BasePath)
+	})
+	return b
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref   string
+		want  Reference
+		isErr bool
+	}{
+		{ref: "localhost:5000/bundles/docs:v1", want: Reference{"localhost:5000", "bundles/docs", "v1"}},
+		{ref: "ghcr.io/objst/docs", want: Reference{"ghcr.io", "objst/docs", "latest"}},
+		{ref: "ghcr.io/docs@sha256:abc", want: Reference{"ghcr.io", "docs", "sha256:abc"}},
+		{ref: "docs", isErr: true},
+		{ref: "ghcr.io/docs:", isErr: true},
+	}
+	for _, tc := range tests {
+		got, err := ParseReference(tc.ref)
+		if tc.isErr {
+			if !errors.Is(err, ErrInvalidReference) {
+				t.Fatalf("%s should be invalid. Got: %v", tc.ref, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Fatalf("reference is not correct. Got: %+v. Expected: %+v", got, tc.want)
+		}
+	}
+}
+
+func TestPushPull(t *testing.T) {
+	reg := &registry{blobs: make(map[string][]byte), manifests: make(map[string][]byte)}
+	ts := httptest.NewServer(reg)
+	defer ts.Close()
+	c := NewClient(Options{PlainHTTP: true})
+	ctx := context.Background()
+	ref := strings.TrimPrefix(ts.URL, "http://") + "/bundles/docs:v1"
+
+	src := newBucket(t)
+	owner := uuid.NewString()
+	for i := 0; i < 3; i++ {
+		o, _ := objst.NewObject(fmt.Sprintf("doc_%d.txt", i), owner)
+		o.SetMetaKey("lang", "en")
+		o.Write([]byte(fmt.Sprintf("payload %d", i)))
+		if err := src.Create(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other, _ := objst.NewObject("other.txt", uuid.NewString())
+	other.Write([]byte("other"))
+	if err := src.Create(other); err != nil {
+		t.Fatal(err)
+	}
+
+	dgst, err := c.PushNamespace(ctx, src, owner, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// config and one blob per object
+	if reg.uploads != 4 {
+		t.Fatalf("4 blobs should be uploaded. Got: %d", reg.uploads)
+	}
+	if _, err := c.PushNamespace(ctx, src, owner, ref); err != nil {
+		t.Fatal(err)
+	}
+	if reg.uploads != 4 {
+		t.Fatalf("existing blobs should not be uploaded again. Got: %d", reg.uploads)
+	}
+
+	dst := newBucket(t)
+	newOwner := uuid.NewString()
+	objs, err := c.PullInto(ctx, dst, ref, newOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 3 {
+		t.Fatalf("3 objects should be pulled. Got: %d", len(objs))
+	}
+	for i := 0; i < 3; i++ {
+		got, err := dst.GetByName(fmt.Sprintf("doc_%d.txt", i), newOwner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Payload(), []byte(fmt.Sprintf("payload %d", i))) {
+			t.Fatalf("payload is not the same. Got: %s", got.Payload())
+		}
+		if got.GetMetaKey("lang") != "en" || got.GetMetaKey(objst.MetaKeyContentType) == "" {
+			t.Fatalf("meta data should be pulled. Got: %v", got.Info().UserMeta)
+		}
+	}
+
+	byDigest := strings.Replace(ref, ":v1", "@"+dgst, 1)
+	if objs, err := c.Pull(ctx, byDigest); err != nil || len(objs) != 3 {
+		t.Fatalf("bundle should be pullable by digest. Got: %d, %v", len(objs), err)
+	}
+	for d := range reg.blobs {
+		if d != digest(emptyConfig) {
+			reg.blobs[d] = []byte("tampered")
+		}
+	}
+	if _, err := c.Pull(ctx, ref); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("tampered blob should be detected. Got: %v", err)
+	}
+	if _, err := c.Push(ctx, ref, nil); !errors.Is(err, ErrEmptyBundle) {
+		t.Fatalf("empty bundle should be rejected. Got: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package objst
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// GetOptions are the options of BatchGetWithOptions.
+type GetOptions struct {
+	// Partial returns the readable objects and a PartialError
+	// mapping the ids of the missing or corrupted objects to
+	// the error instead of failing the whole read.
+	Partial bool
+}
+
+// PartialError is returned along with the readable objects by
+// partial reads if some of the requested objects can't be read.
+// See GetOptions.Partial and Query.Partial.
+type PartialError struct {
+	// Errs maps the ids of the objects
+	// which can't be read to the error.
+	Errs map[string]error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("%d objects could not be read", len(e.Errs))
+}
+
+// Unwrap returns the errors ordered by the id of the objects
+// e.g. to check for ErrObjectNotFound using errors.Is.
+func (e *PartialError) Unwrap() []error {
+	ids := maps.Keys(e.Errs)
+	slices.Sort(ids)
+	errs := make([]error, 0, len(ids))
+	for _, id := range ids {
+		errs = append(errs, e.Errs[id])
+	}
+	return errs
+}
+
+// BatchGet returns the objects with the given ids in the same
+// order. The payloads are loaded lazily. See Object.Load.
+func (b *Bucket) BatchGet(ids []string) ([]*Object, error) {
+	return b.BatchGetWithOptions(ids, GetOptions{})
+}
+
+// BatchGetWithOptions is like BatchGet but allows to return
+// partial results if some of the objects can't be read.
+func (b *Bucket) BatchGetWithOptions(ids []string, opts GetOptions) ([]*Object, error) {
+	op := b.startOp("BatchGet", ids...)
+	defer b.finishOp(op)
+	var errs map[string]error
+	if opts.Partial {
+		errs = make(map[string]error)
+	}
+	return b.idsToObjs(ids, errs)
+}
+
+func isPartial(err error) bool {
+	var perr *PartialError
+	return errors.As(err, &perr)
+}
+
+// partialResult returns the objects and a PartialError
+// if any errors have been recorded during the read.
+func partialResult(objs []*Object, errs map[string]error) ([]*Object, error) {
+	if len(errs) == 0 {
+		return objs, nil
+	}
+	return objs, &PartialError{Errs: errs}
+}
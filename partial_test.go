@@ -0,0 +1,69 @@
+package objst
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestPartialResults(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	owner := tEnv.owner()
+	objs := make([]*Object, 0, 2)
+	for i := 0; i < 2; i++ {
+		o, err := NewObject(tEnv.name(), owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.SetMetaKey(MetaKeyContentType, "text/plain")
+		o.Write(tEnv.payload(10))
+		if err := b.Create(o); err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, o)
+	}
+	corrupted := objs[1].ID()
+	err = b.meta.Update(func(txn *storeTxn) error {
+		return txn.Set([]byte(corrupted), []byte{0xff, 0xff})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Execute(NewQuery().Owner(owner)); err == nil || isPartial(err) {
+		t.Fatalf("query should fail without partial results. Got: %v", err)
+	}
+	got, err := b.Execute(NewQuery().Owner(owner).Partial())
+	var perr *PartialError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a partial error. Got: %v", err)
+	}
+	if len(got) != 1 || got[0].ID() != objs[0].ID() || perr.Errs[corrupted] == nil {
+		t.Fatalf("expected the readable object and the error of the corrupted one. Got: %d, %v", len(got), perr.Errs)
+	}
+
+	missing := "missing"
+	ids := []string{objs[0].ID(), missing, corrupted}
+	if _, err := b.BatchGet(ids); err == nil {
+		t.Fatalf("batch get should fail without partial results")
+	}
+	got, err = b.BatchGetWithOptions(ids, GetOptions{Partial: true})
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected a partial error. Got: %v", err)
+	}
+	if len(got) != 1 || len(perr.Errs) != 2 || !errors.Is(perr.Errs[missing], ErrObjectNotFound) {
+		t.Fatalf("expected the readable object and two errors. Got: %d, %v", len(got), perr.Errs)
+	}
+	if !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("partial error should wrap the errors of the objects")
+	}
+	if _, err := b.BatchGetWithOptions(ids[:1], GetOptions{Partial: true}); err != nil {
+		t.Fatalf("no error expected if all objects are readable. Got: %v", err)
+	}
+}
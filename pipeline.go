@@ -0,0 +1,340 @@
+package objst
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// pipelinePrefix is the prefix of the progress of the pipelines
+// in the name store. Object names cannot start with "#" so the
+// entries never collide with a name entry.
+const pipelinePrefix = "#pipeline/"
+
+// pipelinePageSize is the number of ids read
+// at once while iterating the objects.
+const pipelinePageSize = 64
+
+// Pipeline re-encodes the payload of all existing objects with new
+// settings e.g. after PayloadCompression or the Cipher of the bucket
+// changed. The objects are processed in the order of their id and the
+// progress is persisted after every object so an interrupted pipeline
+// is resumed where it stopped by the next run with the same name.
+//
+// The payloads are decoded using the Cipher of the bucket. To rotate
+// the master key of the payloads the bucket has to be opened with a
+// cipher which can decrypt both e.g. NewKeyRing(newCipher, oldCipher).
+type Pipeline struct {
+	// Name identifies the progress of the pipeline.
+	Name string
+
+	// Compression is the algorithm the payloads are
+	// compressed with. Payloads which are not getting
+	// smaller are stored uncompressed.
+	Compression Compression
+
+	// Cipher is used to encrypt the payloads. If nil
+	// the payloads are stored unencrypted.
+	Cipher Cipher
+
+	// ChunkSize is the maximum size in bytes of one payload
+	// chunk. If it is not positive the chunk size of the
+	// object is kept.
+	ChunkSize int64
+
+	// Throttle is the minimum duration between two
+	// processed objects to limit the load of the
+	// pipeline on concurrent operations.
+	Throttle time.Duration
+
+	// SkipEncrypted skips the objects with an encrypted payload
+	// and the objects which opted out of the encryption. See
+	// Bucket.EncryptLegacy.
+	SkipEncrypted bool
+}
+
+func (p Pipeline) isValid() error {
+	if p.Name == "" {
+		return ErrEmptyPipelineName
+	}
+	if p.Throttle < 0 {
+		return ErrNegativeDuration
+	}
+	if _, err := compress(p.Compression, nil); err != nil {
+		return err
+	}
+	return nil
+}
+
+// PipelineProgress is the persisted progress of a pipeline.
+type PipelineProgress struct {
+	Name string `json:"name"`
+	// LastID is the id of the last processed object.
+	LastID string `json:"lastId"`
+	// Processed is the number of re-encoded objects.
+	Processed int64 `json:"processed"`
+	// Skipped is the number of objects which
+	// are skipped because of SkipEncrypted.
+	Skipped int64 `json:"skipped,omitempty"`
+	// Done is true if all objects were processed.
+	Done      bool      `json:"done"`
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+func pipelineKey(name string) []byte {
+	return []byte(pipelinePrefix + name)
+}
+
+// RunPipeline runs the pipeline until all objects are processed or
+// the context is canceled. Objects created during the run may not be
+// processed. A pipeline which is done returns immediately until its
+// progress is reset using ResetPipeline.
+func (b *Bucket) RunPipeline(ctx context.Context, p Pipeline) error {
+	if err := p.isValid(); err != nil {
+		return err
+	}
+	if err := validateFIPSCipher(b.opts.FIPS, p.Cipher); err != nil {
+		return err
+	}
+	prog, err := b.PipelineProgress(p.Name)
+	if errors.Is(err, ErrPipelineNotFound) {
+		prog = PipelineProgress{Name: p.Name, StartedAt: time.Now()}
+	} else if err != nil {
+		return err
+	}
+	if prog.Done {
+		return nil
+	}
+	first := true
+	for {
+		ids, err := b.pipelineIDs(prog.LastID)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !first {
+				if err := sleep(ctx, p.Throttle); err != nil {
+					return err
+				}
+			}
+			first = false
+			reencoded, err := b.reencode(id, p)
+			if err != nil && !errors.Is(err, ErrObjectNotFound) {
+				return fmt.Errorf("pipeline %s: %s: %w", p.Name, id, err)
+			}
+			if err == nil && reencoded {
+				prog.Processed++
+			} else if err == nil {
+				prog.Skipped++
+			}
+			prog.LastID = id
+			prog.UpdatedAt = time.Now()
+			if err := b.writePipelineProgress(prog); err != nil {
+				return err
+			}
+		}
+		if len(ids) < pipelinePageSize {
+			break
+		}
+	}
+	prog.Done = true
+	prog.UpdatedAt = time.Now()
+	return b.writePipelineProgress(prog)
+}
+
+// StartPipeline runs the pipeline in the background until it is
+// done, stopped using StopPipeline or the bucket is shut down.
+// Errors are logged. ErrPipelineRunning is returned if a pipeline
+// with the same name is already running.
+func (b *Bucket) StartPipeline(p Pipeline) error {
+	if err := p.isValid(); err != nil {
+		return err
+	}
+	if err := validateFIPSCipher(b.opts.FIPS, p.Cipher); err != nil {
+		return err
+	}
+	b.pipelineMu.Lock()
+	defer b.pipelineMu.Unlock()
+	if _, ok := b.pipelines[p.Name]; ok {
+		return ErrPipelineRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b.pipelines[p.Name] = cancel
+	b.pipelineWg.Add(1)
+	go func() {
+		defer b.pipelineWg.Done()
+		err := b.RunPipeline(ctx, p)
+		if err != nil && ctx.Err() == nil && b.opts.Logger != nil {
+			b.opts.Logger.Warningf("running the pipeline %s failed: %v", p.Name, err)
+		}
+		b.pipelineMu.Lock()
+		defer b.pipelineMu.Unlock()
+		delete(b.pipelines, p.Name)
+		cancel()
+	}()
+	return nil
+}
+
+// StopPipeline stops the pipeline started using StartPipeline.
+// The pipeline can be resumed by starting it again.
+func (b *Bucket) StopPipeline(name string) {
+	b.pipelineMu.Lock()
+	cancel, ok := b.pipelines[name]
+	b.pipelineMu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// stopPipelines stops all running pipelines
+// and waits until they are stopped.
+func (b *Bucket) stopPipelines() {
+	b.pipelineMu.Lock()
+	for _, cancel := range b.pipelines {
+		cancel()
+	}
+	b.pipelineMu.Unlock()
+	b.pipelineWg.Wait()
+}
+
+// PipelineProgress returns the progress of the pipeline. If the
+// pipeline never ran ErrPipelineNotFound is returned.
+func (b *Bucket) PipelineProgress(name string) (PipelineProgress, error) {
+	var prog PipelineProgress
+	err := b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get(pipelineKey(name))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &prog)
+		})
+	})
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return prog, ErrPipelineNotFound
+	}
+	return prog, err
+}
+
+// ResetPipeline removes the progress of the pipeline
+// so the next run processes all objects again.
+func (b *Bucket) ResetPipeline(name string) error {
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Delete(pipelineKey(name))
+	})
+}
+
+func (b *Bucket) writePipelineProgress(prog PipelineProgress) error {
+	data, err := json.Marshal(&prog)
+	if err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Set(pipelineKey(prog.Name), data)
+	})
+}
+
+// pipelineIDs returns the next pipelinePageSize
+// ids of the objects following the id after.
+func (b *Bucket) pipelineIDs(after string) ([]string, error) {
+	ids := make([]string, 0, pipelinePageSize)
+	err := b.meta.View(func(txn *storeTxn) error {
+		opts := defaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek([]byte(after)); it.Valid() && len(ids) < pipelinePageSize; it.Next() {
+			id := string(it.Item().Key())
+			if id == after {
+				continue
+			}
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	return ids, err
+}
+
+// reencode re-encodes the payload of the object with
+// the settings of the pipeline. The decoded payload is
+// verified before the re-encoded payload is written.
+// The returned bool is false if the object is skipped.
+func (b *Bucket) reencode(id string, p Pipeline) (bool, error) {
+	done, err := b.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	defer b.finishOp(b.startOp("Reencode", id))
+	defer b.lockPayload(id)()
+	meta, err := b.GetMeta(id)
+	if err != nil {
+		return false, err
+	}
+	if _, encrypted := envelopeOf(meta); p.SkipEncrypted && (encrypted || isPlaintext(meta)) {
+		return false, nil
+	}
+	// the payloads of cold objects are not stored locally
+	if isCold(meta) {
+		return false, nil
+	}
+	pl, err := b.readPayload(meta)
+	if err != nil {
+		return false, err
+	}
+	if meta.Has(MetaKeyChecksum) && checksum(pl) != meta.Get(MetaKeyChecksum) {
+		return false, ErrChecksumMismatch
+	}
+	encoded := plaintextMeta(meta)
+	enc, err := b.encodePayloadWith(encoded, pl, p.Compression, p.Cipher)
+	if err != nil {
+		return false, err
+	}
+	chunkSize := p.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = chunkSizeOf(meta)
+	}
+	if chunkSize <= 0 {
+		chunkSize = int64(len(enc))
+	}
+	entries, err := b.splitPayload(id, chunkSize, enc)
+	if err != nil {
+		return false, err
+	}
+	if err := b.replacePayload(id, entries); err != nil {
+		return false, err
+	}
+	encoded.set(MetaKeyChunkSize, strconv.FormatInt(chunkSize, 10))
+	return true, b.updateMeta(id, func(meta *Metadata) error {
+		for _, k := range []MetaKey{MetaKeySize, MetaKeyChunkSize, MetaKeyCompression, MetaKeyDictionary, MetaKeyDataKey, MetaKeyNonce} {
+			if encoded.Has(k) {
+				meta.set(k, encoded.Get(k))
+			} else {
+				meta.del(k)
+			}
+		}
+		return nil
+	})
+}
+
+// sleep waits for d or until the context is canceled.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,154 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestPipeline(t *testing.T) {
+	oldKey, _ := randomBytes(32)
+	newKey, _ := randomBytes(32)
+	oldCipher, _ := NewAESGCMCipher(oldKey)
+	newCipher, _ := NewAESGCMCipher(newKey)
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.Cipher = oldCipher
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	pl := bytes.Repeat([]byte("objst"), 100)
+	objs := make([]*Object, 0, 3)
+	for i := 0; i < 3; i++ {
+		o := tEnv.obj()
+		o.Reset()
+		o.Write(pl)
+		objs = append(objs, o)
+	}
+	if err := b.BatchCreate(objs); err != nil {
+		t.Error(err)
+		return
+	}
+
+	b.opts.Cipher = NewKeyRing(newCipher, oldCipher)
+	p := Pipeline{
+		Name:        "rotate",
+		Compression: CompressionZstd,
+		Cipher:      newCipher,
+		ChunkSize:   16,
+		Throttle:    time.Hour,
+	}
+	// the throttle interrupts the pipeline after the first object
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := b.RunPipeline(ctx, p); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("pipeline should be interrupted. Got: %v", err)
+	}
+	prog, err := b.PipelineProgress(p.Name)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if prog.Processed != 1 || prog.Done {
+		t.Fatalf("one object should be processed. Got: %+v", prog)
+	}
+	p.Throttle = 0
+	if err := b.RunPipeline(context.Background(), p); err != nil {
+		t.Error(err)
+		return
+	}
+	prog, err = b.PipelineProgress(p.Name)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if prog.Processed != 3 || !prog.Done {
+		t.Fatalf("all objects should be processed. Got: %+v", prog)
+	}
+
+	// the payloads can be read without the old key
+	b.opts.Cipher = newCipher
+	for _, o := range objs {
+		meta, err := b.GetMeta(o.ID())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if meta.Get(MetaKeyCompression) != string(CompressionZstd) || chunkSizeOf(meta) != 16 {
+			t.Fatalf("payload should be re-encoded. Got: %s", meta.Encode())
+		}
+		got, err := b.GetByID(o.ID())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !bytes.Equal(got.Payload(), pl) {
+			t.Fatalf("payload changed")
+		}
+		if got.Checksum() != o.Checksum() {
+			t.Fatalf("checksum changed. Got: %s. Expected: %s", got.Checksum(), o.Checksum())
+		}
+	}
+
+	if err := b.ResetPipeline(p.Name); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := b.PipelineProgress(p.Name); !errors.Is(err, ErrPipelineNotFound) {
+		t.Fatalf("progress should be reset. Got: %v", err)
+	}
+	if err := b.RunPipeline(context.Background(), Pipeline{}); !errors.Is(err, ErrEmptyPipelineName) {
+		t.Fatalf("pipeline without a name should be invalid. Got: %v", err)
+	}
+}
+
+func TestStartPipeline(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	if err := b.BatchCreate([]*Object{tEnv.obj(), tEnv.obj()}); err != nil {
+		t.Error(err)
+		return
+	}
+	p := Pipeline{Name: "recompress", Compression: CompressionSnappy, Throttle: time.Hour}
+	if err := b.StartPipeline(p); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.StartPipeline(p); !errors.Is(err, ErrPipelineRunning) {
+		t.Fatalf("pipeline should be running. Got: %v", err)
+	}
+	// the throttle blocks the pipeline after the first object
+	prog, err := b.PipelineProgress(p.Name)
+	for deadline := time.Now().Add(time.Second); errors.Is(err, ErrPipelineNotFound) && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		prog, err = b.PipelineProgress(p.Name)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	b.StopPipeline(p.Name)
+	b.pipelineWg.Wait()
+	if prog.Done {
+		t.Fatalf("stopped pipeline should not be done. Got: %+v", prog)
+	}
+	p.Throttle = 0
+	if err := b.StartPipeline(p); err != nil {
+		t.Fatalf("stopped pipeline should be resumable. Got: %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package objst
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// policyFormKey is the key of the signed
+// upload policy in the multipart form.
+const policyFormKey = "policy"
+
+// UploadPolicy constrains the uploads of untrusted clients like
+// browsers similar to the POST policies of S3. The policy is
+// signed by a trusted party using SignUploadPolicy and sent by
+// the client in the "policy" field of the multipart form to the
+// /objst/upload endpoint which rejects all uploads violating it.
+type UploadPolicy struct {
+	// Owner is the owner of the uploaded object.
+	Owner string `json:"owner"`
+	// NamePrefix is the prefix the name of the
+	// uploaded object has to start with.
+	NamePrefix string `json:"namePrefix,omitempty"`
+	// ContentType is the allowed content type of the
+	// uploaded object e.g. "image/png". A trailing "/*"
+	// allows all subtypes e.g. "image/*". Empty allows
+	// all content types.
+	ContentType string `json:"contentType,omitempty"`
+	// MaxSize is the maximum size of the uploaded
+	// object in bytes. Zero means no limit.
+	MaxSize int64 `json:"maxSize,omitempty"`
+	// Expires is the time after which
+	// the policy is not valid anymore.
+	Expires time.Time `json:"expires"`
+}
+
+func (p UploadPolicy) isValid() error {
+	// the format of the owner is validated by the
+	// OwnerValidator of the bucket on insertion.
+	if p.Owner == "" {
+		return fmt.Errorf("%w: missing owner", ErrInvalidPolicy)
+	}
+	if p.MaxSize < 0 {
+		return fmt.Errorf("%w: negative max size", ErrInvalidPolicy)
+	}
+	if p.Expires.IsZero() {
+		return fmt.Errorf("%w: missing expiry", ErrInvalidPolicy)
+	}
+	return nil
+}
+
+// allows returns an error wrapping ErrPolicyViolated if an
+// object with the given name, content type and size is not
+// allowed to be uploaded.
+func (p UploadPolicy) allows(name, contentType string, size int64) error {
+	if !strings.HasPrefix(name, p.NamePrefix) {
+		return fmt.Errorf("%w: name must start with %s", ErrPolicyViolated, p.NamePrefix)
+	}
+	if !p.allowsContentType(contentType) {
+		return fmt.Errorf("%w: content type %s is not allowed", ErrPolicyViolated, contentType)
+	}
+	if p.MaxSize > 0 && size > p.MaxSize {
+		return fmt.Errorf("%w: size exceeds %d bytes", ErrPolicyViolated, p.MaxSize)
+	}
+	return nil
+}
+
+func (p UploadPolicy) allowsContentType(contentType string) bool {
+	return p.ContentType == "" || matchContentType(p.ContentType, contentType)
+}
+
+// SignUploadPolicy returns the token of the policy signed with
+// the secret using HMAC-SHA256. The token has to be verified
+// with the same secret which is the PolicySecret option of the
+// HTTPHandler. Binaries built with the objst_fips build tag
+// require secrets of at least 14 bytes.
+func SignUploadPolicy(p UploadPolicy, secret []byte) (string, error) {
+	if len(secret) == 0 {
+		return "", ErrMissingPolicySecret
+	}
+	if err := validateFIPSKey(false, secret); err != nil {
+		return "", err
+	}
+	if err := p.isValid(); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(&p)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(policySignature(payload, secret)), nil
+}
+
+// parseUploadPolicy verifies the signature of the token
+// and returns the policy iff it is not expired.
+func parseUploadPolicy(token string, secret []byte) (UploadPolicy, error) {
+	var p UploadPolicy
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return p, ErrInvalidPolicy
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, policySignature(payload, secret)) {
+		return p, ErrInvalidPolicy
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return p, ErrInvalidPolicy
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, ErrInvalidPolicy
+	}
+	if err := p.isValid(); err != nil {
+		return p, err
+	}
+	if time.Now().After(p.Expires) {
+		return p, ErrPolicyExpired
+	}
+	return p, nil
+}
+
+func policySignature(payload string, secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// uploadPolicy verifies the upload policy of the multipart
+// form if present and injects the owner of the policy and
+// the policy itself into the request context.
+func (h *HTTPHandler) uploadPolicy(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := r.Context().Value(CtxKeyReqID).(string)
+		if err := r.ParseMultipartForm(h.opts.MaxUploadSize); err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, "something went wrong while parsing the multipart form", http.StatusBadRequest)
+			return
+		}
+		token := r.FormValue(policyFormKey)
+		if token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(h.opts.PolicySecret) == 0 {
+			h.opts.Logger.ErrorCtx(r.Context(), ErrMissingPolicySecret.Error(), slog.String("req_id", reqID))
+			http.Error(w, "upload policies are not supported", http.StatusForbidden)
+			return
+		}
+		if err := validateFIPSKey(h.bucket.opts.FIPS, h.opts.PolicySecret); err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, "upload policies are not supported", http.StatusForbidden)
+			return
+		}
+		p, err := parseUploadPolicy(token, h.opts.PolicySecret)
+		if err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		ctx := context.WithValue(r.Context(), CtxKeyOwner, p.Owner)
+		ctx = context.WithValue(ctx, CtxKeyPolicy, p)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,146 @@
+package objst
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignUploadPolicy(t *testing.T) {
+	secret := []byte("secret-of-the-test")
+	p := UploadPolicy{
+		Owner:       tEnv.owner(),
+		NamePrefix:  "avatars/",
+		ContentType: "image/*",
+		MaxSize:     1024,
+		Expires:     time.Now().Add(time.Hour),
+	}
+	if _, err := SignUploadPolicy(p, nil); !errors.Is(err, ErrMissingPolicySecret) {
+		t.Fatalf("signing without a secret should fail. Got: %v", err)
+	}
+	if _, err := SignUploadPolicy(UploadPolicy{Expires: p.Expires}, secret); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("signing a policy without an owner should fail. Got: %v", err)
+	}
+	token, err := SignUploadPolicy(p, secret)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	got, err := parseUploadPolicy(token, secret)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if got.Owner != p.Owner || got.NamePrefix != p.NamePrefix || !got.Expires.Equal(p.Expires) {
+		t.Fatalf("policy is not the same. Got: %+v. Expected: %+v", got, p)
+	}
+	if _, err := parseUploadPolicy(token, []byte("other-secret-of-the-test")); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("policy signed with another secret should be invalid. Got: %v", err)
+	}
+	tampered, _ := SignUploadPolicy(UploadPolicy{Owner: p.Owner, Expires: p.Expires}, []byte("other-secret-of-the-test"))
+	payload, _, _ := strings.Cut(tampered, ".")
+	_, sig, _ := strings.Cut(token, ".")
+	if _, err := parseUploadPolicy(payload+"."+sig, secret); !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("tampered policy should be invalid. Got: %v", err)
+	}
+	p.Expires = time.Now().Add(-time.Second)
+	expired, _ := SignUploadPolicy(p, secret)
+	if _, err := parseUploadPolicy(expired, secret); !errors.Is(err, ErrPolicyExpired) {
+		t.Fatalf("expired policy should be rejected. Got: %v", err)
+	}
+}
+
+func TestHTTPUploadPolicy(t *testing.T) {
+	secret := []byte("secret-of-the-test")
+	opts := DefaultHTTPHandlerOptions()
+	opts.PolicySecret = secret
+	h := NewHTTPHandler(tEnv.b, opts)
+	target, err := url.JoinPath(tEnv.ts.URL, route, "upload")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	policy := func(mutate func(p *UploadPolicy)) string {
+		p := UploadPolicy{
+			Owner:       tEnv.owner(),
+			NamePrefix:  "2500",
+			ContentType: "image/*",
+			MaxSize:     2048,
+			Expires:     time.Now().Add(time.Hour),
+		}
+		mutate(&p)
+		token, err := SignUploadPolicy(p, secret)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	tests := []struct {
+		name   string
+		h      *HTTPHandler
+		policy string
+		code   int
+	}{
+		{
+			name:   "allowed",
+			h:      h,
+			policy: policy(func(p *UploadPolicy) {}),
+			code:   http.StatusOK,
+		},
+		{
+			name:   "name prefix",
+			h:      h,
+			policy: policy(func(p *UploadPolicy) { p.NamePrefix = "avatars/" }),
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "content type",
+			h:      h,
+			policy: policy(func(p *UploadPolicy) { p.ContentType = "text/plain" }),
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "max size",
+			h:      h,
+			policy: policy(func(p *UploadPolicy) { p.MaxSize = 100 }),
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "expired",
+			h:      h,
+			policy: policy(func(p *UploadPolicy) { p.Expires = time.Now().Add(-time.Minute) }),
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "forged",
+			h:      h,
+			policy: "e30.c2lnbmF0dXJl",
+			code:   http.StatusForbidden,
+		},
+		{
+			name:   "no secret configured",
+			h:      tEnv.h,
+			policy: policy(func(p *UploadPolicy) {}),
+			code:   http.StatusForbidden,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := map[string]string{policyFormKey: test.policy}
+			r, err := tEnv.newUploadRequest(target, params, opts.FormKey, "testdata/images/2500KB.jpg")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			w := httptest.NewRecorder()
+			test.h.ServeHTTP(w, r)
+			if w.Code != test.code {
+				t.Fatalf("statuscode is not %d. Got: %d. Body: %s", test.code, w.Code, w.Body.String())
+			}
+		})
+	}
+}
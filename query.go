@@ -1,39 +1,333 @@
 package objst
 
 import (
+	"context"
 	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
 )
 
-type action int
+type operation int
 
 const (
-	// logical `Or` relationship
-	Or action = iota + 1
+	OperationDelete = iota + 1
 
-	// logical `And` relationship
-	And
+	OperationGet
 )
 
-type operation int
+// Operator is the comparison operator of a Condition.
+type Operator int
 
 const (
-	OperationDelete = iota + 1
+	// OpEq matches if the values are equal
+	OpEq Operator = iota + 1
+	// OpNe matches if the values are not equal
+	OpNe
+	// OpGt matches if the value of the meta data is
+	// greater than the value of the condition
+	OpGt
+	// OpLt matches if the value of the meta data is
+	// less than the value of the condition
+	OpLt
+	// OpContains matches if the value of the meta data
+	// contains the value of the condition
+	OpContains
+	// OpPrefix matches if the value of the meta data
+	// starts with the value of the condition
+	OpPrefix
+	// OpMatch matches if the value of the meta data is
+	// matching the regular expression of the condition.
+	// The expression has to match the whole value.
+	OpMatch
+	// OpGlob matches if the value of the meta data is
+	// matching the shell pattern of the condition. See
+	// path.Match for the syntax of the pattern.
+	OpGlob
+	// OpHas matches if the values of the list-valued
+	// meta data contain the value of the condition.
+	// See Metadata.Add.
+	OpHas
+)
 
-	OperationGet
+// Expr is a boolean expression evaluated against the
+// meta data of an object. Expressions are built using
+// the leaf conditions e.g. Eq and Prefix and can be
+// nested arbitrarily using And, Or and Not.
+type Expr interface {
+	fmt.Stringer
+
+	eval(m *Metadata) bool
+	isValid() error
+}
+
+// Condition compares the value of the meta data with
+// the key Key to Value using the operator Op. OpGt and OpLt
+// are comparing the values as numbers if both are numbers,
+// as times if both are RFC3339 timestamps and as strings
+// otherwise. The values of system keys storing a time like
+// MetaKeyCreatedAt are always compared as times formatted
+// with TimeFormat. A condition never matches if the key
+// is missing.
+type Condition struct {
+	Key   MetaKey
+	Op    Operator
+	Value string
+}
+
+func (c Condition) eval(m *Metadata) bool {
+	return m.matches(c)
+}
+
+func (c Condition) isValid() error {
+	if c.Op < OpEq || c.Op > OpHas {
+		return fmt.Errorf("%w: %d", ErrUnknownOperator, c.Op)
+	}
+	if c.Op == OpMatch {
+		if _, err := regexp.Compile(metaPattern(c.Value)); err != nil {
+			return fmt.Errorf("invalid pattern for the field `%s`: %w", c.Key, err)
+		}
+		return nil
+	}
+	if c.Op == OpGlob {
+		if _, err := path.Match(c.Value, ""); err != nil {
+			return fmt.Errorf("invalid pattern for the field `%s`: %w", c.Key, err)
+		}
+	}
+	if !slices.Contains(timeKeys, c.Key) {
+		return nil
+	}
+	if c.Op == OpContains || c.Op == OpPrefix || c.Op == OpGlob || c.Op == OpHas {
+		return fmt.Errorf("%w: %d for the time field `%s`", ErrUnknownOperator, c.Op, c.Key)
+	}
+	if _, err := parseTime(c.Value); err != nil {
+		return fmt.Errorf("invalid time for the field `%s`: %s", c.Key, c.Value)
+	}
+	return nil
+}
+
+func (c Condition) String() string {
+	if c.Op == OpMatch {
+		return fmt.Sprintf("%s~%s", c.Key, c.Value)
+	}
+	return fmt.Sprintf("%s %s %s", c.Key, operatorNames[c.Op], c.Value)
+}
+
+// Eq returns the condition that the value
+// of the key k is equal to v.
+func Eq(k MetaKey, v string) Condition {
+	return Condition{Key: k, Op: OpEq, Value: v}
+}
+
+// Ne returns the condition that the value
+// of the key k is not equal to v.
+func Ne(k MetaKey, v string) Condition {
+	return Condition{Key: k, Op: OpNe, Value: v}
+}
+
+// Gt returns the condition that the value
+// of the key k is greater than v.
+func Gt(k MetaKey, v string) Condition {
+	return Condition{Key: k, Op: OpGt, Value: v}
+}
+
+// Lt returns the condition that the value
+// of the key k is less than v.
+func Lt(k MetaKey, v string) Condition {
+	return Condition{Key: k, Op: OpLt, Value: v}
+}
+
+// Contains returns the condition that the
+// value of the key k contains v.
+func Contains(k MetaKey, v string) Condition {
+	return Condition{Key: k, Op: OpContains, Value: v}
+}
+
+// Prefix returns the condition that the value
+// of the key k starts with p.
+func Prefix(k MetaKey, p string) Condition {
+	return Condition{Key: k, Op: OpPrefix, Value: p}
+}
+
+// Match returns the condition that the value of the
+// key k is matching the regular expression pattern.
+func Match(k MetaKey, pattern string) Condition {
+	return Condition{Key: k, Op: OpMatch, Value: pattern}
+}
+
+// Glob returns the condition that the value of the
+// key k is matching the shell pattern e.g. "*.pdf".
+func Glob(k MetaKey, pattern string) Condition {
+	return Condition{Key: k, Op: OpGlob, Value: pattern}
+}
+
+// Has returns the condition that the values
+// of the list-valued key k contain v.
+func Has(k MetaKey, v string) Condition {
+	return Condition{Key: k, Op: OpHas, Value: v}
+}
+
+type regexpExpr struct {
+	key MetaKey
+	re  *regexp.Regexp
+}
+
+// Regexp returns the expression matching if the value of the key
+// k contains a match of the compiled regular expression re. Unlike
+// Match the expression is not anchored to the whole value.
+func Regexp(k MetaKey, re *regexp.Regexp) Expr {
+	return regexpExpr{key: k, re: re}
+}
+
+func (r regexpExpr) eval(m *Metadata) bool {
+	return m.Has(r.key) && r.re.MatchString(m.Get(r.key))
+}
+
+func (r regexpExpr) isValid() error {
+	if r.re == nil {
+		return ErrNilExpr
+	}
+	return nil
+}
+
+func (r regexpExpr) String() string {
+	return fmt.Sprintf("%s =~ %s", r.key, r.re)
+}
+
+type logicalExpr struct {
+	// and is true for a logical `And` and
+	// false for a logical `Or` relationship.
+	and   bool
+	exprs []Expr
+}
+
+// And returns the expression matching if all of the
+// expressions are matching. An empty And always matches.
+func And(exprs ...Expr) Expr {
+	return logicalExpr{and: true, exprs: exprs}
+}
+
+// Or returns the expression matching if any of the expressions
+// is matching. An empty Or never matches.
+func Or(exprs ...Expr) Expr {
+	return logicalExpr{and: false, exprs: exprs}
+}
+
+func (l logicalExpr) eval(m *Metadata) bool {
+	for _, e := range l.exprs {
+		if e.eval(m) != l.and {
+			return !l.and
+		}
+	}
+	return l.and
+}
+
+func (l logicalExpr) isValid() error {
+	for _, e := range l.exprs {
+		if e == nil {
+			return ErrNilExpr
+		}
+		if err := e.isValid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l logicalExpr) String() string {
+	sep := " or "
+	if l.and {
+		sep = " and "
+	}
+	terms := make([]string, 0, len(l.exprs))
+	for _, e := range l.exprs {
+		terms = append(terms, e.String())
+	}
+	return "(" + strings.Join(terms, sep) + ")"
+}
+
+type notExpr struct {
+	expr Expr
+}
+
+// Not returns the expression matching
+// if the expression e is not matching.
+func Not(e Expr) Expr {
+	return notExpr{expr: e}
+}
+
+func (n notExpr) eval(m *Metadata) bool {
+	return !n.expr.eval(m)
+}
+
+func (n notExpr) isValid() error {
+	if n.expr == nil {
+		return ErrNilExpr
+	}
+	return n.expr.isValid()
+}
+
+func (n notExpr) String() string {
+	return "not " + n.expr.String()
+}
+
+// Order is the sort order of the query results.
+type Order int
+
+const (
+	// Asc orders the results ascending
+	Asc Order = iota + 1
+	// Desc orders the results descending
+	Desc
 )
 
 type Query struct {
 	params *Metadata
-	// logical action of the meta datas
-	act action
+	// exprs are the expressions an object must
+	// match in addition to the params.
+	exprs []Expr
 
 	op operation
+
+	// orderBy is the key the results are
+	// ordered by. Empty means unordered.
+	orderBy MetaKey
+	order   Order
+	limit   int
+	offset  int
+	// afterCursor is the encoded cursor
+	// the results are starting after.
+	afterCursor string
+	// dryRun prevents a delete query
+	// from deleting the objects.
+	dryRun bool
+	// partial returns the readable objects
+	// along with a PartialError.
+	partial bool
+	// ctx stops the scan of the meta data when it is done.
+	ctx context.Context
+	// iterOpts overrides BucketOptions.QueryIterator.
+	iterOpts *IteratorOptions
+}
+
+// IteratorOptions tunes the iteration over the
+// meta data of the objects scanned by a query.
+type IteratorOptions struct {
+	// PrefetchValues reads the meta data of the next
+	// objects in the background while the current
+	// object is compared to the query.
+	PrefetchValues bool
+	// PrefetchSize is the number of objects
+	// whose meta data is prefetched.
+	PrefetchSize int
 }
 
 func NewQuery() *Query {
 	return &Query{
 		params: NewMetadata(),
-		act:    Or,
 		op:     OperationGet,
 	}
 }
@@ -53,32 +347,259 @@ func (q *Query) Name(name string) *Query {
 	return q
 }
 
-// Action sets the logical connection
-// between the params and the meta data
-// of all compared objects.
-func (q *Query) Action(act action) *Query {
-	q.act = act
-	return q
-}
-
 // Param sets a given key value pair as a parameter
-// of the query.
+// of the query. The value is a regular expression
+// which has to match the whole value of the meta data.
 func (q *Query) Param(k MetaKey, v string) *Query {
 	q.params.set(k, v)
 	return q
 }
 
+// NameGlob adds the condition that the name of the object is
+// matching the shell pattern e.g. "reports/2024-*". See path.Match
+// for the syntax of the pattern. Like in a shell `*` is not
+// matching a "/".
+func (q *Query) NameGlob(pattern string) *Query {
+	return q.Filter(Glob(MetaKeyName, pattern))
+}
+
+// NameRegexp adds the condition that the
+// name of the object is matching re.
+func (q *Query) NameRegexp(re *regexp.Regexp) *Query {
+	return q.Filter(Regexp(MetaKeyName, re))
+}
+
+// Filter adds the expression e which all matching objects
+// have to satisfy. Filter can be called multiple times and
+// all params, conditions and expressions of the query are
+// connected by a logical `And`. Mixed boolean logic is
+// expressed by nesting e.g.
+//
+//	q.Filter(Or(Eq(k1, v1), And(Prefix(k2, p), Not(Eq(k3, v3)))))
+func (q *Query) Filter(e Expr) *Query {
+	q.exprs = append(q.exprs, e)
+	return q
+}
+
+// Not excludes all objects whose meta data with the key k
+// is matching v. Like Param the value is a regular expression
+// e.g. "image/.*".
+func (q *Query) Not(k MetaKey, v string) *Query {
+	return q.Filter(Not(Match(k, v)))
+}
+
+// HasTag adds the condition that the
+// object is tagged with tag. See MetaKeyTags.
+func (q *Query) HasTag(tag string) *Query {
+	return q.Filter(Has(MetaKeyTags, tag))
+}
+
+// Where adds the condition that the value of the meta
+// data with the key k compared to v using op matches.
+func (q *Query) Where(k MetaKey, op Operator, v string) *Query {
+	return q.Filter(Condition{Key: k, Op: op, Value: v})
+}
+
+// CreatedAfter adds the condition that the
+// object was created after t.
+func (q *Query) CreatedAfter(t time.Time) *Query {
+	return q.Where(MetaKeyCreatedAt, OpGt, formatTime(t))
+}
+
+// CreatedBefore adds the condition that the
+// object was created before t.
+func (q *Query) CreatedBefore(t time.Time) *Query {
+	return q.Where(MetaKeyCreatedAt, OpLt, formatTime(t))
+}
+
+// UpdatedAfter adds the condition that the
+// object was updated after t.
+func (q *Query) UpdatedAfter(t time.Time) *Query {
+	return q.Where(MetaKeyUpdatedAt, OpGt, formatTime(t))
+}
+
+// UpdatedBefore adds the condition that the
+// object was updated before t.
+func (q *Query) UpdatedBefore(t time.Time) *Query {
+	return q.Where(MetaKeyUpdatedAt, OpLt, formatTime(t))
+}
+
+// OrderBy orders the results by the value of the meta
+// data with the key k. The values are compared like the
+// values of the Gt and Lt operators of a Condition.
+func (q *Query) OrderBy(k MetaKey, order Order) *Query {
+	q.orderBy = k
+	q.order = order
+	return q
+}
+
+// Limit limits the number of results to n.
+// Zero means no limit.
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// After returns only the results after the cursor which is the
+// NextCursor of the Page returned by ExecutePage for the same query.
+func (q *Query) After(cursor string) *Query {
+	q.afterCursor = cursor
+	return q
+}
+
+// Offset skips the first n results.
+func (q *Query) Offset(n int) *Query {
+	q.offset = n
+	return q
+}
+
 func (q *Query) Operation(op operation) *Query {
 	q.op = op
 	return q
 }
 
-func (q *Query) isValid() error {
-	if q.params.isEmpty() {
+// DryRun makes Execute return the objects a delete query would
+// delete without deleting them. The returned objects are
+// containing only the meta data. It has no effect on get queries.
+func (q *Query) DryRun() *Query {
+	q.dryRun = true
+	return q
+}
+
+// Partial makes a get query return the readable objects along with
+// a PartialError if the meta data of some objects is missing or
+// corrupted instead of failing the whole query. Objects whose meta
+// data can't be decoded are reported even if they wouldn't match.
+func (q *Query) Partial() *Query {
+	q.partial = true
+	return q
+}
+
+// Iterator tunes the iteration over the meta data
+// of the objects for the query. It overrides
+// BucketOptions.QueryIterator.
+func (q *Query) Iterator(opts IteratorOptions) *Query {
+	q.iterOpts = &opts
+	return q
+}
+
+// WithContext stops the scan of the meta data
+// of the query and returns the error of ctx
+// when ctx is done.
+func (q *Query) WithContext(ctx context.Context) *Query {
+	q.ctx = ctx
+	return q
+}
+
+// streamable reports if the matching ids of the query can be
+// passed on while they are scanned because they don't have to be
+// ordered or counted for a page.
+func (q *Query) streamable() bool {
+	return q.orderBy == "" && q.limit == 0 && q.offset == 0
+}
+
+// keysOnly reports if the query can be evaluated using the
+// ids of the objects only. The meta data of the objects is not
+// read by the scan of such queries.
+func (q *Query) keysOnly() bool {
+	if q.orderBy != "" && q.orderBy != MetaKeyID {
+		return false
+	}
+	return isIDExpr(q.expr())
+}
+
+// isIDExpr reports if the expression is
+// only comparing the ids of the objects.
+func isIDExpr(e Expr) bool {
+	switch e := e.(type) {
+	case Condition:
+		return e.Key == MetaKeyID
+	case regexpExpr:
+		return e.key == MetaKeyID
+	case notExpr:
+		return isIDExpr(e.expr)
+	case logicalExpr:
+		for _, e := range e.exprs {
+			if !isIDExpr(e) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (q *Query) context() context.Context {
+	if q.ctx == nil {
+		return context.Background()
+	}
+	return q.ctx
+}
+
+var operatorNames = map[Operator]string{
+	OpEq:       "=",
+	OpNe:       "!=",
+	OpGt:       ">",
+	OpLt:       "<",
+	OpContains: "contains",
+	OpPrefix:   "prefix",
+	OpGlob:     "glob",
+	OpHas:      "has",
+}
+
+// String returns a human readable representation
+// of the query e.g. for logging.
+func (q *Query) String() string {
+	var b strings.Builder
+	if q.op == OperationDelete && q.dryRun {
+		b.WriteString("dry run delete")
+	} else if q.op == OperationDelete {
+		b.WriteString("delete")
+	} else {
+		b.WriteString("get")
+	}
+	expr := q.expr().String()
+	// strip the parentheses of the outer And
+	fmt.Fprintf(&b, " where %s", expr[1:len(expr)-1])
+	if q.orderBy != "" {
+		order := "asc"
+		if q.order == Desc {
+			order = "desc"
+		}
+		fmt.Fprintf(&b, " order by %s %s", q.orderBy, order)
+	}
+	if q.limit > 0 {
+		fmt.Fprintf(&b, " limit %d", q.limit)
+	}
+	if q.offset > 0 {
+		fmt.Fprintf(&b, " offset %d", q.offset)
+	}
+	if q.afterCursor != "" {
+		fmt.Fprintf(&b, " after %s", q.afterCursor)
+	}
+	return b.String()
+}
+
+func (q *Query) isValid(validateOwner OwnerValidator) error {
+	if q.params.isEmpty() && len(q.exprs) == 0 {
 		return ErrEmptyQuery
 	}
-	if !isValidUUID(q.params.Get(MetaKeyOwner)) {
-		return fmt.Errorf("invalid uuid for the field `owner`: %s", q.params.Get(MetaKeyOwner))
+	if err := q.expr().isValid(); err != nil {
+		return err
+	}
+	if q.limit < 0 || q.offset < 0 {
+		return ErrNegativeLimit
+	}
+	if q.orderBy != "" && q.order != Asc && q.order != Desc {
+		return fmt.Errorf("%w: %d", ErrUnknownOrder, q.order)
+	}
+	if _, err := q.cursor(); err != nil {
+		return err
+	}
+	if owner := q.params.Get(MetaKeyOwner); owner != "" {
+		if err := validateOwner(owner); err != nil {
+			return err
+		}
 	}
 	if !isValidUUID(q.params.Get(MetaKeyID)) {
 		return fmt.Errorf("invalid uuid for the field `id`: %s", q.params.Get(MetaKeyID))
@@ -88,3 +609,18 @@ func (q *Query) isValid() error {
 	}
 	return nil
 }
+
+// expr returns the expression of the query connecting
+// the params and the expressions by a logical `And`.
+func (q *Query) expr() Expr {
+	keys := make([]MetaKey, 0, len(q.params.data))
+	for k := range q.params.data {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	exprs := make([]Expr, 0, len(keys)+len(q.exprs))
+	for _, k := range keys {
+		exprs = append(exprs, Match(k, q.params.data[k]))
+	}
+	return And(append(exprs, q.exprs...)...)
+}
@@ -2,6 +2,7 @@ package objst
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 )
@@ -9,7 +10,7 @@ import (
 func TestQuery_isValid(t *testing.T) {
 	type fields struct {
 		params *Metadata
-		act    action
+		exprs  []Expr
 	}
 	tests := []struct {
 		name    string
@@ -45,6 +46,62 @@ func TestQuery_isValid(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "invalid time",
+			fields: fields{
+				params: NewMetadata(),
+				exprs:  []Expr{Gt(MetaKeyCreatedAt, "yesterday")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "has on time",
+			fields: fields{
+				params: NewMetadata(),
+				exprs:  []Expr{Has(MetaKeyCreatedAt, "2023-01-01T00:00:00Z")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "prefix on time",
+			fields: fields{
+				params: NewMetadata(),
+				exprs:  []Expr{Prefix(MetaKeyCreatedAt, formatTime(time.Now()))},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid nested condition",
+			fields: fields{
+				params: NewMetadata(),
+				exprs:  []Expr{Or(Eq("foo", "bar"), Not(Lt(MetaKeyUpdatedAt, "tomorrow")))},
+			},
+			wantErr: true,
+		},
+		{
+			name: "nil expression",
+			fields: fields{
+				params: NewMetadata(),
+				exprs:  []Expr{And(Eq("foo", "bar"), nil)},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid glob",
+			fields: fields{
+				params: NewMetadata(),
+				exprs:  []Expr{Glob(MetaKeyName, "reports/[")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "nil regexp",
+			fields: fields{
+				params: NewMetadata(),
+				exprs:  []Expr{Regexp(MetaKeyName, nil)},
+			},
+			wantErr: true,
+		},
 		{
 			name: "pass query",
 			fields: fields{
@@ -62,9 +119,9 @@ func TestQuery_isValid(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			q := &Query{
 				params: tt.fields.params,
-				act:    tt.fields.act,
+				exprs:  tt.fields.exprs,
 			}
-			if err := q.isValid(); (err != nil) != tt.wantErr {
+			if err := q.isValid(ValidateUUIDOwner); (err != nil) != tt.wantErr {
 				t.Errorf("Query.isValid() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
@@ -0,0 +1,209 @@
+package objst
+
+import (
+	"fmt"
+	"strconv"
+
+	"golang.org/x/exp/maps"
+)
+
+// Quota limits the storage an owner can use in a bucket.
+// A limit which is not positive is unlimited.
+type Quota struct {
+	// MaxBytes is the maximum sum of the
+	// payload sizes of all objects of the owner.
+	MaxBytes int64
+	// MaxObjects is the maximum number
+	// of objects of the owner.
+	MaxObjects int64
+}
+
+func (q Quota) isUnlimited() bool {
+	return q.MaxBytes <= 0 && q.MaxObjects <= 0
+}
+
+// SetQuota limits the storage of owner to maxBytes of payload
+// and maxObjects objects. Create, BatchCreate, Upload.Commit
+// and PatchPayload are returning ErrQuotaExceeded if the write
+// would exceed the quota of the owner. Setting both limits to
+// zero removes the quota. Quotas are not persisted and have to
+// be set again after the bucket is reopened.
+func (b *Bucket) SetQuota(owner string, maxBytes, maxObjects int64) {
+	b.quotaMu.Lock()
+	defer b.quotaMu.Unlock()
+	q := Quota{MaxBytes: maxBytes, MaxObjects: maxObjects}
+	if q.isUnlimited() {
+		delete(b.quotas, owner)
+		return
+	}
+	if b.quotas == nil {
+		b.quotas = make(map[string]Quota)
+	}
+	b.quotas[owner] = q
+}
+
+// Quota returns the quota of the owner and
+// false if the owner has no quota.
+func (b *Bucket) Quota(owner string) (Quota, bool) {
+	b.quotaMu.Lock()
+	defer b.quotaMu.Unlock()
+	q, ok := b.quotas[owner]
+	return q, ok
+}
+
+// SetContentTypeQuota limits the storage of the objects of owner
+// with the content type to maxBytes of payload and maxObjects
+// objects e.g. for products pricing the storage by media type.
+// A trailing "/*" of the content type matches all subtypes e.g.
+// "video/*". A quota with an empty owner applies to every owner
+// without its own quota for the content type. The quotas are
+// enforced like the quotas set by SetQuota but changes of the
+// content type by UpdateMeta are not checked. Setting both limits
+// to zero removes the quota.
+func (b *Bucket) SetContentTypeQuota(owner, contentType string, maxBytes, maxObjects int64) {
+	b.quotaMu.Lock()
+	defer b.quotaMu.Unlock()
+	q := Quota{MaxBytes: maxBytes, MaxObjects: maxObjects}
+	if q.isUnlimited() {
+		delete(b.typeQuotas[owner], contentType)
+		if len(b.typeQuotas[owner]) == 0 {
+			delete(b.typeQuotas, owner)
+		}
+		return
+	}
+	if b.typeQuotas == nil {
+		b.typeQuotas = make(map[string]map[string]Quota)
+	}
+	if b.typeQuotas[owner] == nil {
+		b.typeQuotas[owner] = make(map[string]Quota)
+	}
+	b.typeQuotas[owner][contentType] = q
+}
+
+// ContentTypeQuota returns the quota of the objects of owner with
+// the content type and false if there is no quota. The quota of
+// the empty owner is returned if the owner has no own quota.
+func (b *Bucket) ContentTypeQuota(owner, contentType string) (Quota, bool) {
+	b.quotaMu.Lock()
+	defer b.quotaMu.Unlock()
+	q, ok := b.contentTypeQuotas(owner)[contentType]
+	return q, ok
+}
+
+// contentTypeQuotas returns the content type quotas applying to
+// the owner which are the quotas of the empty owner overridden
+// by the quotas of the owner.
+func (b *Bucket) contentTypeQuotas(owner string) map[string]Quota {
+	quotas := maps.Clone(b.typeQuotas[""])
+	if quotas == nil {
+		quotas = make(map[string]Quota)
+	}
+	maps.Copy(quotas, b.typeQuotas[owner])
+	return quotas
+}
+
+// exceedsQuota reports whether an object with the content type and
+// size alone is exceeding the byte limit of the quota or of any
+// content type quota of the owner.
+func (b *Bucket) exceedsQuota(owner, contentType string, size int64) bool {
+	b.quotaMu.Lock()
+	defer b.quotaMu.Unlock()
+	if q, ok := b.quotas[owner]; ok && q.MaxBytes > 0 && size > q.MaxBytes {
+		return true
+	}
+	for pattern, q := range b.contentTypeQuotas(owner) {
+		if matchContentType(pattern, contentType) && q.MaxBytes > 0 && size > q.MaxBytes {
+			return true
+		}
+	}
+	return false
+}
+
+// reserveQuota checks if the requested usages fit into the quotas
+// of the owners. If any owner has a quota, writes are serialized
+// until the returned function is called to prevent concurrent
+// writes from exceeding the quota together.
+func (b *Bucket) reserveQuota(req ...Usage) (func(), error) {
+	b.quotaMu.Lock()
+	limited := false
+	for _, r := range req {
+		_, ok := b.quotas[r.Owner]
+		limited = limited || ok || len(b.contentTypeQuotas(r.Owner)) > 0
+	}
+	if !limited {
+		b.quotaMu.Unlock()
+		return func() {}, nil
+	}
+	if err := b.checkQuota(req); err != nil {
+		b.quotaMu.Unlock()
+		return nil, err
+	}
+	return b.quotaMu.Unlock, nil
+}
+
+// checkQuota returns ErrQuotaExceeded if the requested usages are
+// exceeding the quota or any content type quota of their owner.
+func (b *Bucket) checkQuota(req []Usage) error {
+	owners := make(map[string][]Usage)
+	for _, r := range req {
+		owners[r.Owner] = append(owners[r.Owner], r)
+	}
+	for owner, reqs := range owners {
+		if q, ok := b.quotas[owner]; ok {
+			u := b.Usage(owner)
+			for _, r := range reqs {
+				u = u.add(r)
+			}
+			if err := q.check(u); err != nil {
+				return err
+			}
+		}
+		for contentType, q := range b.contentTypeQuotas(owner) {
+			requested := false
+			u := b.UsageByContentType(owner, contentType)
+			for _, r := range reqs {
+				if matchContentType(contentType, r.ContentType) {
+					u = u.add(r)
+					requested = true
+				}
+			}
+			if !requested {
+				continue
+			}
+			if err := q.check(u); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// check returns ErrQuotaExceeded if the usage exceeds the quota.
+func (q Quota) check(u Usage) error {
+	subject := "owner " + u.Owner
+	if u.ContentType != "" {
+		subject = fmt.Sprintf("owner %s with content type %s", u.Owner, u.ContentType)
+	}
+	if q.MaxBytes > 0 && u.Bytes > q.MaxBytes {
+		return fmt.Errorf("%w: %s would use %d of %d bytes", ErrQuotaExceeded, subject, u.Bytes, q.MaxBytes)
+	}
+	if q.MaxObjects > 0 && u.Objects > q.MaxObjects {
+		return fmt.Errorf("%w: %s would have %d of %d objects", ErrQuotaExceeded, subject, u.Objects, q.MaxObjects)
+	}
+	return nil
+}
+
+// sizeOf returns the size of the payload described by meta.
+// Objects created before the size was recorded are falling
+// back to the size of the stored payload.
+func (b *Bucket) sizeOf(meta *Metadata) int64 {
+	if size, err := strconv.ParseInt(meta.Get(MetaKeySize), 10, 64); err == nil {
+		return size
+	}
+	var size int64
+	b.payload.View(func(txn *storeTxn) error {
+		size = payloadSize(txn, meta.Get(MetaKeyID))
+		return nil
+	})
+	return size
+}
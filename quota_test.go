@@ -0,0 +1,84 @@
+package objst
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQuota(t *testing.T) {
+	o := tEnv.obj()
+	owner := o.Owner()
+	tEnv.b.SetQuota(owner, 15, 2)
+	defer tEnv.b.SetQuota(owner, 0, 0)
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	// the payload of the objects are 10 bytes
+	oB, _ := NewObject(tEnv.name(), owner)
+	oB.Write(tEnv.payload(10))
+	if err := tEnv.b.Create(oB); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("create should exceed the byte quota. Got: %v", err)
+	}
+	if err := tEnv.b.PatchPayload(o.ID(), 10, []byte("123456")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("patch should exceed the byte quota. Got: %v", err)
+	}
+	if err := tEnv.b.PatchPayload(o.ID(), 10, []byte("12345")); err != nil {
+		t.Fatalf("patch within the quota should succeed: %v", err)
+	}
+
+	tEnv.b.SetQuota(owner, 0, 1)
+	oC, _ := NewObject(tEnv.name(), owner)
+	oC.Write(tEnv.payload(10))
+	if err := tEnv.b.BatchCreate([]*Object{oC}); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("batch create should exceed the object quota. Got: %v", err)
+	}
+	if _, err := tEnv.b.GetByID(oC.ID()); err == nil {
+		t.Fatalf("object exceeding the quota should not be created")
+	}
+	if err := tEnv.b.Create(tEnv.obj()); err != nil {
+		t.Fatalf("quota of another owner should not be affected: %v", err)
+	}
+}
+
+func TestContentTypeQuota(t *testing.T) {
+	newObj := func(owner, contentType string) *Object {
+		o, _ := NewObject(tEnv.name(), owner)
+		o.SetMetaKey(MetaKeyContentType, contentType)
+		o.Write(tEnv.payload(10))
+		return o
+	}
+	owner := tEnv.owner()
+	tEnv.b.SetContentTypeQuota(owner, "video/*", 15, 0)
+	defer tEnv.b.SetContentTypeQuota(owner, "video/*", 0, 0)
+	if err := tEnv.b.Create(newObj(owner, "video/mp4")); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.Create(newObj(owner, "video/webm")); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("create should exceed the video quota. Got: %v", err)
+	}
+	if err := tEnv.b.Create(newObj(owner, "text/plain")); err != nil {
+		t.Fatalf("other content types should not be affected: %v", err)
+	}
+	if u := tEnv.b.UsageByContentType(owner, "video/*"); u.Objects != 1 || u.Bytes != 10 {
+		t.Fatalf("video usage is not 1 object and 10 bytes. Got: %+v", u)
+	}
+
+	// the quota of the empty owner applies to all owners
+	tEnv.b.SetContentTypeQuota("", "image/png", 0, 1)
+	defer tEnv.b.SetContentTypeQuota("", "image/png", 0, 0)
+	other := tEnv.owner()
+	objs := []*Object{newObj(other, "image/png"), newObj(other, "image/png")}
+	if err := tEnv.b.BatchCreate(objs); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("batch create should exceed the default image quota. Got: %v", err)
+	}
+	tEnv.b.SetContentTypeQuota(other, "image/png", 0, 2)
+	defer tEnv.b.SetContentTypeQuota(other, "image/png", 0, 0)
+	if q, ok := tEnv.b.ContentTypeQuota(other, "image/png"); !ok || q.MaxObjects != 2 {
+		t.Fatalf("quota of the owner should override the default quota. Got: %+v", q)
+	}
+	if err := tEnv.b.BatchCreate(objs); err != nil {
+		t.Fatalf("batch create within the quota of the owner should succeed: %v", err)
+	}
+}
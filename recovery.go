@@ -0,0 +1,210 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Recovery is an option of OpenBucketWithRecovery to open
+// a bucket which can't be opened using OpenBucket.
+type Recovery int
+
+const (
+	// RecoveryNone opens the bucket like OpenBucket.
+	RecoveryNone Recovery = iota
+	// RecoveryReadOnly opens the stores read-only even if they
+	// are locked by another process. The bucket is frozen and
+	// can be used to read or back up the objects. It fails with
+	// ErrStoreTruncated if the logs of a store contain writes
+	// which aren't flushed yet e.g. by the process holding the
+	// lock.
+	RecoveryReadOnly
+	// RecoveryTruncate truncates the torn writes at the end of
+	// the logs of the stores e.g. after a crash. The data of the
+	// torn writes is lost.
+	RecoveryTruncate
+	// RecoveryFsck opens the bucket like RecoveryTruncate and
+	// rebuilds all derived indexes using ReindexAll. Objects whose
+	// payload can't be verified are quarantined. See VerifyAll.
+	RecoveryFsck
+)
+
+func (r Recovery) String() string {
+	switch r {
+	case RecoveryNone:
+		return "none"
+	case RecoveryReadOnly:
+		return "read-only"
+	case RecoveryTruncate:
+		return "truncate"
+	case RecoveryFsck:
+		return "fsck"
+	}
+	return "unknown"
+}
+
+// OpenError is returned if a store of a bucket can't be opened.
+// It wraps ErrStoreLocked, ErrStoreTruncated or ErrStoreCorrupted
+// if the cause is known and the error of badger.
+type OpenError struct {
+	// Store is the name of the store e.g. "meta".
+	Store string
+	Dir   string
+	// PID is the process id written to the lock file of
+	// the store by the process holding the lock or 0.
+	PID int
+	// Recoveries are the options of OpenBucketWithRecovery
+	// which are able to open the bucket.
+	Recoveries []Recovery
+	kind       error
+	cause      error
+}
+
+func (e *OpenError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "cannot open the %s store in %s", e.Store, e.Dir)
+	if e.kind != nil {
+		fmt.Fprintf(&sb, ": %v", e.kind)
+	}
+	if e.PID > 0 {
+		fmt.Fprintf(&sb, " by the process %d", e.PID)
+	}
+	// badger is appending the stack trace to some errors
+	cause, _, _ := strings.Cut(e.cause.Error(), "\n")
+	fmt.Fprintf(&sb, ": %s", cause)
+	if len(e.Recoveries) > 0 {
+		names := make([]string, 0, len(e.Recoveries))
+		for _, r := range e.Recoveries {
+			names = append(names, r.String())
+		}
+		fmt.Fprintf(&sb, ". Recovery options: %s", strings.Join(names, ", "))
+	}
+	return sb.String()
+}
+
+func (e *OpenError) Unwrap() []error {
+	if e.kind == nil {
+		return []error{e.cause}
+	}
+	return []error{e.kind, e.cause}
+}
+
+// newOpenError classifies the error of badger.Open. The errors of
+// badger are wrapped using %v so the messages have to be compared.
+func newOpenError(name, dir string, err error) *OpenError {
+	e := &OpenError{Store: name, Dir: dir, cause: err}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "Cannot acquire directory lock"):
+		e.kind = ErrStoreLocked
+		e.PID = lockHolder(dir)
+		e.Recoveries = []Recovery{RecoveryReadOnly}
+	case strings.Contains(msg, badger.ErrTruncateNeeded.Error()):
+		e.kind = ErrStoreTruncated
+		e.Recoveries = []Recovery{RecoveryTruncate, RecoveryFsck}
+	case strings.Contains(msg, "checksum mismatch"), strings.Contains(strings.ToLower(msg), "manifest"):
+		e.kind = ErrStoreCorrupted
+	}
+	return e
+}
+
+// lockHolder returns the process id written to
+// the lock file of badger or 0 if it is unknown.
+func lockHolder(dir string) int {
+	data, err := os.ReadFile(filepath.Join(dir, "LOCK"))
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// OpenBucket opens the existing bucket stored in path which is
+// the BasePath of the bucket. Unlike NewBucket it returns an
+// *OpenError if a store is locked by another process or the logs
+// of a store have to be truncated after a crash instead of
+// truncating them silently. The error lists the recovery options
+// which can be passed to OpenBucketWithRecovery.
+func OpenBucket(path string, opts BucketOptions) (*Bucket, error) {
+	return OpenBucketWithRecovery(path, opts, RecoveryNone)
+}
+
+// OpenBucketWithRecovery is like OpenBucket but opens the bucket
+// using the recovery option r. See OpenError.Recoveries.
+func OpenBucketWithRecovery(path string, opts BucketOptions, r Recovery) (*Bucket, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrBucketNotExist, err)
+	}
+	switch r {
+	case RecoveryNone:
+		if err := probeBucket(path, opts); err != nil {
+			return nil, err
+		}
+		return openBucket(path, opts, false)
+	case RecoveryReadOnly:
+		return openBucket(path, opts, true)
+	case RecoveryTruncate:
+		return openBucket(path, opts, false)
+	case RecoveryFsck:
+		b, err := openBucket(path, opts, false)
+		if err != nil {
+			return nil, err
+		}
+		if err := b.fsck(context.Background()); err != nil {
+			b.Shutdown()
+			return nil, err
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %d", ErrUnknownRecovery, r)
+}
+
+// probeBucket opens all stores of the bucket read-only which
+// fails if a store is locked or has to be truncated. Opening
+// them read-write would truncate the logs without notice.
+func probeBucket(path string, opts BucketOptions) error {
+	_, configs, err := bucketStores(path, opts)
+	if err != nil {
+		return err
+	}
+	for _, c := range configs {
+		c.opts.ReadOnly = true
+		s, err := openStore(c)
+		if err != nil {
+			return err
+		}
+		if err := s.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fsck rebuilds the derived indexes and
+// quarantines the corrupted objects.
+func (b *Bucket) fsck(ctx context.Context) error {
+	changed, err := b.ReindexAll(ctx)
+	if err != nil {
+		return err
+	}
+	corrupted, err := b.VerifyAll(ctx)
+	if err != nil {
+		return err
+	}
+	for _, id := range corrupted {
+		if err := b.Quarantine(id); err != nil && !errors.Is(err, ErrInvalidModeration) {
+			return err
+		}
+	}
+	if b.opts.Logger != nil {
+		b.opts.Logger.Infof("fsck repaired %d index entries and quarantined %d objects", changed, len(corrupted))
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package objst
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+	"golang.org/x/exp/slices"
+)
+
+func TestOpenBucket(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	_, err = OpenBucket(b.BasePath, opts)
+	var oerr *OpenError
+	if !errors.As(err, &oerr) || !errors.Is(err, ErrStoreLocked) {
+		t.Fatalf("bucket in use should be locked. Got: %v", err)
+	}
+	if oerr.PID != os.Getpid() || !slices.Contains(oerr.Recoveries, RecoveryReadOnly) {
+		t.Fatalf("open error should contain the lock holder and the recoveries. Got: %v", oerr)
+	}
+	// the unflushed writes of the running bucket
+	// are detected by opening the stores read-only
+	if _, err := OpenBucketWithRecovery(b.BasePath, opts, RecoveryReadOnly); !errors.Is(err, ErrStoreTruncated) {
+		t.Fatalf("expected ErrStoreTruncated. Got: %v", err)
+	}
+	if err := b.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	// lock the payload store like another process
+	_, configs, err := bucketStores(b.BasePath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs[0].opts.ReadOnly = true
+	holder, err := badger.Open(configs[0].opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBucket(b.BasePath, opts); !errors.As(err, &oerr) || oerr.Store != payloadStore || !errors.Is(err, ErrStoreLocked) {
+		t.Fatalf("locked payload store should be reported. Got: %v", err)
+	}
+	ro, err := OpenBucketWithRecovery(b.BasePath, opts, RecoveryReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ro.GetByID(o.ID()); err != nil {
+		t.Fatalf("object should be readable from the read-only bucket. Got: %v", err)
+	}
+	ro.Unfreeze()
+	if err := ro.Create(tEnv.obj()); !errors.Is(err, ErrBucketFrozen) {
+		t.Fatalf("read-only bucket should reject writes. Got: %v", err)
+	}
+	if err := ro.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = OpenBucket(b.BasePath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetByName(o.Name(), o.Owner()); err != nil {
+		t.Fatalf("object should be readable after reopening. Got: %v", err)
+	}
+	if err := b.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	b, err = OpenBucketWithRecovery(b.BasePath, opts, RecoveryFsck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenBucket(filepath.Join(b.BasePath, "missing"), opts); !errors.Is(err, ErrBucketNotExist) {
+		t.Fatalf("expected ErrBucketNotExist. Got: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"strings"
+)
+
+// ReindexAll reconstructs the name entries, the checksum index
+// and all secondary indexes from the meta data of the objects.
+// Missing entries are inserted and entries of objects which don't
+// exist anymore are removed. It returns the number of changed
+// entries. All writes are blocked until the indexes are rebuilt.
+func (b *Bucket) ReindexAll(ctx context.Context) (int, error) {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if b.closing {
+		return 0, ErrBucketClosed
+	}
+	if b.frozen {
+		return 0, ErrBucketFrozen
+	}
+	defer b.finishOp(b.startOp("ReindexAll"))
+	want, err := b.derivedEntries(ctx)
+	if err != nil {
+		return 0, err
+	}
+	wb := b.name.NewWriteBatch()
+	defer wb.Cancel()
+	changed := 0
+	err = b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			if !isDerivedKey(item.Key()) {
+				continue
+			}
+			k := string(item.Key())
+			equal := false
+			if e, ok := want[k]; ok {
+				if err := item.Value(func(val []byte) error {
+					equal = bytes.Equal(e.Value, val)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			if equal {
+				delete(want, k)
+				continue
+			}
+			if err := wb.Delete(item.KeyCopy(nil)); err != nil {
+				return err
+			}
+			changed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range want {
+		if err := wb.SetEntry(e); err != nil {
+			return 0, err
+		}
+		changed++
+	}
+	return changed, wb.Flush()
+}
+
+// derivedEntries returns the name, index and checksum entries
+// of all objects mapped by their key as inserted by insertName.
+func (b *Bucket) derivedEntries(ctx context.Context) (map[string]*Entry, error) {
+	entries := make(map[string]*Entry)
+	err := b.meta.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			meta := NewMetadata()
+			err := it.Item().Value(func(val []byte) error {
+				return b.unmarshalMeta(meta, val)
+			})
+			if err != nil {
+				return err
+			}
+			keys := append(b.indexEntries(meta), checksumKey(meta.Get(MetaKeyChecksum), meta.Get(MetaKeyID)))
+			for _, k := range keys {
+				entries[string(k)] = withExpiry(newEntry(k, nil), meta)
+			}
+			name := []byte(b.nameFormat(meta.Get(MetaKeyName), meta.Get(MetaKeyOwner)))
+			entries[string(name)] = withExpiry(newEntry(name, nameValue(meta)), meta)
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// isDerivedKey reports whether the key of the name store is
+// a name, index or checksum entry which can be reconstructed
+// from the meta data. All other entries of the name store
+// are prefixed with # e.g. the manifest or expiry markers.
+func isDerivedKey(k []byte) bool {
+	s := string(k)
+	return !strings.HasPrefix(s, "#") || strings.HasPrefix(s, indexPrefix) || strings.HasPrefix(s, checksumIndexPrefix)
+}
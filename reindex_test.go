@@ -0,0 +1,85 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestReindexAll(t *testing.T) {
+	const tag MetaKey = "tag"
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	if err := b.CreateIndex(tag); err != nil {
+		t.Error(err)
+		return
+	}
+	o1, o2 := tEnv.obj(), tEnv.obj()
+	o1.SetMetaKey(tag, "hr")
+	o2.SetMetaKey(tag, "legal")
+	if err := b.BatchCreate([]*Object{o1, o2}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// remove three entries and insert two stale ones
+	err = b.name.Update(func(txn *storeTxn) error {
+		for _, k := range [][]byte{
+			[]byte(b.nameFormat(o1.Name(), o1.Owner())),
+			indexKey(tag, "hr", o1.ID()),
+			checksumKey(o2.Checksum(), o2.ID()),
+		} {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		if err := txn.Set(indexKey(tag, "finance", o2.ID()), nil); err != nil {
+			return err
+		}
+		return txn.Set([]byte(b.nameFormat(tEnv.name(), o1.Owner())), []byte(tEnv.owner()))
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	n, err := b.ReindexAll(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if n != 5 {
+		t.Fatalf("5 entries should be changed. Got: %d", n)
+	}
+	if id, _, err := b.resolveEntry(o1.Name(), o1.Owner()); err != nil || id != o1.ID() {
+		t.Fatalf("name entry should be restored. Got: %s, %v", id, err)
+	}
+	if ids, err := b.FindByChecksum(o2.Checksum()); err != nil || len(ids) != 1 {
+		t.Fatalf("checksum entry should be restored. Got: %v, %v", ids, err)
+	}
+	for v, c := range map[string]int{"hr": 1, "legal": 1, "finance": 0} {
+		objs, err := b.Execute(NewQuery().Filter(Eq(tag, v)))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(objs) != c {
+			t.Fatalf("index of %s should contain %d objects. Got: %d", v, c, len(objs))
+		}
+	}
+	if n, err := b.ReindexAll(context.Background()); err != nil || n != 0 {
+		t.Fatalf("intact indexes should not be changed. Got: %d, %v", n, err)
+	}
+	b.Freeze()
+	defer b.Unfreeze()
+	if _, err := b.ReindexAll(context.Background()); !errors.Is(err, ErrBucketFrozen) {
+		t.Fatalf("frozen bucket should not be reindexed. Got: %v", err)
+	}
+}
@@ -0,0 +1,812 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// replicationPrefix is the prefix of the positions of the
+	// replicators in the name store of the source bucket.
+	replicationPrefix = "#replication/"
+	// resyncPrefix is the prefix of the markers of the objects
+	// received by a resync in the name store of the target.
+	resyncPrefix = "#resync/"
+)
+
+// Replica is the state of an object sent to a ReplicationTarget.
+type Replica struct {
+	Op ChangeOp `json:"op"`
+	ID string   `json:"id"`
+	// Meta and Payload are the meta data and the decoded
+	// payload of the object and are empty for ChangeDelete.
+	Meta    map[MetaKey]string `json:"meta,omitempty"`
+	Payload []byte             `json:"payload,omitempty"`
+	// Resync is the token of the resync the replica belongs
+	// to or 0. A replica with a token but without an op ends
+	// the resync and deletes all objects of the target which
+	// weren't sent by it.
+	Resync uint64 `json:"resync,omitempty"`
+}
+
+// ReplicationTarget receives the replicas of a Replicator. A
+// *Bucket is a target and NewHTTPReplicationTarget returns a target
+// sending the replicas to a remote bucket served by an HTTPHandler.
+type ReplicationTarget interface {
+	// Apply applies the replicas in their order. Applying
+	// a replica twice must have no further effect.
+	Apply(ctx context.Context, replicas []Replica) error
+}
+
+// ReplicationOptions are the options of ReplicateTo.
+type ReplicationOptions struct {
+	// Name identifies the replicator. Its position is persisted
+	// under the name so a replicator with the same name continues
+	// after a restart. Default: default.
+	Name string
+
+	// Interval is the time between two syncs. Default: 1s.
+	Interval time.Duration
+
+	// BatchSize is the maximum number of replicas
+	// applied at once. Default: 100.
+	BatchSize int
+
+	// BatchBytes is the maximum size of the payloads of the
+	// replicas applied at once. A larger payload is applied
+	// alone. The payloads are base64 encoded by an HTTP target
+	// so the default stays below its MaxUploadSize. Default: 16 MiB.
+	BatchBytes int64
+}
+
+// ReplicationStatus is the state of a Replicator.
+type ReplicationStatus struct {
+	Name string `json:"name"`
+	// Seq is the sequence number of the last replicated
+	// change and Head the one of the last change.
+	Seq  uint64 `json:"seq"`
+	Head uint64 `json:"head"`
+	// Lag is the number of changes which are not
+	// replicated yet and LagTime the time since the
+	// target was in sync or 0 if it is in sync.
+	Lag     uint64        `json:"lag"`
+	LagTime time.Duration `json:"lagTime"`
+	// SyncedAt is the time the target was in sync the last time.
+	SyncedAt time.Time `json:"syncedAt"`
+	// Error is the error of the last sync if it failed.
+	Error string `json:"error,omitempty"`
+}
+
+// replicationState is the persisted position of a replicator.
+type replicationState struct {
+	Seq      uint64    `json:"seq"`
+	SyncedAt time.Time `json:"syncedAt"`
+}
+
+// Replicator replicates the objects of a bucket to a target. See
+// ReplicateTo.
+type Replicator struct {
+	b      *Bucket
+	target ReplicationTarget
+	opts   ReplicationOptions
+	// mu serializes the syncs and resyncs.
+	mu sync.Mutex
+	// statusMu guards state and lastErr.
+	statusMu sync.Mutex
+	state    replicationState
+	lastErr  error
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func replicationKey(name string) []byte {
+	return []byte(replicationPrefix + name)
+}
+
+// ReplicateTo starts a replicator copying every change of the
+// bucket to the target in the background, which keeps the target
+// as a warm standby. The replicator follows the change feed of the
+// bucket and persists its position so only the changes since the
+// last sync are sent after a restart. The target is resynced i.e.
+// all objects are sent and the objects which don't exist in the
+// bucket are deleted on the first sync and if the change feed no
+// longer contains the position because ChangeRetention passed. The
+// replication is asynchronous so the target may lag behind. See
+// Replicator.Status. Payloads are sent decoded and are encoded by
+// the target as configured. Cold payloads are read from the Remote
+// and stored hot by the target.
+func (b *Bucket) ReplicateTo(target ReplicationTarget, opts ReplicationOptions) (*Replicator, error) {
+	if target == nil {
+		return nil, ErrNoReplicationTarget
+	}
+	if t, ok := target.(*Bucket); ok && t == b {
+		return nil, ErrSameBucket
+	}
+	if opts.Name == "" {
+		opts.Name = "default"
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.BatchBytes <= 0 {
+		opts.BatchBytes = 16 << 20
+	}
+	r := &Replicator{b: b, target: target, opts: opts}
+	state, err := r.loadState()
+	if err != nil {
+		return nil, err
+	}
+	r.state = state
+	b.replicatorMu.Lock()
+	defer b.replicatorMu.Unlock()
+	if b.closing {
+		return nil, ErrBucketClosed
+	}
+	if _, ok := b.replicators[opts.Name]; ok {
+		return nil, fmt.Errorf("%w: %s", ErrReplicatorExists, opts.Name)
+	}
+	if b.replicators == nil {
+		b.replicators = make(map[string]*Replicator)
+	}
+	b.replicators[opts.Name] = r
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.wg.Add(1)
+	go r.run(ctx)
+	return r, nil
+}
+
+// Replicators returns the status of the running
+// replicators of the bucket in the order of their names.
+func (b *Bucket) Replicators() []ReplicationStatus {
+	b.replicatorMu.Lock()
+	statuses := make([]ReplicationStatus, 0, len(b.replicators))
+	for _, r := range b.replicators {
+		statuses = append(statuses, r.Status())
+	}
+	b.replicatorMu.Unlock()
+	slices.SortFunc(statuses, func(a, b ReplicationStatus) bool {
+		return a.Name < b.Name
+	})
+	return statuses
+}
+
+// replicator returns the running replicator with the name.
+func (b *Bucket) replicator(name string) (*Replicator, error) {
+	b.replicatorMu.Lock()
+	defer b.replicatorMu.Unlock()
+	r, ok := b.replicators[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrReplicatorNotFound, name)
+	}
+	return r, nil
+}
+
+// stopReplicators stops all replicators
+// and waits until they are stopped.
+func (b *Bucket) stopReplicators() {
+	b.replicatorMu.Lock()
+	replicators := make([]*Replicator, 0, len(b.replicators))
+	for _, r := range b.replicators {
+		replicators = append(replicators, r)
+	}
+	b.replicatorMu.Unlock()
+	for _, r := range replicators {
+		r.Stop()
+	}
+}
+
+// run syncs the target every interval
+// until the context is canceled.
+func (r *Replicator) run(ctx context.Context) {
+	defer r.wg.Done()
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	for {
+		if err := r.Sync(ctx); err != nil && ctx.Err() == nil && r.b.opts.Logger != nil {
+			r.b.opts.Logger.Warningf("replication %s failed: %v", r.opts.Name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop stops the replicator and waits until a running sync is
+// done. The position is kept so a replicator with the same name
+// continues where the replicator stopped.
+func (r *Replicator) Stop() {
+	r.cancel()
+	r.wg.Wait()
+	r.b.replicatorMu.Lock()
+	if r.b.replicators[r.opts.Name] == r {
+		delete(r.b.replicators, r.opts.Name)
+	}
+	r.b.replicatorMu.Unlock()
+}
+
+// Status returns the position and the lag of the replicator.
+func (r *Replicator) Status() ReplicationStatus {
+	head := r.b.headSeq()
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	status := ReplicationStatus{
+		Name:     r.opts.Name,
+		Seq:      r.state.Seq,
+		Head:     head,
+		SyncedAt: r.state.SyncedAt,
+	}
+	if head > r.state.Seq {
+		status.Lag = head - r.state.Seq
+		if !r.state.SyncedAt.IsZero() {
+			status.LagTime = time.Since(r.state.SyncedAt)
+		}
+	}
+	if r.lastErr != nil {
+		status.Error = r.lastErr.Error()
+	}
+	return status
+}
+
+// Sync sends all changes since the last sync to the target and
+// returns when the target caught up with the bucket. The target is
+// resynced first if it never was in sync or the change feed doesn't
+// contain the position anymore. It is called by the replicator
+// every interval and can be called to sync immediately.
+func (r *Replicator) Sync(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.sync(ctx)
+	r.setError(err)
+	return err
+}
+
+// Resync sends all objects of the bucket to the target and deletes
+// all objects of the target which don't exist in the bucket. The
+// objects which are in sync are not rewritten by the target. The
+// changes which happen during the resync are sent by the next sync.
+func (r *Replicator) Resync(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.resync(ctx)
+	r.setError(err)
+	return err
+}
+
+func (r *Replicator) sync(ctx context.Context) error {
+	state := r.position()
+	retention := r.b.opts.ChangeRetention
+	if state.SyncedAt.IsZero() || (retention > 0 && time.Since(state.SyncedAt) > retention) {
+		if err := r.resync(ctx); err != nil {
+			return err
+		}
+		state = r.position()
+	}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		changes, next, err := r.b.Changes("", state.Seq, r.opts.BatchSize)
+		if err != nil {
+			return err
+		}
+		replicas := make([]Replica, 0, len(changes))
+		size := int64(0)
+		for _, c := range changes {
+			rep, err := r.b.replica(c.ID, c.Op)
+			if err != nil {
+				return err
+			}
+			replicas = append(replicas, rep)
+			size += int64(len(rep.Payload))
+			if size < r.opts.BatchBytes {
+				continue
+			}
+			if err := r.target.Apply(ctx, replicas); err != nil {
+				return err
+			}
+			replicas, size = replicas[:0], 0
+		}
+		if len(replicas) > 0 {
+			if err := r.target.Apply(ctx, replicas); err != nil {
+				return err
+			}
+		}
+		state.Seq = next
+		if len(changes) == 0 {
+			state.SyncedAt = time.Now()
+		}
+		if err := r.saveState(state); err != nil {
+			return err
+		}
+		if len(changes) == 0 {
+			return nil
+		}
+	}
+}
+
+func (r *Replicator) resync(ctx context.Context) error {
+	// changes after the head are sent by the next sync
+	head := r.b.headSeq()
+	token := uint64(time.Now().UnixNano())
+	ids := make([]string, 0)
+	err := r.b.forEachID(ctx, func(id string) error {
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	replicas := make([]Replica, 0, r.opts.BatchSize)
+	size := int64(0)
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		rep, err := r.b.replica(id, ChangePut)
+		if err != nil {
+			return err
+		}
+		if rep.Op == ChangeDelete {
+			// deleted in the meantime
+			continue
+		}
+		rep.Resync = token
+		replicas = append(replicas, rep)
+		size += int64(len(rep.Payload))
+		if len(replicas) < r.opts.BatchSize && size < r.opts.BatchBytes {
+			continue
+		}
+		if err := r.target.Apply(ctx, replicas); err != nil {
+			return err
+		}
+		replicas, size = replicas[:0], 0
+	}
+	replicas = append(replicas, Replica{Resync: token})
+	if err := r.target.Apply(ctx, replicas); err != nil {
+		return err
+	}
+	return r.saveState(replicationState{Seq: head, SyncedAt: time.Now()})
+}
+
+func (r *Replicator) position() replicationState {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	return r.state
+}
+
+func (r *Replicator) setError(err error) {
+	r.statusMu.Lock()
+	defer r.statusMu.Unlock()
+	r.lastErr = err
+}
+
+func (r *Replicator) loadState() (replicationState, error) {
+	var state replicationState
+	err := r.b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get(replicationKey(r.opts.Name))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &state)
+		})
+	})
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return state, nil
+	}
+	return state, err
+}
+
+func (r *Replicator) saveState(state replicationState) error {
+	data, err := json.Marshal(&state)
+	if err != nil {
+		return err
+	}
+	err = r.b.name.Update(func(txn *storeTxn) error {
+		return txn.Set(replicationKey(r.opts.Name), data)
+	})
+	if err != nil {
+		return err
+	}
+	r.statusMu.Lock()
+	r.state = state
+	r.statusMu.Unlock()
+	return nil
+}
+
+// headSeq returns the sequence number of the last change.
+func (b *Bucket) headSeq() uint64 {
+	b.changeMu.Lock()
+	defer b.changeMu.Unlock()
+	return b.changeSeq
+}
+
+// replica returns the replica of the current state of the object.
+// Objects which don't exist anymore are replicated as deleted.
+func (b *Bucket) replica(id string, op ChangeOp) (Replica, error) {
+	if op == ChangeDelete {
+		return Replica{Op: ChangeDelete, ID: id}, nil
+	}
+	meta, err := b.GetMeta(id)
+	if errors.Is(err, ErrObjectNotFound) {
+		return Replica{Op: ChangeDelete, ID: id}, nil
+	}
+	if err != nil {
+		return Replica{}, err
+	}
+	pl, err := b.readPayload(meta)
+	if err != nil {
+		return Replica{}, fmt.Errorf("reading %s: %w", id, err)
+	}
+	return Replica{Op: ChangePut, ID: id, Meta: meta.ToMap(), Payload: pl}, nil
+}
+
+// Apply applies the replicas of a Replicator to the bucket, which
+// makes it a ReplicationTarget. Unlike other writes it is accepted
+// by standbys so a demoted bucket can follow the primary.
+func (b *Bucket) Apply(ctx context.Context, replicas []Replica) error {
+	b.writeMu.RLock()
+	defer b.writeMu.RUnlock()
+	if b.closing {
+		return ErrBucketClosed
+	}
+	if b.frozen {
+		return ErrBucketFrozen
+	}
+	defer b.finishOp(b.startOp("Apply"))
+	for _, rep := range replicas {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		switch {
+		case rep.Op == "" && rep.ID == "" && rep.Resync > 0:
+			err = b.pruneResync(ctx, rep.Resync)
+		case rep.Op == ChangePut && rep.ID != "":
+			err = b.applyPut(rep)
+		case rep.Op == ChangeDelete && rep.ID != "":
+			err = b.applyDelete(rep.ID)
+		default:
+			err = ErrInvalidReplica
+		}
+		if err != nil {
+			return fmt.Errorf("applying %s of %s: %w", rep.Op, rep.ID, err)
+		}
+	}
+	return nil
+}
+
+// applyPut writes the object of the replica. If only the meta data
+// changed the stored payload is kept. Another object of the target
+// with the same name is deleted because it was deleted in the source
+// and the change wasn't applied yet.
+func (b *Bucket) applyPut(rep Replica) error {
+	meta := NewMetadata()
+	meta.FromMap(rep.Meta)
+	if meta.Get(MetaKeyID) != rep.ID {
+		return ErrInvalidReplica
+	}
+	// the payload is stored hot and
+	// the meta data signed by the target
+	meta.del(MetaKeyTier)
+	meta.del(MetaKeySignature)
+	cur, err := b.GetMeta(rep.ID)
+	exists := err == nil
+	if exists && samePayload(cur, meta) {
+		if err := b.replaceMeta(cur, meta); err != nil {
+			return err
+		}
+		return b.markResync(rep)
+	}
+	if !exists && !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	if checksum(rep.Payload) != meta.Get(MetaKeyChecksum) {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, rep.ID)
+	}
+	data, err := b.encodePayload(meta, rep.Payload)
+	if err != nil {
+		return err
+	}
+	entries, err := b.splitPayload(rep.ID, chunkSizeOf(meta), data)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := b.insertPayload(entries); err != nil {
+			return err
+		}
+	} else {
+		// the current object is removed after
+		// its payload was replaced by the new one
+		defer b.lockPayload(rep.ID)()
+		if err := b.replacePayload(rep.ID, entries); err != nil {
+			return err
+		}
+		if err := b.detachObject(cur); err != nil && !errors.Is(err, ErrObjectNotFound) {
+			return err
+		}
+	}
+	err = b.reserveNames(meta)
+	if errors.Is(err, ErrDuplicateName) {
+		if err = b.evictName(meta); err == nil {
+			err = b.reserveNames(meta)
+		}
+	}
+	if err != nil {
+		return errors.Join(err, b.deletePayload(rep.ID))
+	}
+	if err := b.writeMeta(rep.ID, meta); err != nil {
+		return errors.Join(err, b.releaseNames(meta))
+	}
+	if err := b.metaInserted(rep.ID, meta); err != nil {
+		return err
+	}
+	return b.markResync(rep)
+}
+
+// samePayload reports whether meta describes the same
+// payload and name as the stored meta data cur.
+func samePayload(cur, meta *Metadata) bool {
+	for _, k := range []MetaKey{MetaKeyGeneration, MetaKeyChecksum, MetaKeyName, MetaKeyOwner, MetaKeyChunkSize} {
+		if cur.Get(k) != meta.Get(k) {
+			return false
+		}
+	}
+	return !isCold(cur)
+}
+
+// replaceMeta replaces the meta data cur of an object by meta
+// keeping the keys needed to decode the stored payload. Nothing
+// is written if the meta data is equal.
+func (b *Bucket) replaceMeta(cur, meta *Metadata) error {
+	for _, k := range []MetaKey{MetaKeySize, MetaKeyCompression, MetaKeyDictionary, MetaKeyDataKey, MetaKeyNonce} {
+		meta.del(k)
+		if cur.Has(k) {
+			meta.set(k, cur.Get(k))
+		}
+	}
+	if cur.Has(MetaKeySignature) {
+		meta.set(MetaKeySignature, cur.Get(MetaKeySignature))
+	}
+	if maps.Equal(cur.ToMap(), meta.ToMap()) {
+		return nil
+	}
+	id := meta.Get(MetaKeyID)
+	err := b.meta.Update(func(txn *storeTxn) error {
+		data, err := b.marshalMeta(meta)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(withExpiry(newEntry([]byte(id), data), meta))
+	})
+	if err != nil {
+		return err
+	}
+	b.cache.invalidate(id)
+	if err := b.reindexMeta(cur, meta); err != nil {
+		return err
+	}
+	if err := b.updateExpiryMarker(cur, meta); err != nil {
+		return err
+	}
+	if err := b.addMetaDelta(cur, meta); err != nil {
+		return err
+	}
+	return b.recordChanges(EventUpdated, meta)
+}
+
+// evictName deletes the object owning the name of meta.
+func (b *Bucket) evictName(meta *Metadata) error {
+	id, _, err := b.resolveEntry(meta.Get(MetaKeyName), meta.Get(MetaKeyOwner))
+	if err != nil {
+		return err
+	}
+	other, err := b.GetMeta(id)
+	if errors.Is(err, ErrObjectNotFound) {
+		// the name entry is stale
+		return b.name.Update(func(txn *storeTxn) error {
+			return txn.Delete([]byte(b.nameFormat(meta.Get(MetaKeyName), meta.Get(MetaKeyOwner))))
+		})
+	}
+	if err != nil {
+		return err
+	}
+	if err := b.removeObject(other); !errors.Is(err, ErrObjectNotFound) {
+		return err
+	}
+	return nil
+}
+
+func (b *Bucket) applyDelete(id string) error {
+	meta, err := b.GetMeta(id)
+	if err == nil {
+		err = b.removeObject(meta)
+	}
+	if errors.Is(err, ErrObjectNotFound) {
+		return nil
+	}
+	return err
+}
+
+func resyncKey(token uint64, id string) []byte {
+	return []byte(fmt.Sprintf("%s%020d/%s", resyncPrefix, token, id))
+}
+
+// markResync marks the object as received by the resync of the replica.
+func (b *Bucket) markResync(rep Replica) error {
+	if rep.Resync == 0 {
+		return nil
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Set(resyncKey(rep.Resync, rep.ID), nil)
+	})
+}
+
+// pruneResync deletes all objects which weren't received by the
+// resync with the token and the markers of all resyncs.
+func (b *Bucket) pruneResync(ctx context.Context, token uint64) error {
+	stale := make([]string, 0)
+	err := b.forEachID(ctx, func(id string) error {
+		err := b.name.View(func(txn *storeTxn) error {
+			_, err := txn.Get(resyncKey(token, id))
+			return err
+		})
+		if errors.Is(err, ErrStoreKeyNotFound) {
+			stale = append(stale, id)
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range stale {
+		if err := b.applyDelete(id); err != nil {
+			return err
+		}
+	}
+	markers := make([][]byte, 0)
+	err = b.name.View(func(txn *storeTxn) error {
+		opts := defaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := []byte(resyncPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			markers = append(markers, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		for _, k := range markers {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// httpTarget sends the replicas to
+// the replicate endpoint of a bucket.
+type httpTarget struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewHTTPReplicationTarget returns a target posting the replicas to
+// the bucket served by the HTTPHandler at the base URL e.g.
+// https://standby:8080. The api key must have the admin scope.
+// The payloads are sent decoded so the URL should use TLS. The
+// client defaults to http.DefaultClient.
+func NewHTTPReplicationTarget(url, apiKey string, client *http.Client) ReplicationTarget {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpTarget{
+		url:    strings.TrimSuffix(url, "/") + "/objst/admin/replicate",
+		apiKey: apiKey,
+		client: client,
+	}
+}
+
+func (t *httpTarget) Apply(ctx context.Context, replicas []Replica) error {
+	body, err := json.Marshal(replicas)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	if t.apiKey != "" {
+		req.Header.Set(headerAuthorization, "Bearer "+t.apiKey)
+	}
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1<<10))
+		return fmt.Errorf("%w: %s: %s", ErrReplicaRejected, res.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Replicate applies the replicas of the request body to the
+// bucket. The body is limited to MaxUploadSize. See Apply.
+func (h *HTTPHandler) Replicate(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	var replicas []Replica
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, h.opts.MaxUploadSize)).Decode(&replicas)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "request body is not a valid list of replicas", http.StatusBadRequest)
+		return
+	}
+	err = h.bucket.Apply(r.Context(), replicas)
+	if errors.Is(err, ErrInvalidReplica) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Replicators returns the status of the replicators of the bucket.
+func (h *HTTPHandler) Replicators(w http.ResponseWriter, r *http.Request) {
+	h.writeJSON(w, r, h.bucket.Replicators())
+}
+
+// Resync resyncs the target of the replicator with the name
+// of the route. It returns after the resync is done.
+func (h *HTTPHandler) Resync(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	rep, err := h.bucket.replicator(chi.URLParam(r, "name"))
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	if err := rep.Resync(r.Context()); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	h.writeJSON(w, r, rep.Status())
+}
@@ -0,0 +1,254 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/maps"
+)
+
+func newReplicationBucket(t *testing.T) *Bucket {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		b.Shutdown()
+		os.RemoveAll(b.BasePath)
+	})
+	return b
+}
+
+func assertReplicated(t *testing.T, src, dst *Bucket, id string) {
+	t.Helper()
+	want, err := src.GetByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := dst.GetByID(id)
+	if err != nil {
+		t.Fatalf("%s should be replicated: %v", id, err)
+	}
+	if !bytes.Equal(want.Payload(), got.Payload()) {
+		t.Fatalf("payload of %s differs", id)
+	}
+	if want.ETag() != got.ETag() || want.Name() != got.Name() || want.meta.Get("foo") != got.meta.Get("foo") {
+		t.Fatalf("meta data of %s differs. Want: %+v. Got: %+v", id, want.Info(), got.Info())
+	}
+}
+
+func TestReplicateTo(t *testing.T) {
+	ctx := context.Background()
+	src := newReplicationBucket(t)
+	dst := newReplicationBucket(t)
+	objs := []*Object{tEnv.obj(), tEnv.obj()}
+	if err := src.BatchCreate(objs); err != nil {
+		t.Fatal(err)
+	}
+	stray := tEnv.obj()
+	if err := dst.Create(stray); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Demote(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.ReplicateTo(src, ReplicationOptions{}); !errors.Is(err, ErrSameBucket) {
+		t.Fatalf("replicating to the bucket itself should fail. Got: %v", err)
+	}
+	rep, err := src.ReplicateTo(dst, ReplicationOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := src.ReplicateTo(dst, ReplicationOptions{}); !errors.Is(err, ErrReplicatorExists) {
+		t.Fatalf("replicators should have unique names. Got: %v", err)
+	}
+	// the first sync resyncs the standby
+	if err := rep.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, o := range objs {
+		assertReplicated(t, src, dst, o.ID())
+	}
+	if _, err := dst.GetMeta(stray.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("objects missing in the source should be deleted by the resync. Got: %v", err)
+	}
+
+	if err := src.UpdateMeta(objs[0].ID(), map[MetaKey]string{"foo": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.DeleteByID(objs[1].ID()); err != nil {
+		t.Fatal(err)
+	}
+	created := tEnv.obj()
+	if err := src.Create(created); err != nil {
+		t.Fatal(err)
+	}
+	if status := rep.Status(); status.Lag == 0 || status.Seq >= status.Head {
+		t.Fatalf("replicator should lag behind. Got: %+v", status)
+	}
+	if err := rep.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	assertReplicated(t, src, dst, objs[0].ID())
+	assertReplicated(t, src, dst, created.ID())
+	if _, err := dst.GetMeta(objs[1].ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("deleted object should be deleted in the target. Got: %v", err)
+	}
+	if status := rep.Status(); status.Lag != 0 || status.LagTime != 0 || status.Error != "" {
+		t.Fatalf("replicator should be in sync. Got: %+v", status)
+	}
+	if stats := src.Stats(); len(stats.Replication) != 1 || stats.Replication[0].Name != "default" {
+		t.Fatalf("stats should contain the replicator. Got: %+v", stats.Replication)
+	}
+
+	// objects which are in sync are not rewritten by a resync
+	_, before, err := dst.Changes("", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rep.Resync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, after, err := dst.Changes("", 0, 0); err != nil || after != before {
+		t.Fatalf("resync should not change objects in sync. Got: %d changes, %v", after-before, err)
+	}
+
+	rep.Stop()
+	if len(src.Replicators()) != 0 {
+		t.Fatal("stopped replicator should be removed")
+	}
+	// the position is kept
+	again, err := src.ReplicateTo(dst, ReplicationOptions{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := again.Status(); status.Seq != status.Head {
+		t.Fatalf("replicator should continue at its position. Got: %+v", status)
+	}
+}
+
+func TestHTTPReplicationTarget(t *testing.T) {
+	ctx := context.Background()
+	src := newReplicationBucket(t)
+	dst := newReplicationBucket(t)
+	hopts := DefaultHTTPHandlerOptions()
+	hopts.IsAdmin = isAuthorized
+	hopts.MaxUploadSize = 4 << 10
+	srv := httptest.NewServer(NewHTTPHandler(dst, hopts))
+	defer srv.Close()
+	// the objects only fit into the body if they are sent alone
+	objs := make([]*Object, 0, 3)
+	for i := 0; i < 3; i++ {
+		obj := tEnv.emptyObj()
+		obj.Write(tEnv.payload(2 << 10))
+		objs = append(objs, obj)
+	}
+	if err := src.BatchCreate(objs); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := src.ReplicateTo(NewHTTPReplicationTarget(srv.URL, "", srv.Client()), ReplicationOptions{Interval: time.Hour, BatchBytes: 1 << 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rep.Sync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objs {
+		assertReplicated(t, src, dst, obj.ID())
+	}
+	target := NewHTTPReplicationTarget(srv.URL, "", srv.Client())
+	err = target.Apply(ctx, []Replica{{Op: "move"}})
+	if !errors.Is(err, ErrReplicaRejected) {
+		t.Fatalf("invalid replicas should be rejected. Got: %v", err)
+	}
+	err = target.Apply(ctx, []Replica{{Op: ChangePut, ID: objs[0].ID(), Payload: tEnv.payload(8 << 10)}})
+	if !errors.Is(err, ErrReplicaRejected) || !strings.Contains(err.Error(), "413") {
+		t.Fatalf("too large replicas should be rejected with 413. Got: %v", err)
+	}
+}
+
+func TestApplyPutRollback(t *testing.T) {
+	ctx := context.Background()
+	src := newReplicationBucket(t)
+	fail := new(atomic.Bool)
+	var payload *memoryStore
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.Layout = LayoutSeparate
+	opts.OpenStore = func(c StoreConfig) (Store, error) {
+		s, err := OpenMemoryStore(c)
+		if err != nil {
+			return nil, err
+		}
+		switch c.Name {
+		case payloadStore:
+			payload = s.(*memoryStore)
+		case metaStore:
+			return failingStore{memoryStore: s.(*memoryStore), fail: fail}, nil
+		}
+		return s, nil
+	}
+	dst, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dst.BasePath)
+	defer dst.Shutdown()
+
+	cur := tEnv.obj()
+	if err := dst.Create(cur); err != nil {
+		t.Fatal(err)
+	}
+	bad, err := dst.replica(cur.ID(), ChangePut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad.Meta = maps.Clone(bad.Meta)
+	bad.Meta[MetaKeyGeneration] = newGeneration()
+	bad.Payload = tEnv.payload(10)
+	if err := dst.Apply(ctx, []Replica{bad}); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("replica with an invalid checksum should be rejected. Got: %v", err)
+	}
+	got, err := dst.GetByID(cur.ID())
+	if err != nil {
+		t.Fatalf("object should be kept if the replica is rejected: %v", err)
+	}
+	if !bytes.Equal(got.Payload(), cur.Payload()) {
+		t.Fatal("payload should be kept if the replica is rejected")
+	}
+
+	obj := tEnv.obj()
+	if err := src.Create(obj); err != nil {
+		t.Fatal(err)
+	}
+	rep, err := src.replica(obj.ID(), ChangePut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fail.Store(true)
+	if err := dst.Apply(ctx, []Replica{rep}); !errors.Is(err, errInjected) {
+		t.Fatalf("apply should fail. Got: %v", err)
+	}
+	fail.Store(false)
+	if dst.isNameExisting(obj.Name(), obj.Owner()) {
+		t.Fatal("name of the replica should be released")
+	}
+	snap, err := payload.snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range snap.keys {
+		if strings.Contains(k, obj.ID()) {
+			t.Fatal("payload of the replica should be deleted")
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package objst
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// retentionPrefix is the prefix of the
+// retention classes in the name store.
+const retentionPrefix = "#retention/"
+
+// RetentionClass locks the objects created with the class
+// in the meta data key MetaKeyRetentionClass for Duration
+// after their creation. Changing or deleting a class only
+// affects objects which are created afterwards because the
+// retention of an object can never be shortened. See Lock.
+type RetentionClass struct {
+	Name     string
+	Duration time.Duration
+}
+
+// retentionClassJSON is the JSON representation of a
+// RetentionClass with Duration formatted like time.Duration.
+type retentionClassJSON struct {
+	Name     string `json:"name"`
+	Duration string `json:"duration"`
+}
+
+func (c RetentionClass) MarshalJSON() ([]byte, error) {
+	return json.Marshal(retentionClassJSON{Name: c.Name, Duration: c.Duration.String()})
+}
+
+func (c *RetentionClass) UnmarshalJSON(data []byte) error {
+	var v retentionClassJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(v.Duration)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRetentionClass, err)
+	}
+	*c = RetentionClass{Name: v.Name, Duration: d}
+	return nil
+}
+
+func (c RetentionClass) isValid() error {
+	if c.Name == "" || c.Duration <= 0 {
+		return ErrInvalidRetentionClass
+	}
+	return nil
+}
+
+func retentionKey(name string) []byte {
+	return []byte(retentionPrefix + name)
+}
+
+// PutRetentionClass persists the retention class in the
+// bucket. An existing class with the same name is replaced.
+func (b *Bucket) PutRetentionClass(c RetentionClass) error {
+	if err := c.isValid(); err != nil {
+		return err
+	}
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Set(retentionKey(c.Name), data)
+	})
+}
+
+// DeleteRetentionClass deletes the retention class. Objects
+// which are created with the class afterwards are rejected.
+func (b *Bucket) DeleteRetentionClass(name string) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	if _, err := b.retentionClass(name); err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Delete(retentionKey(name))
+	})
+}
+
+// RetentionClasses returns all retention
+// classes in the order of their names.
+func (b *Bucket) RetentionClasses() ([]RetentionClass, error) {
+	classes := make([]RetentionClass, 0)
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(retentionPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var c RetentionClass
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &c)
+			}); err != nil {
+				return err
+			}
+			classes = append(classes, c)
+		}
+		return nil
+	})
+	return classes, err
+}
+
+func (b *Bucket) retentionClass(name string) (RetentionClass, error) {
+	var c RetentionClass
+	err := b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get(retentionKey(name))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &c)
+		})
+	})
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return c, fmt.Errorf("%w: %s", ErrRetentionClassNotFound, name)
+	}
+	return c, err
+}
+
+// applyRetentionClass locks the new object described by meta
+// according to its retention class. It has to be called after
+// the creation time is set.
+func (b *Bucket) applyRetentionClass(meta *Metadata) error {
+	name := meta.Get(MetaKeyRetentionClass)
+	if name == "" {
+		return nil
+	}
+	c, err := b.retentionClass(name)
+	if err != nil {
+		return err
+	}
+	createdAt, err := parseTime(meta.Get(MetaKeyCreatedAt))
+	if err != nil {
+		return err
+	}
+	until := createdAt.Add(c.Duration)
+	if expiresAt, ok := expiryOf(meta); ok && expiresAt.Before(until) {
+		return fmt.Errorf("%w: %s", ErrExpiresBeforeRetention, meta.Get(MetaKeyID))
+	}
+	meta.set(MetaKeyRetainUntil, formatTime(until))
+	return nil
+}
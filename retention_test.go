@@ -0,0 +1,61 @@
+package objst
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRetentionClass(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	if err := b.PutRetentionClass(RetentionClass{Name: "legal"}); !errors.Is(err, ErrInvalidRetentionClass) {
+		t.Fatalf("class without duration should be invalid. Got: %v", err)
+	}
+	if err := b.PutRetentionClass(RetentionClass{Name: "legal", Duration: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	o := tEnv.obj()
+	o.SetMetaKey(MetaKeyRetentionClass, "legal")
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	meta, err := b.GetMeta(o.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.Get(MetaKeyRetainUntil) == "" {
+		t.Fatal("object should be retained by its retention class")
+	}
+	if err := b.DeleteByID(o.ID()); !errors.Is(err, ErrObjectLocked) {
+		t.Fatalf("object should be locked. Got: %v", err)
+	}
+
+	expiring := tEnv.obj()
+	expiring.SetMetaKey(MetaKeyRetentionClass, "legal")
+	if err := b.CreateWithOptions(expiring, CreateOptions{TTL: time.Minute}); !errors.Is(err, ErrExpiresBeforeRetention) {
+		t.Fatalf("object expiring before the retention should be rejected. Got: %v", err)
+	}
+	if err := b.DeleteRetentionClass("legal"); err != nil {
+		t.Fatal(err)
+	}
+	unknown := tEnv.obj()
+	unknown.SetMetaKey(MetaKeyRetentionClass, "legal")
+	if err := b.Create(unknown); !errors.Is(err, ErrRetentionClassNotFound) {
+		t.Fatalf("unknown retention class should be rejected. Got: %v", err)
+	}
+	classes, err := b.RetentionClasses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(classes) != 0 {
+		t.Fatalf("retention class should be deleted. Got: %+v", classes)
+	}
+}
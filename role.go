@@ -0,0 +1,187 @@
+package objst
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/exp/slog"
+)
+
+// roleKey is the key of the role and
+// the epoch of the bucket in the name store.
+const roleKey = "#role"
+
+// Role is the role of a bucket in a primary/standby setup in
+// which the data of the primary is copied to the standby e.g.
+// by shipping backups or snapshots of the stores.
+type Role string
+
+const (
+	// RolePrimary accepts reads and writes. Buckets
+	// are created as primary with the epoch 0.
+	RolePrimary Role = "primary"
+	// RoleStandby rejects all writes with ErrStandby.
+	RoleStandby Role = "standby"
+)
+
+// roleState is the persisted role of the bucket.
+type roleState struct {
+	Role  Role   `json:"role"`
+	Epoch uint64 `json:"epoch"`
+}
+
+// Role returns the role of the bucket and its epoch. The epoch
+// is a fencing token which is increased by every promotion. See
+// Promote and CheckEpoch.
+func (b *Bucket) Role() (Role, uint64) {
+	b.writeMu.RLock()
+	defer b.writeMu.RUnlock()
+	return b.role, b.epoch
+}
+
+// Promote makes the bucket the primary of the epoch. The epoch has
+// to be greater than the epoch of the bucket and of any former
+// primary so clients and the former primary can detect that they
+// are stale using CheckEpoch. It blocks until all in-flight writes
+// are done.
+func (b *Bucket) Promote(epoch uint64) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if epoch <= b.epoch {
+		return fmt.Errorf("%w: %d is not greater than %d", ErrStaleEpoch, epoch, b.epoch)
+	}
+	return b.setRole(RolePrimary, epoch)
+}
+
+// Demote makes the bucket a standby which rejects all writes with
+// ErrStandby. The epoch is the epoch of the new primary and must
+// not be less than the epoch of the bucket. It blocks until all
+// in-flight writes are done so no write is accepted after Demote
+// returned.
+func (b *Bucket) Demote(epoch uint64) error {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	if epoch < b.epoch {
+		return fmt.Errorf("%w: %d is less than %d", ErrStaleEpoch, epoch, b.epoch)
+	}
+	return b.setRole(RoleStandby, epoch)
+}
+
+// CheckEpoch compares the epoch known by a client with the epoch
+// of the bucket. ErrStaleEpoch is returned if the epoch is older
+// and the client has to refresh it. If the epoch is newer another
+// bucket was promoted in the meantime, so the bucket demotes itself
+// to prevent double writes and returns ErrFenced.
+func (b *Bucket) CheckEpoch(epoch uint64) error {
+	_, cur := b.Role()
+	switch {
+	case epoch < cur:
+		return fmt.Errorf("%w: %d is less than %d", ErrStaleEpoch, epoch, cur)
+	case epoch > cur:
+		if err := b.Demote(epoch); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %d", ErrFenced, epoch)
+	}
+	return nil
+}
+
+// setRole persists the role and the epoch.
+// b.writeMu must be held exclusively.
+func (b *Bucket) setRole(role Role, epoch uint64) error {
+	if b.closing {
+		return ErrBucketClosed
+	}
+	if b.frozen {
+		return ErrBucketFrozen
+	}
+	data, err := json.Marshal(roleState{Role: role, Epoch: epoch})
+	if err != nil {
+		return err
+	}
+	err = b.name.Update(func(txn *storeTxn) error {
+		return txn.Set([]byte(roleKey), data)
+	})
+	if err != nil {
+		return err
+	}
+	b.role, b.epoch = role, epoch
+	return nil
+}
+
+// loadRole loads the persisted role of the bucket. Buckets
+// without a persisted role are primaries of the epoch 0.
+func (b *Bucket) loadRole() error {
+	state := roleState{Role: RolePrimary}
+	err := b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get([]byte(roleKey))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &state)
+		})
+	})
+	if err != nil && !errors.Is(err, ErrStoreKeyNotFound) {
+		return err
+	}
+	b.role, b.epoch = state.Role, state.Epoch
+	return nil
+}
+
+// fence checks the epoch of the X-Objst-Epoch header of the
+// request using CheckEpoch. Requests without the header are
+// not checked.
+func (h *HTTPHandler) fence(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(headerEpoch)
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		reqID := r.Context().Value(CtxKeyReqID).(string)
+		epoch, err := strconv.ParseUint(header, 10, 64)
+		if err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, "epoch is not a valid number", http.StatusBadRequest)
+			return
+		}
+		if err := h.bucket.CheckEpoch(epoch); err != nil {
+			h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+			http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Promote promotes the bucket to the primary of
+// the epoch of the `epoch` query parameter.
+func (h *HTTPHandler) Promote(w http.ResponseWriter, r *http.Request) {
+	h.changeRole(w, r, h.bucket.Promote)
+}
+
+// Demote demotes the bucket to a standby of
+// the epoch of the `epoch` query parameter.
+func (h *HTTPHandler) Demote(w http.ResponseWriter, r *http.Request) {
+	h.changeRole(w, r, h.bucket.Demote)
+}
+
+func (h *HTTPHandler) changeRole(w http.ResponseWriter, r *http.Request, change func(uint64) error) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	epoch, err := strconv.ParseUint(r.URL.Query().Get("epoch"), 10, 64)
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "epoch is not a valid number", http.StatusBadRequest)
+		return
+	}
+	if err := change(epoch); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
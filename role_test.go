@@ -0,0 +1,100 @@
+package objst
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestPromoteDemote(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	if role, epoch := b.Role(); role != RolePrimary || epoch != 0 {
+		t.Fatalf("new bucket should be the primary of epoch 0. Got: %s %d", role, epoch)
+	}
+	if err := b.Demote(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Create(tEnv.obj()); !errors.Is(err, ErrStandby) {
+		t.Fatalf("standby should reject writes. Got: %v", err)
+	}
+	b.Shutdown()
+
+	b, err = OpenBucket(b.BasePath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Shutdown()
+	if role, epoch := b.Role(); role != RoleStandby || epoch != 1 {
+		t.Fatalf("role should survive a restart. Got: %s %d", role, epoch)
+	}
+	if err := b.Promote(1); !errors.Is(err, ErrStaleEpoch) {
+		t.Fatalf("promotion without a newer epoch should be rejected. Got: %v", err)
+	}
+	if err := b.Promote(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Create(tEnv.obj()); err != nil {
+		t.Fatalf("primary should accept writes: %v", err)
+	}
+	if err := b.CheckEpoch(1); !errors.Is(err, ErrStaleEpoch) {
+		t.Fatalf("older epoch should be stale. Got: %v", err)
+	}
+	if err := b.CheckEpoch(2); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.CheckEpoch(3); !errors.Is(err, ErrFenced) {
+		t.Fatalf("newer epoch should fence the bucket. Got: %v", err)
+	}
+	if role, epoch := b.Role(); role != RoleStandby || epoch != 3 {
+		t.Fatalf("fenced bucket should be a standby of the newer epoch. Got: %s %d", role, epoch)
+	}
+	if err := b.Demote(2); !errors.Is(err, ErrStaleEpoch) {
+		t.Fatalf("demotion with an older epoch should be rejected. Got: %v", err)
+	}
+}
+
+func TestHTTPFence(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	hopts := DefaultHTTPHandlerOptions()
+	hopts.IsAdmin = isAuthorized
+	h := NewHTTPHandler(b, hopts)
+	serve := func(method, target, epoch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		if epoch != "" {
+			r.Header.Set(headerEpoch, epoch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	if w := serve(http.MethodPost, "/objst/admin/promote?epoch=5", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, "/objst/admin/stats", "4"); w.Code != http.StatusConflict {
+		t.Fatalf("request with a stale epoch should be rejected. Got: %d", w.Code)
+	}
+	if w := serve(http.MethodGet, "/objst/admin/stats", "5"); w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusOK, w.Code)
+	}
+	if w := serve(http.MethodGet, "/objst/admin/stats", "6"); w.Code != http.StatusConflict {
+		t.Fatalf("request with a newer epoch should fence the bucket. Got: %d", w.Code)
+	}
+	if role, _ := b.Role(); role != RoleStandby {
+		t.Fatalf("fenced bucket should be a standby. Got: %s", role)
+	}
+}
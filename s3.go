@@ -0,0 +1,204 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options are the options of NewS3Remote.
+type S3Options struct {
+	// Endpoint is the URL of the S3-compatible service e.g.
+	// https://s3.eu-central-1.amazonaws.com or http://localhost:9000.
+	Endpoint string
+
+	// Region of the bucket. Default: us-east-1.
+	Region string
+
+	// Bucket is the name of the S3 bucket.
+	Bucket string
+
+	// Prefix is prepended to the keys of the
+	// payloads e.g. to share one S3 bucket.
+	Prefix string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is the token of
+	// temporary credentials if any.
+	SessionToken string
+
+	// VirtualHosted addresses the bucket as a subdomain of the
+	// endpoint like AWS recommends. Otherwise the bucket is the
+	// first segment of the path which is supported by all
+	// S3-compatible services e.g. minio.
+	VirtualHosted bool
+
+	// Client sends the requests. Default: http.DefaultClient.
+	Client *http.Client
+}
+
+// s3Remote is a Remote storing the payloads in an S3 bucket. The
+// requests are signed using the AWS signature version 4.
+type s3Remote struct {
+	opts S3Options
+}
+
+// NewS3Remote returns a Remote storing the cold payloads in
+// an S3-compatible service like AWS S3 or minio.
+func NewS3Remote(opts S3Options) Remote {
+	if opts.Region == "" {
+		opts.Region = "us-east-1"
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return &s3Remote{opts: opts}
+}
+
+func (s *s3Remote) Put(ctx context.Context, key string, data []byte) error {
+	res, err := s.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return s3Error(res)
+	}
+	return nil
+}
+
+func (s *s3Remote) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := s.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: %s", ErrRemoteNotFound, key)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		return nil, s3Error(res)
+	}
+	return res.Body, nil
+}
+
+func (s *s3Remote) Delete(ctx context.Context, key string) error {
+	res, err := s.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	}
+	return s3Error(res)
+}
+
+func (s *s3Remote) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	u, err := url.Parse(s.opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	path := "/" + s.opts.Prefix + key
+	if s.opts.VirtualHosted {
+		u.Host = s.opts.Bucket + "." + u.Host
+	} else {
+		path = "/" + s.opts.Bucket + path
+	}
+	u.Path = path
+	u.RawPath = s3Escape(path)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if s.opts.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.opts.SessionToken)
+	}
+	sum := sha256.Sum256(body)
+	signS3(req, hex.EncodeToString(sum[:]), s.opts, time.Now())
+	return s.opts.Client.Do(req)
+}
+
+// signS3 signs the request using the AWS signature version 4. All
+// headers of the request and its host are signed.
+func signS3(req *http.Request, payloadHash string, opts S3Options, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		// the requests have no query
+		"",
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + opts.Region + "/s3/aws4_request"
+	hashed := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashed[:])
+	key := hmacSHA256([]byte("AWS4"+opts.SecretAccessKey), day)
+	for _, part := range []string{opts.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		opts.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// s3Escape escapes all bytes except the unreserved
+// characters of RFC 3986 and the slash.
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func s3Error(res *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(res.Body, 1<<10))
+	return fmt.Errorf("s3: unexpected status %s: %s", res.Status, bytes.TrimSpace(msg))
+}
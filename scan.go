@@ -0,0 +1,108 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+)
+
+// maxScanRanges is the maximum number of ranges the keyspace of
+// the meta store is split into. The ranges are split by the first
+// two hex digits of the ids which are UUIDs.
+const maxScanRanges = 256
+
+// scanRange is a range of ids of the meta store. start
+// is inclusive and end exclusive. A nil start or end
+// means that the range is unbounded.
+type scanRange struct {
+	start, end []byte
+}
+
+// scanRanges splits the keyspace of the meta store into n ranges
+// of equal size. The first and the last range are unbounded so
+// ids which are not lower case UUIDs are scanned as well.
+func scanRanges(n int) []scanRange {
+	if n > maxScanRanges {
+		n = maxScanRanges
+	}
+	ranges := make([]scanRange, 0, n)
+	var start []byte
+	for i := 1; i < n; i++ {
+		end := []byte(fmt.Sprintf("%02x", i*maxScanRanges/n))
+		ranges = append(ranges, scanRange{start: start, end: end})
+		start = end
+	}
+	return append(ranges, scanRange{start: start})
+}
+
+// scanParallel scans the meta store using n workers which are each
+// scanning a range of ids starting at after. The matches are merged
+// in the order of their ids like the matches of a sequential scan.
+// The scan is stopped by the first error or if ctx is done.
+func (b *Bucket) scanParallel(ctx context.Context, n int, after []byte, iterOpts IteratorOptions, decode func(item *storeItem) (match, bool, error)) ([]match, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ranges := scanRanges(n)
+	results := make([][]match, len(ranges))
+	// the first error is returned because the others
+	// can be caused by the cancellation of the scan.
+	var first error
+	var once sync.Once
+	var wg sync.WaitGroup
+	for i, r := range ranges {
+		if r.end != nil && bytes.Compare(r.end, after) <= 0 {
+			continue
+		}
+		if bytes.Compare(r.start, after) < 0 {
+			r.start = after
+		}
+		wg.Add(1)
+		go func(i int, r scanRange) {
+			defer wg.Done()
+			var err error
+			results[i], err = b.scanRange(ctx, r, iterOpts, decode)
+			if err != nil {
+				once.Do(func() {
+					first = err
+					cancel()
+				})
+			}
+		}(i, r)
+	}
+	wg.Wait()
+	if first != nil {
+		return nil, first
+	}
+	matches := make([]match, 0)
+	for _, res := range results {
+		matches = append(matches, res...)
+	}
+	return matches, nil
+}
+
+func (b *Bucket) scanRange(ctx context.Context, r scanRange, iterOpts IteratorOptions, decode func(item *storeItem) (match, bool, error)) ([]match, error) {
+	matches := make([]match, 0)
+	err := b.meta.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(iterOpts)
+		defer it.Close()
+		for it.Seek(r.start); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			if r.end != nil && bytes.Compare(item.Key(), r.end) >= 0 {
+				return nil
+			}
+			m, ok, err := decode(item)
+			if err != nil {
+				return err
+			}
+			if ok {
+				matches = append(matches, m)
+			}
+		}
+		return nil
+	})
+	return matches, err
+}
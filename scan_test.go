@@ -0,0 +1,127 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestScanRanges(t *testing.T) {
+	for _, n := range []int{1, 3, 16, 1000} {
+		ranges := scanRanges(n)
+		want := n
+		if want > maxScanRanges {
+			want = maxScanRanges
+		}
+		if len(ranges) != want {
+			t.Fatalf("number of ranges is not %d. Got: %d", want, len(ranges))
+		}
+		if ranges[0].start != nil || ranges[len(ranges)-1].end != nil {
+			t.Fatalf("first and last range should be unbounded. Got: %+v", ranges)
+		}
+		for i := 1; i < len(ranges); i++ {
+			if string(ranges[i].start) != string(ranges[i-1].end) {
+				t.Fatalf("ranges should be contiguous. Got: %+v", ranges)
+			}
+		}
+	}
+}
+
+func TestParallelScan(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.ScanConcurrency = 4
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	objs := tEnv.nObj(50)
+	if err := b.BatchCreate(objs); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		ids = append(ids, obj.ID())
+	}
+	slices.Sort(ids)
+	got, err := b.getMatchingIDs(NewQuery().Param(MetaKeyName, ".*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, ids) {
+		t.Fatalf("parallel scan should return all ids in order. Got: %v", got)
+	}
+	b.opts.ScanConcurrency = 1
+	want, err := b.getMatchingIDs(NewQuery().Param(MetaKeyName, ".*").OrderBy(MetaKeyName, Desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.opts.ScanConcurrency = 4
+	got, err = b.getMatchingIDs(NewQuery().Param(MetaKeyName, ".*").OrderBy(MetaKeyName, Desc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("parallel and sequential scan should be equal. Got: %v. Want: %v", got, want)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.getMatchingIDs(NewQuery().Param(MetaKeyName, ".*").WithContext(ctx)); !errors.Is(err, context.Canceled) {
+		t.Fatalf("scan should be stopped by the context. Got: %v", err)
+	}
+}
+
+func TestKeysOnlyScan(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.ScanConcurrency = 1
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	objs := tEnv.nObj(20)
+	if err := b.BatchCreate(objs); err != nil {
+		t.Fatal(err)
+	}
+	id := objs[0].ID()
+	q := NewQuery().Filter(Or(Eq(MetaKeyID, id), Not(Prefix(MetaKeyID, ""))))
+	if !q.keysOnly() {
+		t.Fatal("query comparing only ids should be evaluated using the keys")
+	}
+	if NewQuery().Filter(Eq(MetaKeyID, id)).OrderBy(MetaKeyName, Asc).keysOnly() {
+		t.Fatal("query ordered by another key should read the meta data")
+	}
+	if NewQuery().Filter(Eq(MetaKeyID, id)).Owner(tEnv.owner()).keysOnly() {
+		t.Fatal("query comparing another key should read the meta data")
+	}
+	got, err := b.getMatchingIDs(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, []string{id}) {
+		t.Fatalf("keys only scan should return the matching id. Got: %v", got)
+	}
+	b.opts.ScanConcurrency = 4
+	iterOpts := IteratorOptions{PrefetchValues: false, PrefetchSize: 1}
+	got, err = b.getMatchingIDs(NewQuery().Param(MetaKeyName, ".*").Iterator(iterOpts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(objs) {
+		t.Fatalf("scan without prefetching should return all ids. Got: %d", len(got))
+	}
+	deleted, err := b.Execute(NewQuery().Filter(Eq(MetaKeyID, id)).Operation(OperationDelete))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(deleted) != 1 || deleted[0].ID() != id {
+		t.Fatalf("delete by id should delete the object. Got: %v", deleted)
+	}
+}
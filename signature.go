@@ -0,0 +1,60 @@
+package objst
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+)
+
+// systemPairs returns the system meta data except the signature
+// encoded in a deterministic format which is signed by signMeta.
+func (m Metadata) systemPairs() string {
+	values := url.Values{}
+	for _, k := range m.systemKeys {
+		if v, ok := m.data[k]; ok && k != MetaKeySignature {
+			values.Set(string(k), v)
+		}
+	}
+	return values.Encode()
+}
+
+// signature returns the hex encoded HMAC-SHA256
+// of the system meta data using the key.
+func (m Metadata) signature(key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(m.systemPairs()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// marshalMeta marshals the meta data to be stored in the meta
+// store. The system meta data is signed if strict mode is
+// enabled by BucketOptions.MetaSigningKey.
+func (b *Bucket) marshalMeta(meta *Metadata) ([]byte, error) {
+	if len(b.opts.MetaSigningKey) > 0 {
+		meta.set(MetaKeySignature, meta.signature(b.opts.MetaSigningKey))
+	}
+	return b.encodeMeta(meta)
+}
+
+// unmarshalMeta unmarshals the meta data read from the meta
+// store. In strict mode ErrMetaTampered is returned if the
+// signature of the system meta data is missing or invalid.
+func (b *Bucket) unmarshalMeta(meta *Metadata, data []byte) error {
+	if err := b.decodeMeta(meta, data); err != nil {
+		return err
+	}
+	if len(b.opts.MetaSigningKey) == 0 {
+		return nil
+	}
+	got, err := hex.DecodeString(meta.Get(MetaKeySignature))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrMetaTampered, meta.Get(MetaKeyID))
+	}
+	want, _ := hex.DecodeString(meta.signature(b.opts.MetaSigningKey))
+	if !hmac.Equal(got, want) {
+		return fmt.Errorf("%w: %s", ErrMetaTampered, meta.Get(MetaKeyID))
+	}
+	return nil
+}
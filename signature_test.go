@@ -0,0 +1,88 @@
+package objst
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMetaSignature(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.MetaSigningKey = []byte("secret-of-the-test")
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := b.UpdateMeta(o.ID(), map[MetaKey]string{"foo": "bar"}); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := b.GetByID(o.ID()); err != nil {
+		t.Fatalf("signed meta data should be valid. Got: %v", err)
+	}
+
+	tamper := func(fn func(meta *Metadata)) {
+		t.Helper()
+		err := b.meta.Update(func(txn *storeTxn) error {
+			item, err := txn.Get([]byte(o.ID()))
+			if err != nil {
+				return err
+			}
+			meta := NewMetadata()
+			err = item.Value(func(val []byte) error {
+				return b.decodeMeta(meta, val)
+			})
+			if err != nil {
+				return err
+			}
+			fn(meta)
+			data, err := b.encodeMeta(meta)
+			if err != nil {
+				return err
+			}
+			return txn.Set([]byte(o.ID()), data)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	tamper(func(meta *Metadata) {
+		meta.data[MetaKeyCreatedAt] = formatTime(time.Now().AddDate(-10, 0, 0))
+	})
+	if _, err := b.GetByID(o.ID()); !errors.Is(err, ErrMetaTampered) {
+		t.Fatalf("modified createdAt should be detected. Got: %v", err)
+	}
+	if err := b.UpdateMeta(o.ID(), map[MetaKey]string{"foo": "baz"}); !errors.Is(err, ErrMetaTampered) {
+		t.Fatalf("tampered meta data should not be re-signed by an update. Got: %v", err)
+	}
+	tamper(func(meta *Metadata) {
+		delete(meta.data, MetaKeySignature)
+	})
+	if _, err := b.GetMeta(o.ID()); !errors.Is(err, ErrMetaTampered) {
+		t.Fatalf("missing signature should be detected. Got: %v", err)
+	}
+
+	// user defined meta data is not signed
+	o = tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	tamper(func(meta *Metadata) {
+		meta.data["foo"] = "bar"
+	})
+	if _, err := b.GetByID(o.ID()); err != nil {
+		t.Fatalf("user defined meta data should not be verified. Got: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package objst
+
+import (
+	"sync"
+	"time"
+)
+
+// SlowOp is an operation of the bucket which took
+// longer than the SlowOpThreshold of the bucket.
+type SlowOp struct {
+	// Op is the name of the method e.g. GetByID
+	Op       string        `json:"op"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	// IDs are the ids of the affected objects
+	IDs []string `json:"ids,omitempty"`
+	// Query is the query of Execute
+	Query string `json:"query,omitempty"`
+	// Size is the number of payload bytes
+	// read or written by the operation.
+	Size int64 `json:"size,omitempty"`
+}
+
+// slowLog is a ring buffer of the most recent slow operations.
+type slowLog struct {
+	mu        sync.Mutex
+	threshold time.Duration
+	ops       []SlowOp
+	next      int
+}
+
+func newSlowLog(threshold time.Duration, size int) *slowLog {
+	if size < 0 {
+		size = 0
+	}
+	return &slowLog{
+		threshold: threshold,
+		ops:       make([]SlowOp, 0, size),
+	}
+}
+
+func (l *slowLog) record(op SlowOp) {
+	if l.threshold <= 0 || cap(l.ops) == 0 || op.Duration < l.threshold {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.ops) < cap(l.ops) {
+		l.ops = append(l.ops, op)
+		return
+	}
+	l.ops[l.next] = op
+	l.next = (l.next + 1) % cap(l.ops)
+}
+
+// list returns the slow operations from the oldest to the newest.
+func (l *slowLog) list() []SlowOp {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ops := make([]SlowOp, 0, len(l.ops))
+	ops = append(ops, l.ops[l.next:]...)
+	return append(ops, l.ops[:l.next]...)
+}
+
+// SlowOps returns the most recent operations which took longer
+// than SlowOpThreshold from the oldest to the newest one.
+func (b *Bucket) SlowOps() []SlowOp {
+	return b.slowOps.list()
+}
+
+// startOp starts the time measurement of the operation.
+// finishOp has to be called when the operation is done.
+func (b *Bucket) startOp(name string, ids ...string) *SlowOp {
+	b.opsMu.Lock()
+	b.ops++
+	b.opsMu.Unlock()
+	return &SlowOp{
+		Op:    name,
+		Start: time.Now(),
+		IDs:   ids,
+	}
+}
+
+// finishOp records the operation if it was slow.
+func (b *Bucket) finishOp(op *SlowOp) {
+	op.Duration = time.Since(op.Start)
+	b.slowOps.record(*op)
+	b.opsMu.Lock()
+	defer b.opsMu.Unlock()
+	if b.ops--; b.ops == 0 && b.drained != nil {
+		close(b.drained)
+		b.drained = nil
+	}
+}
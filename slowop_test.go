@@ -0,0 +1,53 @@
+package objst
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSlowOps(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.SlowOpThreshold = time.Nanosecond
+	opts.SlowOpLogSize = 2
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+
+	o := tEnv.obj()
+	if err := b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := b.GetByID(o.ID()); err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := b.Execute(NewQuery().Owner(o.Owner()).Limit(1)); err != nil {
+		t.Error(err)
+		return
+	}
+	ops := b.SlowOps()
+	if len(ops) != 2 {
+		t.Fatalf("only the last %d operations should be kept. Got: %d", opts.SlowOpLogSize, len(ops))
+	}
+	if ops[0].Op != "GetByID" || ops[0].IDs[0] != o.ID() || ops[0].Size != int64(len(o.Payload())) {
+		t.Fatalf("slow get is not recorded correctly: %+v", ops[0])
+	}
+	if ops[1].Op != "Execute" || ops[1].Query == "" {
+		t.Fatalf("slow query is not recorded correctly: %+v", ops[1])
+	}
+}
+
+func TestSlowOpsDisabled(t *testing.T) {
+	b := &Bucket{slowOps: newSlowLog(0, 10)}
+	b.finishOp(b.startOp("Create"))
+	if len(b.SlowOps()) != 0 {
+		t.Fatalf("no operation should be recorded if the threshold is not positive")
+	}
+}
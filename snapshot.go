@@ -0,0 +1,134 @@
+package objst
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// snapshotDir is the directory of the snapshots in the base path.
+const snapshotDir = "snapshots"
+
+// Snapshot creates a consistent point-in-time copy of the bucket
+// with the name by streaming a full backup into a new bucket. Writes
+// are blocked until the copy is written while reads are still
+// possible. The snapshot is opened read-only using OpenSnapshot e.g.
+// to run reports or experiments against frozen data.
+func (b *Bucket) Snapshot(name string) error {
+	defer b.finishOp(b.startOp("Snapshot"))
+	if err := validateSnapshotName(name); err != nil {
+		return err
+	}
+	path := b.snapshotPath(name)
+	if _, err := os.Stat(path); err == nil {
+		return ErrSnapshotExists
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// the snapshot is written to a hidden directory
+	// so an incomplete snapshot is never opened
+	tmp, err := os.MkdirTemp(filepath.Dir(path), "."+name+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	snap, err := openBucket(tmp, b.snapshotOptions(), false)
+	if err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	backupErr := make(chan error, 1)
+	go func() {
+		_, err := b.Backup(pw, 0)
+		pw.CloseWithError(err)
+		backupErr <- err
+	}()
+	err = snap.Restore(pr)
+	// a failed restore stops the backup
+	pr.CloseWithError(err)
+	if berr := <-backupErr; err == nil {
+		err = berr
+	}
+	if serr := snap.Shutdown(); err == nil {
+		err = serr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// OpenSnapshot opens the snapshot with the name read-only. The
+// returned bucket rejects all writes with ErrBucketFrozen and
+// has to be closed independently of b.
+func (b *Bucket) OpenSnapshot(name string) (*Bucket, error) {
+	if err := validateSnapshotName(name); err != nil {
+		return nil, err
+	}
+	path := b.snapshotPath(name)
+	if !isDir(path) {
+		return nil, ErrSnapshotNotFound
+	}
+	return openBucket(path, b.snapshotOptions(), true)
+}
+
+// Snapshots returns the names of the snapshots of the bucket.
+func (b *Bucket) Snapshots() ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(b.BasePath, snapshotDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// DeleteSnapshot removes the snapshot with the name.
+// The snapshot must not be opened.
+func (b *Bucket) DeleteSnapshot(name string) error {
+	if err := validateSnapshotName(name); err != nil {
+		return err
+	}
+	path := b.snapshotPath(name)
+	if !isDir(path) {
+		return ErrSnapshotNotFound
+	}
+	return os.RemoveAll(path)
+}
+
+func (b *Bucket) snapshotPath(name string) string {
+	return filepath.Join(b.BasePath, snapshotDir, name)
+}
+
+// snapshotOptions returns the options of the snapshots
+// which are running none of the background workers.
+func (b *Bucket) snapshotOptions() BucketOptions {
+	opts := b.opts
+	opts.ArchiveDir = ""
+	opts.LifecycleInterval = 0
+	opts.UsageReconcileInterval = 0
+	opts.StallCheckInterval = 0
+	opts.GCInterval = 0
+	opts.EventDeliveryInterval = 0
+	opts.BackupInterval = 0
+	return opts
+}
+
+func validateSnapshotName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return ErrInvalidSnapshotName
+	}
+	return nil
+}
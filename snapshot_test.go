@@ -0,0 +1,67 @@
+package objst
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	before := tEnv.obj()
+	if err := b.Create(before); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Snapshot("report"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Snapshot("report"); !errors.Is(err, ErrSnapshotExists) {
+		t.Fatalf("snapshot with the same name should be rejected. Got: %v", err)
+	}
+	if err := b.Snapshot("../report"); !errors.Is(err, ErrInvalidSnapshotName) {
+		t.Fatalf("name of the snapshot should be a file name. Got: %v", err)
+	}
+	after := tEnv.obj()
+	if err := b.Create(after); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := b.OpenSnapshot("report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := snap.GetByID(before.ID()); err != nil {
+		t.Fatalf("snapshot should contain the objects created before: %v", err)
+	}
+	if _, err := snap.GetByID(after.ID()); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("snapshot should not contain the objects created after. Got: %v", err)
+	}
+	if err := snap.Create(tEnv.obj()); !errors.Is(err, ErrBucketFrozen) {
+		t.Fatalf("snapshot should be read-only. Got: %v", err)
+	}
+	if err := snap.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := b.Snapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"report"}) {
+		t.Fatalf("snapshots should be listed. Got: %v", names)
+	}
+	if err := b.DeleteSnapshot("report"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.OpenSnapshot("report"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Fatalf("deleted snapshot should not be found. Got: %v", err)
+	}
+}
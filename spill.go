@@ -0,0 +1,120 @@
+package objst
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// idSpool holds the ids of a large result set. The first
+// limit ids are held in memory and all ids are written to a
+// temporary file as soon as the limit is exceeded so the memory
+// usage is bounded regardless of the number of ids.
+type idSpool struct {
+	limit int
+	dir   string
+	ids   []string
+	file  *os.File
+	w     *bufio.Writer
+}
+
+// newIDSpool returns a spool spilling to a temporary file in dir
+// if more than limit ids are added. If limit is not positive the
+// ids are never spilled.
+func newIDSpool(limit int, dir string) *idSpool {
+	return &idSpool{limit: limit, dir: dir, ids: make([]string, 0)}
+}
+
+func (s *idSpool) add(id string) error {
+	if s.file == nil && (s.limit <= 0 || len(s.ids) < s.limit) {
+		s.ids = append(s.ids, id)
+		return nil
+	}
+	if s.file == nil {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	_, err := s.w.WriteString(id + "\n")
+	return err
+}
+
+// spill moves the ids held in memory to the temporary file.
+func (s *idSpool) spill() error {
+	f, err := os.CreateTemp(s.dir, "objst-ids-*")
+	if err != nil {
+		return err
+	}
+	s.file = f
+	s.w = bufio.NewWriter(f)
+	for _, id := range s.ids {
+		if _, err := s.w.WriteString(id + "\n"); err != nil {
+			return err
+		}
+	}
+	s.ids = nil
+	return nil
+}
+
+// each calls fn for every id in the order they were added
+// until fn returns an error.
+func (s *idSpool) each(fn func(id string) error) error {
+	if s.file == nil {
+		for _, id := range s.ids {
+			if err := fn(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	sc := bufio.NewScanner(s.file)
+	for sc.Scan() {
+		if err := fn(sc.Text()); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// Close removes the temporary file if the ids were spilled.
+func (s *idSpool) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	if rmErr := os.Remove(s.file.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// spoolMatchingIDs returns the spool of the ids of the objects
+// matching the query. The ids of streamable queries are spooled
+// while the meta data is scanned. The spool has to be closed.
+func (b *Bucket) spoolMatchingIDs(q *Query) (*idSpool, error) {
+	spool := newIDSpool(b.opts.SpillThreshold, b.opts.SpillDir)
+	if q.streamable() {
+		if _, _, err := b.queryIDs(q, nil, spool.add); err != nil {
+			spool.Close()
+			return nil, err
+		}
+		return spool, nil
+	}
+	ids, err := b.getMatchingIDs(q)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := spool.add(id); err != nil {
+			spool.Close()
+			return nil, err
+		}
+	}
+	return spool, nil
+}
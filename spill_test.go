@@ -0,0 +1,87 @@
+package objst
+
+import (
+	"os"
+	"testing"
+)
+
+func TestIDSpool(t *testing.T) {
+	dir := t.TempDir()
+	s := newIDSpool(2, dir)
+	want := []string{"a", "b", "c", "d"}
+	for _, id := range want {
+		if err := s.add(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.file == nil || len(s.ids) != 0 {
+		t.Fatal("ids exceeding the limit should be spilled to a file")
+	}
+	got := make([]string, 0, len(want))
+	if err := s.each(func(id string) error {
+		got = append(got, id)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) || got[0] != "a" || got[3] != "d" {
+		t.Fatalf("ids should be passed in the order they were added. Got: %v", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Fatalf("temporary file should be removed. Got: %d entries", len(entries))
+	}
+}
+
+func TestDeleteSpilled(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.SpillThreshold = 3
+	opts.SpillDir = t.TempDir()
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	owner := tEnv.owner()
+	for i := 0; i < 10; i++ {
+		o, err := NewObject(tEnv.name(), owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		o.Write(tEnv.payload(10))
+		if err := b.Create(o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Create(tEnv.obj()); err != nil {
+		t.Fatal(err)
+	}
+	n, err := b.Delete(NewQuery().Owner(owner).Operation(OperationDelete).DryRun())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Fatalf("dry run should count all matching objects. Got: %d", n)
+	}
+	n, err = b.Delete(NewQuery().Owner(owner).Operation(OperationDelete))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Fatalf("all matching objects should be deleted. Got: %d", n)
+	}
+	objs, err := b.Execute(NewQuery().Owner(owner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 0 {
+		t.Fatalf("no object of the owner should be left. Got: %d", len(objs))
+	}
+	if entries, _ := os.ReadDir(opts.SpillDir); len(entries) != 0 {
+		t.Fatalf("temporary files should be removed. Got: %d entries", len(entries))
+	}
+}
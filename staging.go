@@ -0,0 +1,488 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/exp/slog"
+)
+
+const stagingPrefix = "staging/"
+
+// Upload is streaming the payload of an object into a
+// staging area of the bucket. The object is invisible
+// to any read or query until the upload is committed.
+// The state of the upload is persisted with every staged
+// chunk so it can be resumed after a restart using
+// Bucket.ResumeUpload. Interrupted uploads which are
+// neither committed nor aborted can be removed using
+// Bucket.PurgeStaging.
+type Upload struct {
+	b   *Bucket
+	obj *Object
+	// chunkSize of the staged payload.
+	// Not positive if the payload is
+	// stored as one chunk.
+	chunkSize int64
+	// encode is true if the payload has to be
+	// compressed or encrypted which requires the whole
+	// payload to be buffered until the commit.
+	encode bool
+	// buf contains the bytes which are
+	// not yet written as a full chunk.
+	buf []byte
+	// head contains the first bytes of the payload
+	// to detect the content type. See detectContentType.
+	head []byte
+	// next is the index of the next chunk
+	next int64
+	size int64
+	// staged is the number of bytes in the staged chunks.
+	staged    int64
+	startedAt time.Time
+	// h is the hash of the staged chunks.
+	h hash.Hash
+}
+
+// uploadState is the persisted state of an upload.
+type uploadState struct {
+	Meta      map[MetaKey]string `json:"meta"`
+	ChunkSize int64              `json:"chunkSize"`
+	Encode    bool               `json:"encode,omitempty"`
+	// Next is the index of the next chunk and Offset
+	// the number of bytes in the staged chunks.
+	Next   int64 `json:"next"`
+	Offset int64 `json:"offset"`
+	// Hash is the marshaled state of the
+	// hash of the staged chunks.
+	Hash      []byte    `json:"hash"`
+	Head      []byte    `json:"head,omitempty"`
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// UploadStatus is the persisted progress of an upload.
+type UploadStatus struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	// Offset is the number of bytes of the payload which
+	// are persisted. A resumed upload has to continue
+	// with the payload starting at the offset.
+	Offset    int64     `json:"offset"`
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// ExpiresAt is the time after which the staged data
+	// is purged. It is nil if uploads never expire. See
+	// BucketOptions.UploadRetention.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// NewUpload starts a new upload for the given object. The
+// id of the object is used as the id of the upload. Any
+// payload which was already written to the object will
+// be staged as the beginning of the payload.
+func (b *Bucket) NewUpload(obj *Object) (*Upload, error) {
+	if !obj.isMutable {
+		return nil, ErrObjectIsImmutable
+	}
+	done, err := b.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	u := &Upload{
+		b:         b,
+		obj:       obj,
+		chunkSize: b.opts.ChunkSize,
+		encode:    b.opts.Cipher != nil || b.opts.PayloadCompression != CompressionNone,
+		startedAt: time.Now().UTC(),
+		h:         sha256.New(),
+	}
+	err = b.payload.Update(func(txn *storeTxn) error {
+		return u.setState(txn)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := u.write(obj.Payload()); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ResumeUpload returns the upload with the id from its persisted
+// state e.g. after a restart. The payload has to be written
+// starting at the Offset of the upload because the bytes which
+// were not staged as a full chunk are lost. Uploads which are
+// compressed or encrypted are buffered until the commit and
+// have to be written from the beginning.
+func (b *Bucket) ResumeUpload(id string) (*Upload, error) {
+	done, err := b.beginWrite()
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	state, err := b.uploadState(id)
+	if err != nil {
+		return nil, err
+	}
+	if state.Meta == nil {
+		return nil, fmt.Errorf("%w: %s", ErrUploadNotResumable, id)
+	}
+	h := sha256.New()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(state.Hash); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUploadNotResumable, err)
+	}
+	meta := NewMetadata()
+	meta.data = state.Meta
+	return &Upload{
+		b:         b,
+		obj:       &Object{meta: meta, pl: new(bytes.Buffer), isMutable: true},
+		chunkSize: state.ChunkSize,
+		encode:    state.Encode,
+		head:      state.Head,
+		next:      state.Next,
+		size:      state.Offset,
+		staged:    state.Offset,
+		startedAt: state.StartedAt,
+		h:         h,
+	}, nil
+}
+
+// UploadStatus returns the persisted progress of the upload.
+// ErrUploadNotFound is returned if the upload doesn't exist
+// e.g. because it was committed, aborted or purged.
+func (b *Bucket) UploadStatus(id string) (UploadStatus, error) {
+	state, err := b.uploadState(id)
+	if err != nil {
+		return UploadStatus{}, err
+	}
+	status := UploadStatus{
+		ID:        id,
+		Name:      state.Meta[MetaKeyName],
+		Owner:     state.Meta[MetaKeyOwner],
+		Offset:    state.Offset,
+		StartedAt: state.StartedAt,
+		UpdatedAt: state.UpdatedAt,
+	}
+	if b.opts.UploadRetention > 0 {
+		expiresAt := state.UpdatedAt.Add(b.opts.UploadRetention)
+		status.ExpiresAt = &expiresAt
+	}
+	return status, nil
+}
+
+// Offset returns the number of bytes written to the upload.
+func (u *Upload) Offset() int64 {
+	return u.size
+}
+
+// setState persists the state of the upload.
+func (u *Upload) setState(txn *storeTxn) error {
+	sum, err := u.h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&uploadState{
+		Meta:      u.obj.meta.data,
+		ChunkSize: u.chunkSize,
+		Encode:    u.encode,
+		Next:      u.next,
+		Offset:    u.staged,
+		Hash:      sum,
+		Head:      u.head,
+		StartedAt: u.startedAt,
+		UpdatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return txn.Set(stagingKey(u.obj.ID()), data)
+}
+
+func (b *Bucket) uploadState(id string) (uploadState, error) {
+	var state uploadState
+	err := b.payload.View(func(txn *storeTxn) error {
+		item, err := txn.Get(stagingKey(id))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			state, err = parseUploadState(val)
+			return err
+		})
+	})
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return state, fmt.Errorf("%w: %s", ErrUploadNotFound, id)
+	}
+	return state, err
+}
+
+// parseUploadState parses the persisted state of an upload.
+// Uploads of older versions only persisted the time they were
+// started at and can't be resumed.
+func parseUploadState(val []byte) (uploadState, error) {
+	var state uploadState
+	if err := json.Unmarshal(val, &state); err == nil {
+		return state, nil
+	}
+	startedAt, err := time.Parse(time.RFC3339Nano, string(val))
+	if err != nil {
+		return state, err
+	}
+	state.StartedAt, state.UpdatedAt = startedAt, startedAt
+	return state, nil
+}
+
+// ID returns the id of the upload
+// which is the id of the object.
+func (u *Upload) ID() string {
+	return u.obj.ID()
+}
+
+// Write stages p as the next part of the payload.
+func (u *Upload) Write(p []byte) (int, error) {
+	done, err := u.b.beginWrite()
+	if err != nil {
+		return 0, err
+	}
+	defer done()
+	return u.write(p)
+}
+
+func (u *Upload) write(p []byte) (int, error) {
+	if !u.obj.isMutable {
+		return 0, ErrObjectIsImmutable
+	}
+	if missing := sniffLen - len(u.head); missing > 0 {
+		if missing > len(p) {
+			missing = len(p)
+		}
+		u.head = append(u.head, p[:missing]...)
+	}
+	u.size += int64(len(p))
+	u.buf = append(u.buf, p...)
+	for !u.encode && u.chunkSize > 0 && int64(len(u.buf)) >= u.chunkSize {
+		if err := u.writeChunk(u.buf[:u.chunkSize]); err != nil {
+			return 0, err
+		}
+		u.buf = u.buf[u.chunkSize:]
+	}
+	return len(p), nil
+}
+
+// Commit promotes the staged payload to the final object
+// making it visible to reads and queries. The object is
+// immutable after a successful commit.
+func (u *Upload) Commit() error {
+	done, err := u.b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	op := u.b.startOp("Commit", u.obj.ID())
+	op.Size = u.size
+	defer u.b.finishOp(op)
+	if u.size == 0 {
+		return ErrEmptyPayload
+	}
+	u.b.detectContentType(u.obj, u.head)
+	if err := u.obj.isValidMeta(u.b.opts.OwnerValidator); err != nil {
+		return err
+	}
+	if u.b.isNameExisting(u.obj.Name(), u.obj.Owner()) {
+		return fmt.Errorf("%w: %s of %s", ErrDuplicateName, u.obj.Name(), u.obj.Owner())
+	}
+	release, err := u.b.reserveQuota(Usage{
+		Owner:       u.obj.Owner(),
+		ContentType: u.obj.GetMetaKey(MetaKeyContentType),
+		Objects:     1,
+		Bytes:       u.size,
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+	chunkSize := u.chunkSize
+	if chunkSize <= 0 {
+		chunkSize = u.size
+	}
+	u.obj.meta.set(MetaKeySize, strconv.FormatInt(u.size, 10))
+	if u.encode {
+		u.h.Write(u.buf)
+		enc, err := u.b.encodePayload(u.obj.meta, u.buf)
+		if err != nil {
+			return err
+		}
+		entries, err := u.b.splitPayload(u.obj.ID(), chunkSize, enc)
+		if err != nil {
+			return err
+		}
+		if err := u.b.insertPayload(entries); err != nil {
+			return err
+		}
+		u.buf = nil
+	}
+	if len(u.buf) > 0 {
+		if err := u.writeChunk(u.buf); err != nil {
+			return err
+		}
+		u.buf = nil
+	}
+	now := formatTime(time.Now())
+	u.obj.meta.set(MetaKeyCreatedAt, now)
+	u.obj.meta.set(MetaKeyUpdatedAt, now)
+	u.obj.meta.set(MetaKeyGeneration, newGeneration())
+	u.obj.meta.set(MetaKeyChunkSize, strconv.FormatInt(chunkSize, 10))
+	u.obj.meta.set(MetaKeyChecksum, hex.EncodeToString(u.h.Sum(nil)))
+	// the hooks load the staged payload lazily
+	u.obj.bucket, u.obj.staged = u.b, true
+	err = u.b.beforeCreate(u.obj)
+	u.obj.staged = false
+	if err != nil {
+		return err
+	}
+	u.obj.meta.set(MetaKeyETag, u.obj.etag())
+	if err := u.b.applyRetentionClass(u.obj.meta); err != nil {
+		return err
+	}
+	if err := u.b.validateMeta(u.obj.meta); err != nil {
+		return err
+	}
+	if err := u.b.reserveNames(u.obj.meta); err != nil {
+		return err
+	}
+	// writing the meta data makes the object visible
+	if err := u.b.writeMeta(u.obj.ID(), u.obj.meta); err != nil {
+		return errors.Join(err, u.b.releaseNames(u.obj.meta), u.b.deleteStagingKey(u.obj.ID()))
+	}
+	if err := u.b.metaInserted(u.obj.ID(), u.obj.meta); err != nil {
+		return err
+	}
+	u.obj.markAsImmutable()
+	if err := u.b.deleteStagingKey(u.obj.ID()); err != nil {
+		return err
+	}
+	u.b.afterCreate(u.obj)
+	return nil
+}
+
+// Abort discards all staged data of the upload.
+func (u *Upload) Abort() error {
+	u.buf = nil
+	if err := u.b.deletePayload(u.obj.ID()); err != nil {
+		return err
+	}
+	return u.b.deleteStagingKey(u.obj.ID())
+}
+
+// writeChunk stages the chunk and persists the
+// state of the upload in the same transaction.
+func (u *Upload) writeChunk(chunk []byte) error {
+	u.h.Write(chunk)
+	next := u.next
+	u.next++
+	u.staged += int64(len(chunk))
+	err := u.b.payload.Update(func(txn *storeTxn) error {
+		if err := txn.Set(chunkKey(u.obj.ID(), next), chunk); err != nil {
+			return err
+		}
+		return u.setState(txn)
+	})
+	if err != nil {
+		u.next--
+		u.staged -= int64(len(chunk))
+		return err
+	}
+	return nil
+}
+
+// PurgeStaging removes the staged data of all uploads
+// which were not written to for the given duration and
+// are neither committed nor aborted e.g. because of a
+// crash. It is called every LifecycleInterval by the
+// lifecycle worker if UploadRetention is positive. The
+// payload of committed uploads whose staging key was not
+// deleted e.g. because of a crash during the commit is kept.
+func (b *Bucket) PurgeStaging(ctx context.Context, olderThan time.Duration) error {
+	ids := make([]string, 0)
+	err := b.payload.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(stagingPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			err := it.Item().Value(func(val []byte) error {
+				state, err := parseUploadState(val)
+				if err != nil {
+					return err
+				}
+				if time.Since(state.UpdatedAt) >= olderThan {
+					ids = append(ids, string(it.Item().Key()[len(prefix):]))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		// the meta data is inserted before the staging
+		// key is deleted by Upload.Commit
+		if !b.isIDExisting(id) {
+			if err := b.deletePayload(id); err != nil {
+				return err
+			}
+		}
+		if err := b.deleteStagingKey(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Bucket) deleteStagingKey(id string) error {
+	return b.payload.Update(func(txn *storeTxn) error {
+		return txn.Delete(stagingKey(id))
+	})
+}
+
+func stagingKey(id string) []byte {
+	return []byte(stagingPrefix + id)
+}
+
+// UploadStatus returns the persisted progress of the upload
+// of the owner. Uploads of other owners are not found.
+func (h *HTTPHandler) UploadStatus(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	owner := r.Context().Value(CtxKeyOwner).(string)
+	status, err := h.bucket.UploadStatus(chi.URLParam(r, "id"))
+	if err == nil && status.Owner != owner {
+		err = ErrUploadNotFound
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	h.writeJSON(w, r, status)
+}
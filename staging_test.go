@@ -0,0 +1,192 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestUploadCommit(t *testing.T) {
+	o := tEnv.emptyObj()
+	u, err := tEnv.b.NewUpload(o)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	pl := tEnv.payload(100)
+	if _, err := u.Write(pl); err != nil {
+		t.Error(err)
+		return
+	}
+	objs, err := tEnv.b.Execute(NewQuery().Owner(o.Owner()))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(objs) != 0 {
+		t.Fatalf("staged object should not be visible to queries")
+	}
+	if err := u.Commit(); err != nil {
+		t.Error(err)
+		return
+	}
+	oG, err := tEnv.b.GetByID(u.ID())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(oG.Payload(), pl) {
+		t.Fatalf("payload is not the same. Got: %s. Expected: %s", oG.Payload(), pl)
+	}
+	if err := tEnv.b.Verify(u.ID()); err != nil {
+		t.Fatalf("checksum of a committed upload should be valid: %v", err)
+	}
+}
+
+func TestPurgeStaging(t *testing.T) {
+	o := tEnv.emptyObj()
+	u, err := tEnv.b.NewUpload(o)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := u.Write(tEnv.payload(10)); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := u.writeChunk(u.buf); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.PurgeStaging(context.Background(), 0); err != nil {
+		t.Error(err)
+		return
+	}
+	err = tEnv.b.payload.View(func(txn *storeTxn) error {
+		_, err := readChunks(txn, u.ID())
+		return err
+	})
+	if !errors.Is(err, badger.ErrKeyNotFound) {
+		t.Fatalf("staged payload should be purged. Got: %v", err)
+	}
+
+	// the staging key of a committed upload is left over
+	// e.g. because of a crash after the meta data was inserted
+	committed, err := tEnv.b.NewUpload(tEnv.obj())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := committed.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tEnv.b.payload.Update(committed.setState); err != nil {
+		t.Fatal(err)
+	}
+	if err := tEnv.b.PurgeStaging(context.Background(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tEnv.b.UploadStatus(committed.ID()); !errors.Is(err, ErrUploadNotFound) {
+		t.Fatalf("staging key of the committed upload should be purged. Got: %v", err)
+	}
+	if err := tEnv.b.Verify(committed.ID()); err != nil {
+		t.Fatalf("payload of the committed upload should be kept: %v", err)
+	}
+}
+
+func TestResumeUpload(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.ChunkSize = 16
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	o := tEnv.emptyObj()
+	o.SetMetaKey("foo", "bar")
+	u, err := b.NewUpload(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl := tEnv.payload(100)
+	if _, err := u.Write(pl[:40]); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	b, err = OpenBucket(b.BasePath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Shutdown()
+	status, err := b.UploadStatus(u.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the bytes which are not staged as a full chunk are lost
+	if status.Offset != 32 || status.Owner != o.Owner() || status.ExpiresAt == nil {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	u, err = b.ResumeUpload(u.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := u.Write(pl[u.Offset():]); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := b.GetByID(u.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Payload(), pl) || got.GetMetaKey("foo") != "bar" {
+		t.Fatalf("resumed upload differs. Got: %s", got.Payload())
+	}
+	if err := b.Verify(u.ID()); err != nil {
+		t.Fatalf("checksum of a resumed upload should be valid: %v", err)
+	}
+	if _, err := b.UploadStatus(u.ID()); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("committed upload should not have a status. Got: %v", err)
+	}
+}
+
+func TestHTTPUploadStatus(t *testing.T) {
+	o := tEnv.emptyObj()
+	u, err := tEnv.b.NewUpload(o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Abort()
+	h := NewHTTPHandler(tEnv.b, DefaultHTTPHandlerOptions())
+	serve := func(owner string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/objst/uploads/"+u.ID()+"/status", nil)
+		r = r.WithContext(context.WithValue(r.Context(), CtxKeyOwner, owner))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	w := serve(o.Owner())
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var status UploadStatus
+	if err := json.NewDecoder(w.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+	if status.ID != u.ID() || status.Offset != 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if w := serve(tEnv.owner()); w.Code != http.StatusNotFound {
+		t.Fatalf("upload of another owner should not be found. Got: %d", w.Code)
+	}
+}
@@ -0,0 +1,233 @@
+package objst
+
+import (
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Store is an ordered key-value database persisting the payloads,
+// the names or the meta data of a bucket. Badger is used by default.
+// Other databases can be plugged in using BucketOptions.OpenStore
+// without changing the API of the bucket. Features which rely on the
+// internals of badger e.g. Backup, RunGC or RotateEncryptionKey are
+// returning ErrStoreUnsupported for other stores.
+type Store interface {
+	// View runs fn in a read-only transaction.
+	View(fn func(txn Txn) error) error
+
+	// Update runs fn in a read-write transaction which is
+	// committed if fn returns nil and discarded otherwise.
+	// A transaction conflicting with a concurrent transaction
+	// fails with an error wrapping ErrStoreConflict.
+	Update(fn func(txn Txn) error) error
+
+	// NewBatch returns a batch of writes which are
+	// not atomic but faster than a transaction.
+	NewBatch() Batch
+
+	Close() error
+}
+
+// Txn is a transaction of a Store.
+type Txn interface {
+	// Get returns the item of the key or an
+	// error wrapping ErrStoreKeyNotFound.
+	Get(key []byte) (Item, error)
+
+	Set(e *Entry) error
+
+	Delete(key []byte) error
+
+	// Iterate returns an iterator over the keys with the
+	// prefix in ascending order. It has to be closed
+	// before the transaction is done.
+	Iterate(prefix []byte, opts IteratorOptions) Iterator
+}
+
+// Iterator iterates over the keys of a Store.
+type Iterator interface {
+	// Seek moves to the first key which is greater
+	// than or equal to the key.
+	Seek(key []byte)
+
+	// Rewind moves to the first key.
+	Rewind()
+
+	// Valid reports if the iterator is positioned at a key.
+	Valid() bool
+
+	Next()
+
+	// Item returns the item at the current position. It
+	// is only valid until the iterator is moved.
+	Item() Item
+
+	Close()
+}
+
+// Item is a key-value pair of a Store.
+type Item interface {
+	Key() []byte
+
+	// Value calls fn with the value of the item
+	// which must not be used after fn returned.
+	Value(fn func(val []byte) error) error
+
+	ValueSize() int64
+
+	// ExpiresAt returns the expiry time of the
+	// entry. See Entry.ExpiresAt.
+	ExpiresAt() uint64
+}
+
+// Batch is a batch of writes of a Store. It
+// has to be flushed or canceled when it's done.
+type Batch interface {
+	Set(e *Entry) error
+
+	Delete(key []byte) error
+
+	Flush() error
+
+	Cancel()
+}
+
+// Entry is a key-value pair written to a Store.
+type Entry struct {
+	Key   []byte
+	Value []byte
+	// ExpiresAt is the unix time in seconds after which the
+	// entry is treated as deleted. The entry never expires
+	// if it is 0.
+	ExpiresAt uint64
+}
+
+// StoreConfig describes the store opened by BucketOptions.OpenStore.
+type StoreConfig struct {
+	// Name is the name of the store e.g. "payload", "name"
+	// or "meta". The three stores of the single layout are
+	// sharing the store named "store".
+	Name string
+	// Dir is the directory of the store in the base path of
+	// the bucket. Stores which are not persisted on disk can
+	// ignore it.
+	Dir      string
+	ReadOnly bool
+}
+
+// defaultIteratorOptions are the options of
+// the iterators used by the bucket internally.
+var defaultIteratorOptions = IteratorOptions{PrefetchValues: true, PrefetchSize: 100}
+
+func newEntry(key, value []byte) *Entry {
+	return &Entry{Key: key, Value: value}
+}
+
+// withTTL lets the entry expire after d.
+func (e *Entry) withTTL(d time.Duration) *Entry {
+	e.ExpiresAt = uint64(time.Now().Add(d).Unix())
+	return e
+}
+
+// badgerStore is the default Store.
+type badgerStore struct {
+	db *badger.DB
+}
+
+func (s *badgerStore) View(fn func(txn Txn) error) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		return fn(badgerTxn{txn})
+	})
+}
+
+func (s *badgerStore) Update(fn func(txn Txn) error) error {
+	return badgerError(s.db.Update(func(txn *badger.Txn) error {
+		return fn(badgerTxn{txn})
+	}))
+}
+
+func (s *badgerStore) NewBatch() Batch {
+	return badgerBatch{s.db.NewWriteBatch()}
+}
+
+func (s *badgerStore) Close() error {
+	return s.db.Close()
+}
+
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (t badgerTxn) Get(key []byte) (Item, error) {
+	item, err := t.txn.Get(key)
+	if err != nil {
+		return nil, badgerError(err)
+	}
+	return item, nil
+}
+
+func (t badgerTxn) Set(e *Entry) error {
+	return badgerError(t.txn.SetEntry(badgerEntry(e)))
+}
+
+func (t badgerTxn) Delete(key []byte) error {
+	return t.txn.Delete(key)
+}
+
+func (t badgerTxn) Iterate(prefix []byte, opts IteratorOptions) Iterator {
+	o := badger.DefaultIteratorOptions
+	o.Prefix = prefix
+	o.PrefetchValues = opts.PrefetchValues
+	if opts.PrefetchSize > 0 {
+		o.PrefetchSize = opts.PrefetchSize
+	}
+	return badgerIterator{t.txn.NewIterator(o)}
+}
+
+type badgerIterator struct {
+	*badger.Iterator
+}
+
+func (it badgerIterator) Item() Item {
+	return it.Iterator.Item()
+}
+
+type badgerBatch struct {
+	wb *badger.WriteBatch
+}
+
+func (b badgerBatch) Set(e *Entry) error {
+	return b.wb.SetEntry(badgerEntry(e))
+}
+
+func (b badgerBatch) Delete(key []byte) error {
+	return b.wb.Delete(key)
+}
+
+func (b badgerBatch) Flush() error {
+	return badgerError(b.wb.Flush())
+}
+
+func (b badgerBatch) Cancel() {
+	b.wb.Cancel()
+}
+
+func badgerEntry(e *Entry) *badger.Entry {
+	entry := badger.NewEntry(e.Key, e.Value)
+	entry.ExpiresAt = e.ExpiresAt
+	return entry
+}
+
+// badgerError maps the errors of badger to the errors of the
+// Store interface. Errors returned by the callbacks of the
+// transactions are kept as they are.
+func badgerError(err error) error {
+	switch err {
+	case badger.ErrKeyNotFound:
+		return ErrStoreKeyNotFound
+	case badger.ErrConflict:
+		return ErrStoreConflict
+	}
+	return err
+}
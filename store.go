@@ -0,0 +1,339 @@
+package objst
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// store is a badger database which can be reopened
+// while the bucket is in use e.g. to rotate the
+// encryption key. Operations are blocked while the
+// database is reopened instead of failing. The stores
+// of the single layout are sharing one database and
+// are separated by the prefix of their keys.
+type store struct {
+	*handle
+	// prefix of all keys of the store. It is
+	// empty if the store has its own database.
+	prefix []byte
+
+	// name of the store used for the latencies
+	name    string
+	latency *latencySampler
+}
+
+// handle is the database of one or more stores.
+type handle struct {
+	// name of the database which is the name of the
+	// store or singleStore for the single layout.
+	name string
+	mu   sync.RWMutex
+	db   Store
+	opts badger.Options
+	// open opens the database instead of badger.
+	// See BucketOptions.OpenStore.
+	open func(StoreConfig) (Store, error)
+	// refs is the number of stores using the database
+	// which is closed when the last store is closed.
+	refs int
+}
+
+const (
+	payloadStore = "payload"
+	nameStore    = "name"
+	metaStore    = "meta"
+	// singleStore is the database of the single layout.
+	singleStore = "store"
+)
+
+// key prefixes of the stores of the single layout
+const (
+	payloadKeyPrefix = "data/"
+	nameKeyPrefix    = "name/"
+	metaKeyPrefix    = "meta/"
+)
+
+func openStore(c storeConfig) (*store, error) {
+	db, err := c.openDB()
+	if err != nil {
+		return nil, newOpenError(c.name, c.opts.Dir, err)
+	}
+	return &store{handle: &handle{name: c.name, db: db, opts: c.opts, open: c.open, refs: 1}, name: c.name, latency: newLatencySampler()}, nil
+}
+
+// split returns the payload, name and meta store of the single
+// layout which are sharing the database of s. The store s must
+// not be used afterwards.
+func (s *store) split() (*store, *store, *store) {
+	s.refs = 3
+	view := func(name, prefix string) *store {
+		return &store{handle: s.handle, prefix: []byte(prefix), name: name, latency: newLatencySampler()}
+	}
+	return view(payloadStore, payloadKeyPrefix), view(nameStore, nameKeyPrefix), view(metaStore, metaKeyPrefix)
+}
+
+type storeConfig struct {
+	name string
+	opts badger.Options
+	open func(StoreConfig) (Store, error)
+}
+
+// openDB opens the database of the store which
+// is badger if no other store is configured.
+func (c storeConfig) openDB() (Store, error) {
+	if c.open != nil {
+		return c.open(StoreConfig{Name: c.name, Dir: c.opts.Dir, ReadOnly: c.opts.ReadOnly})
+	}
+	db, err := badger.Open(c.opts)
+	if err != nil {
+		return nil, err
+	}
+	return &badgerStore{db: db}, nil
+}
+
+// badger returns the badger database of the store. It
+// returns false if another store is plugged in.
+func (h *handle) badger() (*badger.DB, bool) {
+	bs, ok := h.db.(*badgerStore)
+	if !ok {
+		return nil, false
+	}
+	return bs.db, true
+}
+
+// openStores opens the stores in the given order. The already
+// opened stores are closed if one of them can't be opened.
+// Read-only stores are opened even if they are locked by
+// another process.
+func openStores(readOnly bool, configs ...storeConfig) ([]*store, error) {
+	stores := make([]*store, 0, len(configs))
+	for _, c := range configs {
+		c.opts.ReadOnly = readOnly
+		c.opts.BypassLockGuard = readOnly
+		s, err := openStore(c)
+		if err != nil {
+			for _, s := range stores {
+				s.Close()
+			}
+			return nil, err
+		}
+		stores = append(stores, s)
+	}
+	return stores, nil
+}
+
+// size returns the size of the LSM tree and the value
+// log of the store which is 0 if it isn't badger.
+func (s *store) size() (int64, int64) {
+	db, ok := s.badger()
+	if !ok {
+		return 0, 0
+	}
+	return db.Size()
+}
+
+func (s *store) View(fn func(txn *storeTxn) error) error {
+	defer s.latency.record(opView, time.Now())
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.View(func(txn Txn) error {
+		return fn(&storeTxn{Txn: txn, prefix: s.prefix})
+	})
+}
+
+func (s *store) Update(fn func(txn *storeTxn) error) error {
+	defer s.latency.record(opUpdate, time.Now())
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.db.Update(func(txn Txn) error {
+		return fn(&storeTxn{Txn: txn, prefix: s.prefix})
+	})
+}
+
+// NewWriteBatch returns a write batch which is
+// preventing the store from being reopened until
+// the batch is flushed or canceled.
+func (s *store) NewWriteBatch() *writeBatch {
+	s.mu.RLock()
+	return &writeBatch{
+		Batch:   s.db.NewBatch(),
+		prefix:  s.prefix,
+		release: s.mu.RUnlock,
+		latency: s.latency,
+	}
+}
+
+func (s *store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.refs--; s.refs > 0 {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// rotateEncryptionKey re-encrypts the key registry of
+// the store with newKey. The data keys and therefore the
+// data itself don't have to be rewritten. Badger reads the
+// registry only on open so the database is closed and
+// reopened. Operations of the store wait until it's reopened.
+func (s *store) rotateEncryptionKey(newKey []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.badger(); !ok {
+		return ErrStoreUnsupported
+	}
+	if err := s.db.Close(); err != nil {
+		return err
+	}
+	regOpts := badger.KeyRegistryOptions{
+		Dir:                           s.opts.Dir,
+		ReadOnly:                      true,
+		EncryptionKey:                 s.opts.EncryptionKey,
+		EncryptionKeyRotationDuration: s.opts.EncryptionKeyRotationDuration,
+	}
+	reg, err := badger.OpenKeyRegistry(regOpts)
+	if err == nil {
+		regOpts.EncryptionKey = newKey
+		err = badger.WriteKeyRegistry(reg, regOpts)
+	}
+	if err == nil {
+		s.opts.EncryptionKey = newKey
+	}
+	// reopen the store in any case so it stays usable
+	// with the old key if the rotation failed.
+	db, openErr := badger.Open(s.opts)
+	if openErr != nil {
+		return openErr
+	}
+	s.db = &badgerStore{db: db}
+	return err
+}
+
+// writeBatch is a Batch which releases the
+// read lock of the store when it's done.
+type writeBatch struct {
+	Batch
+	prefix  []byte
+	once    sync.Once
+	release func()
+	latency *latencySampler
+}
+
+func (wb *writeBatch) Set(k, v []byte) error {
+	return wb.Batch.Set(newEntry(prefixed(wb.prefix, k), v))
+}
+
+func (wb *writeBatch) SetEntry(e *Entry) error {
+	return wb.Batch.Set(prefixedEntry(wb.prefix, e))
+}
+
+func (wb *writeBatch) Delete(k []byte) error {
+	return wb.Batch.Delete(prefixed(wb.prefix, k))
+}
+
+func (wb *writeBatch) Flush() error {
+	defer wb.latency.record(opBatch, time.Now())
+	defer wb.once.Do(wb.release)
+	return wb.Batch.Flush()
+}
+
+func (wb *writeBatch) Cancel() {
+	defer wb.once.Do(wb.release)
+	wb.Batch.Cancel()
+}
+
+// storeTxn is a Txn which is prefixing the keys with
+// the prefix of the store. The keys of the returned
+// items are without the prefix.
+type storeTxn struct {
+	Txn
+	prefix []byte
+}
+
+func (t *storeTxn) Get(k []byte) (*storeItem, error) {
+	item, err := t.Txn.Get(prefixed(t.prefix, k))
+	if err != nil {
+		return nil, err
+	}
+	return &storeItem{Item: item, prefix: t.prefix}, nil
+}
+
+func (t *storeTxn) Set(k, v []byte) error {
+	return t.Txn.Set(newEntry(prefixed(t.prefix, k), v))
+}
+
+func (t *storeTxn) SetEntry(e *Entry) error {
+	return t.Txn.Set(prefixedEntry(t.prefix, e))
+}
+
+func (t *storeTxn) Delete(k []byte) error {
+	return t.Txn.Delete(prefixed(t.prefix, k))
+}
+
+// NewIterator returns an iterator over the keys of the store.
+func (t *storeTxn) NewIterator(opts IteratorOptions) *storeIterator {
+	return &storeIterator{Iterator: t.Txn.Iterate(t.prefix, opts), prefix: t.prefix}
+}
+
+type storeIterator struct {
+	Iterator
+	prefix []byte
+}
+
+func (it *storeIterator) Seek(k []byte) {
+	it.Iterator.Seek(prefixed(it.prefix, k))
+}
+
+func (it *storeIterator) ValidForPrefix(p []byte) bool {
+	return it.Valid() && bytes.HasPrefix(it.Iterator.Item().Key(), prefixed(it.prefix, p))
+}
+
+func (it *storeIterator) Item() *storeItem {
+	return &storeItem{Item: it.Iterator.Item(), prefix: it.prefix}
+}
+
+type storeItem struct {
+	Item
+	prefix []byte
+}
+
+func (i *storeItem) Key() []byte {
+	return bytes.TrimPrefix(i.Item.Key(), i.prefix)
+}
+
+func (i *storeItem) KeyCopy(dst []byte) []byte {
+	return append(dst[:0], i.Key()...)
+}
+
+// ValueCopy returns a copy of the value appended to dst[:0].
+func (i *storeItem) ValueCopy(dst []byte) ([]byte, error) {
+	err := i.Value(func(val []byte) error {
+		dst = append(dst[:0], val...)
+		return nil
+	})
+	return dst, err
+}
+
+// prefixed returns k prefixed with p. The
+// key is returned as it is if p is empty.
+func prefixed(p, k []byte) []byte {
+	if len(p) == 0 {
+		return k
+	}
+	key := make([]byte, 0, len(p)+len(k))
+	return append(append(key, p...), k...)
+}
+
+// prefixedEntry returns a copy of e with the key prefixed with p.
+func prefixedEntry(p []byte, e *Entry) *Entry {
+	if len(p) == 0 {
+		return e
+	}
+	cp := *e
+	cp.Key = prefixed(p, e.Key)
+	return &cp
+}
@@ -0,0 +1,427 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// subscriptionPrefix is the prefix of the
+	// subscriptions in the name store.
+	subscriptionPrefix = "#subscription/"
+	// eventBatchSize is the maximum number of changes
+	// delivered to a subscription at once.
+	eventBatchSize = 100
+	// webhookTimeout is the timeout of the delivery
+	// of one batch of changes to a webhook.
+	webhookTimeout = 10 * time.Second
+)
+
+// Subscription delivers the change feed of an owner to a webhook.
+// The changes are delivered at least once in the order of their
+// sequence numbers. The cursor is only advanced after the webhook
+// accepted the changes so changes which happened while the webhook
+// was unavailable are delivered later. Consumers can deduplicate
+// the changes using their sequence number. See Subscribe.
+type Subscription struct {
+	ID string `json:"id"`
+	// Owner is the owner whose changes are delivered.
+	// Empty delivers the changes of all owners.
+	Owner string `json:"owner,omitempty"`
+	// Ops are the kinds of changes to deliver.
+	// Empty delivers all changes.
+	Ops []ChangeOp `json:"ops,omitempty"`
+	// URL of the webhook the changes are posted to.
+	URL string `json:"url"`
+	// Cursor is the sequence number of the last change
+	// delivered or skipped by the subscription.
+	Cursor    uint64    `json:"cursor"`
+	CreatedAt time.Time `json:"createdAt"`
+	// LastError is the error of the last failed
+	// delivery and empty after a successful one.
+	LastError string `json:"lastError,omitempty"`
+}
+
+// eventsModel is the request body posted to a webhook.
+type eventsModel struct {
+	Subscription string   `json:"subscription"`
+	Changes      []Change `json:"changes"`
+}
+
+func subscriptionKey(id string) []byte {
+	return []byte(subscriptionPrefix + id)
+}
+
+func (s Subscription) isValid(validateOwner OwnerValidator) error {
+	if s.Owner != "" {
+		if err := validateOwner(s.Owner); err != nil {
+			return err
+		}
+	}
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https url", ErrInvalidSubscription)
+	}
+	for _, op := range s.Ops {
+		if op != ChangePut && op != ChangeDelete {
+			return fmt.Errorf("%w: unknown change %q", ErrInvalidSubscription, op)
+		}
+	}
+	return nil
+}
+
+func (s Subscription) wants(c Change) bool {
+	return len(s.Ops) == 0 || slices.Contains(s.Ops, c.Op)
+}
+
+// Subscribe persists the subscription and returns it with its id.
+// The changes are delivered starting with the next change of the
+// bucket. Use ReplaySubscription to deliver older changes.
+func (b *Bucket) Subscribe(s Subscription) (Subscription, error) {
+	if err := s.isValid(b.opts.OwnerValidator); err != nil {
+		return s, err
+	}
+	done, err := b.beginWrite()
+	if err != nil {
+		return s, err
+	}
+	defer done()
+	b.changeMu.Lock()
+	s.Cursor = b.changeSeq
+	b.changeMu.Unlock()
+	s.ID = uuid.NewString()
+	s.CreatedAt = time.Now().UTC()
+	s.LastError = ""
+	return s, b.writeSubscription(s)
+}
+
+// Unsubscribe deletes the subscription.
+func (b *Bucket) Unsubscribe(id string) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	if _, err := b.Subscription(id); err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Delete(subscriptionKey(id))
+	})
+}
+
+// ReplaySubscription resets the cursor of the subscription so all
+// changes with a sequence number greater than since are delivered
+// again. Changes older than ChangeRetention can't be replayed.
+func (b *Bucket) ReplaySubscription(id string, since uint64) error {
+	done, err := b.beginWrite()
+	if err != nil {
+		return err
+	}
+	defer done()
+	s, err := b.Subscription(id)
+	if err != nil {
+		return err
+	}
+	s.Cursor = since
+	return b.writeSubscription(s)
+}
+
+// Subscriptions returns the subscriptions of the owner ordered by
+// their id. An empty owner returns the subscriptions of all owners.
+func (b *Bucket) Subscriptions(owner string) ([]Subscription, error) {
+	subs := make([]Subscription, 0)
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(subscriptionPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var s Subscription
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &s)
+			}); err != nil {
+				return err
+			}
+			if owner == "" || s.Owner == owner {
+				subs = append(subs, s)
+			}
+		}
+		return nil
+	})
+	return subs, err
+}
+
+// Subscription returns the subscription with the id.
+func (b *Bucket) Subscription(id string) (Subscription, error) {
+	var s Subscription
+	err := b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get(subscriptionKey(id))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &s)
+		})
+	})
+	if errors.Is(err, ErrStoreKeyNotFound) {
+		return s, fmt.Errorf("%w: %s", ErrSubscriptionNotFound, id)
+	}
+	return s, err
+}
+
+func (b *Bucket) writeSubscription(s Subscription) error {
+	data, err := json.Marshal(&s)
+	if err != nil {
+		return err
+	}
+	return b.name.Update(func(txn *storeTxn) error {
+		return txn.Set(subscriptionKey(s.ID), data)
+	})
+}
+
+// DeliverEvents delivers the pending changes to all subscriptions
+// once. It is called every EventDeliveryInterval by the bucket but
+// can be used to deliver the changes manually. The delivery to a
+// subscription stops at the first failed batch which is retried by
+// the next call. The errors of all subscriptions are joined.
+func (b *Bucket) DeliverEvents(ctx context.Context) error {
+	b.deliveryMu.Lock()
+	defer b.deliveryMu.Unlock()
+	subs, err := b.Subscriptions("")
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range subs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := b.deliver(ctx, s); err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", s.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver delivers the pending changes of the subscription in
+// batches and persists the cursor after every delivered batch.
+func (b *Bucket) deliver(ctx context.Context, s Subscription) error {
+	for {
+		changes, next, err := b.Changes(s.Owner, s.Cursor, eventBatchSize)
+		if err != nil {
+			return err
+		}
+		if next == s.Cursor {
+			return nil
+		}
+		wanted := make([]Change, 0, len(changes))
+		for _, c := range changes {
+			if s.wants(c) {
+				wanted = append(wanted, c)
+			}
+		}
+		if len(wanted) > 0 {
+			if err := b.opts.EventDelivery(ctx, s, wanted); err != nil {
+				_, updateErr := b.updateSubscription(s, s.Cursor, err.Error())
+				return errors.Join(err, updateErr)
+			}
+		}
+		ok, err := b.updateSubscription(s, next, "")
+		if err != nil || !ok {
+			return err
+		}
+		s.Cursor = next
+	}
+}
+
+// updateSubscription persists the cursor and the last error of the
+// subscription s if its cursor wasn't changed in the meantime. It
+// returns false if the subscription was deleted or replayed while
+// the changes were delivered.
+func (b *Bucket) updateSubscription(s Subscription, cursor uint64, lastErr string) (bool, error) {
+	done, err := b.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	ok := false
+	err = b.name.Update(func(txn *storeTxn) error {
+		item, err := txn.Get(subscriptionKey(s.ID))
+		if errors.Is(err, ErrStoreKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var cur Subscription
+		if err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &cur)
+		}); err != nil {
+			return err
+		}
+		if cur.Cursor != s.Cursor {
+			return nil
+		}
+		cur.Cursor = cursor
+		cur.LastError = lastErr
+		data, err := json.Marshal(&cur)
+		if err != nil {
+			return err
+		}
+		ok = true
+		return txn.Set(subscriptionKey(s.ID), data)
+	})
+	return ok, err
+}
+
+// webhookClient is the client of DeliverWebhook.
+var webhookClient = &http.Client{Timeout: webhookTimeout}
+
+// DeliverWebhook posts the changes as JSON object with the keys
+// subscription and changes to the URL of the subscription. Every
+// status code except 2xx is a failed delivery. It is the default
+// of BucketOptions.EventDelivery.
+func DeliverWebhook(ctx context.Context, s Subscription, changes []Change) error {
+	body, err := json.Marshal(eventsModel{Subscription: s.ID, Changes: changes})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(headerContentType, contentTypeJSON)
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("%w: %s", ErrWebhookRejected, res.Status)
+	}
+	return nil
+}
+
+// runEventDelivery delivers the changes to
+// the subscriptions every interval.
+func (b *Bucket) runEventDelivery(ctx context.Context, interval time.Duration) {
+	defer b.deliveryWg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.DeliverEvents(ctx); err != nil && ctx.Err() == nil && b.opts.Logger != nil {
+				b.opts.Logger.Warningf("delivering the events failed: %v", err)
+			}
+		}
+	}
+}
+
+// subscribeModel is the request body of Subscribe.
+type subscribeModel struct {
+	URL string     `json:"url"`
+	Ops []ChangeOp `json:"ops,omitempty"`
+}
+
+// Subscriptions returns the subscriptions of the owner.
+func (h *HTTPHandler) Subscriptions(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	owner := r.Context().Value(CtxKeyOwner).(string)
+	subs, err := h.bucket.Subscriptions(owner)
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "something went wrong while reading the subscriptions", http.StatusInternalServerError)
+		return
+	}
+	h.writeJSON(w, r, subs)
+}
+
+// Subscribe subscribes the webhook to the changes of the owner.
+func (h *HTTPHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	owner := r.Context().Value(CtxKeyOwner).(string)
+	var body subscribeModel
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "request body is not a valid subscription", http.StatusBadRequest)
+		return
+	}
+	s, err := h.bucket.Subscribe(Subscription{Owner: owner, URL: body.URL, Ops: body.Ops})
+	if errors.Is(err, ErrInvalidSubscription) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	h.writeJSON(w, r, s)
+}
+
+// Unsubscribe deletes the subscription of the owner.
+func (h *HTTPHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	id := chi.URLParam(r, "subID")
+	err := h.ownSubscription(r, id)
+	if err == nil {
+		err = h.bucket.Unsubscribe(id)
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReplaySubscription delivers the changes of the owner since the
+// sequence number of the `since` query parameter again.
+func (h *HTTPHandler) ReplaySubscription(w http.ResponseWriter, r *http.Request) {
+	reqID := r.Context().Value(CtxKeyReqID).(string)
+	id := chi.URLParam(r, "subID")
+	since, err := strconv.ParseUint(r.URL.Query().Get("since"), 10, 64)
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, "since is not a valid sequence number", http.StatusBadRequest)
+		return
+	}
+	err = h.ownSubscription(r, id)
+	if err == nil {
+		err = h.bucket.ReplaySubscription(id, since)
+	}
+	if err != nil {
+		h.opts.Logger.ErrorCtx(r.Context(), err.Error(), slog.String("req_id", reqID))
+		http.Error(w, err.Error(), errorStatus(err, http.StatusInternalServerError))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ownSubscription returns ErrSubscriptionNotFound if the
+// subscription doesn't belong to the owner of the request.
+func (h *HTTPHandler) ownSubscription(r *http.Request, id string) error {
+	s, err := h.bucket.Subscription(id)
+	if err != nil {
+		return err
+	}
+	if s.Owner != r.Context().Value(CtxKeyOwner).(string) {
+		return fmt.Errorf("%w: %s", ErrSubscriptionNotFound, id)
+	}
+	return nil
+}
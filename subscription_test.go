@@ -0,0 +1,169 @@
+package objst
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSubscriptionDelivery(t *testing.T) {
+	delivered := make([]Change, 0)
+	var fail error
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.EventDeliveryInterval = 0
+	opts.EventDelivery = func(ctx context.Context, s Subscription, changes []Change) error {
+		if fail != nil {
+			return fail
+		}
+		delivered = append(delivered, changes...)
+		return nil
+	}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	ctx := context.Background()
+
+	owner := tEnv.owner()
+	sub, err := b.Subscribe(Subscription{Owner: owner, URL: "https://example.com/hook"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, _ := NewObject(tEnv.name(), owner)
+	o.Write(tEnv.payload(10))
+	if err := b.Create(o); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Create(tEnv.obj()); err != nil {
+		t.Fatal(err)
+	}
+	fail = errors.New("webhook is down")
+	if err := b.DeliverEvents(ctx); !errors.Is(err, fail) {
+		t.Fatalf("failed delivery should be reported. Got: %v", err)
+	}
+	if sub, err = b.Subscription(sub.ID); err != nil || sub.LastError == "" {
+		t.Fatalf("failed delivery should be recorded. Got: %+v, %v", sub, err)
+	}
+	fail = nil
+	if err := b.DeliverEvents(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 1 || delivered[0].ID != o.ID() || delivered[0].Op != ChangePut {
+		t.Fatalf("pending change of the owner should be delivered. Got: %+v", delivered)
+	}
+	b.Shutdown()
+
+	b, err = OpenBucket(b.BasePath, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Shutdown()
+	if err := b.DeliverEvents(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 1 {
+		t.Fatalf("delivered changes should not be delivered again. Got: %+v", delivered)
+	}
+	if err := b.ReplaySubscription(sub.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.DeliverEvents(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(delivered) != 2 || delivered[1].ID != o.ID() {
+		t.Fatalf("replayed change should be delivered again. Got: %+v", delivered)
+	}
+	if err := b.Unsubscribe(sub.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Subscription(sub.ID); !errors.Is(err, ErrSubscriptionNotFound) {
+		t.Fatalf("subscription should be deleted. Got: %v", err)
+	}
+	if _, err := b.Subscribe(Subscription{URL: "file:///etc/passwd"}); !errors.Is(err, ErrInvalidSubscription) {
+		t.Fatalf("subscription with a non http url should be invalid. Got: %v", err)
+	}
+}
+
+func TestDeliverWebhook(t *testing.T) {
+	var got eventsModel
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	s := Subscription{ID: "sub", URL: srv.URL}
+	changes := []Change{{Seq: 1, Op: ChangeDelete, ID: "id"}}
+	if err := DeliverWebhook(context.Background(), s, changes); err != nil {
+		t.Fatal(err)
+	}
+	if got.Subscription != s.ID || len(got.Changes) != 1 || got.Changes[0].Seq != 1 {
+		t.Fatalf("unexpected request body: %+v", got)
+	}
+	status = http.StatusInternalServerError
+	if err := DeliverWebhook(context.Background(), s, changes); !errors.Is(err, ErrWebhookRejected) {
+		t.Fatalf("non 2xx status should be a failed delivery. Got: %v", err)
+	}
+}
+
+func TestHTTPSubscriptions(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.EventDeliveryInterval = 0
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	h := NewHTTPHandler(b, DefaultHTTPHandlerOptions())
+	owner := tEnv.owner()
+	serve := func(method, target, body, owner string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), CtxKeyOwner, owner))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	w := serve(http.MethodPost, "/objst/subscriptions", `{"url": "https://example.com/hook", "ops": ["delete"]}`, owner)
+	if w.Code != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var sub Subscription
+	if err := json.NewDecoder(w.Body).Decode(&sub); err != nil {
+		t.Fatal(err)
+	}
+	if sub.Owner != owner || len(sub.Ops) != 1 {
+		t.Fatalf("unexpected subscription: %+v", sub)
+	}
+	if w := serve(http.MethodPost, "/objst/subscriptions", `{"url": "ftp://example.com"}`, owner); w.Code != http.StatusBadRequest {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusBadRequest, w.Code)
+	}
+	w = serve(http.MethodGet, "/objst/subscriptions", "", owner)
+	subs := make([]Subscription, 0)
+	if err := json.NewDecoder(w.Body).Decode(&subs); err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].ID != sub.ID {
+		t.Fatalf("subscriptions of the owner should be listed. Got: %+v", subs)
+	}
+	target := "/objst/subscriptions/" + sub.ID
+	if w := serve(http.MethodPost, target+"/replay?since=0", "", tEnv.owner()); w.Code != http.StatusNotFound {
+		t.Fatalf("subscription of another owner should not be found. Got: %d", w.Code)
+	}
+	if w := serve(http.MethodPost, target+"/replay?since=0", "", owner); w.Code != http.StatusNoContent {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodDelete, target, "", owner); w.Code != http.StatusNoContent {
+		t.Fatalf("statuscode is not %d. Got: %d. Body: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,142 @@
+package objst
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// StoreEventKind is the kind of a StoreEvent.
+type StoreEventKind string
+
+const (
+	// StoreEventFlush is a memtable which is flushed to disk.
+	StoreEventFlush StoreEventKind = "flush"
+	// StoreEventCompaction is a finished compaction of a level.
+	StoreEventCompaction StoreEventKind = "compaction"
+	// StoreEventStall is a stall of all writes because
+	// level 0 has too many tables. Stalls shorter than
+	// one second are not reported by badger.
+	StoreEventStall StoreEventKind = "stall"
+	// StoreEventGC is a finished garbage collection of the
+	// value log. See RunGC.
+	StoreEventGC StoreEventKind = "gc"
+	// StoreEventError is any warning or error logged
+	// by badger e.g. a failed compaction.
+	StoreEventError StoreEventKind = "error"
+)
+
+// StoreEvent is an internal event of a badger store of the bucket.
+// The events explain e.g. spikes of the write latency without the
+// verbose logging of badger. See BucketOptions.OnStoreEvent.
+type StoreEvent struct {
+	// Store is the name of the store e.g. "payload". The
+	// database of the single layout is named "store".
+	Store string
+	Kind  StoreEventKind
+	At    time.Time
+	// Duration is the duration of a stall
+	// or of the garbage collection.
+	Duration time.Duration
+	// Level is the compacted level of a compaction.
+	Level int
+	// Rewritten is the number of value log files
+	// rewritten by the garbage collection.
+	Rewritten int
+	// Message is the log message of badger
+	// the event is derived from.
+	Message string
+}
+
+// eventLogger is the badger.Logger of a store deriving
+// StoreEvents from the log messages of badger. All messages
+// are passed to the logger of the bucket. Stalls are passed
+// as warnings because they are increasing the latency of
+// every write.
+type eventLogger struct {
+	next  badger.Logger
+	store string
+	emit  func(StoreEvent)
+}
+
+// withEventLogger installs an eventLogger in the
+// options of the stores if it has anything to do.
+func withEventLogger(opts BucketOptions, configs []storeConfig) {
+	if opts.Logger == nil && opts.OnStoreEvent == nil {
+		return
+	}
+	for i := range configs {
+		configs[i].opts.Logger = &eventLogger{
+			next:  configs[i].opts.Logger,
+			store: configs[i].name,
+			emit:  opts.OnStoreEvent,
+		}
+	}
+}
+
+func (l *eventLogger) Errorf(format string, v ...any) {
+	l.observe(format, v, true)
+	if l.next != nil {
+		l.next.Errorf(format, v...)
+	}
+}
+
+func (l *eventLogger) Warningf(format string, v ...any) {
+	l.observe(format, v, true)
+	if l.next != nil {
+		l.next.Warningf(format, v...)
+	}
+}
+
+func (l *eventLogger) Infof(format string, v ...any) {
+	if l.observe(format, v, false) == StoreEventStall && l.next != nil {
+		l.next.Warningf("%s: "+format, append([]any{l.store}, v...)...)
+		return
+	}
+	if l.next != nil {
+		l.next.Infof(format, v...)
+	}
+}
+
+func (l *eventLogger) Debugf(format string, v ...any) {
+	l.observe(format, v, false)
+	if l.next != nil {
+		l.next.Debugf(format, v...)
+	}
+}
+
+// observe emits the event of the log message if there is
+// any and returns its kind. The formats of the messages
+// are the ones of badger. All warnings and errors are
+// emitted as StoreEventError.
+func (l *eventLogger) observe(format string, v []any, isErr bool) StoreEventKind {
+	e := StoreEvent{Store: l.store, At: time.Now()}
+	switch {
+	case strings.HasPrefix(format, "Flushing memtable"):
+		e.Kind = StoreEventFlush
+	case strings.Contains(format, "Compaction for level: %d DONE") && len(v) == 2:
+		e.Kind = StoreEventCompaction
+		e.Level, _ = v[1].(int)
+	case strings.HasPrefix(format, "L0 was stalled for %s") && len(v) == 1:
+		e.Kind = StoreEventStall
+		e.Duration, _ = time.ParseDuration(fmt.Sprint(v[0]))
+	case isErr:
+		e.Kind = StoreEventError
+	default:
+		return ""
+	}
+	if l.emit != nil {
+		e.Message = strings.TrimSpace(fmt.Sprintf(format, v...))
+		l.emit(e)
+	}
+	return e.Kind
+}
+
+// emitStoreEvent calls OnStoreEvent if it is set.
+func (b *Bucket) emitStoreEvent(e StoreEvent) {
+	if b.opts.OnStoreEvent != nil {
+		b.opts.OnStoreEvent(e)
+	}
+}
@@ -0,0 +1,87 @@
+package objst
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recordingLogger struct {
+	mu       sync.Mutex
+	warnings []string
+}
+
+func (r *recordingLogger) Errorf(string, ...any) {}
+func (r *recordingLogger) Infof(string, ...any)  {}
+func (r *recordingLogger) Debugf(string, ...any) {}
+
+func (r *recordingLogger) Warningf(format string, v ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.warnings = append(r.warnings, fmt.Sprintf(format, v...))
+}
+
+func TestEventLogger(t *testing.T) {
+	next := &recordingLogger{}
+	events := make([]StoreEvent, 0)
+	l := &eventLogger{next: next, store: metaStore, emit: func(e StoreEvent) {
+		events = append(events, e)
+	}}
+	l.Debugf("Flushing memtable, mt.size=%d size of flushChan: %d\n", 1024, 0)
+	l.Debugf("[Compactor: %d] Compaction for level: %d DONE", 1, 2)
+	l.Infof("L0 was stalled for %s\n", (1500 * time.Millisecond).String())
+	l.Warningf("While running doCompact: %v\n", "failed")
+	l.Infof("Replaying file id: %d at offset: %d\n", 1, 0)
+	want := []StoreEvent{
+		{Kind: StoreEventFlush},
+		{Kind: StoreEventCompaction, Level: 2},
+		{Kind: StoreEventStall, Duration: 1500 * time.Millisecond},
+		{Kind: StoreEventError},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events. Got: %+v", len(want), events)
+	}
+	for i, e := range events {
+		if e.Store != metaStore || e.Kind != want[i].Kind || e.Level != want[i].Level || e.Duration != want[i].Duration {
+			t.Fatalf("event %d should be %+v. Got: %+v", i, want[i], e)
+		}
+	}
+	if len(next.warnings) != 2 {
+		t.Fatalf("stall and warning should be logged as warnings. Got: %v", next.warnings)
+	}
+}
+
+func TestStoreEvents(t *testing.T) {
+	var mu sync.Mutex
+	kinds := make(map[StoreEventKind]int)
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.OnStoreEvent = func(e StoreEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		kinds[e.Kind]++
+	}
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	if err := b.Create(tEnv.obj()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.RunGC(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// the memtables are flushed on shutdown
+	if err := b.Shutdown(); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if kinds[StoreEventGC] != 3 || kinds[StoreEventFlush] == 0 {
+		t.Fatalf("expected a gc event per store and flushes. Got: %v", kinds)
+	}
+}
@@ -0,0 +1,188 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Tier is the storage tier of the payload of an object.
+type Tier string
+
+const (
+	// TierHot stores the payload in the bucket.
+	TierHot Tier = "hot"
+	// TierCold stores the payload in the Remote of the
+	// bucket while the meta data is kept in the bucket.
+	TierCold Tier = "cold"
+)
+
+// Remote is an object storage the cold payloads are pushed to
+// e.g. an S3-compatible endpoint. See NewS3Remote. The payloads
+// are stored with the id of the object as key and as they are
+// stored in the bucket i.e. compressed or encrypted.
+type Remote interface {
+	Put(ctx context.Context, key string, data []byte) error
+
+	// Get returns the data of the key or an error
+	// wrapping ErrRemoteNotFound if it doesn't exist.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete deletes the key. Deleting
+	// a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// isCold reports whether the payload
+// of the object is stored in the Remote.
+func isCold(meta *Metadata) bool {
+	return meta.Get(MetaKeyTier) == string(TierCold)
+}
+
+// Tier moves the payloads of all objects matching the query to
+// the tier and returns the number of moved objects. Objects which
+// are already stored in the tier are skipped. The meta data of cold
+// objects stays in the bucket so queries are not affected and their
+// payloads are read through from the Remote transparently. The
+// payloads are verified against their checksum before the copy in
+// the former tier is deleted. Cold objects can't be patched or
+// re-encoded and are not part of backups or snapshots.
+func (b *Bucket) Tier(q *Query, tier Tier) (int, error) {
+	if tier != TierHot && tier != TierCold {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownTier, tier)
+	}
+	if b.opts.Remote == nil {
+		return 0, ErrNoRemote
+	}
+	ids, err := b.getMatchingIDs(q)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, id := range ids {
+		moved, err := b.tierObject(id, tier)
+		if errors.Is(err, ErrObjectNotFound) {
+			// deleted in the meantime
+			continue
+		}
+		if err != nil {
+			return n, fmt.Errorf("tiering %s: %w", id, err)
+		}
+		if moved {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// tierObject moves the payload of the object to the tier.
+// The meta data is updated before the former copy is deleted
+// so readers never miss the payload.
+func (b *Bucket) tierObject(id string, tier Tier) (bool, error) {
+	done, err := b.beginWrite()
+	if err != nil {
+		return false, err
+	}
+	defer done()
+	defer b.finishOp(b.startOp("Tier", id))
+	defer b.lockPayload(id)()
+	meta, err := b.GetMeta(id)
+	if err != nil {
+		return false, err
+	}
+	if isCold(meta) == (tier == TierCold) {
+		return false, nil
+	}
+	data, err := b.readStoredPayload(meta)
+	if err != nil {
+		return false, err
+	}
+	if err := b.verifyStored(meta, data); err != nil {
+		return false, err
+	}
+	ctx := context.Background()
+	if tier == TierCold {
+		if err := b.opts.Remote.Put(ctx, id, data); err != nil {
+			return false, err
+		}
+		err := b.updateMeta(id, func(m *Metadata) error {
+			m.set(MetaKeyTier, string(TierCold))
+			return nil
+		})
+		if err != nil {
+			return false, err
+		}
+		return true, b.deletePayload(id)
+	}
+	entries, err := b.splitPayload(id, chunkSizeOf(meta), data)
+	if err != nil {
+		return false, err
+	}
+	if err := b.replacePayload(id, entries); err != nil {
+		return false, err
+	}
+	err = b.updateMeta(id, func(m *Metadata) error {
+		m.del(MetaKeyTier)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, b.opts.Remote.Delete(ctx, id)
+}
+
+// verifyStored verifies the stored payload of the object
+// against its checksum. Objects of older versions don't
+// record their checksum and are not verified.
+func (b *Bucket) verifyStored(meta *Metadata, data []byte) error {
+	if !meta.Has(MetaKeyChecksum) {
+		return nil
+	}
+	pl, err := b.decodePayload(meta, data)
+	if err != nil {
+		return err
+	}
+	if checksum(pl) != meta.Get(MetaKeyChecksum) {
+		return ErrChecksumMismatch
+	}
+	return nil
+}
+
+// readRemote reads the cold payload of the object.
+func (b *Bucket) readRemote(id string) ([]byte, error) {
+	if b.opts.Remote == nil {
+		return nil, ErrNoRemote
+	}
+	rc, err := b.opts.Remote.Get(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+// viewRemote calls fn with the cold payload of
+// the object in parts of blobReadSize bytes.
+func (b *Bucket) viewRemote(id string, fn func(chunk []byte) error) error {
+	if b.opts.Remote == nil {
+		return ErrNoRemote
+	}
+	rc, err := b.opts.Remote.Get(context.Background(), id)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return viewParts(rc, fn)
+}
+
+// deleteRemote deletes the cold payload of the object.
+func (b *Bucket) deleteRemote(meta *Metadata) error {
+	if !isCold(meta) {
+		return nil
+	}
+	if b.opts.Remote == nil {
+		return ErrNoRemote
+	}
+	return b.opts.Remote.Delete(context.Background(), meta.Get(MetaKeyID))
+}
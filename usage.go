@@ -0,0 +1,303 @@
+package objst
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// usagePrefix is the prefix of the usage counters in the
+// name store. Object names cannot start with "#" so the
+// counters never collide with a name entry.
+const usagePrefix = "#usage/"
+
+// Usage is the storage used by an owner.
+type Usage struct {
+	Owner string `json:"owner"`
+	// ContentType is the content type the usage is limited
+	// to. It is empty for the usage of all content types.
+	ContentType string `json:"contentType,omitempty"`
+	// Objects is the number of objects of the owner.
+	Objects int64 `json:"objects"`
+	// Bytes is the sum of the payload
+	// sizes of all objects of the owner.
+	Bytes int64 `json:"bytes"`
+}
+
+// usageID identifies a usage counter. Every owner has a
+// counter of all objects with an empty content type and
+// one counter per content type of its objects.
+type usageID struct {
+	owner       string
+	contentType string
+}
+
+func (u Usage) id() usageID {
+	return usageID{owner: u.Owner, contentType: u.ContentType}
+}
+
+func (u Usage) add(o Usage) Usage {
+	u.Objects += o.Objects
+	u.Bytes += o.Bytes
+	return u
+}
+
+func (u Usage) neg() Usage {
+	u.Objects, u.Bytes = -u.Objects, -u.Bytes
+	return u
+}
+
+// usageKey returns the key of the usage counter in the format
+// #usage/<owner>/<content type>. Both are escaped so they can
+// contain a "/".
+func usageKey(u Usage) []byte {
+	return []byte(usagePrefix + url.PathEscape(u.Owner) + "/" + url.PathEscape(u.ContentType))
+}
+
+// counters returns the deltas of the counters which are affected by
+// the delta u i.e. the counter of all objects and the counter of
+// the content type of u.
+func (u Usage) counters() []Usage {
+	total := u
+	total.ContentType = ""
+	if u.ContentType == "" {
+		return []Usage{total}
+	}
+	return []Usage{total, u}
+}
+
+// Usage returns the usage of the owner. The usage is maintained
+// incrementally on every write so it doesn't require a scan of
+// all objects. Concurrent writes during a reconciliation may let
+// the usage drift until the next one. See ReconcileUsage.
+func (b *Bucket) Usage(owner string) Usage {
+	b.usageMu.Lock()
+	defer b.usageMu.Unlock()
+	u := b.usages[usageID{owner: owner}]
+	u.Owner = owner
+	return u
+}
+
+// UsageByContentType returns the usage of the objects of the owner
+// with the content type. A trailing "/*" of the content type matches
+// all subtypes e.g. "video/*".
+func (b *Bucket) UsageByContentType(owner, contentType string) Usage {
+	b.usageMu.Lock()
+	defer b.usageMu.Unlock()
+	return b.usageByContentType(owner, contentType)
+}
+
+func (b *Bucket) usageByContentType(owner, contentType string) Usage {
+	used := Usage{Owner: owner, ContentType: contentType}
+	for id, u := range b.usages {
+		if id.owner == owner && id.contentType != "" && matchContentType(contentType, id.contentType) {
+			used.Objects += u.Objects
+			used.Bytes += u.Bytes
+		}
+	}
+	return used
+}
+
+// Usages returns the usage of all owners with at least one object
+// and their usage per content type sorted by owner and content type.
+func (b *Bucket) Usages() []Usage {
+	b.usageMu.Lock()
+	defer b.usageMu.Unlock()
+	usages := maps.Values(b.usages)
+	slices.SortFunc(usages, func(a, b Usage) bool {
+		if a.Owner != b.Owner {
+			return a.Owner < b.Owner
+		}
+		return a.ContentType < b.ContentType
+	})
+	return usages
+}
+
+// ReconcileUsage recalculates the usage of all owners by scanning
+// the meta data of all objects and corrects the counters if they
+// drifted. The reconciliation runs every UsageReconcileInterval.
+func (b *Bucket) ReconcileUsage(ctx context.Context) error {
+	scanned, err := b.scanUsage(ctx)
+	if err != nil {
+		return err
+	}
+	b.usageMu.Lock()
+	defer b.usageMu.Unlock()
+	err = b.name.Update(func(txn *storeTxn) error {
+		for id, u := range b.usages {
+			if _, ok := scanned[id]; ok {
+				continue
+			}
+			if err := txn.Delete(usageKey(u)); err != nil {
+				return err
+			}
+		}
+		for id, u := range scanned {
+			if b.usages[id] == u {
+				continue
+			}
+			data, err := json.Marshal(&u)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(usageKey(u), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	b.usages = scanned
+	return nil
+}
+
+// scanUsage calculates the usage of all owners
+// by iterating the meta data of all objects.
+func (b *Bucket) scanUsage(ctx context.Context) (map[usageID]Usage, error) {
+	used := make(map[usageID]Usage)
+	err := b.meta.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			meta := NewMetadata()
+			err := it.Item().Value(func(val []byte) error {
+				return b.unmarshalMeta(meta, val)
+			})
+			if err != nil {
+				return err
+			}
+			for _, c := range b.usageOf(meta).counters() {
+				u, ok := used[c.id()]
+				if !ok {
+					u = Usage{Owner: c.Owner, ContentType: c.ContentType}
+				}
+				used[c.id()] = u.add(c)
+			}
+		}
+		return nil
+	})
+	return used, err
+}
+
+// addUsage adds the deltas to the affected usage counters.
+// Counters without objects are removed.
+func (b *Bucket) addUsage(deltas ...Usage) error {
+	b.usageMu.Lock()
+	defer b.usageMu.Unlock()
+	usages := make(map[usageID]Usage, len(deltas))
+	for _, d := range deltas {
+		for _, c := range d.counters() {
+			u, ok := usages[c.id()]
+			if !ok {
+				u = b.usages[c.id()]
+				u.Owner, u.ContentType = c.Owner, c.ContentType
+			}
+			usages[c.id()] = u.add(c)
+		}
+	}
+	err := b.name.Update(func(txn *storeTxn) error {
+		for _, u := range usages {
+			if u.Objects <= 0 {
+				if err := txn.Delete(usageKey(u)); err != nil {
+					return err
+				}
+				continue
+			}
+			data, err := json.Marshal(&u)
+			if err != nil {
+				return err
+			}
+			if err := txn.Set(usageKey(u), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for id, u := range usages {
+		if u.Objects <= 0 {
+			delete(b.usages, id)
+			continue
+		}
+		b.usages[id] = u
+	}
+	return nil
+}
+
+// usageOf returns the usage of the object with the meta data.
+func (b *Bucket) usageOf(meta *Metadata) Usage {
+	return Usage{
+		Owner:       meta.Get(MetaKeyOwner),
+		ContentType: meta.Get(MetaKeyContentType),
+		Objects:     1,
+		Bytes:       b.sizeOf(meta),
+	}
+}
+
+// addMetaDelta moves the usage of the object from the old to the
+// new meta data if the recorded payload size or the content type
+// changed.
+func (b *Bucket) addMetaDelta(old, meta *Metadata) error {
+	if old.Get(MetaKeySize) == meta.Get(MetaKeySize) && old.Get(MetaKeyContentType) == meta.Get(MetaKeyContentType) {
+		return nil
+	}
+	return b.addUsage(b.usageOf(old).neg(), b.usageOf(meta))
+}
+
+// loadUsage loads the persisted usage counters. The usage of
+// buckets without any counters e.g. created before the usage was
+// recorded is reconciled once.
+func (b *Bucket) loadUsage() error {
+	b.usageMu.Lock()
+	b.usages = make(map[usageID]Usage)
+	err := b.name.View(func(txn *storeTxn) error {
+		it := txn.NewIterator(defaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(usagePrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			var u Usage
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &u)
+			}); err != nil {
+				return err
+			}
+			b.usages[u.id()] = u
+		}
+		return nil
+	})
+	loaded := len(b.usages)
+	b.usageMu.Unlock()
+	if err != nil || loaded > 0 {
+		return err
+	}
+	return b.ReconcileUsage(context.Background())
+}
+
+// runUsageReconciler reconciles the usage every
+// interval until the context is canceled.
+func (b *Bucket) runUsageReconciler(ctx context.Context, interval time.Duration) {
+	defer b.usageWg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := b.ReconcileUsage(ctx); err != nil && ctx.Err() == nil && b.opts.Logger != nil {
+				b.opts.Logger.Warningf("reconciling the usage failed: %v", err)
+			}
+		}
+	}
+}
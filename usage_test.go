@@ -0,0 +1,112 @@
+package objst
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUsage(t *testing.T) {
+	owner := tEnv.owner()
+	want := func(objects, bytes int64) {
+		t.Helper()
+		u := tEnv.b.Usage(owner)
+		if u.Objects != objects || u.Bytes != bytes {
+			t.Fatalf("usage is not %d objects and %d bytes. Got: %+v", objects, bytes, u)
+		}
+	}
+	want(0, 0)
+	o, _ := NewObject(tEnv.name(), owner)
+	o.Write(tEnv.payload(10))
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	want(1, 10)
+	objs := make([]*Object, 0, 2)
+	for i := 0; i < 2; i++ {
+		o, _ := NewObject(tEnv.name(), owner)
+		o.Write(tEnv.payload(5))
+		objs = append(objs, o)
+	}
+	if err := tEnv.b.BatchCreate(objs); err != nil {
+		t.Error(err)
+		return
+	}
+	want(3, 20)
+	if err := tEnv.b.PatchPayload(o.ID(), 10, []byte("12345")); err != nil {
+		t.Error(err)
+		return
+	}
+	want(3, 25)
+	if err := tEnv.b.DeleteByID(objs[0].ID()); err != nil {
+		t.Error(err)
+		return
+	}
+	want(2, 20)
+
+	// drift is corrected by the reconciliation
+	if err := tEnv.b.addUsage(Usage{Owner: owner, Objects: 3, Bytes: 100}); err != nil {
+		t.Error(err)
+		return
+	}
+	want(5, 120)
+	if err := tEnv.b.ReconcileUsage(context.Background()); err != nil {
+		t.Error(err)
+		return
+	}
+	want(2, 20)
+	err := tEnv.b.name.View(func(txn *storeTxn) error {
+		item, err := txn.Get(usageKey(Usage{Owner: owner}))
+		if err != nil {
+			return err
+		}
+		var u Usage
+		if err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &u)
+		}); err != nil {
+			return err
+		}
+		if u.Objects != 2 || u.Bytes != 20 {
+			t.Fatalf("persisted usage is not reconciled. Got: %+v", u)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHTTPUsages(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	opts := DefaultHTTPHandlerOptions()
+	opts.Debug = true
+	ts := httptest.NewServer(NewHTTPHandler(tEnv.b, opts))
+	defer ts.Close()
+	res, err := ts.Client().Get(ts.URL + "/objst/debug/usage")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("statuscode is not %d. Got: %d", http.StatusOK, res.StatusCode)
+	}
+	usages := make([]Usage, 0)
+	if err := json.NewDecoder(res.Body).Decode(&usages); err != nil {
+		t.Error(err)
+		return
+	}
+	for _, u := range usages {
+		if u.Owner == o.Owner() && u.Objects == 1 && u.Bytes == int64(len(o.Payload())) {
+			return
+		}
+	}
+	t.Fatalf("usage of the owner %s is missing. Got: %+v", o.Owner(), usages)
+}
@@ -1,11 +1,27 @@
 package objst
 
 import (
+	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
+// OwnerValidator validates the format of the owner of objects
+// and queries. It returns an error if the owner is invalid which
+// should wrap ErrInvalidOwner. See BucketOptions.OwnerValidator.
+type OwnerValidator func(owner string) error
+
+// ValidateUUIDOwner is the default OwnerValidator
+// which requires the owner to be a UUID.
+func ValidateUUIDOwner(owner string) error {
+	if _, err := uuid.Parse(owner); err != nil {
+		return fmt.Errorf("%w: %s is not a uuid", ErrInvalidOwner, owner)
+	}
+	return nil
+}
+
 func isValidObjectName(name string) bool {
 	ok, _ := regexp.MatchString(objectNamePattern, name)
 	return ok
@@ -18,3 +34,13 @@ func isValidUUID(s string) bool {
 	_, err := uuid.Parse(s)
 	return err == nil
 }
+
+// matchContentType reports whether the content type matches the
+// pattern. A trailing "/*" of the pattern matches all subtypes
+// e.g. "image/*" matches "image/png".
+func matchContentType(pattern, contentType string) bool {
+	if family, ok := strings.CutSuffix(pattern, "/*"); ok {
+		return strings.HasPrefix(contentType, family+"/")
+	}
+	return contentType == pattern
+}
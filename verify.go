@@ -0,0 +1,50 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Verify recalculates the checksum of the payload of the
+// object with the given id and compares it to the stored
+// checksum. If they don't match ErrChecksumMismatch will
+// be returned. If the object was deleted or replaced while
+// it was verified the error wraps ErrObjectNotFound.
+func (b *Bucket) Verify(id string) error {
+	meta, err := b.readMeta(id)
+	if err != nil {
+		return err
+	}
+	pl, err := b.readPayload(meta)
+	if err == nil && checksum(pl) != meta.Get(MetaKeyChecksum) {
+		err = fmt.Errorf("%w: %s", ErrChecksumMismatch, id)
+	}
+	if err != nil {
+		if genErr := b.checkGeneration(meta); genErr != nil {
+			return genErr
+		}
+		return err
+	}
+	return nil
+}
+
+// VerifyAll verifies every object of the bucket and returns
+// the ids of all corrupted objects. An object is considered
+// corrupted if the checksum does not match or the payload
+// cannot be read at all.
+func (b *Bucket) VerifyAll(ctx context.Context) ([]string, error) {
+	corrupted := make([]string, 0)
+	err := b.forEachID(ctx, func(id string) error {
+		err := b.Verify(id)
+		if errors.Is(err, ErrObjectNotFound) {
+			// deleted in the meantime
+			return nil
+		}
+		if err != nil {
+			corrupted = append(corrupted, id)
+		}
+		return nil
+	})
+	return corrupted, err
+}
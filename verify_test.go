@@ -0,0 +1,79 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestVerify(t *testing.T) {
+	o := tEnv.obj()
+	if err := tEnv.b.Create(o); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.Verify(o.ID()); err != nil {
+		t.Fatalf("untouched object should be valid: %v", err)
+	}
+	tampered := payloadEntries(o.ID(), chunkSizeOf(o.meta), tEnv.payload(10))
+	if err := tEnv.b.insertPayload(tampered); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := tEnv.b.Verify(o.ID()); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("tampered object should be corrupted. Got: %v", err)
+	}
+	corrupted, err := tEnv.b.VerifyAll(context.Background())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !slices.Contains(corrupted, o.ID()) {
+		t.Fatalf("corrupted object %s should be reported", o.ID())
+	}
+	if err := tEnv.b.DeleteByID(o.ID()); err != nil {
+		t.Error(err)
+		return
+	}
+}
+
+func TestVerifyAllConcurrentDelete(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	objs := make([]*Object, 0, 200)
+	for i := 0; i < cap(objs); i++ {
+		objs = append(objs, tEnv.obj())
+	}
+	if err := b.BatchCreate(objs); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		for _, obj := range objs {
+			if err := b.DeleteByID(obj.ID()); err != nil {
+				done <- err
+				return
+			}
+		}
+		done <- nil
+	}()
+	corrupted, err := b.VerifyAll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if len(corrupted) != 0 {
+		t.Fatalf("deleted objects should not be reported as corrupted. Got: %v", corrupted)
+	}
+}
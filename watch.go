@@ -0,0 +1,142 @@
+package objst
+
+import (
+	"bytes"
+	"context"
+	"time"
+)
+
+// EventType is the kind of an Event.
+type EventType string
+
+const (
+	// EventCreated means that the object was created.
+	EventCreated EventType = "created"
+	// EventUpdated means that the meta data
+	// or the payload of the object was updated.
+	EventUpdated EventType = "updated"
+	// EventDeleted means that the object was deleted.
+	EventDeleted EventType = "deleted"
+)
+
+// Event is a change of an object received by Watch.
+type Event struct {
+	Type EventType `json:"type"`
+	// Seq is the sequence number of the change in the
+	// change feed. See Changes.
+	Seq uint64 `json:"seq"`
+	ID  string `json:"id"`
+	// Info is the ObjectInfo of the object after the
+	// change or before the deletion for EventDeleted.
+	Info *ObjectInfo `json:"info"`
+	At   time.Time   `json:"at"`
+}
+
+// defaultWatchBufferSize is the default
+// of BucketOptions.WatchBufferSize.
+const defaultWatchBufferSize = 256
+
+// watcher is a running Watch.
+type watcher struct {
+	expr Expr
+	ch   chan Event
+	// done is closed with ch to
+	// stop waiting for the context.
+	done chan struct{}
+}
+
+// Watch emits an event for every change of the objects matching
+// the query until the context is done, which allows applications to
+// react to changes e.g. by invalidating caches without polling. A
+// nil query matches all objects. Only the conditions of the query
+// are used. Updates are matched against the new meta data and
+// deletions against the meta data of the deleted object. The events
+// are emitted in the order of their sequence numbers and are not
+// persisted. The channel is closed when the context is done or the
+// bucket is closed. It is also closed if the events aren't received
+// fast enough and more than WatchBufferSize events are pending. The
+// missed changes can be read using Changes since the sequence
+// number of the last received event.
+func (b *Bucket) Watch(ctx context.Context, q *Query) (<-chan Event, error) {
+	var expr Expr
+	if q != nil {
+		if err := q.isValid(b.opts.OwnerValidator); err != nil {
+			return nil, err
+		}
+		expr = q.expr()
+	}
+	size := b.opts.WatchBufferSize
+	if size <= 0 {
+		size = defaultWatchBufferSize
+	}
+	w := &watcher{expr: expr, ch: make(chan Event, size), done: make(chan struct{})}
+	b.watchMu.Lock()
+	if b.watchStopped {
+		b.watchMu.Unlock()
+		return nil, ErrBucketClosed
+	}
+	if b.watchers == nil {
+		b.watchers = make(map[*watcher]struct{})
+	}
+	b.watchers[w] = struct{}{}
+	b.watchMu.Unlock()
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.unwatch(w)
+		case <-w.done:
+		}
+	}()
+	return w.ch, nil
+}
+
+// unwatch removes the watcher and closes its channel.
+func (b *Bucket) unwatch(w *watcher) {
+	b.watchMu.Lock()
+	defer b.watchMu.Unlock()
+	if _, ok := b.watchers[w]; ok {
+		b.dropWatcher(w)
+	}
+}
+
+// dropWatcher removes the watcher and closes
+// its channel. watchMu must be held.
+func (b *Bucket) dropWatcher(w *watcher) {
+	delete(b.watchers, w)
+	close(w.ch)
+	close(w.done)
+}
+
+// stopWatchers closes the channels of all watchers.
+func (b *Bucket) stopWatchers() {
+	b.watchMu.Lock()
+	defer b.watchMu.Unlock()
+	b.watchStopped = true
+	for w := range b.watchers {
+		b.dropWatcher(w)
+	}
+}
+
+// publish emits the event of the change of the object with the
+// meta data to the matching watchers. It never blocks so watchers
+// which can't receive the event are closed. changeMu must be held
+// so the events are emitted in the order of their sequence numbers.
+// Every watcher receives its own ObjectInfo so it can be modified.
+func (b *Bucket) publish(t EventType, seq uint64, meta *Metadata, at time.Time) {
+	b.watchMu.Lock()
+	defer b.watchMu.Unlock()
+	if len(b.watchers) == 0 {
+		return
+	}
+	obj := &Object{meta: meta, pl: new(bytes.Buffer)}
+	for w := range b.watchers {
+		if w.expr != nil && !meta.Compare(w.expr) {
+			continue
+		}
+		select {
+		case w.ch <- Event{Type: t, Seq: seq, ID: meta.Get(MetaKeyID), Info: obj.Info(), At: at}:
+		default:
+			b.dropWatcher(w)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package objst
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func receiveEvent(t *testing.T, events <-chan Event) (Event, bool) {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		return e, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+	}
+	return Event{}, false
+}
+
+func TestWatch(t *testing.T) {
+	opts := NewDefaultBucketOptions()
+	opts.Logger = nil
+	opts.WatchBufferSize = 3
+	b, err := NewBucket(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(b.BasePath)
+	defer b.Shutdown()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	owner := tEnv.owner()
+	events, err := b.Watch(ctx, NewQuery().Owner(owner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err := b.Watch(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Watch(ctx, NewQuery()); !errors.Is(err, ErrEmptyQuery) {
+		t.Fatalf("invalid queries should be rejected. Got: %v", err)
+	}
+
+	obj, _ := NewObject(tEnv.name(), owner)
+	obj.Write(tEnv.payload(10))
+	if err := b.Create(obj); err != nil {
+		t.Fatal(err)
+	}
+	// objects of other owners are not matching
+	if err := b.Create(tEnv.obj()); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.UpdateMeta(obj.ID(), map[MetaKey]string{"foo": "bar"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.DeleteByID(obj.ID()); err != nil {
+		t.Fatal(err)
+	}
+	var seq uint64
+	for _, want := range []EventType{EventCreated, EventUpdated, EventDeleted} {
+		e, ok := receiveEvent(t, events)
+		if !ok {
+			t.Fatal("channel should be open")
+		}
+		if e.Type != want || e.ID != obj.ID() || e.Info == nil || e.Seq <= seq {
+			t.Fatalf("expected %s of %s after %d. Got: %+v", want, obj.ID(), seq, e)
+		}
+		if want == EventUpdated && e.Info.UserMeta["foo"] != "bar" {
+			t.Fatalf("event should contain the updated info. Got: %+v", e.Info)
+		}
+		// the info isn't shared with the other watchers
+		e.Info.UserMeta["foo"] = "baz"
+		seq = e.Seq
+	}
+
+	// the watcher of all objects received four events with a
+	// buffer of three and is closed because it fell behind.
+	n := 0
+	for {
+		e, ok := receiveEvent(t, all)
+		if !ok {
+			break
+		}
+		if e.Type == EventUpdated && e.Info.UserMeta["foo"] != "bar" {
+			t.Fatalf("info of the event should not be shared. Got: %+v", e.Info)
+		}
+		n++
+	}
+	if n != 3 {
+		t.Fatalf("slow watcher should be closed after %d events. Got: %d", 3, n)
+	}
+
+	cancel()
+	if _, ok := receiveEvent(t, events); ok {
+		t.Fatal("channel should be closed when the context is done")
+	}
+}
@@ -0,0 +1,174 @@
+package objst
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// The wire format is documented by the files in the schema
+// directory which are generated from the Go types by running
+// `go test -run TestWireFormat -update` in the root of the module.
+
+// jsonSchemaDialect is the JSON schema version of the generated schemas.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// CodecVector is a conformance vector of a Codec. Decoding
+// Encoded using the codec must result in Meta and encoding
+// Meta must result in Encoded iff the codec is Deterministic.
+type CodecVector struct {
+	Name  string             `json:"name"`
+	Codec string             `json:"codec"`
+	Meta  map[MetaKey]string `json:"meta"`
+	// Encoded is the hex encoded output of the codec.
+	Encoded string `json:"encoded"`
+	// Deterministic reports whether the encoding of the meta
+	// data is always the same. Gob encodes maps in random order.
+	Deterministic bool `json:"deterministic"`
+}
+
+// ObjectInfoSchema returns the JSON schema of ObjectInfo which is
+// returned by the HTTP endpoints and written next to the payload
+// of archived objects. See LifecycleArchive.
+func ObjectInfoSchema() ([]byte, error) {
+	schema := jsonSchemaOf(reflect.TypeOf(ObjectInfo{}))
+	schema["$schema"] = jsonSchemaDialect
+	schema["title"] = "ObjectInfo"
+	schema["description"] = fmt.Sprintf("JSON representation of an object without its payload in version %d.", ObjectInfoVersion)
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// MetadataSchema returns the JSON schema of the meta data of an
+// object as encoded by CodecJSON. All values are strings and
+// times are formatted using TimeFormat.
+func MetadataSchema() ([]byte, error) {
+	props := make(map[string]any)
+	for _, k := range NewMetadata().systemKeys {
+		prop := map[string]any{"type": "string", "description": "system meta data managed by the bucket"}
+		if slices.Contains(timeKeys, k) {
+			prop["format"] = "date-time"
+		}
+		props[k.String()] = prop
+	}
+	props[MetaKeyContentType.String()] = map[string]any{"type": "string", "description": "media type of the payload"}
+	props[MetaKeyTags.String()] = map[string]any{"type": "string", "description": fmt.Sprintf("list of escaped values separated by %q", valueSeparator)}
+	props[MetaKeyRetentionClass.String()] = map[string]any{"type": "string", "description": "name of the retention class locking the object on creation"}
+	schema := map[string]any{
+		"$schema":              jsonSchemaDialect,
+		"title":                "Metadata",
+		"description":          "Meta data of an object. Keys which are not listed are user defined.",
+		"type":                 "object",
+		"properties":           props,
+		"required":             []string{MetaKeyID.String(), MetaKeyName.String(), MetaKeyOwner.String()},
+		"additionalProperties": map[string]any{"type": "string"},
+	}
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// MetadataProto returns the protobuf schema of the meta data as
+// encoded by CodecProtobuf and of the objects as encoded by
+// Object.MarshalBinary.
+func MetadataProto() string {
+	var sb strings.Builder
+	sb.WriteString("// Code generated by TestWireFormat. DO NOT EDIT.\n\n")
+	sb.WriteString("syntax = \"proto3\";\n\npackage objst;\n\n")
+	sb.WriteString("// Metadata is the meta data of an object. The map entries are\n")
+	sb.WriteString("// encoded in the order of their keys. The system keys are:\n")
+	for _, k := range NewMetadata().systemKeys {
+		sb.WriteString("//   - " + k.String())
+		if slices.Contains(timeKeys, k) {
+			sb.WriteString(" (time formatted as RFC 3339 with nanoseconds in UTC)")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("message Metadata {\n  map<string, string> data = 1;\n}\n\n")
+	sb.WriteString("// Object is an object including its payload.\n")
+	sb.WriteString("message Object {\n  Metadata meta = 1;\n  bytes payload = 2;\n}\n")
+	return sb.String()
+}
+
+// CodecVectors returns the conformance vectors of the built-in codecs.
+func CodecVectors() ([]CodecVector, error) {
+	samples := []struct {
+		name string
+		meta map[MetaKey]string
+	}{
+		{name: "empty", meta: map[MetaKey]string{}},
+		{name: "system", meta: map[MetaKey]string{
+			MetaKeyID:          "9b2a5d0e-3c1f-4e5a-8f7b-6d4c3b2a1f0e",
+			MetaKeyName:        "docs/report.txt",
+			MetaKeyOwner:       "6f1c2d3e-4b5a-4c6d-9e8f-7a6b5c4d3e2f",
+			MetaKeyContentType: "text/plain",
+			MetaKeyCreatedAt:   formatTime(time.Date(2023, 6, 26, 21, 25, 59, 123456789, time.UTC)),
+			MetaKeySize:        "1024",
+		}},
+		{name: "unicode", meta: map[MetaKey]string{"lang": "日本語", "emoji": "☃"}},
+		{name: "long value", meta: map[MetaKey]string{"text": strings.Repeat("objst", 60)}},
+	}
+	vectors := make([]CodecVector, 0)
+	for _, c := range []Codec{CodecGob, CodecJSON, CodecProtobuf, CodecMsgpack} {
+		for _, s := range samples {
+			data, err := c.Marshal(s.meta)
+			if err != nil {
+				return nil, err
+			}
+			vectors = append(vectors, CodecVector{
+				Name:          s.name,
+				Codec:         c.Name(),
+				Meta:          s.meta,
+				Encoded:       hex.EncodeToString(data),
+				Deterministic: c != CodecGob || len(s.meta) < 2,
+			})
+		}
+	}
+	return vectors, nil
+}
+
+// jsonSchemaOf returns the JSON schema of the type t
+// using the JSON tags of the fields of structs.
+func jsonSchemaOf(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Pointer:
+		return jsonSchemaOf(t.Elem())
+	case reflect.Struct:
+		props := make(map[string]any)
+		required := make([]string, 0)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = jsonSchemaOf(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		return map[string]any{"type": "object", "properties": props, "required": required}
+	}
+	return map[string]any{}
+}
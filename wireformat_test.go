@@ -0,0 +1,99 @@
+package objst
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/exp/maps"
+)
+
+var update = flag.Bool("update", false, "update the generated schema files")
+
+func TestWireFormat(t *testing.T) {
+	info, err := ObjectInfoSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := MetadataSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vectors, err := CodecVectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vecs, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"objectinfo.schema.json": append(info, '\n'),
+		"metadata.schema.json":   append(meta, '\n'),
+		"metadata.proto":         []byte(MetadataProto()),
+		"vectors.json":           append(vecs, '\n'),
+	}
+	for name, want := range files {
+		path := filepath.Join("schema", name)
+		if *update {
+			if err := os.WriteFile(path, want, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		got, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the gob vectors are not deterministic
+		if name == "vectors.json" {
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("%s is outdated. Run the tests with -update if the change is intended", path)
+		}
+	}
+}
+
+func TestCodecVectors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("schema", "vectors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []CodecVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	codecs := map[string]Codec{}
+	for _, c := range []Codec{CodecGob, CodecJSON, CodecProtobuf, CodecMsgpack} {
+		codecs[c.Name()] = c
+	}
+	for _, v := range vectors {
+		c := codecs[v.Codec]
+		encoded, err := hex.DecodeString(v.Encoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.Unmarshal(encoded)
+		if err != nil {
+			t.Fatalf("%s/%s: %v", v.Codec, v.Name, err)
+		}
+		if !maps.Equal(got, v.Meta) {
+			t.Fatalf("%s/%s: decoded meta data is not the same. Got: %v", v.Codec, v.Name, got)
+		}
+		if !v.Deterministic {
+			continue
+		}
+		data, err := c.Marshal(v.Meta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, encoded) {
+			t.Fatalf("%s/%s: encoding changed. Got: %x", v.Codec, v.Name, data)
+		}
+	}
+}